@@ -0,0 +1,163 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	recomputeBaseFeeCommand = cli.Command{
+		Action:    utils.MigrateFlags(recomputeBaseFee),
+		Name:      "recompute-basefee",
+		Usage:     "Recompute the base fees of a range of blocks under a different fee market",
+		ArgsUsage: "<blockNumFirst> <blockNumLast>",
+		Flags: append([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.BaseFeeRuleFlag,
+			utils.BaseFeeDenominatorFlag,
+			utils.BaseFeeElasticityFlag,
+		}, utils.DatabasePathFlags...),
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The recompute-basefee command replays the gas usage of the canonical blocks in
+the given range through the base fee update rule selected by --basefee.rule,
+parameterized by --basefee.denominator and --basefee.elasticity. The trajectory
+starts from the actual base fee of the parent of the first block, after which
+every block is priced from the recomputed base fee of its parent. The actual
+and the recomputed base fee of each block is printed, followed by the total fees
+burnt under both rules.
+
+Note, the gas usage of the historical blocks is taken as is, the reaction of the
+users to the different prices is not modelled.`,
+	}
+)
+
+// baseFeeResult is the recomputed base fee of a single block.
+type baseFeeResult struct {
+	number     uint64
+	gasUsed    uint64
+	gasLimit   uint64
+	baseFee    *big.Int // Base fee of the canonical block
+	recomputed *big.Int // Base fee under the alternative fee market
+}
+
+// recomputeBaseFee recomputes the base fee trajectory of a range of blocks.
+func recomputeBaseFee(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Recompute error in parsing parameters: block number not an integer\n")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil {
+		utils.Fatalf("Recompute error: chain config not found\n")
+	}
+	alt := *config
+	alt.FeeMarket = &params.FeeMarketConfig{
+		Rule:                     ctx.String(utils.BaseFeeRuleFlag.Name),
+		BaseFeeChangeDenominator: ctx.Uint64(utils.BaseFeeDenominatorFlag.Name),
+		ElasticityMultiplier:     ctx.Uint64(utils.BaseFeeElasticityFlag.Name),
+	}
+	if config.FeeMarket != nil {
+		alt.FeeMarket.InitialBaseFee = config.FeeMarket.InitialBaseFee
+	}
+	if err := alt.CheckConfigForkOrder(); err != nil {
+		utils.Fatalf("Recompute error: %v\n", err)
+	}
+	results, err := recomputeBaseFees(db, &alt, first, last)
+	if err != nil {
+		utils.Fatalf("Recompute error: %v\n", err)
+	}
+	var (
+		burnt           = new(big.Int)
+		burntRecomputed = new(big.Int)
+	)
+	fmt.Printf("Fee market: %v\n", alt.FeeMarket)
+	fmt.Printf("%-10s %12s %12s %20s %20s\n", "block", "gas used", "gas limit", "base fee", "recomputed")
+	for _, res := range results {
+		fmt.Printf("%-10d %12d %12d %20v %20v\n", res.number, res.gasUsed, res.gasLimit, res.baseFee, res.recomputed)
+
+		gasUsed := new(big.Int).SetUint64(res.gasUsed)
+		if res.baseFee != nil {
+			burnt.Add(burnt, new(big.Int).Mul(gasUsed, res.baseFee))
+		}
+		burntRecomputed.Add(burntRecomputed, gasUsed.Mul(gasUsed, res.recomputed))
+	}
+	fmt.Printf("Fees burnt: %v wei, recomputed: %v wei\n", burnt, burntRecomputed)
+	return nil
+}
+
+// recomputeBaseFees walks the canonical headers in the given range, calculating
+// the base fee of each block from its parent with the gas usage of the canonical
+// chain, but with the base fee of the parent replaced by its recomputed one.
+// Blocks before the London fork of the config are not included in the results.
+func recomputeBaseFees(db ethdb.Reader, config *params.ChainConfig, first, last uint64) ([]*baseFeeResult, error) {
+	if first == 0 {
+		first = 1
+	}
+	if first > last {
+		return nil, fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	parent := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, first-1), first-1)
+	if parent == nil {
+		return nil, fmt.Errorf("header #%d not found", first-1)
+	}
+	var results []*baseFeeResult
+	for number := first; number <= last; number++ {
+		header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number), number)
+		if header == nil {
+			return nil, fmt.Errorf("header #%d not found", number)
+		}
+		if config.IsLondon(header.Number) {
+			res := &baseFeeResult{
+				number:     number,
+				gasUsed:    header.GasUsed,
+				gasLimit:   header.GasLimit,
+				baseFee:    header.BaseFee,
+				recomputed: misc.CalcBaseFee(config, parent),
+			}
+			results = append(results, res)
+
+			// Continue the trajectory from the recomputed base fee
+			header = types.CopyHeader(header)
+			header.BaseFee = res.recomputed
+		}
+		parent = header
+	}
+	return results, nil
+}
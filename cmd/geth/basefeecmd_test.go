@@ -0,0 +1,106 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the base fee trajectory of a chain is recomputed from the gas usage
+// of the canonical blocks under an alternative fee market.
+func TestRecomputeBaseFees(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		config = params.TestChainConfig
+		signer = types.LatestSigner(config)
+		db     = rawdb.NewMemoryDatabase()
+		gspec  = &core.Genesis{
+			Config:   config,
+			Alloc:    core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(params.Ether)}},
+			GasLimit: 100000,
+			BaseFee:  big.NewInt(params.InitialBaseFee),
+		}
+		genesis = gspec.MustCommit(db)
+		engine  = ethash.NewFaker()
+	)
+	// Create a chain with the odd blocks above the gas target, the even ones empty
+	blocks, _ := core.GenerateChain(config, genesis, engine, db, 6, func(i int, b *core.BlockGen) {
+		if i%2 == 1 {
+			return
+		}
+		for j := 0; j < 4; j++ {
+			tx := types.NewTransaction(b.TxNonce(crypto.PubkeyToAddress(key.PublicKey)), common.Address{0xff}, big.NewInt(1), params.TxGas, b.BaseFee(), nil)
+			tx, _ = types.SignTx(tx, signer, key)
+			b.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(db, nil, config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Recomputing with the same fee market reproduces the canonical base fees
+	results, err := recomputeBaseFees(db, config, 2, 6)
+	if err != nil {
+		t.Fatalf("failed to recompute base fees: %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("result count mismatch: have %d, want 5", len(results))
+	}
+	for i, res := range results {
+		if block := blocks[i+1]; res.number != block.NumberU64() || res.gasUsed != block.GasUsed() || res.recomputed.Cmp(block.BaseFee()) != 0 {
+			t.Errorf("block %d: recomputed base fee mismatch: have %v, want %v", res.number, res.recomputed, block.BaseFee())
+		}
+	}
+	// Recomputing with a different fee market diverges from the first block on
+	alt := *config
+	alt.FeeMarket = &params.FeeMarketConfig{Rule: params.BaseFeeRuleExponential}
+
+	results, err = recomputeBaseFees(db, &alt, 2, 6)
+	if err != nil {
+		t.Fatalf("failed to recompute base fees: %v", err)
+	}
+	parent := types.CopyHeader(blocks[0].Header())
+	for i, res := range results {
+		want := misc.CalcBaseFee(&alt, parent)
+		if res.recomputed.Cmp(want) != 0 {
+			t.Errorf("block %d: recomputed base fee mismatch: have %v, want %v", res.number, res.recomputed, want)
+		}
+		if res.recomputed.Cmp(res.baseFee) == 0 {
+			t.Errorf("block %d: base fee unchanged under exponential rule", res.number)
+		}
+		parent = types.CopyHeader(blocks[i+1].Header())
+		parent.BaseFee = res.recomputed
+	}
+	if _, err := recomputeBaseFees(db, config, 5, 7); err == nil {
+		t.Errorf("recomputed base fees beyond the chain head")
+	}
+}
@@ -47,7 +47,7 @@ var (
 		Name:      "init",
 		Usage:     "Bootstrap and initialize a new genesis block",
 		ArgsUsage: "<genesisPath>",
-		Flags:     utils.DatabasePathFlags,
+		Flags:     append([]cli.Flag{utils.StateSchemeFlag}, utils.DatabasePathFlags...),
 		Category:  "BLOCKCHAIN COMMANDS",
 		Description: `
 The init command initializes a new genesis block and definition for the network.
@@ -75,6 +75,7 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.StateSchemeFlag,
 			utils.SnapshotFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
@@ -118,6 +119,25 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	exportReceiptsCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportReceipts),
+		Name:      "export-receipts",
+		Usage:     "Export the receipts of a range of blocks into file",
+		ArgsUsage: "<filename> <blockNumFirst> <blockNumLast>",
+		Flags: append([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.ReceiptsFormatFlag,
+		}, utils.DatabasePathFlags...),
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-receipts command exports the receipts of the canonical blocks in the
+given range, including all derived fields. With --format=jsonl (the default) a
+receipt is written per line as JSON, with --format=rlp a stream of RLP lists
+[number, hash, receipts] holding the consensus encoded receipts of each block is
+written. The file is truncated if already existing. If the file ends with .gz,
+the output will be gzipped.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -196,6 +216,12 @@ func initGenesis(ctx *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Failed to open database: %v", err)
 		}
+		// Light clients retrieve the state on demand, always keyed by hash
+		if name == "chaindata" {
+			if _, err := rawdb.ParseStateScheme(ctx.GlobalString(utils.StateSchemeFlag.Name), chaindb); err != nil {
+				utils.Fatalf("Failed to initialize state scheme: %v", err)
+			}
+		}
 		_, hash, err := core.SetupGenesisBlock(chaindb, genesis)
 		if err != nil {
 			utils.Fatalf("Failed to write genesis block: %v", err)
@@ -335,6 +361,37 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// exportReceipts exports the receipts of a range of blocks into the specified file.
+func exportReceipts(ctx *cli.Context) error {
+	if len(ctx.Args()) < 3 {
+		utils.Fatalf("This command requires three arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil {
+		utils.Fatalf("Export error: chain config not found\n")
+	}
+	if head := rawdb.ReadHeadFastBlockHash(db); head != (common.Hash{}) {
+		if number := rawdb.ReadHeaderNumber(db, head); number != nil && last > *number {
+			utils.Fatalf("Export error: block number %d larger than head block %d\n", last, *number)
+		}
+	}
+	start := time.Now()
+	if err := utils.ExportReceipts(db, config, ctx.Args().First(), first, last, ctx.String(utils.ReceiptsFormatFlag.Name)); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
@@ -181,6 +181,13 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 		}
 	}
 
+	// Drive the chain with the mock beacon client if requested
+	if ctx.GlobalBool(utils.DeveloperBeaconFlag.Name) {
+		if eth == nil {
+			utils.Fatalf("Light clients do not support the dev beacon")
+		}
+		utils.RegisterDevBeaconService(ctx, stack, eth)
+	}
 	// Configure GraphQL if requested
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, backend, cfg.Node)
@@ -0,0 +1,95 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	engineReplayCommand = cli.Command{
+		Action:    utils.MigrateFlags(engineReplay),
+		Name:      "engine-replay",
+		Usage:     "Replay a capture of engine API calls against the local chain",
+		ArgsUsage: "<capture file>",
+		Flags: append([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		}, utils.DatabasePathFlags...),
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The engine-replay command feeds the payload and forkchoice calls logged by a node
+running with --authrpc.capture into a node built from the given datadir, in the
+order they were captured. The datadir is expected to be a snapshot of the chain
+taken when the capture started, and is modified by the replay.
+
+The replay stops at the first call the node responds to with a different payload
+status than the captured one, reporting both. Payload retrievals are replayed,
+but their results are not compared. Networking is disabled during the replay.`,
+	}
+)
+
+// engineReplay replays an engine API capture against a local chain.
+func engineReplay(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	// Keep the network from interfering with the replayed calls
+	ctx.GlobalSet(utils.MaxPeersFlag.Name, "0")
+	ctx.GlobalSet(utils.NoDiscoverFlag.Name, "true")
+	ctx.GlobalSet(utils.ListenPortFlag.Name, "0")
+
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	backend, err := eth.New(stack, &cfg.Eth)
+	if err != nil {
+		utils.Fatalf("Failed to create the Ethereum service: %v", err)
+	}
+	if backend.BlockChain().Config().TerminalTotalDifficulty == nil {
+		utils.Fatalf("Replay error: chain has no terminal total difficulty configured")
+	}
+	if err := stack.Start(); err != nil {
+		utils.Fatalf("Failed to start the node: %v", err)
+	}
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to open capture: %v", err)
+	}
+	defer file.Close()
+
+	start := time.Now()
+	calls, divergence, err := catalyst.Replay(catalyst.NewConsensusAPI(backend), file)
+	if err != nil {
+		utils.Fatalf("Replay error after %d calls: %v", calls, err)
+	}
+	if divergence != nil {
+		fmt.Printf("Divergence at %v\n", divergence)
+	} else {
+		fmt.Printf("No divergence in %d calls\n", calls)
+	}
+	head := backend.BlockChain().CurrentBlock()
+	fmt.Printf("Replay done in %v, head block #%d [%x]\n", time.Since(start), head.NumberU64(), head.Hash())
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	gpoBacktestCommand = cli.Command{
+		Action:    utils.MigrateFlags(gpoBacktest),
+		Name:      "gpo-backtest",
+		Usage:     "Evaluate the gas price oracle against a range of historical blocks",
+		ArgsUsage: "<blockNumFirst> <blockNumLast> [<strategy>:<blocks>:<percentile> ...]",
+		Flags: append([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GpoBlocksFlag,
+			utils.GpoPercentileFlag,
+			utils.GpoMaxGasPriceFlag,
+			utils.GpoIgnoreGasPriceFlag,
+			utils.GpoStrategyFlag,
+			utils.BacktestInclusionFlag,
+		}, utils.DatabasePathFlags...),
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The gpo-backtest command walks the canonical blocks in the given range and asks
+the gas price oracle for a tip suggestion at each of them, seeing only the chain
+up to that block. A suggestion counts as included if a transaction paying it,
+with a fee cap of twice the base fee plus the tip, would have paid at least the
+lowest tip of any of the next --inclusion blocks.
+
+The oracle configurations to compare are given as additional arguments in the
+form <strategy>:<blocks>:<percentile>, where empty fields are taken from the
+--gpo flags, e.g. "percentile:20:60 weighted::60 minimum". Without any, the
+configuration of the --gpo flags is evaluated. For each configuration, the
+ratio of included suggestions, the average inclusion delay and the tips paid
+above the lowest included one are reported.`,
+	}
+)
+
+// chainOracleBackend implements gasprice.OracleBackend on top of a local chain.
+type chainOracleBackend struct {
+	chain *core.BlockChain
+}
+
+func (b *chainOracleBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	if number < 0 {
+		return nil, nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *chainOracleBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	if number < 0 {
+		return nil, nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *chainOracleBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *chainOracleBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
+	return nil, nil
+}
+
+func (b *chainOracleBackend) ChainConfig() *params.ChainConfig {
+	return b.chain.Config()
+}
+
+func (b *chainOracleBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.chain.SubscribeChainHeadEvent(ch)
+}
+
+// parseBacktestConfig parses an oracle configuration in the form of
+// <strategy>:<blocks>:<percentile>, taking empty or missing fields from base.
+func parseBacktestConfig(base gasprice.Config, spec string) (gasprice.Config, error) {
+	config := base
+	fields := strings.Split(spec, ":")
+	if len(fields) > 3 {
+		return config, fmt.Errorf("invalid oracle configuration %q", spec)
+	}
+	if fields[0] != "" {
+		config.Strategy = fields[0]
+	}
+	if len(fields) > 1 && fields[1] != "" {
+		blocks, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return config, fmt.Errorf("invalid block count %q: %v", fields[1], err)
+		}
+		config.Blocks = blocks
+	}
+	if len(fields) > 2 && fields[2] != "" {
+		percentile, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return config, fmt.Errorf("invalid percentile %q: %v", fields[2], err)
+		}
+		config.Percentile = percentile
+	}
+	return config, nil
+}
+
+// gpoBacktest evaluates gas price oracle configurations over a range of blocks.
+func gpoBacktest(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires at least two arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Backtest error in parsing parameters: block number not an integer\n")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	configs := []gasprice.Config{cfg.Eth.GPO}
+	if specs := ctx.Args()[2:]; len(specs) > 0 {
+		configs = configs[:0]
+		for _, spec := range specs {
+			config, err := parseBacktestConfig(cfg.Eth.GPO, spec)
+			if err != nil {
+				utils.Fatalf("Backtest error: %v\n", err)
+			}
+			configs = append(configs, config)
+		}
+	}
+	chain, _ := utils.MakeChain(ctx, stack)
+	defer chain.Stop()
+
+	window := ctx.Uint64(utils.BacktestInclusionFlag.Name)
+	if head := chain.CurrentBlock().NumberU64(); last+window > head {
+		utils.Fatalf("Backtest error: inclusion window of block %d beyond head block %d\n", last, head)
+	}
+	start := time.Now()
+	results, err := gasprice.Backtest(context.Background(), &chainOracleBackend{chain}, configs, first, last, window)
+	if err != nil {
+		utils.Fatalf("Backtest error: %v\n", err)
+	}
+	fmt.Printf("%-24s %8s %10s %8s %16s %16s %16s\n", "configuration", "blocks", "hit rate", "delay", "mean overpaid", "median overpaid", "p90 overpaid")
+	for _, res := range results {
+		strategy := res.Config.Strategy
+		if strategy == "" {
+			strategy = gasprice.StrategyPercentile
+		}
+		name := fmt.Sprintf("%s:%d:%d", strategy, res.Config.Blocks, res.Config.Percentile)
+		fmt.Printf("%-24s %8d %9.2f%% %8.2f %16v %16v %16v\n", name, res.Suggestions, res.HitRate()*100, res.MeanDelay(),
+			res.MeanOverpayment(), res.OverpaymentPercentile(50), res.OverpaymentPercentile(90))
+	}
+	fmt.Printf("Backtest done in %v\n", time.Since(start))
+	return nil
+}
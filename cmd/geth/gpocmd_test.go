@@ -0,0 +1,57 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth/gasprice"
+)
+
+func TestParseBacktestConfig(t *testing.T) {
+	base := gasprice.Config{Blocks: 20, Percentile: 60, Strategy: gasprice.StrategyPercentile}
+
+	tests := []struct {
+		spec string
+		want gasprice.Config
+		fail bool
+	}{
+		{spec: "", want: base},
+		{spec: "weighted", want: gasprice.Config{Blocks: 20, Percentile: 60, Strategy: gasprice.StrategyWeighted}},
+		{spec: "minimum:10", want: gasprice.Config{Blocks: 10, Percentile: 60, Strategy: gasprice.StrategyMinimum}},
+		{spec: "::40", want: gasprice.Config{Blocks: 20, Percentile: 40, Strategy: gasprice.StrategyPercentile}},
+		{spec: "weighted:5:90", want: gasprice.Config{Blocks: 5, Percentile: 90, Strategy: gasprice.StrategyWeighted}},
+		{spec: "weighted:x", fail: true},
+		{spec: "weighted:5:x", fail: true},
+		{spec: "weighted:5:90:1", fail: true},
+	}
+	for _, tt := range tests {
+		have, err := parseBacktestConfig(base, tt.spec)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.spec, err)
+		} else if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("%q: config mismatch: have %+v, want %+v", tt.spec, have, tt.want)
+		}
+	}
+}
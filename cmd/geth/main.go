@@ -83,6 +83,7 @@ var (
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolCaptureFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -93,6 +94,10 @@ var (
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
+		utils.StateSchemeFlag,
+		utils.StatePruningFlag,
+		utils.StatePruningBloomFlag,
+		utils.StateDiffExportFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
 		utils.LightServeFlag,
@@ -116,6 +121,7 @@ var (
 		utils.CacheGCFlag,
 		utils.CacheSnapshotFlag,
 		utils.CacheNoPrefetchFlag,
+		utils.CacheTrieStatsFlag,
 		utils.CachePreimagesFlag,
 		utils.FDLimitFlag,
 		utils.ListenPortFlag,
@@ -131,6 +137,7 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerifyFlag,
+		utils.MinerOrderingFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -141,6 +148,11 @@ var (
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
 		utils.DeveloperGasLimitFlag,
+		utils.DeveloperBeaconFlag,
+		utils.DeveloperBeaconSlotFlag,
+		utils.DeveloperBeaconFinalityFlag,
+		utils.DeveloperBeaconMissRateFlag,
+		utils.DeveloperBeaconReorgRateFlag,
 		utils.VMEnableDebugFlag,
 		utils.NetworkIdFlag,
 		utils.EthStatsURLFlag,
@@ -150,6 +162,7 @@ var (
 		utils.GpoPercentileFlag,
 		utils.GpoMaxGasPriceFlag,
 		utils.GpoIgnoreGasPriceFlag,
+		utils.GpoStrategyFlag,
 		utils.MinerNotifyFullFlag,
 		utils.IgnoreLegacyReceiptsFlag,
 		configFileFlag,
@@ -164,6 +177,7 @@ var (
 		utils.AuthPortFlag,
 		utils.AuthVirtualHostsFlag,
 		utils.JWTSecretFlag,
+		utils.EngineCaptureFlag,
 		utils.HTTPVirtualHostsFlag,
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
@@ -182,6 +196,15 @@ var (
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCLogsLimitFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateLimitBurstFlag,
+		utils.RPCRateLimitIdentityFlag,
+		utils.RPCRateLimitCostsFlag,
+		utils.RPCRateLimitConcurrencyFlag,
+		utils.RPCRecordFlag,
+		utils.RPCRecordMaxSizeFlag,
+		utils.RPCRecordMaxFilesFlag,
 		utils.AllowUnprotectedTxs,
 	}
 
@@ -213,6 +236,12 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		exportReceiptsCommand,
+		mempoolReplayCommand,
+		recomputeBaseFeeCommand,
+		gpoBacktestCommand,
+		engineReplayCommand,
+		verifyHeadersCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		removedbCommand,
@@ -431,7 +460,9 @@ func startNode(ctx *cli.Context, stack *node.Node, backend ethapi.Backend, isCon
 	}
 
 	// Start auxiliary services if enabled
-	if ctx.GlobalBool(utils.MiningEnabledFlag.Name) || ctx.GlobalBool(utils.DeveloperFlag.Name) {
+	// Post-merge developer chains are driven by the dev beacon instead of mining
+	devMining := ctx.GlobalBool(utils.DeveloperFlag.Name) && !ctx.GlobalBool(utils.DeveloperBeaconFlag.Name)
+	if ctx.GlobalBool(utils.MiningEnabledFlag.Name) || devMining {
 		// Mining only makes sense if a full Ethereum node is running
 		if ctx.GlobalString(utils.SyncModeFlag.Name) == "light" {
 			utils.Fatalf("Light clients do not support mining")
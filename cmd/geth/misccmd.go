@@ -45,14 +45,16 @@ var (
 	makecacheCommand = cli.Command{
 		Action:    utils.MigrateFlags(makecache),
 		Name:      "makecache",
-		Usage:     "Generate ethash verification cache (for testing)",
-		ArgsUsage: "<blockNum> <outputDir>",
+		Usage:     "Generate ethash verification caches",
+		ArgsUsage: "<blockNum> [<lastBlockNum>] <outputDir>",
 		Category:  "MISCELLANEOUS COMMANDS",
 		Description: `
 The makecache command generates an ethash cache in <outputDir>.
 
-This command exists to support the system testing project.
-Regular users do not need to execute it.
+If a last block number is given, the caches of all the epochs up to it are
+generated in parallel. Pointing --ethash.cachedir at <outputDir> afterwards
+lets any number of nodes verify the range from the same memory mapped caches,
+without regenerating them.
 `,
 	}
 	makedagCommand = cli.Command{
@@ -105,15 +107,24 @@ and displays information about any security vulnerabilities that affect the curr
 // makecache generates an ethash verification cache into the provided folder.
 func makecache(ctx *cli.Context) error {
 	args := ctx.Args()
-	if len(args) != 2 {
-		utils.Fatalf(`Usage: geth makecache <block number> <outputdir>`)
+	if len(args) != 2 && len(args) != 3 {
+		utils.Fatalf(`Usage: geth makecache <block number> [<last block number>] <outputdir>`)
 	}
 	block, err := strconv.ParseUint(args[0], 0, 64)
 	if err != nil {
 		utils.Fatalf("Invalid block number: %v", err)
 	}
-	ethash.MakeCache(block, args[1])
-
+	if len(args) == 2 {
+		ethash.MakeCache(block, args[1])
+		return nil
+	}
+	last, err := strconv.ParseUint(args[1], 0, 64)
+	if err != nil {
+		utils.Fatalf("Invalid last block number: %v", err)
+	}
+	if err := ethash.MakeCaches(block, last, args[2], runtime.NumCPU()); err != nil {
+		utils.Fatalf("Failed to generate caches: %v", err)
+	}
 	return nil
 }
 
@@ -20,7 +20,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -31,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
@@ -45,6 +49,13 @@ var (
 	emptyCode = crypto.Keccak256(nil)
 )
 
+var (
+	diffAddressesFlag = cli.StringFlag{
+		Name:  "addresses",
+		Usage: "Comma separated list of accounts to restrict the diff to",
+	}
+)
+
 var (
 	snapshotCommand = cli.Command{
 		Name:        "snapshot",
@@ -166,6 +177,27 @@ as the backend data source, making this command a lot faster.
 
 The argument is interpreted as block number or hash. If none is provided, the latest
 block is used.
+`,
+			},
+			{
+				Name:      "diff",
+				Usage:     "Compare the states of two blocks",
+				ArgsUsage: "<blockHash | blockNum> <blockHash | blockNum>",
+				Action:    utils.MigrateFlags(diffState),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: utils.GroupFlags([]cli.Flag{
+					diffAddressesFlag,
+					utils.StateSchemeFlag,
+				}, utils.NetworkFlags, utils.DatabasePathFlags),
+				Description: `
+geth snapshot diff <blockA> <blockB>
+will print the accounts and storage slots which differ between the states of the
+two blocks as JSON, along with their old and new values. The arguments are
+interpreted as block numbers or hashes.
+
+The state tries are walked in lockstep, skipping all the shared subtries. If they
+are not available anymore, the snapshot is used instead, given that it covers
+both states.
 `,
 			},
 		},
@@ -299,7 +331,7 @@ func traverseState(ctx *cli.Context) error {
 			return err
 		}
 		if acc.Root != emptyRoot {
-			storageTrie, err := trie.NewSecure(common.BytesToHash(accIter.Key), acc.Root, triedb)
+			storageTrie, err := trie.NewSecureStorage(root, common.BytesToHash(accIter.Key), acc.Root, triedb)
 			if err != nil {
 				log.Error("Failed to open storage trie", "root", acc.Root, "err", err)
 				return err
@@ -413,7 +445,7 @@ func traverseRawState(ctx *cli.Context) error {
 				return errors.New("invalid account")
 			}
 			if acc.Root != emptyRoot {
-				storageTrie, err := trie.NewSecure(common.BytesToHash(accIter.LeafKey()), acc.Root, triedb)
+				storageTrie, err := trie.NewSecureStorage(root, common.BytesToHash(accIter.LeafKey()), acc.Root, triedb)
 				if err != nil {
 					log.Error("Failed to open storage trie", "root", acc.Root, "err", err)
 					return errors.New("missing storage trie")
@@ -548,6 +580,84 @@ func dumpState(ctx *cli.Context) error {
 	return nil
 }
 
+// diffState compares the states of two blocks and prints the differences.
+func diffState(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return errors.New("need <blockA> <blockB> args")
+	}
+	var addresses []common.Address
+	if list := ctx.String(diffAddressesFlag.Name); list != "" {
+		for _, addr := range strings.Split(list, ",") {
+			if !common.IsHexAddress(addr) {
+				return fmt.Errorf("invalid address: %q", addr)
+			}
+			addresses = append(addresses, common.HexToAddress(addr))
+		}
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	defer chaindb.Close()
+
+	from, err := readHeaderArg(chaindb, ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	to, err := readHeaderArg(chaindb, ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	head := rawdb.ReadHeadHeader(chaindb)
+	if head == nil {
+		return errors.New("no head block found")
+	}
+	scheme, err := rawdb.ParseStateScheme(ctx.GlobalString(utils.StateSchemeFlag.Name), chaindb)
+	if err != nil {
+		return err
+	}
+	statedb := state.NewDatabaseWithConfig(chaindb, &trie.Config{Scheme: scheme})
+	snaptree, err := snapshot.New(chaindb, statedb.TrieDB(), 256, head.Root, false, false, false)
+	if err != nil {
+		log.Warn("Failed to open snapshot tree", "err", err)
+		snaptree = nil
+	}
+	start := time.Now()
+	diff, err := state.DiffStates(statedb, snaptree, from.Root, to.Root, addresses)
+	if err != nil {
+		return err
+	}
+	log.Info("Compared states", "from", from.Number, "to", to.Number, "accounts", len(diff.Accounts),
+		"elapsed", common.PrettyDuration(time.Since(start)))
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diff)
+}
+
+// readHeaderArg retrieves the header of a block given by number or hash.
+func readHeaderArg(db ethdb.Database, arg string) (*types.Header, error) {
+	var header *types.Header
+	if hashish(arg) {
+		hash := common.HexToHash(arg)
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+			header = rawdb.ReadHeader(db, hash, *number)
+		}
+	} else {
+		number, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if hash := rawdb.ReadCanonicalHash(db, number); hash != (common.Hash{}) {
+			header = rawdb.ReadHeader(db, hash, number)
+		}
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %s not found", arg)
+	}
+	return header, nil
+}
+
 // checkAccount iterates the snap data layers, and looks up the given account
 // across all layers.
 func checkAccount(ctx *cli.Context) error {
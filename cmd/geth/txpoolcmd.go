@@ -0,0 +1,300 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	mempoolReplayCommand = cli.Command{
+		Action:    utils.MigrateFlags(mempoolReplay),
+		Name:      "mempool-replay",
+		Usage:     "Replay a transaction pool capture against the historical chain",
+		ArgsUsage: "<capturefile>",
+		Flags: append([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.StateSchemeFlag,
+			utils.ReplaySpeedFlag,
+			utils.MinerOrderingFlag,
+		}, utils.DatabasePathFlags...),
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The mempool-replay command feeds the transactions of a capture journal recorded
+with --txpool.capture into a fresh transaction pool, at the recorded timings
+scaled by --speed. The pool starts from the state of the block the capture was
+started at and follows the canonical chain as the capture advances. Whenever the
+capture moves past a block, the miner builds a block on the same parent from the
+replayed pool, which is compared against the canonical one. The transaction
+ordering of the built blocks is selected by --miner.ordering.
+
+The historical states touched by the replay must be available, i.e. the chain
+usually needs to be synced with --gcmode=archive.`,
+	}
+)
+
+// replayChain is a view of the blockchain with the head pinned to a historical
+// block, which the replayed transaction pool treats as the chain head. The head
+// is read concurrently by the pool's goroutines while the replay advances it.
+type replayChain struct {
+	*core.BlockChain
+	head atomic.Value // *types.Block
+	feed event.Feed
+}
+
+// CurrentBlock returns the pinned head block.
+func (rc *replayChain) CurrentBlock() *types.Block {
+	return rc.head.Load().(*types.Block)
+}
+
+// SubscribeChainHeadEvent returns a subscription which never delivers events,
+// the pool is moved to new heads explicitly during the replay.
+func (rc *replayChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return rc.feed.Subscribe(ch)
+}
+
+// replayBackend serves the replayed transaction pool to the miner.
+type replayBackend struct {
+	chain *core.BlockChain
+	pool  *core.TxPool
+}
+
+func (b *replayBackend) BlockChain() *core.BlockChain { return b.chain }
+func (b *replayBackend) TxPool() *core.TxPool         { return b.pool }
+
+func (b *replayBackend) StateAtBlock(block *types.Block, reexec uint64, base *state.StateDB, checkLive bool, preferDisk bool) (*state.StateDB, error) {
+	return b.chain.StateAt(block.Root())
+}
+
+// replayBlockResult compares a block built from the replayed pool with the
+// canonical block at the same height.
+type replayBlockResult struct {
+	number    uint64
+	txs       int    // Transactions in the canonical block
+	built     int    // Transactions in the locally built block
+	common    int    // Transactions included in both blocks
+	unseen    int    // Canonical transactions never admitted into the pool
+	gasUsed   uint64 // Gas used by the canonical block
+	builtUsed uint64 // Gas used by the locally built block
+}
+
+// mempoolReplayer drives a fresh transaction pool and miner through a capture.
+type mempoolReplayer struct {
+	chain *replayChain
+	pool  *core.TxPool
+	miner *miner.Miner
+	speed float64
+
+	seen     map[common.Hash]struct{} // Transactions admitted into the pool so far
+	first    uint64                   // Capture time of the first replayed entry
+	start    time.Time                // Wall time the replay started at
+	added    int
+	dropped  int
+	rejected int // Added transactions rejected by the replayed pool
+	blocks   []*replayBlockResult
+
+	report func(*replayBlockResult)
+}
+
+func newMempoolReplayer(chain *core.BlockChain, base *types.Block, ordering string, speed float64) (*mempoolReplayer, error) {
+	if _, err := chain.StateAt(base.Root()); err != nil {
+		return nil, fmt.Errorf("state of block %d unavailable: %v", base.NumberU64(), err)
+	}
+	rc := &replayChain{BlockChain: chain}
+	rc.head.Store(base)
+
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(config, chain.Config(), rc)
+
+	minerConfig := ethconfig.Defaults.Miner
+	minerConfig.Ordering = ordering
+	backend := &replayBackend{chain: chain, pool: pool}
+	return &mempoolReplayer{
+		chain:  rc,
+		pool:   pool,
+		miner:  miner.New(backend, &minerConfig, chain.Config(), new(event.TypeMux), chain.Engine(), nil),
+		speed:  speed,
+		seen:   make(map[common.Hash]struct{}),
+		report: func(*replayBlockResult) {},
+	}, nil
+}
+
+// replay processes a single capture entry, building and comparing the blocks
+// the capture moved past since the previous entry.
+func (r *mempoolReplayer) replay(entry *core.TxCaptureEntry) error {
+	// Wait until the entry is due according to the recorded timings
+	if r.start.IsZero() {
+		r.first, r.start = entry.Time, time.Now()
+	}
+	if r.speed > 0 && entry.Time > r.first {
+		due := r.start.Add(time.Duration(float64(entry.Time-r.first) / r.speed))
+		if wait := time.Until(due); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	for r.chain.CurrentBlock().NumberU64() < entry.Head {
+		if err := r.advance(); err != nil {
+			return err
+		}
+	}
+	switch entry.Event {
+	case core.TxCaptureAdded:
+		tx, err := entry.Transaction()
+		if err != nil {
+			return fmt.Errorf("invalid captured transaction %x: %v", entry.Hash, err)
+		}
+		r.added++
+
+		var errs []error
+		if entry.Source == core.TxSourceLocal {
+			errs = r.pool.AddLocals([]*types.Transaction{tx})
+		} else {
+			errs = r.pool.AddRemotesSync([]*types.Transaction{tx})
+		}
+		if errs[0] != nil {
+			log.Debug("Replayed transaction rejected", "hash", tx.Hash(), "err", errs[0])
+			r.rejected++
+			return nil
+		}
+		r.seen[tx.Hash()] = struct{}{}
+	case core.TxCaptureDropped:
+		// Drops are decided by the replayed pool itself
+		r.dropped++
+	}
+	return nil
+}
+
+// advance builds a block on the current head from the replayed pool, compares
+// it against the next canonical block and moves the pool onto the latter.
+func (r *mempoolReplayer) advance() error {
+	head := r.chain.CurrentBlock()
+	next := r.chain.GetBlockByNumber(head.NumberU64() + 1)
+	if next == nil {
+		return fmt.Errorf("canonical block %d not found", head.NumberU64()+1)
+	}
+	r.miner.SetGasCeil(next.GasLimit())
+	built, err := r.miner.GetSealingBlockSync(head.Hash(), next.Time(), next.Coinbase(), next.MixDigest(), false)
+	if err != nil {
+		return fmt.Errorf("failed to build block %d: %v", next.NumberU64(), err)
+	}
+	included := make(map[common.Hash]struct{})
+	for _, tx := range built.Transactions() {
+		included[tx.Hash()] = struct{}{}
+	}
+	res := &replayBlockResult{
+		number:    next.NumberU64(),
+		txs:       len(next.Transactions()),
+		built:     len(built.Transactions()),
+		gasUsed:   next.GasUsed(),
+		builtUsed: built.GasUsed(),
+	}
+	for _, tx := range next.Transactions() {
+		if _, ok := included[tx.Hash()]; ok {
+			res.common++
+		}
+		if _, ok := r.seen[tx.Hash()]; !ok {
+			res.unseen++
+		}
+	}
+	r.blocks = append(r.blocks, res)
+	r.report(res)
+
+	r.chain.head.Store(next)
+	r.pool.ResetHead(head.Header(), next.Header())
+	return nil
+}
+
+func (r *mempoolReplayer) close() {
+	r.miner.Close()
+	r.pool.Stop()
+}
+
+func mempoolReplay(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack)
+	defer chain.Stop()
+
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to open capture: %v", err)
+	}
+	defer file.Close()
+
+	var (
+		replayer *mempoolReplayer
+		start    = time.Now()
+	)
+	err = core.ReadTxCapture(file, func(entry *core.TxCaptureEntry) error {
+		if replayer == nil {
+			base := chain.GetBlockByNumber(entry.Head)
+			if base == nil {
+				return fmt.Errorf("capture base block %d not found", entry.Head)
+			}
+			var err error
+			if replayer, err = newMempoolReplayer(chain, base, ctx.String(utils.MinerOrderingFlag.Name), ctx.Float64(utils.ReplaySpeedFlag.Name)); err != nil {
+				return err
+			}
+			replayer.report = func(res *replayBlockResult) {
+				log.Info("Replayed block", "number", res.number, "txs", res.txs, "built", res.built,
+					"common", res.common, "unseen", res.unseen, "gas", res.gasUsed, "builtgas", res.builtUsed)
+			}
+			log.Info("Replaying transaction pool capture", "base", base.NumberU64(), "hash", base.Hash())
+		}
+		return replayer.replay(entry)
+	})
+	if replayer != nil {
+		defer replayer.close()
+	}
+	if err != nil {
+		utils.Fatalf("Replay error: %v", err)
+	}
+	if replayer == nil {
+		utils.Fatalf("Replay error: empty capture")
+	}
+	var txs, included, unseen int
+	for _, res := range replayer.blocks {
+		txs += res.txs
+		included += res.common
+		unseen += res.unseen
+	}
+	fmt.Printf("Replayed %d added (%d rejected) and %d dropped transactions over %d blocks in %v\n",
+		replayer.added, replayer.rejected, replayer.dropped, len(replayer.blocks), time.Since(start))
+	fmt.Printf("Canonical transactions: %d, built as well: %d, never seen: %d\n", txs, included, unseen)
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/miner"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that a captured transaction pool history is replayed block by block,
+// comparing the locally built blocks with the canonical ones.
+func TestMempoolReplay(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		config  = params.TestChainConfig
+		signer  = types.LatestSigner(config)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{
+			Config: config,
+			Alloc: core.GenesisAlloc{
+				crypto.PubkeyToAddress(key1.PublicKey): {Balance: big.NewInt(params.Ether)},
+				crypto.PubkeyToAddress(key2.PublicKey): {Balance: big.NewInt(params.Ether)},
+			},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		genesis = gspec.MustCommit(db)
+		engine  = ethash.NewFaker()
+	)
+	transfer := func(key *ecdsa.PrivateKey, nonce uint64) *types.Transaction {
+		tx := types.NewTransaction(nonce, common.Address{0xff}, big.NewInt(1), params.TxGas, big.NewInt(10*params.InitialBaseFee), nil)
+		tx, _ = types.SignTx(tx, signer, key)
+		return tx
+	}
+	// Create a chain of two blocks, the second one including a transaction which
+	// never made it into the captured pool
+	var (
+		tx0 = transfer(key1, 0)
+		tx1 = transfer(key1, 1)
+		tx2 = transfer(key1, 2)
+		tx3 = transfer(key2, 0)
+	)
+	blocks, _ := core.GenerateChain(config, genesis, engine, db, 2, func(i int, b *core.BlockGen) {
+		switch i {
+		case 0:
+			b.AddTx(tx0)
+			b.AddTx(tx1)
+		case 1:
+			b.AddTx(tx2)
+			b.AddTx(tx3)
+		}
+	})
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieDirtyDisabled: true}, config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	added := func(head uint64, tx *types.Transaction, source string) *core.TxCaptureEntry {
+		blob, _ := tx.MarshalBinary()
+		return &core.TxCaptureEntry{Head: head, Event: core.TxCaptureAdded, Hash: tx.Hash(), Tx: blob, Source: source}
+	}
+	entries := []*core.TxCaptureEntry{
+		added(0, tx0, core.TxSourceLocal),
+		added(0, tx1, "peer"),
+		{Head: 1, Event: core.TxCaptureDropped, Hash: tx0.Hash(), Reason: core.TxDropStale},
+		added(1, tx2, "peer"),
+		{Head: 2, Event: core.TxCaptureDropped, Hash: tx2.Hash(), Reason: core.TxDropStale},
+	}
+	replayer, err := newMempoolReplayer(chain, chain.GetBlockByNumber(0), miner.OrderingPriceAndNonce, 0)
+	if err != nil {
+		t.Fatalf("failed to create replayer: %v", err)
+	}
+	defer replayer.close()
+
+	for i, entry := range entries {
+		if err := replayer.replay(entry); err != nil {
+			t.Fatalf("failed to replay entry %d: %v", i, err)
+		}
+	}
+	if replayer.added != 3 || replayer.dropped != 2 {
+		t.Errorf("wrong entry counts: have %d added %d dropped, want 3 added 2 dropped", replayer.added, replayer.dropped)
+	}
+	want := []*replayBlockResult{
+		{number: 1, txs: 2, built: 2, common: 2, gasUsed: 2 * params.TxGas, builtUsed: 2 * params.TxGas},
+		{number: 2, txs: 2, built: 1, common: 1, unseen: 1, gasUsed: 2 * params.TxGas, builtUsed: params.TxGas},
+	}
+	if !reflect.DeepEqual(replayer.blocks, want) {
+		for i, res := range replayer.blocks {
+			t.Errorf("block %d: %+v", i, res)
+		}
+		t.Fatalf("replay results mismatch")
+	}
+}
@@ -42,6 +42,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.SyncModeFlag,
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
+			utils.StateSchemeFlag,
+			utils.StatePruningFlag,
+			utils.StatePruningBloomFlag,
+			utils.StateDiffExportFlag,
 			utils.TxLookupLimitFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
@@ -69,6 +73,11 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.DeveloperFlag,
 			utils.DeveloperPeriodFlag,
 			utils.DeveloperGasLimitFlag,
+			utils.DeveloperBeaconFlag,
+			utils.DeveloperBeaconSlotFlag,
+			utils.DeveloperBeaconFinalityFlag,
+			utils.DeveloperBeaconMissRateFlag,
+			utils.DeveloperBeaconReorgRateFlag,
 		},
 	},
 	{
@@ -91,6 +100,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
+			utils.TxPoolCaptureFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
 			utils.TxPoolAccountSlotsFlag,
@@ -111,6 +121,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.CacheGCFlag,
 			utils.CacheSnapshotFlag,
 			utils.CacheNoPrefetchFlag,
+			utils.CacheTrieStatsFlag,
 			utils.CachePreimagesFlag,
 			utils.FDLimitFlag,
 		},
@@ -146,12 +157,22 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.AuthListenFlag,
 			utils.AuthPortFlag,
 			utils.AuthVirtualHostsFlag,
+			utils.EngineCaptureFlag,
 			utils.GraphQLEnabledFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
 			utils.RPCGlobalGasCapFlag,
 			utils.RPCGlobalEVMTimeoutFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCLogsLimitFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateLimitBurstFlag,
+			utils.RPCRateLimitIdentityFlag,
+			utils.RPCRateLimitCostsFlag,
+			utils.RPCRateLimitConcurrencyFlag,
+			utils.RPCRecordFlag,
+			utils.RPCRecordMaxSizeFlag,
+			utils.RPCRecordMaxFilesFlag,
 			utils.AllowUnprotectedTxs,
 			utils.JSpathFlag,
 			utils.ExecFlag,
@@ -187,6 +208,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerifyFlag,
+			utils.MinerOrderingFlag,
 		},
 	},
 	{
@@ -196,6 +218,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.GpoPercentileFlag,
 			utils.GpoMaxGasPriceFlag,
 			utils.GpoIgnoreGasPriceFlag,
+			utils.GpoStrategyFlag,
 		},
 	},
 	{
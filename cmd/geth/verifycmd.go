@@ -0,0 +1,212 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// verifyHeadersBatch is the number of headers handed to the consensus engine
+// for verification at once.
+const verifyHeadersBatch = 2048
+
+var (
+	verifyHeadersCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyHeaders),
+		Name:      "verify-headers",
+		Usage:     "Verify the stored canonical headers without executing the blocks",
+		ArgsUsage: "",
+		Flags: append([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.VerifyFromFlag,
+			utils.VerifyToFlag,
+			utils.VerifyCachesFlag,
+		}, utils.DatabasePathFlags...),
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify-headers command walks the canonical headers between --from and --to
+through the header verification of the configured consensus engine, checking
+the proof-of-work seals, clique signatures and merge transition rules. It also
+checks that every header hashes to its entry in the canonical hash index (the
+freezer hashes table for ancient blocks), that it links to the canonical parent
+and that the stored total difficulty accumulates correctly.
+
+Every problem found is reported, the command fails if there were any. No
+transactions are executed. With --ethash.pregen, the ethash caches of all the
+epochs in the range are generated on disk in parallel first.`,
+	}
+)
+
+// headerProblem is an inconsistency found in a stored canonical header.
+type headerProblem struct {
+	number uint64
+	hash   common.Hash
+	err    error
+}
+
+// verifyHeaders verifies a range of stored canonical headers.
+func verifyHeaders(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	// Open the database read-only, the verification must not repair or rewind
+	// the chain it is checking
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil {
+		utils.Fatalf("Chain config not found")
+	}
+	// Clique persists its vote snapshots, keep them out of the read-only database
+	ethashConf := ethconfig.Defaults.Ethash
+	engine := ethconfig.CreateConsensusEngine(stack, config, &ethashConf, nil, false, rawdb.NewMemoryDatabase())
+	defer engine.Close()
+
+	chain, err := core.NewHeaderChain(db, config, engine, func() bool { return false })
+	if err != nil {
+		utils.Fatalf("Failed to open header chain: %v", err)
+	}
+	from, to := ctx.Uint64(utils.VerifyFromFlag.Name), uint64(0)
+	if head := rawdb.ReadHeadHeader(db); head != nil {
+		to = head.Number.Uint64()
+	}
+	if ctx.IsSet(utils.VerifyToFlag.Name) {
+		to = ctx.Uint64(utils.VerifyToFlag.Name)
+	}
+	if ctx.Bool(utils.VerifyCachesFlag.Name) {
+		inner := engine
+		if b, ok := inner.(*beacon.Beacon); ok {
+			inner = b.InnerEngine()
+		}
+		if pow, ok := inner.(*ethash.Ethash); ok {
+			start := time.Now()
+			if err := pow.GenerateCaches(from, to); err != nil {
+				utils.Fatalf("Failed to generate ethash caches: %v", err)
+			}
+			log.Info("Generated ethash caches", "elapsed", common.PrettyDuration(time.Since(start)))
+		}
+	}
+	start := time.Now()
+	problems, err := verifyStoredHeaders(chain, engine, db, from, to)
+	for _, problem := range problems {
+		fmt.Printf("Header #%d [%x]: %v\n", problem.number, problem.hash, problem.err)
+	}
+	if err != nil {
+		utils.Fatalf("Verification aborted: %v", err)
+	}
+	fmt.Printf("Verified headers #%d-#%d in %v, %d problems found\n", from, to, common.PrettyDuration(time.Since(start)), len(problems))
+	if len(problems) > 0 {
+		return errors.New("header verification failed")
+	}
+	return nil
+}
+
+// verifyStoredHeaders walks the canonical headers in the given range, checking
+// their hash linkage and total difficulty, and verifying them in batches with
+// the consensus engine. An error is returned if the walk could not complete,
+// along with the problems found until then.
+func verifyStoredHeaders(chain consensus.ChainHeaderReader, engine consensus.Engine, db ethdb.Reader, from, to uint64) ([]*headerProblem, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	var (
+		problems []*headerProblem
+		parent   common.Hash
+		parentTd = new(big.Int)
+		logged   = time.Now()
+	)
+	if from > 0 {
+		parent = rawdb.ReadCanonicalHash(db, from-1)
+		if parentTd = rawdb.ReadTd(db, parent, from-1); parentTd == nil {
+			return nil, fmt.Errorf("total difficulty of parent header #%d [%x] not found", from-1, parent)
+		}
+	}
+	for number := from; number <= to; {
+		// Collect the next batch of headers, checking their linkage along the way
+		var headers []*types.Header
+		for ; number <= to && len(headers) < verifyHeadersBatch; number++ {
+			hash := rawdb.ReadCanonicalHash(db, number)
+			if hash == (common.Hash{}) {
+				return problems, fmt.Errorf("canonical hash #%d not found", number)
+			}
+			header := rawdb.ReadHeader(db, hash, number)
+			if header == nil {
+				return problems, fmt.Errorf("header #%d [%x] not found", number, hash)
+			}
+			if have := header.Hash(); have != hash {
+				problems = append(problems, &headerProblem{number, hash, fmt.Errorf("header hashes to %x", have)})
+			}
+			if number > 0 && header.ParentHash != parent {
+				problems = append(problems, &headerProblem{number, hash, fmt.Errorf("parent hash mismatch: have %x, want %x", header.ParentHash, parent)})
+			}
+			want := new(big.Int).Add(parentTd, header.Difficulty)
+			td := rawdb.ReadTd(db, hash, number)
+			switch {
+			case td == nil:
+				problems = append(problems, &headerProblem{number, hash, errors.New("total difficulty not found")})
+				td = want
+			case td.Cmp(want) != 0:
+				problems = append(problems, &headerProblem{number, hash, fmt.Errorf("total difficulty mismatch: have %v, want %v", td, want)})
+			}
+			parent, parentTd = hash, td
+
+			// The genesis header is not verified by the engine
+			if number > 0 {
+				headers = append(headers, header)
+			}
+		}
+		if len(headers) == 0 {
+			continue
+		}
+		// Verify the batch of headers with the consensus engine
+		seals := make([]bool, len(headers))
+		for i := range seals {
+			seals[i] = true
+		}
+		abort, results := engine.VerifyHeaders(chain, headers, seals)
+		for _, header := range headers {
+			if err := <-results; err != nil {
+				problems = append(problems, &headerProblem{header.Number.Uint64(), header.Hash(), err})
+			}
+		}
+		close(abort)
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying headers", "number", number-1, "problems", len(problems))
+			logged = time.Now()
+		}
+	}
+	return problems, nil
+}
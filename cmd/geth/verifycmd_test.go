@@ -0,0 +1,83 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the stored headers are walked through the consensus engine and
+// checked for total difficulty consistency, reporting every problem found.
+func TestVerifyStoredHeaders(t *testing.T) {
+	var (
+		config  = params.TestChainConfig
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: config, BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(config, genesis, ethash.NewFaker(), db, 8, nil)
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Verify through a header chain, like the command does
+	hc, err := core.NewHeaderChain(db, config, ethash.NewFaker(), func() bool { return false })
+	if err != nil {
+		t.Fatalf("failed to create header chain: %v", err)
+	}
+	// A consistent chain should have no problems
+	problems, err := verifyStoredHeaders(hc, ethash.NewFaker(), db, 0, 8)
+	if err != nil {
+		t.Fatalf("failed to verify headers: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems: %v", problems[0].err)
+	}
+	// Fail the seal of one header and corrupt the total difficulty of another,
+	// which is inconsistent with both its parent and its child
+	rawdb.WriteTd(db, blocks[4].Hash(), 5, big.NewInt(1))
+
+	problems, err = verifyStoredHeaders(hc, ethash.NewFakeFailer(3), db, 2, 8)
+	if err != nil {
+		t.Fatalf("failed to verify headers: %v", err)
+	}
+	have := make(map[uint64]bool)
+	for _, problem := range problems {
+		have[problem.number] = true
+	}
+	if len(problems) != 3 || !have[3] || !have[5] || !have[6] {
+		for _, problem := range problems {
+			t.Errorf("header #%d: %v", problem.number, problem.err)
+		}
+		t.Fatalf("problem count mismatch: have %d, want 3", len(problems))
+	}
+	// Verifying beyond the stored headers should abort
+	if _, err := verifyStoredHeaders(hc, ethash.NewFaker(), db, 7, 9); err == nil {
+		t.Fatalf("verification beyond the head succeeded")
+	}
+}
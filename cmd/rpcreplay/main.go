@@ -0,0 +1,139 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// rpcreplay replays the requests recorded by a node started with --rpc.record
+// against an RPC endpoint, comparing the responses and latencies.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	endpointFlag = cli.StringFlag{
+		Name:  "endpoint",
+		Usage: "RPC endpoint to replay the requests against (http, ws or ipc)",
+		Value: "http://127.0.0.1:8545",
+	}
+	speedFlag = cli.Float64Flag{
+		Name:  "speed",
+		Usage: "replay speed relative to the recording (1 = original pace, 0 = as fast as possible)",
+		Value: 1,
+	}
+	parallelFlag = cli.IntFlag{
+		Name:  "parallel",
+		Usage: "maximum number of requests in flight at once",
+		Value: 64,
+	}
+	verboseFlag = cli.BoolFlag{
+		Name:  "verbose",
+		Usage: "print every mismatching or failing request",
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "an RPC traffic replay tool")
+	app.ArgsUsage = "<logfile or directory> [<logfile or directory>...]"
+	app.Flags = []cli.Flag{
+		endpointFlag,
+		speedFlag,
+		parallelFlag,
+		verboseFlag,
+	}
+	app.Action = replay
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// replay is the entry point of the tool, replaying the given request logs and
+// printing the comparison report.
+func replay(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return errors.New("no request log specified")
+	}
+	speed := ctx.Float64(speedFlag.Name)
+	if speed < 0 {
+		return errors.New("negative replay speed")
+	}
+	parallel := ctx.Int(parallelFlag.Name)
+	if parallel <= 0 {
+		return errors.New("parallelism must be positive")
+	}
+	var files []string
+	for _, arg := range ctx.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		logs, err := rpc.RecordFiles(arg)
+		if err != nil {
+			return err
+		}
+		files = append(files, logs...)
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	go func() {
+		select {
+		case <-sigc:
+			fmt.Fprintln(os.Stderr, "Interrupted, waiting for in-flight requests")
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	r := newReplayer(ctx.String(endpointFlag.Name), speed, parallel)
+	if ctx.Bool(verboseFlag.Name) {
+		r.report = func(res *replayResult) {
+			if res.status != statusMatch {
+				fmt.Println(res)
+			}
+		}
+	}
+	start := time.Now()
+	err := r.run(runCtx, files)
+	r.stats.print(os.Stdout, time.Since(start))
+	return err
+}
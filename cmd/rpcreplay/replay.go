@@ -0,0 +1,396 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// replayStatus is the outcome of replaying a single request.
+type replayStatus int
+
+const (
+	statusMatch    replayStatus = iota // Response identical to the recorded one
+	statusMismatch                     // Response differs from the recorded one
+	statusFailed                       // Request could not be delivered
+	statusSkipped                      // Request cannot be replayed
+)
+
+func (s replayStatus) String() string {
+	switch s {
+	case statusMatch:
+		return "match"
+	case statusMismatch:
+		return "mismatch"
+	case statusFailed:
+		return "failed"
+	default:
+		return "skipped"
+	}
+}
+
+// replayResult is the outcome of replaying a single recorded request.
+type replayResult struct {
+	method   string
+	conn     uint64
+	time     time.Time     // Time the request was originally received
+	status   replayStatus  //
+	recorded time.Duration // Time it took to serve the request originally
+	replayed time.Duration // Time it took to serve the request when replayed
+	detail   string        // Reason of a mismatch, failure or skip
+}
+
+func (r *replayResult) String() string {
+	return fmt.Sprintf("%s %s conn=%d time=%s: %s", r.status, r.method, r.conn, r.time.Format(time.RFC3339Nano), r.detail)
+}
+
+// recordedRequest and recordedResponse are the parts of the recorded messages
+// needed to replay a request and compare its outcome.
+type recordedRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type recordedResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// replayer sends recorded requests to an endpoint, reusing one connection for
+// all the requests originally made over the same connection.
+type replayer struct {
+	endpoint string
+	speed    float64                // Replay speed relative to the recording, 0 for no delays
+	slots    chan struct{}          // Limits the number of requests in flight
+	report   func(*replayResult)    // Optional callback invoked with every result
+	stats    *replayStats           //
+	pending  sync.WaitGroup         // Requests in flight
+	origin   time.Time              // Time of the first recorded request
+	start    time.Time              // Time the first request was replayed
+	lock     sync.Mutex             // Protects the clients
+	clients  map[uint64]*rpc.Client // Connections to the endpoint by recorded connection
+}
+
+func newReplayer(endpoint string, speed float64, parallel int) *replayer {
+	return &replayer{
+		endpoint: endpoint,
+		speed:    speed,
+		slots:    make(chan struct{}, parallel),
+		stats:    newReplayStats(),
+		clients:  make(map[uint64]*rpc.Client),
+	}
+}
+
+// run replays the requests of the given log files in order, waiting for all of
+// them to complete. Replaying stops early if the context is canceled.
+func (r *replayer) run(ctx context.Context, files []string) error {
+	defer r.close()
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = rpc.ReadRecords(f, func(entry *rpc.RecordEntry) error {
+			return r.schedule(ctx, entry)
+		})
+		f.Close()
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// schedule waits until the request is due, then sends it in the background.
+func (r *replayer) schedule(ctx context.Context, entry *rpc.RecordEntry) error {
+	if r.start.IsZero() {
+		r.origin, r.start = entry.Time, time.Now()
+	}
+	if r.speed > 0 {
+		offset := time.Duration(float64(entry.Time.Sub(r.origin)) / r.speed)
+		if wait := time.Until(r.start.Add(offset)); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	r.pending.Add(1)
+	go func() {
+		defer func() {
+			<-r.slots
+			r.pending.Done()
+		}()
+		res := r.replay(ctx, entry)
+		r.stats.add(res)
+		if r.report != nil {
+			r.report(res)
+		}
+	}()
+	return nil
+}
+
+// replay sends a single recorded request and compares its response.
+func (r *replayer) replay(ctx context.Context, entry *rpc.RecordEntry) *replayResult {
+	res := &replayResult{conn: entry.Conn, time: entry.Time, recorded: entry.Elapsed, status: statusSkipped}
+
+	var req recordedRequest
+	if err := json.Unmarshal(entry.Request, &req); err != nil {
+		res.detail = fmt.Sprintf("invalid request: %v", err)
+		return res
+	}
+	res.method = req.Method
+	if strings.HasSuffix(req.Method, "_subscribe") || strings.HasSuffix(req.Method, "_unsubscribe") {
+		res.detail = "subscriptions are not replayed"
+		return res
+	}
+	var params []json.RawMessage
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			res.detail = "only positional parameters are replayed"
+			return res
+		}
+	}
+	var resp recordedResponse
+	if err := json.Unmarshal(entry.Response, &resp); err != nil {
+		res.detail = fmt.Sprintf("invalid response: %v", err)
+		return res
+	}
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	client, err := r.client(ctx, entry.Conn)
+	if err != nil {
+		res.status, res.detail = statusFailed, err.Error()
+		return res
+	}
+	var (
+		result json.RawMessage
+		start  = time.Now()
+	)
+	err = client.CallContext(ctx, &result, req.Method, args...)
+	res.replayed = time.Since(start)
+	res.status, res.detail = compareResponse(&resp, result, err)
+	return res
+}
+
+// compareResponse checks the outcome of a replayed request against the recorded
+// response.
+func compareResponse(recorded *recordedResponse, result json.RawMessage, err error) (replayStatus, string) {
+	if err != nil {
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) {
+			return statusFailed, err.Error()
+		}
+		if recorded.Error == nil {
+			return statusMismatch, fmt.Sprintf("unexpected error %d %q", rpcErr.ErrorCode(), err.Error())
+		}
+		if recorded.Error.Code != rpcErr.ErrorCode() || recorded.Error.Message != err.Error() {
+			return statusMismatch, fmt.Sprintf("error %d %q, recorded %d %q", rpcErr.ErrorCode(), err.Error(), recorded.Error.Code, recorded.Error.Message)
+		}
+		return statusMatch, ""
+	}
+	if recorded.Error != nil {
+		return statusMismatch, fmt.Sprintf("missing error %d %q", recorded.Error.Code, recorded.Error.Message)
+	}
+	if !jsonEqual(recorded.Result, result) {
+		return statusMismatch, fmt.Sprintf("result %s, recorded %s", result, recorded.Result)
+	}
+	return statusMatch, ""
+}
+
+// jsonEqual reports whether two JSON values are semantically equal, ignoring
+// the formatting and the order of object keys.
+func jsonEqual(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// client returns the connection replaying the requests of a recorded connection,
+// dialing it if needed.
+func (r *replayer) client(ctx context.Context, conn uint64) (*rpc.Client, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if client := r.clients[conn]; client != nil {
+		return client, nil
+	}
+	client, err := rpc.DialContext(ctx, r.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	r.clients[conn] = client
+	return client, nil
+}
+
+// close waits for the requests in flight and closes all the connections.
+func (r *replayer) close() {
+	r.pending.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, client := range r.clients {
+		client.Close()
+	}
+	r.clients = make(map[uint64]*rpc.Client)
+}
+
+// replayStats aggregates the results of the replayed requests.
+type replayStats struct {
+	lock    sync.Mutex
+	total   *methodStats
+	methods map[string]*methodStats
+}
+
+// methodStats are the aggregated results of the requests to a single method.
+type methodStats struct {
+	counts   [statusSkipped + 1]int
+	recorded []time.Duration // Original latencies of the replayed requests
+	replayed []time.Duration // Latencies of the replayed requests
+}
+
+func newReplayStats() *replayStats {
+	return &replayStats{
+		total:   new(methodStats),
+		methods: make(map[string]*methodStats),
+	}
+}
+
+// add accounts a replay result.
+func (s *replayStats) add(res *replayResult) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	method := s.methods[res.method]
+	if method == nil {
+		method = new(methodStats)
+		s.methods[res.method] = method
+	}
+	for _, stats := range []*methodStats{s.total, method} {
+		stats.counts[res.status]++
+		if res.status == statusMatch || res.status == statusMismatch {
+			stats.recorded = append(stats.recorded, res.recorded)
+			stats.replayed = append(stats.replayed, res.replayed)
+		}
+	}
+}
+
+// print writes the comparison report, listing the methods by request count.
+func (s *replayStats) print(out io.Writer, elapsed time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := s.methods[names[i]].requests(), s.methods[names[j]].requests()
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(out, "Replayed %d requests in %v\n\n", s.total.requests(), elapsed.Round(time.Millisecond))
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tREQUESTS\tMATCH\tMISMATCH\tFAILED\tSKIPPED\tRECORDED p50/p90/p99/max\tREPLAYED p50/p90/p99/max")
+	for _, name := range names {
+		s.methods[name].print(w, name)
+	}
+	s.total.print(w, "TOTAL")
+	w.Flush()
+}
+
+// requests returns the number of requests accounted.
+func (s *methodStats) requests() int {
+	var n int
+	for _, count := range s.counts {
+		n += count
+	}
+	return n
+}
+
+// print writes the report line of the method.
+func (s *methodStats) print(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", name, s.requests(),
+		s.counts[statusMatch], s.counts[statusMismatch], s.counts[statusFailed], s.counts[statusSkipped],
+		latencies(s.recorded), latencies(s.replayed))
+}
+
+// latencies formats the percentiles of the given latencies.
+func latencies(samples []time.Duration) string {
+	if len(samples) == 0 {
+		return "-"
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	parts := make([]string, 0, 4)
+	for _, p := range []float64{0.5, 0.9, 0.99, 1} {
+		parts = append(parts, percentile(sorted, p).Round(time.Microsecond).String())
+	}
+	return strings.Join(parts, "/")
+}
+
+// percentile returns the p-th percentile of the sorted samples, using the
+// nearest rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testService struct {
+	version string
+}
+
+func (s *testService) Echo(x int) int {
+	return x
+}
+
+func (s *testService) Version() string {
+	return s.version
+}
+
+func (s *testService) Fail() error {
+	return errors.New("failure")
+}
+
+// newTestServer starts an HTTP RPC server with the test service, recording the
+// served requests if a recorder is given.
+func newTestServer(t *testing.T, version string, recorder *rpc.Recorder) *httptest.Server {
+	server := rpc.NewServer()
+	if err := server.RegisterName("test", &testService{version: version}); err != nil {
+		t.Fatal(err)
+	}
+	if recorder != nil {
+		server.SetRecorder(recorder)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(func() {
+		ts.Close()
+		server.Stop()
+	})
+	return ts
+}
+
+// Tests that recorded requests are replayed against another endpoint and that
+// differing responses are detected.
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := rpc.NewRecorder(rpc.RecordConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	origin := newTestServer(t, "v1", recorder)
+
+	client, err := rpc.Dial(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		var res int
+		if err := client.Call(&res, "test_echo", i); err != nil {
+			t.Fatalf("failed to call: %v", err)
+		}
+	}
+	var version string
+	if err := client.Call(&version, "test_version"); err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	if err := client.Call(nil, "test_fail"); err == nil {
+		t.Fatalf("call did not fail")
+	}
+	client.Close()
+	recorder.Close()
+
+	files, err := rpc.RecordFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := newTestServer(t, "v2", nil)
+	r := newReplayer(target.URL, 0, 1)
+
+	var mismatches []*replayResult
+	r.report = func(res *replayResult) {
+		if res.status != statusMatch {
+			mismatches = append(mismatches, res)
+		}
+	}
+	if err := r.run(context.Background(), files); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if have := r.stats.total.requests(); have != 5 {
+		t.Fatalf("wrong number of replayed requests: have %d, want 5", have)
+	}
+	if have := r.stats.total.counts[statusMatch]; have != 4 {
+		t.Errorf("wrong number of matching requests: have %d, want 4", have)
+	}
+	if len(mismatches) != 1 || mismatches[0].method != "test_version" || mismatches[0].status != statusMismatch {
+		t.Errorf("unexpected mismatches: %v", mismatches)
+	}
+	if have := r.stats.methods["test_echo"].counts[statusMatch]; have != 3 {
+		t.Errorf("wrong number of matching echo requests: have %d, want 3", have)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.5, 50 * time.Millisecond},
+		{0.9, 90 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+		{0, time.Millisecond},
+	}
+	for _, tt := range tests {
+		if have := percentile(samples, tt.p); have != tt.want {
+			t.Errorf("p%v: have %v, want %v", tt.p*100, have, tt.want)
+		}
+	}
+	if have := percentile(nil, 0.5); have != 0 {
+		t.Errorf("empty samples: have %v, want 0", have)
+	}
+}
@@ -20,6 +20,7 @@ package utils
 import (
 	"bufio"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -40,6 +41,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -287,6 +289,80 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// exportedReceipts is the RLP export format of the receipts of a single block.
+type exportedReceipts struct {
+	Number   uint64
+	Hash     common.Hash
+	Receipts []*types.Receipt
+}
+
+// ExportReceipts exports the receipts of the canonical blocks in the given range
+// into the specified file, truncating any data already present in the file. The
+// receipts are written either as JSON, one receipt with all derived fields per
+// line, or as a stream of RLP encoded lists of consensus receipts per block.
+func ExportReceipts(db ethdb.Database, config *params.ChainConfig, fn string, first, last uint64, format string) error {
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if format != "jsonl" && format != "rlp" {
+		return fmt.Errorf("unknown receipt export format %q", format)
+	}
+	log.Info("Exporting receipts", "file", fn, "first", first, "last", last, "format", format)
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	buffered := bufio.NewWriter(writer)
+	enc := json.NewEncoder(buffered)
+
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		count    int
+	)
+	for number := first; number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("export failed on #%d: not found", number)
+		}
+		receipts := rawdb.ReadReceipts(db, hash, number, config)
+		if receipts == nil {
+			return fmt.Errorf("export failed on #%d: receipts not found", number)
+		}
+		if format == "rlp" {
+			err = rlp.Encode(buffered, &exportedReceipts{Number: number, Hash: hash, Receipts: receipts})
+		} else {
+			for _, receipt := range receipts {
+				if err = enc.Encode(receipt); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		count += len(receipts)
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Exporting receipts", "block", number, "receipts", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	log.Info("Exported receipts", "file", fn, "blocks", last-first+1, "receipts", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
 // It's a part of the deprecated functionality, should be removed in the future.
 func ImportPreimages(db ethdb.Database, fn string) error {
@@ -17,14 +17,26 @@
 package utils
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 // TestExport does basic sanity checks on the export/import functionality
@@ -196,3 +208,90 @@ func TestImportFutureFormat(t *testing.T) {
 		t.Fatalf("wrong error: %v", err)
 	}
 }
+
+// TestExportReceipts tests that receipts are exported with derived fields in
+// both formats.
+func TestExportReceipts(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, receipts := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, func(i int, b *core.BlockGen) {
+		for j := 0; j < i; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.Address{0xaa}, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	for i, block := range blocks {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	dir := t.TempDir()
+
+	// Export as JSON, skipping the first block
+	fn := filepath.Join(dir, "receipts.jsonl")
+	if err := ExportReceipts(db, gspec.Config, fn, 2, 4, "jsonl"); err != nil {
+		t.Fatalf("failed to export receipts: %v", err)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1+2+3 {
+		t.Fatalf("wrong number of exported receipts: have %d, want %d", len(lines), 6)
+	}
+	var index int
+	for _, block := range blocks[1:] {
+		for i, tx := range block.Transactions() {
+			var receipt types.Receipt
+			if err := json.Unmarshal([]byte(lines[index]), &receipt); err != nil {
+				t.Fatalf("failed to decode receipt %d: %v", index, err)
+			}
+			if receipt.TxHash != tx.Hash() || receipt.BlockHash != block.Hash() || receipt.TransactionIndex != uint(i) || receipt.BlockNumber.Cmp(block.Number()) != 0 {
+				t.Errorf("receipt %d has wrong derived fields: %+v", index, receipt)
+			}
+			index++
+		}
+	}
+	// Export as RLP
+	fn = filepath.Join(dir, "receipts.rlp.gz")
+	if err := ExportReceipts(db, gspec.Config, fn, 0, 4, "rlp"); err != nil {
+		t.Fatalf("failed to export receipts: %v", err)
+	}
+	fh, err := os.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := rlp.NewStream(gz, 0)
+	for number := uint64(0); number <= 4; number++ {
+		var exported exportedReceipts
+		if err := stream.Decode(&exported); err != nil {
+			t.Fatalf("failed to decode receipts of block %d: %v", number, err)
+		}
+		want := rawdb.ReadRawReceipts(db, exported.Hash, number)
+		if exported.Number != number || exported.Hash != rawdb.ReadCanonicalHash(db, number) {
+			t.Errorf("wrong block %d: have #%d %x", number, exported.Number, exported.Hash)
+		}
+		if have, want := types.DeriveSha(types.Receipts(exported.Receipts), trie.NewStackTrie(nil)), types.DeriveSha(want, trie.NewStackTrie(nil)); have != want {
+			t.Errorf("block %d: receipt root mismatch: have %x, want %x", number, have, want)
+		}
+	}
+	if err := stream.Decode(new(exportedReceipts)); err != io.EOF {
+		t.Errorf("expected end of stream, have %v", err)
+	}
+	// Missing blocks fail the export
+	if err := ExportReceipts(db, gspec.Config, fn, 3, 5, "rlp"); err == nil {
+		t.Errorf("export of missing block succeeded")
+	}
+}
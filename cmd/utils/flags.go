@@ -40,6 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
@@ -67,6 +68,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"gopkg.in/urfave/cli.v1"
@@ -181,6 +183,28 @@ var (
 		Usage: "Initial block gas limit",
 		Value: 11500000,
 	}
+	DeveloperBeaconFlag = cli.BoolFlag{
+		Name:  "dev.beacon",
+		Usage: "Run the developer network post-merge, driven by an in-process mock beacon client",
+	}
+	DeveloperBeaconSlotFlag = cli.DurationFlag{
+		Name:  "dev.beacon.slot",
+		Usage: "Slot time of the mock beacon client",
+		Value: 12 * time.Second,
+	}
+	DeveloperBeaconFinalityFlag = cli.Uint64Flag{
+		Name:  "dev.beacon.finality",
+		Usage: "Number of blocks the finalized block of the mock beacon client trails the head by (at least 1, the safe block)",
+		Value: 64,
+	}
+	DeveloperBeaconMissRateFlag = cli.Float64Flag{
+		Name:  "dev.beacon.missrate",
+		Usage: "Probability of the mock beacon client missing a slot",
+	}
+	DeveloperBeaconReorgRateFlag = cli.Float64Flag{
+		Name:  "dev.beacon.reorgrate",
+		Usage: "Probability of the mock beacon client reorging out the head block in a slot",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -220,6 +244,46 @@ var (
 		Usage: "Max number of elements (0 = no limit)",
 		Value: 0,
 	}
+	ReceiptsFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "Output format of the exported receipts (jsonl or rlp)",
+		Value: "jsonl",
+	}
+	ReplaySpeedFlag = cli.Float64Flag{
+		Name:  "speed",
+		Usage: "Speed factor of replaying captured transactions relative to the recorded timings (0 = no delays)",
+		Value: 1,
+	}
+	BacktestInclusionFlag = cli.Uint64Flag{
+		Name:  "inclusion",
+		Usage: "Number of blocks a transaction paying the suggested gas price must be included within",
+		Value: 3,
+	}
+	BaseFeeRuleFlag = cli.StringFlag{
+		Name:  "basefee.rule",
+		Usage: "Base fee update rule to recompute with (eip1559 or exponential)",
+		Value: params.BaseFeeRuleEIP1559,
+	}
+	BaseFeeDenominatorFlag = cli.Uint64Flag{
+		Name:  "basefee.denominator",
+		Usage: "Base fee change denominator to recompute with (0 = default)",
+	}
+	BaseFeeElasticityFlag = cli.Uint64Flag{
+		Name:  "basefee.elasticity",
+		Usage: "Gas limit elasticity multiplier to recompute with (0 = default)",
+	}
+	VerifyFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "Number of the first header to verify",
+	}
+	VerifyToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Number of the last header to verify (default = head)",
+	}
+	VerifyCachesFlag = cli.BoolFlag{
+		Name:  "ethash.pregen",
+		Usage: "Generate the ethash caches of the whole range in parallel before verifying",
+	}
 	defaultSyncMode = ethconfig.Defaults.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
@@ -231,6 +295,23 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	StateSchemeFlag = cli.StringFlag{
+		Name:  "state.scheme",
+		Usage: `Scheme to use for storing the state trie nodes ("hash", "path"), fixed when the database is initialized`,
+	}
+	StatePruningFlag = cli.BoolFlag{
+		Name:  "state.pruning",
+		Usage: "Enables pruning stale state in the background while the node is running (hash scheme only)",
+	}
+	StatePruningBloomFlag = cli.Uint64Flag{
+		Name:  "state.pruning.bloom",
+		Usage: "Megabytes of memory allocated to the bloom filter of online state pruning",
+		Value: pruner.DefaultOnlineConfig.BloomSize,
+	}
+	StateDiffExportFlag = DirectoryFlag{
+		Name:  "statediff.export",
+		Usage: "File to append the state changes of every imported block to (RLP if ending in .rlp, JSON lines otherwise)",
+	}
 	SnapshotFlag = cli.BoolTFlag{
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
@@ -365,6 +446,10 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolCaptureFlag = cli.StringFlag{
+		Name:  "txpool.capture",
+		Usage: "Disk journal capturing all transactions added to and dropped from the pool (disabled if empty)",
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -440,6 +525,10 @@ var (
 		Name:  "cache.noprefetch",
 		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
 	}
+	CacheTrieStatsFlag = cli.BoolFlag{
+		Name:  "cache.trie.stats",
+		Usage: "Enable collecting per-block trie node access statistics (debug_trieAccessStats)",
+	}
 	CachePreimagesFlag = cli.BoolFlag{
 		Name:  "cache.preimages",
 		Usage: "Enable recording the SHA3/keccak preimages of trie keys",
@@ -494,6 +583,11 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: "Transaction ordering policy of mined blocks (price, fifo or profit)",
+		Value: miner.OrderingPriceAndNonce,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -533,6 +627,47 @@ var (
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ethconfig.Defaults.RPCTxFeeCap,
 	}
+	RPCLogsLimitFlag = cli.IntFlag{
+		Name:  "rpc.logslimit",
+		Usage: "Maximum number of logs returned by eth_getLogs and in a page of eth_getLogsPage (0 = no limit)",
+		Value: ethconfig.Defaults.RPCLogsLimit,
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Cost units each HTTP and WS RPC client may spend per second (0 = no limit)",
+	}
+	RPCRateLimitBurstFlag = cli.IntFlag{
+		Name:  "rpc.ratelimit.burst",
+		Usage: "Cost units each HTTP and WS RPC client may spend at once",
+		Value: 100,
+	}
+	RPCRateLimitIdentityFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.identity",
+		Usage: "Identity RPC clients are rate limited by (addr, jwt or header:<name>)",
+		Value: rpc.IdentityRemoteAddr,
+	}
+	RPCRateLimitCostsFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.costs",
+		Usage: "Comma separated list of method=cost pairs overriding the default cost (1) of RPC methods, the cost of eth_getLogs is charged per block",
+	}
+	RPCRateLimitConcurrencyFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.concurrency",
+		Usage: "Comma separated list of namespace=limit pairs capping the concurrently executing HTTP and WS RPC requests",
+	}
+	RPCRecordFlag = DirectoryFlag{
+		Name:  "rpc.record",
+		Usage: "Directory to record the requests served by the HTTP and WS RPC servers into, except personal and admin calls (disabled if empty)",
+	}
+	RPCRecordMaxSizeFlag = cli.Int64Flag{
+		Name:  "rpc.record.maxsize",
+		Usage: "Size in megabytes after which an RPC request log file is rotated",
+		Value: 256,
+	}
+	RPCRecordMaxFilesFlag = cli.IntFlag{
+		Name:  "rpc.record.maxfiles",
+		Usage: "Number of RPC request log files to keep (0 = keep all)",
+		Value: 16,
+	}
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = cli.StringFlag{
 		Name:  "authrpc.addr",
@@ -553,6 +688,10 @@ var (
 		Name:  "authrpc.jwtsecret",
 		Usage: "Path to a JWT secret to use for authenticated RPC endpoints",
 	}
+	EngineCaptureFlag = DirectoryFlag{
+		Name:  "authrpc.capture",
+		Usage: "File to log the payload and forkchoice calls of the engine API into, for replay with 'geth engine-replay'",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -748,6 +887,11 @@ var (
 		Usage: "Gas price below which gpo will ignore transactions",
 		Value: ethconfig.Defaults.GPO.IgnorePrice.Int64(),
 	}
+	GpoStrategyFlag = cli.StringFlag{
+		Name:  "gpo.strategy",
+		Usage: "Strategy of deriving the suggested gas price from recent blocks (percentile, minimum or weighted)",
+		Value: gasprice.StrategyPercentile,
+	}
 
 	// Metrics flags
 	MetricsEnabledFlag = cli.BoolFlag{
@@ -1095,6 +1239,68 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setRPCRateLimit creates the rate limit policy of the HTTP and WebSocket RPC
+// servers from the set command line flags, leaving it nil if no limit is set.
+func setRPCRateLimit(ctx *cli.Context, cfg *node.Config) {
+	if !ctx.GlobalIsSet(RPCRateLimitFlag.Name) && !ctx.GlobalIsSet(RPCRateLimitConcurrencyFlag.Name) {
+		return
+	}
+	policy := &rpc.RateLimitPolicy{
+		Identity:    ctx.GlobalString(RPCRateLimitIdentityFlag.Name),
+		Rate:        ctx.GlobalFloat64(RPCRateLimitFlag.Name),
+		Burst:       ctx.GlobalInt(RPCRateLimitBurstFlag.Name),
+		Costs:       parseLimitList(ctx, RPCRateLimitCostsFlag.Name),
+		Concurrency: parseLimitList(ctx, RPCRateLimitConcurrencyFlag.Name),
+	}
+	// Charge the logs queries for the size of their block range
+	perBlock, ok := policy.Costs["eth_getLogs"]
+	if !ok {
+		perBlock = 1
+	}
+	policy.CostFuncs = map[string]rpc.CostFunc{
+		"eth_getLogs": rpc.BlockRangeCost(perBlock, rpcRateLimitLogsRange),
+	}
+	cfg.RPCRateLimit = policy
+}
+
+// rpcRateLimitLogsRange is the number of blocks charged for open ended or
+// overly large eth_getLogs ranges.
+const rpcRateLimitLogsRange = 10000
+
+// setRPCRecord configures the recording of the requests served by the HTTP and
+// WebSocket RPC servers from the set command line flags.
+func setRPCRecord(ctx *cli.Context, cfg *node.Config) {
+	if !ctx.GlobalIsSet(RPCRecordFlag.Name) {
+		return
+	}
+	cfg.RPCRecord = &rpc.RecordConfig{
+		Dir:      ctx.GlobalString(RPCRecordFlag.Name),
+		MaxSize:  ctx.GlobalInt64(RPCRecordMaxSizeFlag.Name) * 1024 * 1024,
+		MaxFiles: ctx.GlobalInt(RPCRecordMaxFilesFlag.Name),
+	}
+}
+
+// parseLimitList parses a comma separated list of name=value pairs.
+func parseLimitList(ctx *cli.Context, flag string) map[string]int {
+	list := ctx.GlobalString(flag)
+	if list == "" {
+		return nil
+	}
+	limits := make(map[string]int)
+	for _, entry := range SplitAndTrim(list) {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			Fatalf("Invalid --%s entry: %s", flag, entry)
+		}
+		value, err := strconv.Atoi(parts[1])
+		if err != nil || value < 0 {
+			Fatalf("Invalid --%s value %s: %v", flag, parts[1], err)
+		}
+		limits[parts[0]] = value
+	}
+	return limits
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -1314,6 +1520,8 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCRateLimit(ctx, cfg)
+	setRPCRecord(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
@@ -1413,6 +1621,9 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config, light bool) {
 	if ctx.GlobalIsSet(GpoIgnoreGasPriceFlag.Name) {
 		cfg.IgnorePrice = big.NewInt(ctx.GlobalInt64(GpoIgnoreGasPriceFlag.Name))
 	}
+	if ctx.GlobalIsSet(GpoStrategyFlag.Name) {
+		cfg.Strategy = ctx.GlobalString(GpoStrategyFlag.Name)
+	}
 }
 
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
@@ -1435,6 +1646,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolCaptureFlag.Name) {
+		cfg.Capture = ctx.GlobalString(TxPoolCaptureFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1505,6 +1719,9 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerifyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerifyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.Ordering = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
 	if ctx.GlobalIsSet(LegacyMinerGasTargetFlag.Name) {
 		log.Warn("The generic --miner.gastarget flag is deprecated and will be removed in the future!")
 	}
@@ -1585,6 +1802,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	CheckExclusive(ctx, MainnetFlag, DeveloperFlag, RopstenFlag, RinkebyFlag, GoerliFlag, SepoliaFlag, KilnFlag)
 	CheckExclusive(ctx, LightServeFlag, SyncModeFlag, "light")
 	CheckExclusive(ctx, DeveloperFlag, ExternalSignerFlag) // Can't use both ephemeral unlocked and external signer
+	if ctx.GlobalBool(DeveloperBeaconFlag.Name) && !ctx.GlobalBool(DeveloperFlag.Name) {
+		Fatalf("--%s is only available with --%s", DeveloperBeaconFlag.Name, DeveloperFlag.Name)
+	}
 	if ctx.GlobalString(GCModeFlag.Name) == "archive" && ctx.GlobalUint64(TxLookupLimitFlag.Name) != 0 {
 		ctx.GlobalSet(TxLookupLimitFlag.Name, "0")
 		log.Warn("Disable transaction unindexing for archive node")
@@ -1644,9 +1864,27 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(GCModeFlag.Name) {
 		cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 	}
+	if ctx.GlobalIsSet(StateSchemeFlag.Name) {
+		cfg.StateScheme = ctx.GlobalString(StateSchemeFlag.Name)
+	}
+	if ctx.GlobalIsSet(StatePruningFlag.Name) {
+		cfg.StatePruning = ctx.GlobalBool(StatePruningFlag.Name)
+	}
+	if ctx.GlobalIsSet(StatePruningBloomFlag.Name) {
+		cfg.StatePruningBloom = ctx.GlobalUint64(StatePruningBloomFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateDiffExportFlag.Name) {
+		cfg.StateDiffExport = ctx.GlobalString(StateDiffExportFlag.Name)
+	}
+	if ctx.GlobalIsSet(EngineCaptureFlag.Name) {
+		cfg.EngineCapture = ctx.GlobalString(EngineCaptureFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
 	}
+	if ctx.GlobalIsSet(CacheTrieStatsFlag.Name) {
+		cfg.TrieStats = ctx.GlobalBool(CacheTrieStatsFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.GlobalBool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1702,6 +1940,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCLogsLimitFlag.Name) {
+		cfg.RPCLogsLimit = ctx.GlobalInt(RPCLogsLimitFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
@@ -1795,6 +2036,10 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 
 		// Create a new developer genesis block or reuse existing one
 		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), ctx.GlobalUint64(DeveloperGasLimitFlag.Name), developer.Address)
+		if ctx.GlobalBool(DeveloperBeaconFlag.Name) {
+			// Merge at genesis, the mock beacon client takes over block production
+			cfg.Genesis.Config.TerminalTotalDifficulty = common.Big0
+		}
 		if ctx.GlobalIsSet(DataDirFlag.Name) {
 			// If datadir doesn't exist we need to open db in write-mode
 			// so leveldb can create files.
@@ -1864,7 +2109,7 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 		}
 	}
 	if backend.BlockChain().Config().TerminalTotalDifficulty != nil {
-		if err := ethcatalyst.Register(stack, backend); err != nil {
+		if err := ethcatalyst.Register(stack, backend, cfg.EngineCapture); err != nil {
 			Fatalf("Failed to register the catalyst service: %v", err)
 		}
 	}
@@ -1872,6 +2117,25 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 	return backend.APIBackend, backend
 }
 
+// RegisterDevBeaconService adds a mock consensus client driving the given full
+// node to the stack.
+func RegisterDevBeaconService(ctx *cli.Context, stack *node.Node, backend *eth.Ethereum) {
+	feeRecipient, err := backend.Etherbase()
+	if err != nil {
+		log.Warn("No fee recipient for the dev beacon", "err", err)
+	}
+	config := ethcatalyst.DevBeaconConfig{
+		SlotTime:     ctx.GlobalDuration(DeveloperBeaconSlotFlag.Name),
+		FinalityLag:  ctx.GlobalUint64(DeveloperBeaconFinalityFlag.Name),
+		MissRate:     ctx.GlobalFloat64(DeveloperBeaconMissRateFlag.Name),
+		ReorgRate:    ctx.GlobalFloat64(DeveloperBeaconReorgRateFlag.Name),
+		FeeRecipient: feeRecipient,
+	}
+	if err := ethcatalyst.RegisterDevBeacon(stack, backend, config); err != nil {
+		Fatalf("Failed to register the dev beacon service: %v", err)
+	}
+}
+
 // RegisterEthStatsService configures the Ethereum Stats daemon and adds it to
 // the given node.
 func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url string) {
@@ -2012,6 +2276,10 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb ethdb.Database) {
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack, false) // TODO(rjl493456442) support read-only database
+	scheme, err := rawdb.ParseStateScheme(ctx.GlobalString(StateSchemeFlag.Name), chainDb)
+	if err != nil {
+		Fatalf("%v", err)
+	}
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
 		Fatalf("%v", err)
@@ -2032,8 +2300,13 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieDirtyLimit:      ethconfig.Defaults.TrieDirtyCache,
 		TrieDirtyDisabled:   ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieTimeLimit:       ethconfig.Defaults.TrieTimeout,
+		TrieAccessStats:     ctx.GlobalBool(CacheTrieStatsFlag.Name),
 		SnapshotLimit:       ethconfig.Defaults.SnapshotCache,
 		Preimages:           ctx.GlobalBool(CachePreimagesFlag.Name),
+		StateScheme:         scheme,
+	}
+	if cache.StateScheme == rawdb.PathScheme && cache.TrieDirtyDisabled {
+		Fatalf("--%s=%s is incompatible with --%s=archive", StateSchemeFlag.Name, rawdb.PathScheme, GCModeFlag.Name)
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
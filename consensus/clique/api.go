@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -179,6 +180,53 @@ func (api *API) Status() (*status, error) {
 	}, nil
 }
 
+// maxSignerStatsRange is the maximum number of blocks the signer statistics can
+// be requested for at once.
+const maxSignerStatsRange = 1 << 16
+
+// GetSignerStats returns the signing and voting activity of each signer over the
+// given range of blocks, as recorded by the signer statistics index: the number
+// of blocks sealed in-turn and out-of-turn, the turns missed and the votes cast.
+// The latest block resolves to the last indexed one, which lags behind the head
+// of the chain by up to a section of the index.
+func (api *API) GetSignerStats(from, to rpc.BlockNumber) (map[common.Address]*SignerStats, error) {
+	indexed, err := api.clique.indexedStatsHead()
+	if err != nil {
+		return nil, err
+	}
+	resolve := func(number rpc.BlockNumber) (uint64, error) {
+		switch {
+		case number == rpc.LatestBlockNumber:
+			return indexed, nil
+		case number < 0:
+			return 0, fmt.Errorf("unsupported block number %v", number)
+		default:
+			return uint64(number), nil
+		}
+	}
+	first, err := resolve(from)
+	if err != nil {
+		return nil, err
+	}
+	last, err := resolve(to)
+	if err != nil {
+		return nil, err
+	}
+	if first == 0 {
+		first = 1 // Genesis is not signed
+	}
+	if first > last {
+		return nil, fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if last > indexed {
+		return nil, fmt.Errorf("block #%d not indexed yet, signer statistics indexed up to #%d", last, indexed)
+	}
+	if last-first >= maxSignerStatsRange {
+		return nil, fmt.Errorf("block range %d-%d exceeds the maximum of %d blocks", first, last, maxSignerStatsRange)
+	}
+	return signerStats(rawdb.NewTable(api.clique.db, SignerStatsPrefix), first, last)
+}
+
 type blockNumberOrHashOrRLP struct {
 	*rpc.BlockNumberOrHash
 	RLP hexutil.Bytes `json:"rlp,omitempty"`
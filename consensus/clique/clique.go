@@ -180,7 +180,9 @@ type Clique struct {
 
 	signer common.Address // Ethereum address of the signing key
 	signFn SignerFn       // Signer function to authorize hashes with
-	lock   sync.RWMutex   // Protects the signer and proposals fields
+	lock   sync.RWMutex   // Protects the signer, proposals and statsIndexer fields
+
+	statsIndexer StatsIndexer // Chain indexer of the signer statistics, nil if not indexed
 
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
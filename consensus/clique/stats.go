@@ -0,0 +1,254 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// SignerStatsPrefix is the database table prefix of the signer statistics index.
+const SignerStatsPrefix = "clique-stats-"
+
+// signerRecordPrefix + num (uint64 big endian) -> signer record
+var signerRecordPrefix = []byte("r")
+
+// SignerRecord is the signing and voting activity of a single block.
+type SignerRecord struct {
+	Number   uint64         `json:"number"`          // Block number the record belongs to
+	Signer   common.Address `json:"signer"`          // Authorized signer that sealed the block
+	InTurn   bool           `json:"inturn"`          // Whether the signer sealed the block in-turn
+	Expected common.Address `json:"expected"`        // Signer whose turn the block was
+	Signers  int            `json:"signers"`         // Number of authorized signers at the block
+	Vote     *Vote          `json:"vote,omitempty"`  // Vote cast in the block, if any
+	Tally    *Tally         `json:"tally,omitempty"` // Tally of the voted account after the block, nil if not pending
+}
+
+// signerRecordKey = signerRecordPrefix + num (uint64 big endian)
+func signerRecordKey(number uint64) []byte {
+	key := make([]byte, len(signerRecordPrefix)+8)
+	copy(key, signerRecordPrefix)
+	binary.BigEndian.PutUint64(key[len(signerRecordPrefix):], number)
+	return key
+}
+
+// readSignerRecord retrieves the signer record of a block from the index.
+func readSignerRecord(db ethdb.KeyValueReader, number uint64) (*SignerRecord, error) {
+	blob, err := db.Get(signerRecordKey(number))
+	if err != nil {
+		return nil, err
+	}
+	record := new(SignerRecord)
+	if err := json.Unmarshal(blob, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// writeSignerRecord stores the signer record of a block into the index.
+func writeSignerRecord(db ethdb.KeyValueWriter, record *SignerRecord) error {
+	blob, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return db.Put(signerRecordKey(record.Number), blob)
+}
+
+// StatsIndexer is the chain indexer running the signer statistics backend,
+// reporting how far the statistics have been indexed. It is implemented by
+// core.ChainIndexer.
+type StatsIndexer interface {
+	// Sections returns the number of processed sections, along with the number
+	// and hash of the last block of the last one.
+	Sections() (uint64, uint64, common.Hash)
+}
+
+// SetStatsIndexer sets the chain indexer of the signer statistics, which the
+// API consults to only serve statistics of the blocks already indexed.
+func (c *Clique) SetStatsIndexer(indexer StatsIndexer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.statsIndexer = indexer
+}
+
+// indexedStatsHead returns the number of the last block whose signer statistics
+// have been indexed.
+func (c *Clique) indexedStatsHead() (uint64, error) {
+	c.lock.RLock()
+	indexer := c.statsIndexer
+	c.lock.RUnlock()
+
+	if indexer == nil {
+		return 0, errors.New("signer statistics not indexed")
+	}
+	sections, head, _ := indexer.Sections()
+	if sections == 0 {
+		return 0, errors.New("no signer statistics indexed yet")
+	}
+	return head, nil
+}
+
+// SignerStatsIndexer implements core.ChainIndexerBackend, recording the signer,
+// turn and votes of each canonical block into the signer statistics index.
+type SignerStatsIndexer struct {
+	chain  consensus.ChainHeaderReader
+	engine *Clique
+	db     ethdb.Database // Signer statistics table to write the records into
+
+	batch ethdb.Batch // Batch of records of the section being processed
+	snap  *Snapshot   // Voting snapshot at the last processed header
+}
+
+// NewSignerStatsIndexer creates a chain indexer backend for the signer statistics
+// of a clique chain. The records are stored in the SignerStatsPrefix table of the
+// database the engine keeps its snapshots in.
+func NewSignerStatsIndexer(chain consensus.ChainHeaderReader, engine *Clique) *SignerStatsIndexer {
+	return &SignerStatsIndexer{
+		chain:  chain,
+		engine: engine,
+		db:     rawdb.NewTable(engine.db, SignerStatsPrefix),
+	}
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new section.
+func (idx *SignerStatsIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	idx.batch, idx.snap = idx.db.NewBatch(), nil
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, recording the activity of a new
+// header into the section.
+func (idx *SignerStatsIndexer) Process(ctx context.Context, header *types.Header) error {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Retrieve the voting snapshot the header was sealed on, tracking it along
+	// the section to avoid going through the engine for every header
+	parent := idx.snap
+	if parent == nil || parent.Hash != header.ParentHash {
+		snap, err := idx.engine.snapshot(idx.chain, number-1, header.ParentHash, nil)
+		if err != nil {
+			return err
+		}
+		parent = snap
+	}
+	snap, err := parent.apply([]*types.Header{header})
+	if err != nil {
+		return err
+	}
+	idx.snap = snap
+
+	signer, err := ecrecover(header, parent.sigcache)
+	if err != nil {
+		return err
+	}
+	signers := parent.signers()
+	record := &SignerRecord{
+		Number:   number,
+		Signer:   signer,
+		InTurn:   parent.inturn(number, signer),
+		Expected: signers[number%uint64(len(signers))],
+		Signers:  len(signers),
+	}
+	if header.Coinbase != (common.Address{}) {
+		record.Vote = &Vote{
+			Signer:    signer,
+			Block:     number,
+			Address:   header.Coinbase,
+			Authorize: bytes.Equal(header.Nonce[:], nonceAuthVote),
+		}
+		if tally, ok := snap.Tally[header.Coinbase]; ok {
+			record.Tally = &tally
+		}
+	}
+	return writeSignerRecord(idx.batch, record)
+}
+
+// Commit implements core.ChainIndexerBackend, writing the records of the section
+// into the database.
+func (idx *SignerStatsIndexer) Commit() error {
+	return idx.batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (idx *SignerStatsIndexer) Prune(threshold uint64) error {
+	return nil
+}
+
+// SignerStats is the signing and voting activity of a signer over a range of
+// blocks.
+type SignerStats struct {
+	Signed        uint64      `json:"signed"`        // Number of blocks sealed
+	InTurn        uint64      `json:"inturn"`        // Number of blocks sealed in-turn
+	OutOfTurn     uint64      `json:"outOfTurn"`     // Number of blocks sealed out-of-turn
+	OutOfTurnRate float64     `json:"outOfTurnRate"` // Ratio of the sealed blocks which were out-of-turn
+	MissedTurns   uint64      `json:"missedTurns"`   // Number of in-turn blocks sealed by others
+	Votes         []*CastVote `json:"votes"`         // Votes cast in chronological order
+}
+
+// CastVote is a vote cast by a signer along with the resulting tally.
+type CastVote struct {
+	*Vote
+	Tally *Tally `json:"tally,omitempty"` // Tally of the voted account after the vote, nil if not pending
+}
+
+// signerStats aggregates the records of a range of blocks into the statistics
+// of each signer.
+func signerStats(db ethdb.KeyValueReader, from, to uint64) (map[common.Address]*SignerStats, error) {
+	stats := make(map[common.Address]*SignerStats)
+	get := func(signer common.Address) *SignerStats {
+		if stats[signer] == nil {
+			stats[signer] = &SignerStats{Votes: []*CastVote{}}
+		}
+		return stats[signer]
+	}
+	for number := from; number <= to; number++ {
+		record, err := readSignerRecord(db, number)
+		if err != nil {
+			return nil, fmt.Errorf("block #%d not indexed yet", number)
+		}
+		signer := get(record.Signer)
+		signer.Signed++
+		if record.InTurn {
+			signer.InTurn++
+		} else {
+			signer.OutOfTurn++
+			get(record.Expected).MissedTurns++
+		}
+		if record.Vote != nil {
+			signer.Votes = append(signer.Votes, &CastVote{Vote: record.Vote, Tally: record.Tally})
+		}
+	}
+	for _, signer := range stats {
+		if signer.Signed > 0 {
+			signer.OutOfTurnRate = float64(signer.OutOfTurn) / float64(signer.Signed)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testStatsIndexer reports a fixed indexing progress of the signer statistics.
+type testStatsIndexer struct {
+	sections uint64
+	head     uint64
+}
+
+func (idx testStatsIndexer) Sections() (uint64, uint64, common.Hash) {
+	return idx.sections, idx.head, common.Hash{}
+}
+
+// Tests that the signer statistics index records the turns and votes of each
+// block, and that they are aggregated correctly per signer.
+func TestSignerStats(t *testing.T) {
+	// Create three signers, ordered by address as clique assigns turns
+	accounts := newTesterAccountPool()
+
+	names := []string{"A", "B", "C"}
+	sort.Slice(names, func(i, j int) bool {
+		return bytes.Compare(accounts.address(names[i]).Bytes(), accounts.address(names[j]).Bytes()) < 0
+	})
+	signers := make([]common.Address, len(names))
+	for i, name := range names {
+		signers[i] = accounts.address(name)
+	}
+	genesis := &core.Genesis{
+		ExtraData: make([]byte, extraVanity+common.AddressLength*len(signers)+extraSeal),
+		BaseFee:   big.NewInt(params.InitialBaseFee),
+	}
+	for i, signer := range signers {
+		copy(genesis.ExtraData[extraVanity+i*common.AddressLength:], signer[:])
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+	// Seal blocks in a schedule where the first signer never gets its turn and
+	// the others alternate, while the first two signers cast votes
+	var (
+		schedule = []int{1, 0, 2, 1, 0, 2}
+		votes    = map[int]string{1: "D", 3: "E"}
+	)
+	blocks, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, len(schedule), func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(accounts.address(votes[i]))
+		if votes[i] != "" {
+			var nonce types.BlockNonce
+			copy(nonce[:], nonceAuthVote)
+			gen.SetNonce(nonce)
+		}
+	})
+	for i, block := range blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		header.Extra = make([]byte, extraVanity+extraSeal)
+		header.Difficulty = diffInTurn // Ignored, we just need a valid number
+
+		accounts.sign(header, names[schedule[i]])
+		blocks[i] = block.WithSeal(header)
+	}
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	// Index the chain in a single section, excluding the last block
+	indexer := NewSignerStatsIndexer(chain, engine)
+	if err := indexer.Reset(context.Background(), 0, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset indexer: %v", err)
+	}
+	for i := uint64(0); i < uint64(len(blocks)); i++ {
+		if err := indexer.Process(context.Background(), chain.GetHeaderByNumber(i)); err != nil {
+			t.Fatalf("failed to index block %d: %v", i, err)
+		}
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit index: %v", err)
+	}
+	api := &API{chain: chain, clique: engine}
+	if _, err := api.GetSignerStats(0, 5); err == nil {
+		t.Fatalf("statistics returned without an indexer")
+	}
+	engine.SetStatsIndexer(testStatsIndexer{sections: 1, head: 5})
+	if _, err := api.GetSignerStats(0, 6); err == nil || !strings.Contains(err.Error(), "indexed up to #5") {
+		t.Fatalf("error mismatch for unindexed block: have %v, want indexed height", err)
+	}
+	stats, err := api.GetSignerStats(0, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve signer stats: %v", err)
+	}
+	want := map[common.Address]*SignerStats{
+		signers[0]: {
+			Signed: 2, OutOfTurn: 2, OutOfTurnRate: 1, MissedTurns: 1,
+			Votes: []*CastVote{{
+				Vote:  &Vote{Signer: signers[0], Block: 2, Address: accounts.address("D"), Authorize: true},
+				Tally: &Tally{Authorize: true, Votes: 1},
+			}},
+		},
+		signers[1]: {
+			Signed: 2, InTurn: 2,
+			Votes: []*CastVote{{
+				Vote:  &Vote{Signer: signers[1], Block: 4, Address: accounts.address("E"), Authorize: true},
+				Tally: &Tally{Authorize: true, Votes: 1},
+			}},
+		},
+		signers[2]: {
+			Signed: 1, OutOfTurn: 1, OutOfTurnRate: 1, MissedTurns: 2, Votes: []*CastVote{},
+		},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		for signer, stat := range stats {
+			t.Errorf("signer %x: %+v", signer, stat)
+		}
+		t.Fatalf("signer stats mismatch")
+	}
+}
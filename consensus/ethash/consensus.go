@@ -147,6 +147,7 @@ func (ethash *Ethash) VerifyHeaders(chain consensus.ChainHeaderReader, headers [
 		abort   = make(chan struct{})
 		unixNow = time.Now().Unix()
 	)
+	ethash.warmSealCaches(headers, seals)
 	for i := 0; i < workers; i++ {
 		go func() {
 			for index := range inputs {
@@ -199,6 +200,33 @@ func (ethash *Ethash) verifyHeaderWorker(chain consensus.ChainHeaderReader, head
 	return ethash.verifyHeader(chain, headers[index], parent, false, seals[index], unixNow)
 }
 
+// warmSealCaches starts generating the verification caches of the epochs spanned
+// by the headers whose seals are verified in a batch, so that the workers share
+// them through the engine's caches rather than running into each generation in
+// turn. No more epochs are warmed than caches are kept in memory.
+func (ethash *Ethash) warmSealCaches(headers []*types.Header, seals []bool) {
+	// Fake PoW doesn't need caches, shared PoW verifies with the caches of that
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
+		return
+	}
+	if ethash.shared != nil {
+		ethash.shared.warmSealCaches(headers, seals)
+		return
+	}
+	epochs := make(map[uint64]bool)
+	for i, header := range headers {
+		epoch := header.Number.Uint64() / epochLength
+		if !seals[i] || epochs[epoch] {
+			continue
+		}
+		if len(epochs) == ethash.config.CachesInMem {
+			return
+		}
+		epochs[epoch] = true
+		go ethash.cache(header.Number.Uint64())
+	}
+}
+
 // VerifyUncles verifies that the given block's uncles conform to the consensus
 // rules of the stock Ethereum ethash engine.
 func (ethash *Ethash) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
@@ -401,6 +401,59 @@ func MakeDataset(block uint64, dir string) {
 	d.generate(dir, math.MaxInt32, false, false)
 }
 
+// MakeCaches generates the ethash caches of all the epochs spanned by the given
+// block range on multiple threads and stores them to disk, keeping any existing
+// ones. Engines load the cache files as read only memory maps, so generating
+// them upfront lets any number of processes verify the range without ever
+// regenerating a cache.
+func MakeCaches(first, last uint64, dir string, threads int) error {
+	return makeCaches(first, last, dir, threads, false)
+}
+
+// makeCaches generates the ethash caches of all the epochs spanned by the given
+// block range on multiple threads and stores them to disk.
+func makeCaches(first, last uint64, dir string, threads int, test bool) error {
+	if dir == "" {
+		return errors.New("no ethash cache directory")
+	}
+	if first > last {
+		return fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if threads <= 0 {
+		threads = 1
+	}
+	var (
+		epochs = make(chan uint64)
+		failed uint64 // Number of caches not stored on disk, accessed atomically
+		pend   sync.WaitGroup
+	)
+	for i := 0; i < threads; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			for epoch := range epochs {
+				c := &cache{epoch: epoch}
+				c.generate(dir, math.MaxInt32, false, test)
+				if c.dump == nil {
+					atomic.AddUint64(&failed, 1)
+				}
+				// Release the memory map right away, the cache lives on disk
+				c.finalizer()
+			}
+		}()
+	}
+	for epoch := first / epochLength; epoch <= last/epochLength; epoch++ {
+		epochs <- epoch
+	}
+	close(epochs)
+	pend.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("failed to store %d ethash caches", failed)
+	}
+	return nil
+}
+
 // Mode defines the type and amount of PoW verification an ethash engine makes.
 type Mode uint
 
@@ -584,6 +637,18 @@ func (ethash *Ethash) cache(block uint64) *cache {
 	return current
 }
 
+// GenerateCaches generates the verification caches of all the epochs spanned by
+// the given block range into the configured cache directory, using all cores.
+// Existing caches are kept, so subsequent verifications of the range only need
+// to memory map them.
+func (ethash *Ethash) GenerateCaches(first, last uint64) error {
+	// If we're running a shared PoW, generate the caches of that instead
+	if ethash.shared != nil {
+		return ethash.shared.GenerateCaches(first, last)
+	}
+	return makeCaches(first, last, ethash.config.CacheDir, runtime.NumCPU(), ethash.config.PowMode == ModeTest)
+}
+
 // dataset tries to retrieve a mining dataset for the specified block number
 // by first checking against a list of in-memory datasets, then against DAGs
 // stored on disk, and finally generating one if none can be found.
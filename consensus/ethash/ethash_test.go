@@ -20,7 +20,11 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -175,3 +179,175 @@ func TestClosedRemoteSealer(t *testing.T) {
 		t.Error("expect to return false when submit hashrate to a stopped ethash")
 	}
 }
+
+// Tests that the caches of a block range are generated onto disk, and that the
+// engine loads them from there instead of regenerating them.
+func TestGenerateCaches(t *testing.T) {
+	// TODO: t.TempDir fails to remove the directory on Windows
+	tmpdir, err := os.MkdirTemp("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	config := Config{
+		CachesInMem:  1,
+		CachesOnDisk: 1,
+		CacheDir:     tmpdir,
+		PowMode:      ModeTest,
+	}
+	e := New(config, nil, false)
+	defer e.Close()
+
+	// Generate a cache beyond the verified range too, the engine loads it ahead
+	if err := e.GenerateCaches(epochLength-1, 4*epochLength); err != nil {
+		t.Fatalf("failed to generate caches: %v", err)
+	}
+	// All epochs should be on disk, despite the on-disk limit of the engine
+	files, _ := filepath.Glob(filepath.Join(tmpdir, "cache-*"))
+	if len(files) != 5 {
+		t.Fatalf("cache file count mismatch: have %d, want %d", len(files), 5)
+	}
+	stats := make(map[string]os.FileInfo)
+	for _, file := range files {
+		if stats[file], err = os.Stat(file); err != nil {
+			t.Fatalf("failed to stat cache file: %v", err)
+		}
+	}
+	// Verify across the range and ensure the caches were neither regenerated
+	// nor evicted from disk
+	for epoch := uint64(0); epoch <= 3; epoch++ {
+		c := e.cache(epoch * epochLength)
+
+		want := make([]uint32, 1024/4)
+		generateCache(want, epoch, seedHash(epoch*epochLength+1))
+		if !reflect.DeepEqual(c.cache, want) {
+			t.Errorf("epoch %d: cache content mismatch", epoch)
+		}
+	}
+	for file, stat := range stats {
+		current, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("cache file %s lost: %v", file, err)
+		}
+		if !current.ModTime().Equal(stat.ModTime()) {
+			t.Errorf("cache file %s regenerated", file)
+		}
+	}
+}
+
+// sealHeaders creates headers of the given numbers, sealed by the engine.
+func sealHeaders(tb testing.TB, ethash *Ethash, numbers []uint64) []*types.Header {
+	headers := make([]*types.Header, 0, len(numbers))
+	for _, number := range numbers {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: big.NewInt(100)}
+
+		results := make(chan *types.Block)
+		if err := ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+			tb.Fatalf("failed to seal block: %v", err)
+		}
+		select {
+		case block := <-results:
+			header.Nonce = types.EncodeNonce(block.Nonce())
+			header.MixDigest = block.MixDigest()
+		case <-time.NewTimer(4 * time.Second).C:
+			tb.Fatalf("sealing result timeout")
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// Tests that batch verification warms the caches of the epochs whose seals are
+// verified through the engine, without exceeding the caches kept in memory.
+func TestWarmSealCaches(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, CachesInMem: 2}, nil, false)
+	defer ethash.Close()
+
+	headers := sealHeaders(t, ethash, []uint64{1, 2, epochLength, 2*epochLength + 1, 3 * epochLength})
+	seals := []bool{true, true, false, true, true}
+
+	cached := func(epoch uint64) bool {
+		ethash.caches.mu.Lock()
+		defer ethash.caches.mu.Unlock()
+		return ethash.caches.cache.Contains(epoch)
+	}
+	ethash.warmSealCaches(headers, seals)
+	for deadline := time.Now().Add(time.Second); !cached(0) || !cached(2); {
+		if time.Now().After(deadline) {
+			t.Fatalf("caches not warmed: epoch 0 %v, epoch 2 %v", cached(0), cached(2))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cached(1) || cached(3) {
+		t.Errorf("unexpected caches warmed: epoch 1 %v, epoch 3 %v", cached(1), cached(3))
+	}
+	for i, header := range headers {
+		if err := ethash.verifySeal(nil, header, false); err != nil {
+			t.Errorf("header %d: unexpected verification error: %v", i, err)
+		}
+	}
+	// Fake engines have no caches to warm
+	NewFaker().warmSealCaches(headers, seals)
+}
+
+// Benchmarks the seal verification of a header segment spanning four epochs, one
+// by one and as a batch on all cores. The segment crosses three epoch boundaries, so the
+// verification caches of several epochs are needed along it.
+func BenchmarkVerifySeals(b *testing.B) {
+	ethash := New(Config{PowMode: ModeTest, CachesInMem: 4}, nil, false)
+	defer ethash.Close()
+
+	// Seal 64 consecutive headers at the end of every epoch and the start of the
+	// following one
+	var numbers []uint64
+	for epoch := uint64(1); epoch <= 3; epoch++ {
+		for i := uint64(0); i < 64; i++ {
+			numbers = append(numbers, epoch*epochLength-32+i)
+		}
+	}
+	segment := sealHeaders(b, ethash, numbers)
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, header := range segment {
+				if err := ethash.verifySeal(nil, header, false); err != nil {
+					b.Fatalf("failed to verify seal: %v", err)
+				}
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(segment)), "ns/header")
+	})
+	b.Run("batch", func(b *testing.B) {
+		seals := make([]bool, len(segment))
+		for i := range seals {
+			seals[i] = true
+		}
+		for i := 0; i < b.N; i++ {
+			// Verify like VerifyHeaders does, on all cores with the caches warmed
+			ethash.warmSealCaches(segment, seals)
+
+			var (
+				wg     sync.WaitGroup
+				next   int32 = -1
+				failed int32
+			)
+			for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := int(atomic.AddInt32(&next, 1)); j < len(segment); j = int(atomic.AddInt32(&next, 1)) {
+						if ethash.verifySeal(nil, segment[j], false) != nil {
+							atomic.StoreInt32(&failed, 1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if failed != 0 {
+				b.Fatal("failed to verify seal")
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(segment)), "ns/header")
+	})
+}
@@ -33,7 +33,7 @@ func VerifyEip1559Header(config *params.ChainConfig, parent, header *types.Heade
 	// Verify that the gas limit remains within allowed bounds
 	parentGasLimit := parent.GasLimit
 	if !config.IsLondon(parent.Number) {
-		parentGasLimit = parent.GasLimit * params.ElasticityMultiplier
+		parentGasLimit = parent.GasLimit * config.ElasticityMultiplier()
 	}
 	if err := VerifyGaslimit(parentGasLimit, header.GasLimit); err != nil {
 		return err
@@ -55,10 +55,12 @@ func VerifyEip1559Header(config *params.ChainConfig, parent, header *types.Heade
 func CalcBaseFee(config *params.ChainConfig, parent *types.Header) *big.Int {
 	// If the current block is the first EIP-1559 block, return the InitialBaseFee.
 	if !config.IsLondon(parent.Number) {
-		return new(big.Int).SetUint64(params.InitialBaseFee)
+		return new(big.Int).SetUint64(config.InitialBaseFee())
 	}
-
-	parentGasTarget := parent.GasLimit / params.ElasticityMultiplier
+	if config.BaseFeeRule() == params.BaseFeeRuleExponential {
+		return calcExponentialBaseFee(config, parent)
+	}
+	parentGasTarget := parent.GasLimit / config.ElasticityMultiplier()
 	// If the parent gasUsed is the same as the target, the baseFee remains unchanged.
 	if parent.GasUsed == parentGasTarget {
 		return new(big.Int).Set(parent.BaseFee)
@@ -75,7 +77,7 @@ func CalcBaseFee(config *params.ChainConfig, parent *types.Header) *big.Int {
 		num.SetUint64(parent.GasUsed - parentGasTarget)
 		num.Mul(num, parent.BaseFee)
 		num.Div(num, denom.SetUint64(parentGasTarget))
-		num.Div(num, denom.SetUint64(params.BaseFeeChangeDenominator))
+		num.Div(num, denom.SetUint64(config.BaseFeeChangeDenominator()))
 		baseFeeDelta := math.BigMax(num, common.Big1)
 
 		return num.Add(parent.BaseFee, baseFeeDelta)
@@ -85,9 +87,55 @@ func CalcBaseFee(config *params.ChainConfig, parent *types.Header) *big.Int {
 		num.SetUint64(parentGasTarget - parent.GasUsed)
 		num.Mul(num, parent.BaseFee)
 		num.Div(num, denom.SetUint64(parentGasTarget))
-		num.Div(num, denom.SetUint64(params.BaseFeeChangeDenominator))
+		num.Div(num, denom.SetUint64(config.BaseFeeChangeDenominator()))
 		baseFee := num.Sub(parent.BaseFee, num)
 
 		return math.BigMax(baseFee, common.Big0)
 	}
 }
+
+// calcExponentialBaseFee calculates the basefee of the header using an exponential
+// update rule, similar to the blob gas price of EIP-4844:
+//
+//	baseFee = parentBaseFee * e^((parentGasUsed - parentGasTarget) / parentGasTarget / baseFeeChangeDenominator)
+//
+// As with EIP-1559, an increase is at least 1 wei.
+func calcExponentialBaseFee(config *params.ChainConfig, parent *types.Header) *big.Int {
+	parentGasTarget := parent.GasLimit / config.ElasticityMultiplier()
+	if parent.GasUsed == parentGasTarget {
+		return new(big.Int).Set(parent.BaseFee)
+	}
+	denom := new(big.Int).SetUint64(parentGasTarget)
+	denom.Mul(denom, new(big.Int).SetUint64(config.BaseFeeChangeDenominator()))
+
+	if parent.GasUsed > parentGasTarget {
+		num := new(big.Int).SetUint64(parent.GasUsed - parentGasTarget)
+		baseFee := fakeExponential(parent.BaseFee, num, denom)
+		return math.BigMax(baseFee, new(big.Int).Add(parent.BaseFee, common.Big1))
+	}
+	// Otherwise decrease by the inverse factor, parentBaseFee / e^x
+	num := new(big.Int).SetUint64(parentGasTarget - parent.GasUsed)
+	exp := fakeExponential(parent.BaseFee, num, denom)
+	if exp.Sign() == 0 {
+		return new(big.Int)
+	}
+	baseFee := new(big.Int).Mul(parent.BaseFee, parent.BaseFee)
+	return baseFee.Div(baseFee, exp)
+}
+
+// fakeExponential approximates factor * e ** (numerator / denominator) using
+// Taylor expansion, as specified by EIP-4844.
+func fakeExponential(factor, numerator, denominator *big.Int) *big.Int {
+	var (
+		output = new(big.Int)
+		accum  = new(big.Int).Mul(factor, denominator)
+	)
+	for i := 1; accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+
+		accum.Mul(accum, numerator)
+		accum.Div(accum, denominator)
+		accum.Div(accum, big.NewInt(int64(i)))
+	}
+	return output.Div(output, denominator)
+}
@@ -45,6 +45,7 @@ func copyConfig(original *params.ChainConfig) *params.ChainConfig {
 		BerlinBlock:             original.BerlinBlock,
 		LondonBlock:             original.LondonBlock,
 		TerminalTotalDifficulty: original.TerminalTotalDifficulty,
+		FeeMarket:               original.FeeMarket,
 		Ethash:                  original.Ethash,
 		Clique:                  original.Clique,
 	}
@@ -130,3 +131,54 @@ func TestCalcBaseFee(t *testing.T) {
 		}
 	}
 }
+
+// TestCalcBaseFeeFeeMarket tests the base fee calculation with custom fee market
+// parameters and update rules.
+func TestCalcBaseFeeFeeMarket(t *testing.T) {
+	tests := []struct {
+		feeMarket       *params.FeeMarketConfig
+		parentGasUsed   uint64
+		expectedBaseFee int64
+	}{
+		// Custom parameters of the linear rule with a target of 5M gas
+		{&params.FeeMarketConfig{BaseFeeChangeDenominator: 16, ElasticityMultiplier: 4}, 5000000, params.InitialBaseFee},
+		{&params.FeeMarketConfig{BaseFeeChangeDenominator: 16, ElasticityMultiplier: 4}, 3000000, 975000000},
+		{&params.FeeMarketConfig{BaseFeeChangeDenominator: 16, ElasticityMultiplier: 4}, 7000000, 1025000000},
+		// Exponential rule with a target of 10M gas, changing by at most e^(1/8)
+		{&params.FeeMarketConfig{Rule: params.BaseFeeRuleExponential}, 10000000, params.InitialBaseFee},
+		{&params.FeeMarketConfig{Rule: params.BaseFeeRuleExponential}, 0, 882496902},
+		{&params.FeeMarketConfig{Rule: params.BaseFeeRuleExponential}, 20000000, 1133148453},
+		{&params.FeeMarketConfig{Rule: params.BaseFeeRuleExponential, BaseFeeChangeDenominator: 4}, 15000000, 1133148453},
+	}
+	for i, test := range tests {
+		config := config()
+		config.FeeMarket = test.feeMarket
+
+		parent := &types.Header{
+			Number:   common.Big32,
+			GasLimit: 20000000,
+			GasUsed:  test.parentGasUsed,
+			BaseFee:  big.NewInt(params.InitialBaseFee),
+		}
+		if have, want := CalcBaseFee(config, parent), big.NewInt(test.expectedBaseFee); have.Cmp(want) != 0 {
+			t.Errorf("test %d: have %d  want %d, ", i, have, want)
+		}
+	}
+}
+
+// TestCalcBaseFeeExponentialMinimum tests that the exponential rule raises the
+// base fee by at least 1 wei if the parent exceeds the gas target.
+func TestCalcBaseFeeExponentialMinimum(t *testing.T) {
+	config := config()
+	config.FeeMarket = &params.FeeMarketConfig{Rule: params.BaseFeeRuleExponential}
+
+	parent := &types.Header{
+		Number:   common.Big32,
+		GasLimit: 20000000,
+		GasUsed:  10000001,
+		BaseFee:  big.NewInt(7),
+	}
+	if have := CalcBaseFee(config, parent); have.Cmp(big.NewInt(8)) != 0 {
+		t.Errorf("base fee mismatch: have %d, want 8", have)
+	}
+}
@@ -87,6 +87,7 @@ const (
 	bodyCacheLimit      = 256
 	blockCacheLimit     = 256
 	receiptsCacheLimit  = 32
+	trieStatsCacheLimit = 256
 	txLookupCacheLimit  = 1024
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
@@ -128,8 +129,10 @@ type CacheConfig struct {
 	TrieDirtyLimit      int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
+	TrieAccessStats     bool          // Whether to collect per-block trie node access statistics
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	StateScheme         string        // Scheme used to store trie nodes on disk (hash or path)
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -167,6 +170,8 @@ type BlockChain struct {
 	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
+	persisted common.Hash // Root of the most recent state flushed entirely to disk
+
 	// txLookupLimit is the maximum number of blocks from head whose tx indices
 	// are reserved:
 	//  * 0:   means no limit and regenerate any missing indexes
@@ -181,6 +186,8 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
+	stateDiffFeed event.Feed
+	stateDiffSubs int32 // Number of active state diff subscriptions (atomic)
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -198,6 +205,7 @@ type BlockChain struct {
 	receiptsCache *lru.Cache     // Cache for the most recent receipts per block
 	blockCache    *lru.Cache     // Cache for the most recent entire blocks
 	txLookupCache *lru.Cache     // Cache for the most recent transaction lookup data.
+	trieStats     *lru.Cache     // Trie node access statistics of the most recently imported blocks
 	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
 
 	wg            sync.WaitGroup //
@@ -225,6 +233,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
+	trieStats, _ := lru.New(trieStatsCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 
 	bc := &BlockChain{
@@ -236,6 +245,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			Cache:     cacheConfig.TrieCleanLimit,
 			Journal:   cacheConfig.TrieCleanJournal,
 			Preimages: cacheConfig.Preimages,
+			Stats:     cacheConfig.TrieAccessStats,
+			Scheme:    cacheConfig.StateScheme,
 		}),
 		quit:          make(chan struct{}),
 		chainmu:       syncx.NewClosableMutex(),
@@ -244,6 +255,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		receiptsCache: receiptsCache,
 		blockCache:    blockCache,
 		txLookupCache: txLookupCache,
+		trieStats:     trieStats,
 		futureBlocks:  futureBlocks,
 		engine:        engine,
 		vmConfig:      vmConfig,
@@ -364,6 +376,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// The head state is now available on disk, track it as the persisted one
+	bc.persisted = bc.CurrentBlock().Root()
 
 	// Load any existing snapshot, regenerating it if loading failed
 	if bc.cacheConfig.SnapshotLimit > 0 {
@@ -832,7 +846,15 @@ func (bc *BlockChain) Stop() {
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
 	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-	if !bc.cacheConfig.TrieDirtyDisabled {
+	//
+	// The path-based scheme can only persist a single state, so flush the HEAD.
+	if triedb := bc.stateCache.TrieDB(); triedb.Scheme() == rawdb.PathScheme {
+		recent := bc.CurrentBlock()
+		log.Info("Writing cached state layers to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+		if err := triedb.Commit(recent.Root(), true, nil); err != nil {
+			log.Error("Failed to commit recent state layers", "err", err)
+		}
+	} else if !bc.cacheConfig.TrieDirtyDisabled {
 		triedb := bc.stateCache.TrieDB()
 
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
@@ -1235,8 +1257,15 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return err
 	}
+	bc.sendStateDiff(block, root)
+
 	triedb := bc.stateCache.TrieDB()
 
+	// If we're using the path-based scheme, keep the recent layers in memory
+	// and flatten the rest, overwriting the stale nodes on disk
+	if triedb.Scheme() == rawdb.PathScheme {
+		return triedb.CapLayers(root, TriesInMemory)
+	}
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
 		return triedb.Commit(root, false, nil)
@@ -1273,6 +1302,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 					// Flush an entire trie and restart the counters
 					triedb.Commit(header.Root, true, nil)
 					lastWrite = chosen
+					bc.persisted = header.Root
 					bc.gcproc = 0
 				}
 			}
@@ -1290,6 +1320,33 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return nil
 }
 
+// sendStateDiff publishes the state changes of a freshly written block, as held
+// by the snapshot diff layer created for it. Nothing is sent if nobody listens
+// or if snapshots are disabled. If the layer is not available (e.g. snapshot
+// being regenerated), an event without changes is sent to mark the gap.
+func (bc *BlockChain) sendStateDiff(block *types.Block, root common.Hash) {
+	if bc.snaps == nil || atomic.LoadInt32(&bc.stateDiffSubs) == 0 {
+		return
+	}
+	var diff *snapshot.StateDiff
+	if parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1); parent != nil && parent.Root == root {
+		// Empty transitions don't create a new layer (e.g. clique without txs)
+		diff = &snapshot.StateDiff{
+			Root:      root,
+			Parent:    root,
+			Destructs: []common.Hash{},
+			Accounts:  []snapshot.AccountDiff{},
+			Storage:   []snapshot.StorageDiff{},
+		}
+	} else {
+		var err error
+		if diff, err = bc.snaps.StateDiff(root); err != nil {
+			log.Warn("State diff unavailable", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}
+	bc.stateDiffFeed.Send(StateDiffEvent{Hash: block.Hash(), Number: block.NumberU64(), Diff: diff})
+}
+
 // WriteBlockAndSetHead writes the given block and all associated state to the database,
 // and applies the block as the new chain head.
 func (bc *BlockChain) WriteBlockAndSetHead(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
@@ -1605,6 +1662,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			return it.index, err
 		}
 
+		// Drop any trie node accesses not belonging to this block (e.g. RPC calls)
+		bc.stateCache.TrieDB().TakeAccessStats()
+
 		// Enable prefetching to pull in trie node paths while processing transactions
 		statedb.StartPrefetcher("chain")
 		activeState = statedb
@@ -1684,6 +1744,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 		blockWriteTimer.Update(time.Since(substart) - statedb.AccountCommits - statedb.StorageCommits - statedb.SnapshotCommits)
 		blockInsertTimer.UpdateSince(start)
 
+		// Stash away the trie node accesses of the block if tracking is enabled
+		if stats := bc.stateCache.TrieDB().TakeAccessStats(); stats != nil {
+			bc.trieStats.Add(block.Hash(), stats)
+		}
+
 		// Report the import stats before returning the various results
 		stats.processed++
 		stats.usedGas += usedGas
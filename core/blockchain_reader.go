@@ -18,6 +18,8 @@ package core
 
 import (
 	"math/big"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -29,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 // CurrentHeader retrieves the current head header of the canonical chain. The
@@ -340,6 +343,44 @@ func (bc *BlockChain) StateCache() state.Database {
 	return bc.stateCache
 }
 
+// RetainedStates returns the root of the most recent state flushed entirely to
+// disk, along with the roots of all the states which are still referenced from
+// the in-memory trie database, including the current head state. Together they
+// cover every trie node the chain may still need.
+func (bc *BlockChain) RetainedStates() (common.Hash, []common.Hash, error) {
+	if !bc.chainmu.TryLock() {
+		return common.Hash{}, nil, errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	var (
+		roots = []common.Hash{bc.CurrentBlock().Root()}
+		items []interface{}
+		prios []int64
+	)
+	for !bc.triegc.Empty() {
+		root, number := bc.triegc.Pop()
+		items, prios = append(items, root), append(prios, number)
+	}
+	for i, root := range items {
+		bc.triegc.Push(root, prios[i])
+		if root.(common.Hash) != roots[0] {
+			roots = append(roots, root.(common.Hash))
+		}
+	}
+	return bc.persisted, roots, nil
+}
+
+// TrieAccessStats retrieves the trie node access statistics collected while
+// importing the block with the given hash. Nil is returned if statistics are
+// not enabled or the block is not among the recently imported ones.
+func (bc *BlockChain) TrieAccessStats(hash common.Hash) *trie.AccessStats {
+	if stats, ok := bc.trieStats.Get(hash); ok {
+		return stats.(*trie.AccessStats)
+	}
+	return nil
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (bc *BlockChain) GasLimit() uint64 {
 	return bc.CurrentBlock().GasLimit()
@@ -387,6 +428,26 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeStateDiffEvent registers a subscription of StateDiffEvent. The state
+// diffs are only assembled while there are active subscriptions.
+func (bc *BlockChain) SubscribeStateDiffEvent(ch chan<- StateDiffEvent) event.Subscription {
+	atomic.AddInt32(&bc.stateDiffSubs, 1)
+	return bc.scope.Track(&countedSub{Subscription: bc.stateDiffFeed.Subscribe(ch), count: &bc.stateDiffSubs})
+}
+
+// countedSub is a subscription decrementing a counter of active subscriptions
+// when unsubscribed.
+type countedSub struct {
+	event.Subscription
+	count *int32
+	once  sync.Once
+}
+
+func (s *countedSub) Unsubscribe() {
+	s.once.Do(func() { atomic.AddInt32(s.count, -1) })
+	s.Subscription.Unsubscribe()
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
@@ -23,6 +23,7 @@ import (
 	"math/rand"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -3869,3 +3871,163 @@ func TestCanonicalHashMarker(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a chain using the path-based state scheme retains the recent states
+// in memory, flattens the older ones into the disk and persists the head state
+// across restarts.
+func TestPathSchemeChain(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		recv    = common.Address{0xaa}
+		funds   = big.NewInt(1000000000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 2*TriesInMemory, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), recv, big.NewInt(1), params.TxGas, block.header.BaseFee, nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign tx: %v", err)
+		}
+		block.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteStateScheme(db, rawdb.PathScheme)
+	gspec.MustCommit(db)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.StateScheme = rawdb.PathScheme
+
+	chain, err := NewBlockChain(db, &cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	head := chain.CurrentBlock()
+	if !chain.HasState(head.Root()) {
+		t.Fatalf("head state missing")
+	}
+	if !chain.HasState(blocks[TriesInMemory].Root()) {
+		t.Fatalf("recent state missing")
+	}
+	if chain.HasState(blocks[TriesInMemory-2].Root()) {
+		t.Fatalf("stale state not pruned")
+	}
+	chain.Stop()
+
+	// Reopen the chain and ensure the head state was persisted, without the
+	// genesis setup attempting to recommit the long gone genesis state
+	if _, _, err := SetupGenesisBlock(db, gspec); err != nil {
+		t.Fatalf("failed to set up genesis on restart: %v", err)
+	}
+	chain, err = NewBlockChain(db, &cacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if have := chain.CurrentBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", have, head.Hash())
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if have, want := statedb.GetBalance(recv), big.NewInt(2*TriesInMemory); have.Cmp(want) != 0 {
+		t.Fatalf("balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the state changes of every imported block are published from the
+// snapshot diff layers.
+func TestStateDiffEvents(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		recv    = common.Address{0xaa}
+		funds   = big.NewInt(1000000000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 4, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), recv, big.NewInt(1), params.TxGas, block.header.BaseFee, nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign tx: %v", err)
+		}
+		block.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, defaultCacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Blocks imported without subscribers are not reported
+	if n, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	diffs := make(chan StateDiffEvent, len(blocks))
+	sub := chain.SubscribeStateDiffEvent(diffs)
+	defer sub.Unsubscribe()
+
+	if subs := atomic.LoadInt32(&chain.stateDiffSubs); subs != 1 {
+		t.Fatalf("subscription count mismatch: have %d, want 1", subs)
+	}
+	genesis, blocks = blocks[0], blocks[1:]
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	parent := genesis.Root()
+	for _, block := range blocks {
+		ev := <-diffs
+		if ev.Hash != block.Hash() || ev.Number != block.NumberU64() {
+			t.Fatalf("block mismatch: have #%d [%x], want #%d [%x]", ev.Number, ev.Hash, block.NumberU64(), block.Hash())
+		}
+		if ev.Diff.Root != block.Root() || ev.Diff.Parent != parent {
+			t.Fatalf("block #%d: root mismatch: have %x->%x, want %x->%x", block.NumberU64(), ev.Diff.Parent, ev.Diff.Root, parent, block.Root())
+		}
+		// Sender, recipient and miner are modified by each block
+		if len(ev.Diff.Accounts) != 3 {
+			t.Fatalf("block #%d: modified account count mismatch: have %d, want 3", block.NumberU64(), len(ev.Diff.Accounts))
+		}
+		recvHash := crypto.Keccak256Hash(recv.Bytes())
+		var found bool
+		for _, acc := range ev.Diff.Accounts {
+			if acc.Hash == recvHash {
+				found = true
+				full, err := snapshot.FullAccount(acc.Data)
+				if err != nil {
+					t.Fatalf("block #%d: failed to decode account: %v", block.NumberU64(), err)
+				}
+				if balance := full.Balance; balance.Cmp(block.Number()) != 0 {
+					t.Fatalf("block #%d: recipient balance mismatch: have %v, want %v", block.NumberU64(), balance, block.Number())
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("block #%d: recipient not in diff", block.NumberU64())
+		}
+		parent = block.Root()
+	}
+	// Blocks whose changes are unavailable are reported as a gap
+	last := blocks[len(blocks)-1]
+	chain.sendStateDiff(last, common.Hash{0x01})
+	if ev := <-diffs; ev.Hash != last.Hash() || ev.Number != last.NumberU64() || ev.Diff != nil {
+		t.Fatalf("gap mismatch: have #%d [%x] diff %v, want #%d [%x] without diff", ev.Number, ev.Hash, ev.Diff, last.NumberU64(), last.Hash())
+	}
+	// Unsubscribing, even repeatedly, stops assembling the diffs
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if subs := atomic.LoadInt32(&chain.stateDiffSubs); subs != 0 {
+		t.Fatalf("subscription count mismatch: have %d, want 0", subs)
+	}
+}
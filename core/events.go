@@ -18,6 +18,7 @@ package core
 
 import (
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -41,3 +42,13 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// StateDiffEvent is posted when the state changes of a block have been written,
+// irrelevant of whether the block became canonical or not. If the changes could
+// not be assembled, Diff is nil, and consumers need to resync the state of the
+// block from elsewhere.
+type StateDiffEvent struct {
+	Hash   common.Hash         // Hash of the block producing the changes
+	Number uint64              // Number of the block producing the changes
+	Diff   *snapshot.StateDiff `rlp:"nil"` // State changes introduced by the block, nil if missing
+}
@@ -83,7 +83,7 @@ func (ga *GenesisAlloc) UnmarshalJSON(data []byte) error {
 // flush adds allocated genesis accounts into a fresh new statedb and
 // commit the state changes into the given database handler.
 func (ga *GenesisAlloc) flush(db ethdb.Database) (common.Hash, error) {
-	statedb, err := state.New(common.Hash{}, state.NewDatabase(db), nil)
+	statedb, err := state.New(common.Hash{}, state.NewDatabaseWithConfig(db, &trie.Config{Preimages: true, Scheme: rawdb.ReadStateScheme(db)}), nil)
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -216,6 +216,17 @@ func (e *GenesisMismatchError) Error() string {
 	return fmt.Sprintf("database contains incompatible genesis (have %x, new %x)", e.Stored, e.New)
 }
 
+// hasGenesisState reports whether the state of the stored genesis block is
+// available. The path-based scheme only retains the state of the chain head, so
+// there the genesis state is deemed present as soon as any state was persisted.
+func hasGenesisState(db ethdb.Database, root common.Hash) bool {
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return root == types.EmptyRootHash || rawdb.ReadAccountTrieNode(db, nil) != nil
+	}
+	_, err := state.New(root, state.NewDatabaseWithConfig(db, nil), nil)
+	return err == nil
+}
+
 // SetupGenesisBlock writes or updates the genesis block in db.
 // The block that will be used is:
 //
@@ -255,7 +266,7 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	// We have the genesis block in database(perhaps in ancient database)
 	// but the corresponding state is missing.
 	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
+	if !hasGenesisState(db, header.Root) {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
@@ -377,7 +388,7 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 		if g.BaseFee != nil {
 			head.BaseFee = g.BaseFee
 		} else {
-			head.BaseFee = new(big.Int).SetUint64(params.InitialBaseFee)
+			head.BaseFee = new(big.Int).SetUint64(g.Config.InitialBaseFee())
 		}
 	}
 	return types.NewBlock(head, nil, nil, nil, trie.NewStackTrie(nil))
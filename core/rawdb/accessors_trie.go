@@ -0,0 +1,144 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The list of schemes for persisting trie nodes in the database.
+const (
+	// HashScheme stores trie nodes keyed by their hash, with reference counted
+	// in-memory garbage collection. Stale nodes can only be removed by the
+	// offline pruner.
+	HashScheme = "hash"
+
+	// PathScheme stores trie nodes keyed by their owner and path, overwriting
+	// the stale version of a node with each newer one.
+	PathScheme = "path"
+)
+
+// ReadAccountTrieNode retrieves the account trie node with the specified node path.
+func ReadAccountTrieNode(db ethdb.KeyValueReader, path []byte) []byte {
+	data, _ := db.Get(accountTrieNodeKey(path))
+	return data
+}
+
+// HasAccountTrieNode checks the account trie node presence with the specified
+// node path.
+func HasAccountTrieNode(db ethdb.KeyValueReader, path []byte) bool {
+	ok, _ := db.Has(accountTrieNodeKey(path))
+	return ok
+}
+
+// WriteAccountTrieNode writes the provided account trie node into database.
+func WriteAccountTrieNode(db ethdb.KeyValueWriter, path []byte, node []byte) {
+	if err := db.Put(accountTrieNodeKey(path), node); err != nil {
+		log.Crit("Failed to store account trie node", "err", err)
+	}
+}
+
+// DeleteAccountTrieNode deletes the specified account trie node from the database.
+func DeleteAccountTrieNode(db ethdb.KeyValueWriter, path []byte) {
+	if err := db.Delete(accountTrieNodeKey(path)); err != nil {
+		log.Crit("Failed to delete account trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNode retrieves the storage trie node with the specified owner
+// and node path.
+func ReadStorageTrieNode(db ethdb.KeyValueReader, owner common.Hash, path []byte) []byte {
+	data, _ := db.Get(storageTrieNodeKey(owner, path))
+	return data
+}
+
+// WriteStorageTrieNode writes the provided storage trie node into database.
+func WriteStorageTrieNode(db ethdb.KeyValueWriter, owner common.Hash, path []byte, node []byte) {
+	if err := db.Put(storageTrieNodeKey(owner, path), node); err != nil {
+		log.Crit("Failed to store storage trie node", "err", err)
+	}
+}
+
+// DeleteStorageTrieNode deletes the specified storage trie node from the database.
+func DeleteStorageTrieNode(db ethdb.KeyValueWriter, owner common.Hash, path []byte) {
+	if err := db.Delete(storageTrieNodeKey(owner, path)); err != nil {
+		log.Crit("Failed to delete storage trie node", "err", err)
+	}
+}
+
+// DeleteStorageTrieNodes deletes all the storage trie nodes of the specified
+// owner found in the database, writing the deletions into the given batch. The
+// number of deleted nodes is returned.
+func DeleteStorageTrieNodes(db ethdb.Iteratee, batch ethdb.KeyValueWriter, owner common.Hash) int {
+	it := db.NewIterator(storageTrieNodeKey(owner, nil), nil)
+	defer it.Release()
+
+	var count int
+	for it.Next() {
+		if err := batch.Delete(it.Key()); err != nil {
+			log.Crit("Failed to delete storage trie node", "err", err)
+		}
+		count++
+	}
+	return count
+}
+
+// ReadStateScheme retrieves the trie node storage scheme the database was
+// initialized with, or an empty string if it was never recorded.
+func ReadStateScheme(db ethdb.KeyValueReader) string {
+	data, _ := db.Get(stateSchemeKey)
+	return string(data)
+}
+
+// WriteStateScheme stores the trie node storage scheme of the database.
+func WriteStateScheme(db ethdb.KeyValueWriter, scheme string) {
+	if err := db.Put(stateSchemeKey, []byte(scheme)); err != nil {
+		log.Crit("Failed to store state scheme", "err", err)
+	}
+}
+
+// ParseStateScheme checks the requested trie node storage scheme against the
+// one the database was initialized with and returns the scheme to use. An empty
+// request means "whatever the database has". Databases created before schemes
+// were recorded are treated as hash-based. A fresh database adopts the requested
+// scheme permanently, other databases are not written to, so read-only ones can
+// be checked too.
+func ParseStateScheme(provided string, disk ethdb.Database) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("unknown state scheme %q", provided)
+	}
+	stored := ReadStateScheme(disk)
+	if stored == "" {
+		if ReadCanonicalHash(disk, 0) != (common.Hash{}) {
+			stored = HashScheme // Legacy database, always hash based
+		} else {
+			stored = provided
+			if stored == "" {
+				stored = HashScheme
+			}
+			WriteStateScheme(disk, stored)
+		}
+	}
+	if provided != "" && provided != stored {
+		return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+	}
+	return stored, nil
+}
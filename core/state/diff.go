@@ -0,0 +1,429 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// DiffAccount is the content of an account in one of the diffed states.
+type DiffAccount struct {
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	Balance  *hexutil.Big   `json:"balance"`
+	Root     common.Hash    `json:"root"`
+	CodeHash common.Hash    `json:"codeHash"`
+}
+
+// SlotDiff is a storage slot which differs between two states. Created slots
+// have no old value, deleted ones have no new value.
+type SlotDiff struct {
+	Hash common.Hash  `json:"hash"`          // Hash of the slot key
+	Key  *common.Hash `json:"key,omitempty"` // Slot key, if the preimage is known
+	Old  *common.Hash `json:"old"`           // Value in the original state
+	New  *common.Hash `json:"new"`           // Value in the updated state
+}
+
+// AccountDiff is an account which differs between two states. Created accounts
+// have no old content, deleted ones have no new content.
+type AccountDiff struct {
+	Hash    common.Hash     `json:"hash"`              // Hash of the account address
+	Address *common.Address `json:"address,omitempty"` // Account address, if the preimage is known
+	Old     *DiffAccount    `json:"old"`               // Content in the original state
+	New     *DiffAccount    `json:"new"`               // Content in the updated state
+	Storage []SlotDiff      `json:"storage,omitempty"` // Modified storage slots, sorted by hash
+}
+
+// Diff is the set of differences between two arbitrary states.
+type Diff struct {
+	From     common.Hash   `json:"from"`     // Root of the original state
+	To       common.Hash   `json:"to"`       // Root of the updated state
+	Accounts []AccountDiff `json:"accounts"` // Modified accounts, sorted by hash
+}
+
+// diffCallback is invoked for each key whose value differs between two states,
+// with the raw values in the original and the updated state (nil if missing).
+type diffCallback func(hash common.Hash, prev, post []byte) error
+
+// differ is a data source able to enumerate the differences between two states.
+type differ interface {
+	// accounts invokes the callback for every account which differs between the
+	// two states, in no particular order.
+	accounts(fn diffCallback) error
+
+	// account retrieves the raw content of a single account in both states.
+	account(hash common.Hash) ([]byte, []byte, error)
+
+	// storage invokes the callback for every storage slot of the account which
+	// differs between the two storage roots, in no particular order.
+	storage(account common.Hash, from, to common.Hash, fn diffCallback) error
+
+	// decode converts a raw account into its diff representation.
+	decode(blob []byte) (*DiffAccount, error)
+}
+
+// DiffStates computes the differences between two states, including the storage
+// of every modified account. If addresses are specified, only those accounts are
+// compared, avoiding a walk of the entire account trie.
+//
+// The tries are walked in lockstep, skipping all the shared subtries. If any of
+// them is not available anymore, the snapshot tree is used instead, given that
+// both states are covered by it.
+func DiffStates(db Database, snaps *snapshot.Tree, from, to common.Hash, addresses []common.Address) (*Diff, error) {
+	diff, err := diffStates(&trieDiffer{db: db.TrieDB(), from: from, to: to}, db.TrieDB(), from, to, addresses)
+	if err == nil {
+		return diff, nil
+	}
+	var missing *trie.MissingNodeError
+	if !errors.As(err, &missing) || snaps == nil || snaps.Snapshot(from) == nil || snaps.Snapshot(to) == nil {
+		return nil, err
+	}
+	log.Debug("State tries unavailable, diffing snapshots", "from", from, "to", to, "err", err)
+	return diffStates(&snapDiffer{snaps: snaps, from: from, to: to}, db.TrieDB(), from, to, addresses)
+}
+
+// diffStates collects the differences between two states from the given source.
+func diffStates(d differ, triedb *trie.Database, from, to common.Hash, addresses []common.Address) (*Diff, error) {
+	var (
+		diffs = make(map[common.Hash]*AccountDiff)
+		known = make(map[common.Hash]common.Address)
+	)
+	onAccount := func(hash common.Hash, prev, post []byte) error {
+		if bytes.Equal(prev, post) {
+			return nil
+		}
+		acc := &AccountDiff{Hash: hash}
+		if addr, ok := known[hash]; ok {
+			acc.Address = &addr
+		} else if preimage := triedb.Preimage(hash); len(preimage) == common.AddressLength {
+			addr := common.BytesToAddress(preimage)
+			acc.Address = &addr
+		}
+		var err error
+		if prev != nil {
+			if acc.Old, err = d.decode(prev); err != nil {
+				return err
+			}
+		}
+		if post != nil {
+			if acc.New, err = d.decode(post); err != nil {
+				return err
+			}
+		}
+		diffs[hash] = acc
+		return nil
+	}
+	if len(addresses) == 0 {
+		if err := d.accounts(onAccount); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, addr := range addresses {
+			hash := crypto.Keccak256Hash(addr.Bytes())
+			known[hash] = addr
+
+			prev, post, err := d.account(hash)
+			if err != nil {
+				return nil, err
+			}
+			if err := onAccount(hash, prev, post); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Gather the storage changes of all the modified accounts
+	result := &Diff{From: from, To: to, Accounts: make([]AccountDiff, 0, len(diffs))}
+	for hash, acc := range diffs {
+		oldRoot, newRoot := emptyRoot, emptyRoot
+		if acc.Old != nil {
+			oldRoot = acc.Old.Root
+		}
+		if acc.New != nil {
+			newRoot = acc.New.Root
+		}
+		if oldRoot != newRoot {
+			err := d.storage(hash, oldRoot, newRoot, func(slot common.Hash, prev, post []byte) error {
+				if bytes.Equal(prev, post) {
+					return nil
+				}
+				change := SlotDiff{Hash: slot}
+				if preimage := triedb.Preimage(slot); len(preimage) == common.HashLength {
+					key := common.BytesToHash(preimage)
+					change.Key = &key
+				}
+				var err error
+				if change.Old, err = decodeSlot(prev); err != nil {
+					return err
+				}
+				if change.New, err = decodeSlot(post); err != nil {
+					return err
+				}
+				acc.Storage = append(acc.Storage, change)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(acc.Storage, func(i, j int) bool {
+				return bytes.Compare(acc.Storage[i].Hash[:], acc.Storage[j].Hash[:]) < 0
+			})
+		}
+		result.Accounts = append(result.Accounts, *acc)
+	}
+	sort.Slice(result.Accounts, func(i, j int) bool {
+		return bytes.Compare(result.Accounts[i].Hash[:], result.Accounts[j].Hash[:]) < 0
+	})
+	return result, nil
+}
+
+// decodeSlot converts a raw RLP encoded storage slot into its value.
+func decodeSlot(blob []byte) (*common.Hash, error) {
+	if blob == nil {
+		return nil, nil
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return nil, err
+	}
+	value := common.BytesToHash(content)
+	return &value, nil
+}
+
+// trieDiffer enumerates the differences between two states by iterating their
+// tries, descending only into the subtries which differ.
+type trieDiffer struct {
+	db       *trie.Database
+	from, to common.Hash
+}
+
+func (d *trieDiffer) accounts(fn diffCallback) error {
+	return diffTries(d.db, common.Hash{}, d.from, d.from, d.to, d.to, fn)
+}
+
+func (d *trieDiffer) account(hash common.Hash) ([]byte, []byte, error) {
+	from, err := trie.New(common.Hash{}, d.from, d.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := trie.New(common.Hash{}, d.to, d.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	prev, err := from.TryGet(hash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	post, err := to.TryGet(hash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, post, nil
+}
+
+func (d *trieDiffer) storage(account common.Hash, from, to common.Hash, fn diffCallback) error {
+	return diffTries(d.db, account, d.from, from, d.to, to, fn)
+}
+
+func (d *trieDiffer) decode(blob []byte) (*DiffAccount, error) {
+	var acc types.StateAccount
+	if err := rlp.DecodeBytes(blob, &acc); err != nil {
+		return nil, err
+	}
+	return &DiffAccount{
+		Nonce:    hexutil.Uint64(acc.Nonce),
+		Balance:  (*hexutil.Big)(acc.Balance),
+		Root:     acc.Root,
+		CodeHash: common.BytesToHash(acc.CodeHash),
+	}, nil
+}
+
+// diffTries invokes the callback for every leaf which differs between the two
+// tries. Two passes are needed since the difference iterator only reports the
+// nodes missing from one side: the first finds the created and modified leaves,
+// the second the deleted ones. The tries belong to the fromState and toState
+// states respectively.
+func diffTries(db *trie.Database, owner common.Hash, fromState, from, toState, to common.Hash, fn diffCallback) error {
+	fromTrie, err := trie.NewStorage(fromState, owner, from, db)
+	if err != nil {
+		return err
+	}
+	toTrie, err := trie.NewStorage(toState, owner, to, db)
+	if err != nil {
+		return err
+	}
+	it, _ := trie.NewDifferenceIterator(fromTrie.NodeIterator(nil), toTrie.NodeIterator(nil))
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		prev, err := fromTrie.TryGet(it.LeafKey())
+		if err != nil {
+			return err
+		}
+		if err := fn(common.BytesToHash(it.LeafKey()), prev, it.LeafBlob()); err != nil {
+			return err
+		}
+	}
+	if it.Error() != nil {
+		return it.Error()
+	}
+	it, _ = trie.NewDifferenceIterator(toTrie.NodeIterator(nil), fromTrie.NodeIterator(nil))
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		// Leaves still present were already reported by the first pass
+		blob, err := toTrie.TryGet(it.LeafKey())
+		if err != nil {
+			return err
+		}
+		if blob != nil {
+			continue
+		}
+		if err := fn(common.BytesToHash(it.LeafKey()), it.LeafBlob(), nil); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// snapDiffer enumerates the differences between two states by iterating their
+// snapshots in lockstep.
+type snapDiffer struct {
+	snaps    *snapshot.Tree
+	from, to common.Hash
+}
+
+func (d *snapDiffer) accounts(fn diffCallback) error {
+	from, err := d.snaps.AccountIterator(d.from, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer from.Release()
+
+	to, err := d.snaps.AccountIterator(d.to, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer to.Release()
+
+	return diffIterators(from, to, from.Account, to.Account, fn)
+}
+
+func (d *snapDiffer) account(hash common.Hash) ([]byte, []byte, error) {
+	prev, err := d.snaps.Snapshot(d.from).AccountRLP(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	post, err := d.snaps.Snapshot(d.to).AccountRLP(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Deleted accounts are reported as empty blobs, normalize them
+	if len(prev) == 0 {
+		prev = nil
+	}
+	if len(post) == 0 {
+		post = nil
+	}
+	return prev, post, nil
+}
+
+func (d *snapDiffer) storage(account common.Hash, _, _ common.Hash, fn diffCallback) error {
+	from, err := d.snaps.StorageIterator(d.from, account, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer from.Release()
+
+	to, err := d.snaps.StorageIterator(d.to, account, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer to.Release()
+
+	return diffIterators(from, to, from.Slot, to.Slot, fn)
+}
+
+func (d *snapDiffer) decode(blob []byte) (*DiffAccount, error) {
+	acc, err := snapshot.FullAccount(blob)
+	if err != nil {
+		return nil, err
+	}
+	root := emptyRoot
+	if len(acc.Root) > 0 {
+		root = common.BytesToHash(acc.Root)
+	}
+	codeHash := emptyCodeHash
+	if len(acc.CodeHash) > 0 {
+		codeHash = acc.CodeHash
+	}
+	return &DiffAccount{
+		Nonce:    hexutil.Uint64(acc.Nonce),
+		Balance:  (*hexutil.Big)(acc.Balance),
+		Root:     root,
+		CodeHash: common.BytesToHash(codeHash),
+	}, nil
+}
+
+// diffIterators merges two sorted snapshot iterators, invoking the callback for
+// every entry which is only present in one of them or differs between the two.
+func diffIterators(from, to snapshot.Iterator, fromValue, toValue func() []byte, fn diffCallback) error {
+	fromOk, toOk := from.Next(), to.Next()
+	for fromOk || toOk {
+		var cmp int
+		switch {
+		case !toOk:
+			cmp = -1
+		case !fromOk:
+			cmp = 1
+		default:
+			cmp = bytes.Compare(from.Hash().Bytes(), to.Hash().Bytes())
+		}
+		var err error
+		switch {
+		case cmp < 0:
+			err = fn(from.Hash(), common.CopyBytes(fromValue()), nil)
+			fromOk = from.Next()
+		case cmp > 0:
+			err = fn(to.Hash(), nil, common.CopyBytes(toValue()))
+			toOk = to.Next()
+		default:
+			if prev, post := fromValue(), toValue(); !bytes.Equal(prev, post) {
+				err = fn(from.Hash(), common.CopyBytes(prev), common.CopyBytes(post))
+			}
+			fromOk, toOk = from.Next(), to.Next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := from.Error(); err != nil {
+		return err
+	}
+	return to.Error()
+}
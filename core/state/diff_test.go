@@ -0,0 +1,172 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeDiffStates creates a base state on disk with a snapshot, and two further
+// states on top of it which only live in memory and in the snapshot diff layers.
+func makeDiffStates(t *testing.T) (ethdb.Database, Database, *snapshot.Tree, common.Hash, common.Hash) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		sdb    = NewDatabaseWithConfig(diskdb, &trie.Config{Preimages: true})
+	)
+	base, _ := New(common.Hash{}, sdb, nil)
+	base.SetBalance(common.Address{0x01}, big.NewInt(1))
+	base.SetState(common.Address{0x01}, common.Hash{0x01}, common.Hash{0x01})
+	root, err := base.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit base state: %v", err)
+	}
+	if err := sdb.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to flush base state: %v", err)
+	}
+	snaps, err := snapshot.New(diskdb, sdb.TrieDB(), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	state, _ := New(root, sdb, snaps)
+	state.SetBalance(common.Address{0x02}, big.NewInt(2))
+	state.SetState(common.Address{0x02}, common.Hash{0x01}, common.Hash{0x01})
+	state.SetNonce(common.Address{0x03}, 3)
+	from, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit original state: %v", err)
+	}
+	state, _ = New(from, sdb, snaps)
+	state.SetBalance(common.Address{0x01}, big.NewInt(10))                 // modified account
+	state.SetState(common.Address{0x02}, common.Hash{0x01}, common.Hash{}) // deleted slot
+	state.SetState(common.Address{0x02}, common.Hash{0x02}, common.Hash{0x02})
+	state.Suicide(common.Address{0x03})               // deleted account
+	state.SetCode(common.Address{0x04}, []byte{0x01}) // created account
+	state.SetState(common.Address{0x04}, common.Hash{0x01}, common.Hash{0x04})
+	to, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit updated state: %v", err)
+	}
+	return diskdb, sdb, snaps, from, to
+}
+
+// Tests that the differences between two states are collected from the tries,
+// including created, modified and deleted accounts and slots.
+func TestDiffStates(t *testing.T) {
+	_, sdb, _, from, to := makeDiffStates(t)
+
+	diff, err := DiffStates(sdb, nil, from, to, nil)
+	if err != nil {
+		t.Fatalf("failed to diff states: %v", err)
+	}
+	if diff.From != from || diff.To != to {
+		t.Fatalf("root mismatch: have %x->%x, want %x->%x", diff.From, diff.To, from, to)
+	}
+	changes := make(map[common.Address]AccountDiff)
+	for _, acc := range diff.Accounts {
+		if acc.Address == nil {
+			t.Fatalf("account %x: missing address preimage", acc.Hash)
+		}
+		changes[*acc.Address] = acc
+	}
+	if len(changes) != 4 {
+		t.Fatalf("modified account count mismatch: have %d, want %d", len(changes), 4)
+	}
+	if acc := changes[common.Address{0x01}]; acc.Old.Balance.ToInt().Uint64() != 1 || acc.New.Balance.ToInt().Uint64() != 10 || len(acc.Storage) != 0 {
+		t.Errorf("modified account mismatch: %+v", acc)
+	}
+	if acc := changes[common.Address{0x03}]; acc.Old == nil || acc.New != nil {
+		t.Errorf("deleted account mismatch: %+v", acc)
+	}
+	if acc := changes[common.Address{0x04}]; acc.Old != nil || acc.New == nil || len(acc.Storage) != 1 || acc.Storage[0].Old != nil || *acc.Storage[0].New != (common.Hash{0x04}) {
+		t.Errorf("created account mismatch: %+v", acc)
+	}
+	acc := changes[common.Address{0x02}]
+	if len(acc.Storage) != 2 {
+		t.Fatalf("modified slot count mismatch: have %d, want %d", len(acc.Storage), 2)
+	}
+	for _, slot := range acc.Storage {
+		switch *slot.Key {
+		case common.Hash{0x01}:
+			if *slot.Old != (common.Hash{0x01}) || slot.New != nil {
+				t.Errorf("deleted slot mismatch: %+v", slot)
+			}
+		case common.Hash{0x02}:
+			if slot.Old != nil || *slot.New != (common.Hash{0x02}) {
+				t.Errorf("created slot mismatch: %+v", slot)
+			}
+		default:
+			t.Errorf("unexpected slot: %+v", slot)
+		}
+	}
+	// Ensure the address filter restricts the compared accounts
+	filtered, err := DiffStates(sdb, nil, from, to, []common.Address{{0x02}, {0x05}})
+	if err != nil {
+		t.Fatalf("failed to diff filtered states: %v", err)
+	}
+	if len(filtered.Accounts) != 1 || !reflect.DeepEqual(filtered.Accounts[0], acc) {
+		t.Fatalf("filtered diff mismatch: have %+v, want %+v", filtered.Accounts, acc)
+	}
+	// Ensure the reverse diff swaps the old and new values
+	reverse, err := DiffStates(sdb, nil, to, from, nil)
+	if err != nil {
+		t.Fatalf("failed to diff reverse states: %v", err)
+	}
+	for i, acc := range reverse.Accounts {
+		if !reflect.DeepEqual(acc.Old, diff.Accounts[i].New) || !reflect.DeepEqual(acc.New, diff.Accounts[i].Old) {
+			t.Errorf("account %x: reverse diff mismatch", acc.Hash)
+		}
+	}
+}
+
+// Tests that the snapshot is used to diff the states if their tries are not
+// available, producing the same results.
+func TestDiffStatesSnapshotFallback(t *testing.T) {
+	diskdb, sdb, snaps, from, to := makeDiffStates(t)
+
+	want, err := DiffStates(sdb, snaps, from, to, nil)
+	if err != nil {
+		t.Fatalf("failed to diff states: %v", err)
+	}
+	// Drop the in-memory tries, only leaving the base state on disk
+	if _, err := DiffStates(NewDatabase(diskdb), nil, from, to, nil); err == nil {
+		t.Fatalf("diffed states without tries or snapshot")
+	}
+	have, err := DiffStates(NewDatabase(diskdb), snaps, from, to, nil)
+	if err != nil {
+		t.Fatalf("failed to diff snapshots: %v", err)
+	}
+	// Preimages of the new states are not flushed, ignore them
+	for _, diff := range []*Diff{want, have} {
+		for i := range diff.Accounts {
+			diff.Accounts[i].Address = nil
+			for j := range diff.Accounts[i].Storage {
+				diff.Accounts[i].Storage[j].Key = nil
+			}
+		}
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("snapshot diff mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+}
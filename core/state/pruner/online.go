@@ -0,0 +1,414 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	onlineMarkTimer       = metrics.NewRegisteredResettingTimer("pruner/online/mark/time", nil)
+	onlineMarkNodesMeter  = metrics.NewRegisteredMeter("pruner/online/mark/nodes", nil)
+	onlineSweepTimer      = metrics.NewRegisteredResettingTimer("pruner/online/sweep/time", nil)
+	onlineSweepNodesMeter = metrics.NewRegisteredMeter("pruner/online/sweep/nodes", nil)
+	onlineSweepSizeMeter  = metrics.NewRegisteredMeter("pruner/online/sweep/size", nil)
+	onlineProgressGauge   = metrics.NewRegisteredGauge("pruner/online/progress", nil)
+)
+
+// errTerminated is returned if the online pruner is stopped mid-cycle.
+var errTerminated = errors.New("pruning terminated")
+
+// Chain defines the subset of the blockchain the online pruner requires to find
+// the states which must be retained.
+type Chain interface {
+	// StateCache returns the caching database underpinning the blockchain.
+	StateCache() state.Database
+
+	// RetainedStates returns the root of the most recent state flushed entirely
+	// to disk, along with the roots of all the states kept in memory.
+	RetainedStates() (common.Hash, []common.Hash, error)
+}
+
+// OnlineConfig contains the settings of the online pruner.
+type OnlineConfig struct {
+	BloomSize  uint64        // Megabytes of memory allocated to the live node bloom filter
+	BatchSize  int           // Number of database entries examined in a single sweep batch
+	BatchDelay time.Duration // Pause between two consecutive sweep batches
+	Interval   time.Duration // Pause between the end of a pruning cycle and the start of the next
+}
+
+// DefaultOnlineConfig contains the default settings for online pruning.
+var DefaultOnlineConfig = OnlineConfig{
+	BloomSize:  2048,
+	BatchSize:  10000,
+	BatchDelay: 100 * time.Millisecond,
+	Interval:   6 * time.Hour,
+}
+
+// OnlinePruner is a background service removing stale state from a hash based
+// database while the node keeps importing blocks. Each pruning cycle consists of
+// two phases:
+//
+//   - mark all the trie nodes and contract codes reachable from the most recently
+//     persisted state, the states held in memory and the genesis in a bloom filter
+//   - iterate the database in small batches, deleting every hash keyed entry not
+//     contained in the bloom filter
+//
+// Nodes flushed from the trie database while a cycle is running are added to the
+// bloom filter before they reach the disk, so freshly written state is never
+// swept. False positives of the bloom only cause some stale nodes to survive
+// until the next cycle.
+type OnlinePruner struct {
+	db     ethdb.Database
+	chain  Chain
+	config OnlineConfig
+	paused func() bool // Reports whether pruning must be postponed (e.g. snap sync)
+
+	bloom *stateBloom // Live set of the running cycle, nil if none is running
+	lock  sync.Mutex  // Lock serializing sweeps with trie node flushes
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewOnlinePruner creates a background state pruner. The optional paused callback
+// is consulted before each cycle to postpone pruning while the database is being
+// populated by other means than block imports, such as snap sync.
+func NewOnlinePruner(db ethdb.Database, chain Chain, config OnlineConfig, paused func() bool) (*OnlinePruner, error) {
+	if scheme := chain.StateCache().TrieDB().Scheme(); scheme != rawdb.HashScheme {
+		return nil, errors.New("online pruning is only supported by the hash scheme")
+	}
+	return &OnlinePruner{
+		db:     db,
+		chain:  chain,
+		config: config,
+		paused: paused,
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Start launches the background pruning loop.
+func (p *OnlinePruner) Start() {
+	p.wg.Add(1)
+	go p.loop()
+}
+
+// Stop terminates the background pruning, aborting any running cycle.
+func (p *OnlinePruner) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// loop runs a pruning cycle every configured interval until terminated.
+func (p *OnlinePruner) loop() {
+	defer p.wg.Done()
+
+	timer := time.NewTimer(p.config.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if p.paused != nil && p.paused() {
+				log.Debug("Online state pruning postponed")
+			} else if err := p.Prune(); err != nil && err != errTerminated {
+				log.Warn("Online state pruning failed", "err", err)
+			}
+			timer.Reset(p.config.Interval)
+
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// Prune runs a single mark and sweep cycle. It's safe to call concurrently with
+// block imports, but not with another pruning cycle.
+func (p *OnlinePruner) Prune() error {
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	triedb := p.chain.StateCache().TrieDB()
+
+	// Start tracking flushed nodes before collecting the retained states, so
+	// that nodes persisted from now on are either part of the retained states
+	// or caught by the hook.
+	p.lock.Lock()
+	p.bloom = bloom
+	p.lock.Unlock()
+	triedb.SetFlushHook(p.markFlushed)
+
+	defer func() {
+		triedb.SetFlushHook(nil)
+
+		p.lock.Lock()
+		p.bloom = nil
+		p.lock.Unlock()
+	}()
+	persisted, retained, err := p.chain.RetainedStates()
+	if err != nil {
+		return err
+	}
+	// Mark the in-memory states first as they are short lived, only walking the
+	// parts which differ from the persisted state. Afterwards mark the persisted
+	// state, which is safe on disk until we start sweeping.
+	var (
+		start  = time.Now()
+		marked int
+	)
+	log.Info("Marking live state", "persisted", persisted, "retained", len(retained))
+	for _, root := range retained {
+		nodes, err := p.markState(triedb, bloom, root, persisted)
+		if err != nil {
+			return err
+		}
+		marked += nodes
+	}
+	nodes, err := p.markState(triedb, bloom, persisted, common.Hash{})
+	if err != nil {
+		return err
+	}
+	marked += nodes
+
+	if err := extractGenesis(p.db, bloom); err != nil {
+		return err
+	}
+	onlineMarkTimer.UpdateSince(start)
+	log.Info("Marked live state", "nodes", marked, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Sweep all the hash keyed entries not marked live
+	start = time.Now()
+	swept, size, err := p.sweep()
+	if err != nil {
+		return err
+	}
+	onlineSweepTimer.UpdateSince(start)
+	log.Info("Pruned stale state", "nodes", swept, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// markFlushed is the flush hook of the trie database, adding every node about
+// to be persisted to the live set of the running cycle.
+func (p *OnlinePruner) markFlushed(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.bloom != nil {
+		p.bloom.Put(hash.Bytes(), nil)
+	}
+}
+
+// markState adds all the trie nodes and contract codes of the state with the
+// given root to the live set. If a base state is specified, only the parts which
+// differ from it are walked, the rest being marked as part of the base.
+func (p *OnlinePruner) markState(triedb *trie.Database, bloom *stateBloom, root, base common.Hash) (int, error) {
+	if root == base {
+		return 0, nil
+	}
+	accTrie, err := trie.New(common.Hash{}, root, triedb)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		baseTrie *trie.Trie
+		iter     = accTrie.NodeIterator(nil)
+		nodes    int
+	)
+	if base != (common.Hash{}) {
+		if baseTrie, err = trie.New(common.Hash{}, base, triedb); err != nil {
+			return 0, err
+		}
+		iter, _ = trie.NewDifferenceIterator(baseTrie.NodeIterator(nil), iter)
+	}
+	for iter.Next(true) {
+		if hash := iter.Hash(); hash != (common.Hash{}) {
+			bloom.Put(hash.Bytes(), nil)
+			if nodes++; nodes%10000 == 0 {
+				onlineMarkNodesMeter.Mark(10000)
+				select {
+				case <-p.quit:
+					return nodes, errTerminated
+				default:
+				}
+			}
+		}
+		if !iter.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(iter.LeafBlob(), &acc); err != nil {
+			return nodes, err
+		}
+		// Only walk the storage changes if the account existed in the base
+		baseStorage := emptyRoot
+		if baseTrie != nil {
+			blob, err := baseTrie.TryGet(iter.LeafKey())
+			if err != nil {
+				return nodes, err
+			}
+			if len(blob) > 0 {
+				var prev types.StateAccount
+				if err := rlp.DecodeBytes(blob, &prev); err != nil {
+					return nodes, err
+				}
+				baseStorage = prev.Root
+			}
+		}
+		if acc.Root != emptyRoot && acc.Root != baseStorage {
+			owner := common.BytesToHash(iter.LeafKey())
+			n, err := p.markStorage(triedb, bloom, owner, acc.Root, baseStorage)
+			nodes += n
+			if err != nil {
+				return nodes, err
+			}
+		}
+		if !bytes.Equal(acc.CodeHash, emptyCode) {
+			bloom.Put(acc.CodeHash, nil)
+		}
+	}
+	onlineMarkNodesMeter.Mark(int64(nodes % 10000))
+	return nodes, iter.Error()
+}
+
+// markStorage adds all the nodes of a storage trie to the live set, skipping the
+// subtries shared with the base version of the same storage.
+func (p *OnlinePruner) markStorage(triedb *trie.Database, bloom *stateBloom, owner, root, base common.Hash) (int, error) {
+	tr, err := trie.New(owner, root, triedb)
+	if err != nil {
+		return 0, err
+	}
+	iter := tr.NodeIterator(nil)
+	if base != emptyRoot {
+		baseTrie, err := trie.New(owner, base, triedb)
+		if err != nil {
+			return 0, err
+		}
+		iter, _ = trie.NewDifferenceIterator(baseTrie.NodeIterator(nil), iter)
+	}
+	var nodes int
+	for iter.Next(true) {
+		if hash := iter.Hash(); hash != (common.Hash{}) {
+			bloom.Put(hash.Bytes(), nil)
+			nodes++
+		}
+	}
+	return nodes, iter.Error()
+}
+
+// sweep iterates the entire key-value store in rate limited batches, deleting
+// every hash keyed entry which is not part of the live set.
+func (p *OnlinePruner) sweep() (int, common.StorageSize, error) {
+	var (
+		start []byte
+		count int
+		size  common.StorageSize
+	)
+	for {
+		next, n, s, err := p.sweepBatch(start)
+		if err != nil {
+			return count, size, err
+		}
+		count, size = count+n, size+s
+		onlineSweepNodesMeter.Mark(int64(n))
+		onlineSweepSizeMeter.Mark(int64(s))
+
+		if next == nil {
+			onlineProgressGauge.Update(100)
+			return count, size, nil
+		}
+		start = next
+		onlineProgressGauge.Update(int64(sweepProgress(start)))
+
+		select {
+		case <-time.After(p.config.BatchDelay):
+		case <-p.quit:
+			return count, size, errTerminated
+		}
+	}
+}
+
+// sweepBatch deletes the stale entries among the next batch of database keys
+// starting at the given position. The position to continue from is returned,
+// or nil if the end of the database was reached.
+//
+// The batch is examined and deleted while holding the lock, so that a node about
+// to be flushed is either marked before the bloom is checked, or written after
+// its stale version has been deleted.
+func (p *OnlinePruner) sweepBatch(start []byte) ([]byte, int, common.StorageSize, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		it    = p.db.NewIterator(nil, start)
+		batch = p.db.NewBatch()
+		count int
+		size  common.StorageSize
+		next  []byte
+	)
+	defer it.Release()
+
+	for examined := 0; it.Next(); examined++ {
+		if examined == p.config.BatchSize {
+			next = common.CopyBytes(it.Key())
+			break
+		}
+		key := it.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		if ok, _ := p.bloom.Contain(key); ok {
+			continue
+		}
+		batch.Delete(key)
+		count++
+		size += common.StorageSize(len(key) + len(it.Value()))
+	}
+	if err := it.Error(); err != nil {
+		return nil, 0, 0, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, 0, 0, err
+	}
+	return next, count, size, nil
+}
+
+// sweepProgress approximates the percentage of the keyspace swept, based on the
+// first two bytes of the next key to process.
+func sweepProgress(key []byte) int {
+	var pos uint64
+	for i := 0; i < 2; i++ {
+		pos <<= 8
+		if i < len(key) {
+			pos |= uint64(key[i])
+		}
+	}
+	return int(pos * 100 / 65536)
+}
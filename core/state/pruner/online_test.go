@@ -0,0 +1,168 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// verifyState iterates over the entire state with the given root, ensuring all
+// the account and storage trie nodes are available.
+func verifyState(t *testing.T, db *trie.Database, root common.Hash) {
+	accTrie, err := trie.New(common.Hash{}, root, db)
+	if err != nil {
+		t.Fatalf("state %x: failed to open account trie: %v", root, err)
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(nil))
+	for it.Next() {
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			t.Fatalf("state %x: invalid account: %v", root, err)
+		}
+		storageTrie, err := trie.New(common.BytesToHash(it.Key), acc.Root, db)
+		if err != nil {
+			t.Fatalf("state %x: failed to open storage trie: %v", root, err)
+		}
+		sit := trie.NewIterator(storageTrie.NodeIterator(nil))
+		for sit.Next() {
+		}
+		if sit.Err != nil {
+			t.Fatalf("state %x: storage iteration failed: %v", root, sit.Err)
+		}
+	}
+	if it.Err != nil {
+		t.Fatalf("state %x: account iteration failed: %v", root, it.Err)
+	}
+}
+
+// importingChain is a blockchain which imports a batch of blocks right after the
+// retained states are collected, simulating the chain progressing mid-cycle.
+type importingChain struct {
+	*core.BlockChain
+	blocks types.Blocks
+	err    error
+}
+
+func (c *importingChain) RetainedStates() (common.Hash, []common.Hash, error) {
+	persisted, retained, err := c.BlockChain.RetainedStates()
+	if err == nil {
+		_, c.err = c.InsertChain(c.blocks)
+	}
+	return persisted, retained, err
+}
+
+// Tests that the online pruner deletes stale state while blocks are imported,
+// without damaging any of the states the chain still relies on.
+func TestOnlinePruning(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.Address{0xcc}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				address:  {Balance: big.NewInt(1000000000000000)},
+				contract: {Balance: common.Big0, Code: []byte{byte(vm.NUMBER), byte(vm.NUMBER), byte(vm.SSTORE)}},
+			},
+		}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 3*core.TriesInMemory, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), contract, big.NewInt(1), 50000, block.BaseFee(), nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign tx: %v", err)
+		}
+		block.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	// Flush all dirty nodes after each block to produce plenty of stale state
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieCleanLimit: 16, TrieTimeLimit: time.Hour}, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks[:2*core.TriesInMemory]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	stale := blocks[0].Root()
+	if !rawdb.HasTrieNode(db, stale) {
+		t.Fatalf("stale state not flushed")
+	}
+	junk := crypto.Keccak256Hash([]byte("junk"))
+	rawdb.WriteTrieNode(db, junk, []byte{0x01})
+
+	// Prune the state while importing the rest of the chain
+	importer := &importingChain{BlockChain: chain, blocks: blocks[2*core.TriesInMemory:]}
+	pruner, err := NewOnlinePruner(db, importer, OnlineConfig{BloomSize: 1, BatchSize: 64}, nil)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if importer.err != nil {
+		t.Fatalf("failed to import blocks: %v", importer.err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 3*core.TriesInMemory {
+		t.Fatalf("chain head mismatch: have %d, want %d", head, 3*core.TriesInMemory)
+	}
+	if rawdb.HasTrieNode(db, stale) {
+		t.Errorf("stale state root not pruned")
+	}
+	if rawdb.HasTrieNode(db, junk) {
+		t.Errorf("junk node not pruned")
+	}
+	// Ensure all the retained states, the genesis and the code are intact
+	_, retained, err := chain.RetainedStates()
+	if err != nil {
+		t.Fatalf("failed to retrieve retained states: %v", err)
+	}
+	if len(retained) != core.TriesInMemory {
+		t.Fatalf("retained state count mismatch: have %d, want %d", len(retained), core.TriesInMemory)
+	}
+	for _, root := range append(retained, genesis.Root()) {
+		verifyState(t, chain.StateCache().TrieDB(), root)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if len(statedb.GetCode(contract)) == 0 {
+		t.Fatalf("contract code missing")
+	}
+	if have, want := statedb.GetState(contract, common.BigToHash(big.NewInt(3*core.TriesInMemory))), common.BigToHash(big.NewInt(3*core.TriesInMemory)); have != want {
+		t.Fatalf("storage mismatch: have %x, want %x", have, want)
+	}
+}
@@ -85,6 +85,9 @@ type Pruner struct {
 
 // NewPruner creates the pruner instance.
 func NewPruner(db ethdb.Database, datadir, trieCachePath string, bloomSize uint64) (*Pruner, error) {
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errors.New("path-based state is pruned online, offline pruning not needed")
+	}
 	headBlock := rawdb.ReadHeadBlock(db)
 	if headBlock == nil {
 		return nil, errors.New("Failed to load head block")
@@ -0,0 +1,115 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// AccountDiff is a single account modified by a state transition.
+type AccountDiff struct {
+	Hash common.Hash   `json:"hash"` // Hash of the account address
+	Data hexutil.Bytes `json:"data"` // Slim RLP encoded account
+}
+
+// SlotDiff is a single storage slot modified by a state transition.
+type SlotDiff struct {
+	Hash  common.Hash   `json:"hash"`  // Hash of the storage slot key
+	Value hexutil.Bytes `json:"value"` // RLP encoded slot value, empty if deleted
+}
+
+// StorageDiff is the set of storage slots of an account modified by a state
+// transition.
+type StorageDiff struct {
+	Account common.Hash `json:"account"` // Hash of the account address
+	Slots   []SlotDiff  `json:"slots"`   // Modified slots, sorted by hash
+}
+
+// StateDiff is the compact set of state changes introduced by a single snapshot
+// diff layer. All the entries are keyed by hash and sorted, making the diff both
+// deterministic and directly RLP encodable.
+//
+// Note, deleted accounts are only listed among the destructed ones. An account
+// might also be deleted and then recreated within the same transition, in which
+// case it's both destructed and modified: all its previous storage is gone, and
+// the account and storage diffs belong to the new incarnation.
+type StateDiff struct {
+	Root      common.Hash   `json:"root"`      // State root after the transition
+	Parent    common.Hash   `json:"parent"`    // State root before the transition
+	Destructs []common.Hash `json:"destructs"` // Hashes of the destructed accounts, sorted
+	Accounts  []AccountDiff `json:"accounts"`  // Modified accounts, sorted by hash
+	Storage   []StorageDiff `json:"storage"`   // Modified storage, sorted by account hash
+}
+
+// StateDiff retrieves the state changes introduced by the diff layer with the
+// given root. An error is returned if the layer is unknown or if it's the disk
+// layer, which doesn't track the changes that led to it.
+func (t *Tree) StateDiff(root common.Hash) (*StateDiff, error) {
+	snap := t.Snapshot(root)
+	if snap == nil {
+		return nil, fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		return nil, fmt.Errorf("snapshot [%#x] is not a diff layer", root)
+	}
+	return diff.stateDiff(), nil
+}
+
+// stateDiff assembles the sorted set of changes contained in the diff layer.
+func (dl *diffLayer) stateDiff() *StateDiff {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	diff := &StateDiff{
+		Root:      dl.root,
+		Parent:    dl.parent.Root(),
+		Destructs: make([]common.Hash, 0, len(dl.destructSet)),
+		Accounts:  make([]AccountDiff, 0, len(dl.accountData)),
+		Storage:   make([]StorageDiff, 0, len(dl.storageData)),
+	}
+	for hash := range dl.destructSet {
+		diff.Destructs = append(diff.Destructs, hash)
+	}
+	sort.Sort(hashes(diff.Destructs))
+
+	for hash, data := range dl.accountData {
+		diff.Accounts = append(diff.Accounts, AccountDiff{Hash: hash, Data: data})
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool {
+		return bytes.Compare(diff.Accounts[i].Hash[:], diff.Accounts[j].Hash[:]) < 0
+	})
+	for account, slots := range dl.storageData {
+		storage := StorageDiff{Account: account, Slots: make([]SlotDiff, 0, len(slots))}
+		for hash, value := range slots {
+			storage.Slots = append(storage.Slots, SlotDiff{Hash: hash, Value: value})
+		}
+		sort.Slice(storage.Slots, func(i, j int) bool {
+			return bytes.Compare(storage.Slots[i].Hash[:], storage.Slots[j].Hash[:]) < 0
+		})
+		diff.Storage = append(diff.Storage, storage)
+	}
+	sort.Slice(diff.Storage, func(i, j int) bool {
+		return bytes.Compare(diff.Storage[i].Account[:], diff.Storage[j].Account[:]) < 0
+	})
+	return diff
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that the state diff of a layer contains exactly its own changes in a
+// sorted order, and that it survives an RLP round trip.
+func TestStateDiff(t *testing.T) {
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	if err := snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"), nil, randomAccountSet("0xa1", "0xa2"), nil); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	var (
+		destructs = map[common.Hash]struct{}{common.HexToHash("0xa2"): {}}
+		accounts  = map[common.Hash][]byte{
+			common.HexToHash("0xa3"): randomAccount(),
+			common.HexToHash("0xa1"): randomAccount(),
+		}
+		storage = map[common.Hash]map[common.Hash][]byte{
+			common.HexToHash("0xa3"): {common.HexToHash("0xb2"): {0x02}, common.HexToHash("0xb1"): {0x01}},
+			common.HexToHash("0xa1"): {common.HexToHash("0xb1"): nil},
+		}
+	)
+	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), destructs, accounts, storage); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	if _, err := snaps.StateDiff(common.HexToHash("0x01")); err == nil {
+		t.Fatalf("state diff of the disk layer retrieved")
+	}
+	if _, err := snaps.StateDiff(common.HexToHash("0x04")); err == nil {
+		t.Fatalf("state diff of an unknown layer retrieved")
+	}
+	diff, err := snaps.StateDiff(common.HexToHash("0x03"))
+	if err != nil {
+		t.Fatalf("failed to retrieve state diff: %v", err)
+	}
+	want := &StateDiff{
+		Root:      common.HexToHash("0x03"),
+		Parent:    common.HexToHash("0x02"),
+		Destructs: []common.Hash{common.HexToHash("0xa2")},
+		Accounts: []AccountDiff{
+			{Hash: common.HexToHash("0xa1"), Data: accounts[common.HexToHash("0xa1")]},
+			{Hash: common.HexToHash("0xa3"), Data: accounts[common.HexToHash("0xa3")]},
+		},
+		Storage: []StorageDiff{
+			{Account: common.HexToHash("0xa1"), Slots: []SlotDiff{{Hash: common.HexToHash("0xb1"), Value: nil}}},
+			{Account: common.HexToHash("0xa3"), Slots: []SlotDiff{
+				{Hash: common.HexToHash("0xb1"), Value: []byte{0x01}},
+				{Hash: common.HexToHash("0xb2"), Value: []byte{0x02}},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("state diff mismatch:\nhave %+v\nwant %+v", diff, want)
+	}
+	blob, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		t.Fatalf("failed to encode state diff: %v", err)
+	}
+	var dec StateDiff
+	if err := rlp.DecodeBytes(blob, &dec); err != nil {
+		t.Fatalf("failed to decode state diff: %v", err)
+	}
+	if dec.Root != diff.Root || dec.Parent != diff.Parent || len(dec.Accounts) != len(diff.Accounts) || len(dec.Storage) != len(diff.Storage) {
+		t.Fatalf("state diff round trip mismatch: have %+v, want %+v", dec, diff)
+	}
+	for i, acc := range dec.Accounts {
+		if acc.Hash != diff.Accounts[i].Hash || !bytes.Equal(acc.Data, diff.Accounts[i].Data) {
+			t.Fatalf("account %d round trip mismatch: have %+v, want %+v", i, acc, diff.Accounts[i])
+		}
+	}
+}
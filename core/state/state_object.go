@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -158,9 +159,9 @@ func (s *stateObject) getTrie(db Database) Trie {
 		}
 		if s.trie == nil {
 			var err error
-			s.trie, err = db.OpenStorageTrie(s.addrHash, s.data.Root)
+			s.trie, err = db.OpenStorageTrie(s.db.originalRoot, s.addrHash, s.data.Root)
 			if err != nil {
-				s.trie, _ = db.OpenStorageTrie(s.addrHash, common.Hash{})
+				s.trie, _ = db.OpenStorageTrie(s.db.originalRoot, s.addrHash, common.Hash{})
 				s.setError(fmt.Errorf("can't create storage trie: %v", err))
 			}
 		}
@@ -390,6 +391,13 @@ func (s *stateObject) CommitTrie(db Database) (int, error) {
 	root, committed, err := s.trie.Commit(nil)
 	if err == nil {
 		s.data.Root = root
+
+		// The path-based scheme resolves the nodes of a storage trie against the
+		// state it belongs to, which the committed trie doesn't know about. Drop
+		// it to have it reopened within the new state on the next access.
+		if db.TrieDB().Scheme() == rawdb.PathScheme {
+			s.trie = nil
+		}
 	}
 	return committed, err
 }
@@ -32,6 +32,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -695,6 +697,68 @@ func TestDeleteCreateRevert(t *testing.T) {
 	}
 }
 
+// Tests that the storage of the destructed accounts is wiped from a path-based
+// database, while the storage of the accounts resurrected within the same block
+// or whose overwrite was reverted survives.
+func TestDestructStorageWipe(t *testing.T) {
+	diskdb := rawdb.NewMemoryDatabase()
+	db := NewDatabaseWithConfig(diskdb, &trie.Config{Scheme: rawdb.PathScheme})
+	state, _ := New(common.Hash{}, db, nil)
+
+	var (
+		destructed  = common.BytesToAddress([]byte("destructed"))
+		resurrected = common.BytesToAddress([]byte("resurrected"))
+		reverted    = common.BytesToAddress([]byte("reverted"))
+	)
+	for _, addr := range []common.Address{destructed, resurrected, reverted} {
+		state.SetNonce(addr, 1)
+		state.SetState(addr, common.Hash{0x01}, common.Hash{0x01})
+	}
+	root, _ := state.Commit(false)
+	state, _ = New(root, db, nil)
+
+	// Destruct two accounts, resurrecting one of them with new storage in the
+	// next transaction, and overwrite the third one in a reverted transaction
+	state.Suicide(destructed)
+	state.Suicide(resurrected)
+	state.Finalise(true)
+
+	state.CreateAccount(resurrected)
+	state.SetNonce(resurrected, 1)
+	state.SetState(resurrected, common.Hash{0x02}, common.Hash{0x02})
+
+	id := state.Snapshot()
+	state.CreateAccount(reverted)
+	state.RevertToSnapshot(id)
+
+	root, err := state.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.TrieDB().CapLayers(root, 0); err != nil {
+		t.Fatalf("failed to flatten state: %v", err)
+	}
+	it := diskdb.NewIterator(append(rawdb.TrieNodeStoragePrefix, crypto.Keccak256(destructed[:])...), nil)
+	for it.Next() {
+		t.Errorf("stale storage trie node left on disk: %x", it.Key())
+	}
+	it.Release()
+
+	state, _ = New(root, NewDatabaseWithConfig(diskdb, &trie.Config{Scheme: rawdb.PathScheme}), nil)
+	if have := state.GetState(resurrected, common.Hash{0x01}); have != (common.Hash{}) {
+		t.Errorf("resurrected account kept its old storage: %x", have)
+	}
+	if have := state.GetState(resurrected, common.Hash{0x02}); have != (common.Hash{0x02}) {
+		t.Errorf("resurrected account storage mismatch: have %x, want %x", have, common.Hash{0x02})
+	}
+	if have := state.GetState(reverted, common.Hash{0x01}); have != (common.Hash{0x01}) {
+		t.Errorf("reverted account storage mismatch: have %x, want %x", have, common.Hash{0x01})
+	}
+	if err := state.Error(); err != nil {
+		t.Errorf("failed to read state: %v", err)
+	}
+}
+
 // TestMissingTrieNodes tests that if the StateDB fails to load parts of the trie,
 // the Commit operation fails with an error
 // If we are missing trie nodes, we should not continue writing to the trie
@@ -0,0 +1,146 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxAdmission describes how the transaction pool would handle a transaction,
+// along with the price thresholds the decision was based on.
+type TxAdmission struct {
+	Error    error              // Reason of rejecting the transaction, nil if it would be accepted
+	Pending  bool               // Whether the transaction would be executable, or queued otherwise
+	Position int                // Number of pending transactions paying a higher effective tip
+	Replaces *types.Transaction // Pooled transaction with the same nonce which would be replaced
+	Evicts   types.Transactions // Pooled transactions which would be evicted to make room
+
+	MinTip        *big.Int // Minimum tip accepted from remote transactions
+	ReplaceFeeCap *big.Int // Fee cap needed to replace the pooled transaction with the same nonce
+	ReplaceTip    *big.Int // Tip needed to replace the pooled transaction with the same nonce
+	PoolFull      bool     // Whether the pool is full and remote transactions must outbid others
+	EvictTip      *big.Int // Effective tip to exceed to outbid the cheapest transactions of a full pool
+	EvictFeeCap   *big.Int // Fee cap to exceed to outbid the cheapest transactions of a full pool
+}
+
+// SimulateAdd runs the validation and admission checks of adding a transaction
+// to the pool without inserting it, reporting the outcome and the thresholds
+// involved.
+func (pool *TxPool) SimulateAdd(tx *types.Transaction, local bool) *TxAdmission {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	res := &TxAdmission{MinTip: new(big.Int).Set(pool.gasPrice)}
+	if pool.all.Get(tx.Hash()) != nil {
+		res.Error = ErrAlreadyKnown
+		return res
+	}
+	isLocal := local || pool.locals.containsTx(tx)
+	if isLocal {
+		res.MinTip = nil
+	}
+	// Report the thresholds of replacing a pooled transaction with the same nonce
+	from, err := types.Sender(pool.signer, tx)
+	if err == nil {
+		if res.Replaces = pool.pooled(from, tx.Nonce()); res.Replaces != nil {
+			res.ReplaceFeeCap, res.ReplaceTip = replacementThreshold(res.Replaces, pool.config.PriceBump)
+
+			// The new fee cap and tip must exceed the old ones even if not bumped by rounding
+			if res.ReplaceFeeCap.Cmp(res.Replaces.GasFeeCap()) <= 0 {
+				res.ReplaceFeeCap = new(big.Int).Add(res.Replaces.GasFeeCap(), common.Big1)
+			}
+			if res.ReplaceTip.Cmp(res.Replaces.GasTipCap()) <= 0 {
+				res.ReplaceTip = new(big.Int).Add(res.Replaces.GasTipCap(), common.Big1)
+			}
+		}
+	}
+	// Report the prices to outbid if the pool is full
+	if uint64(pool.all.Slots()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
+		res.PoolFull = true
+		urgent, floating := pool.priced.cheapest()
+		if urgent != nil {
+			res.EvictTip = urgent.EffectiveGasTipValue(pool.priced.urgent.baseFee)
+		}
+		if floating != nil {
+			res.EvictFeeCap = new(big.Int).Set(floating.GasFeeCap())
+		}
+	}
+	// Run the same checks as adding the transaction would
+	if res.Error = pool.validateTx(tx, isLocal); res.Error != nil {
+		return res
+	}
+	if res.PoolFull {
+		if !isLocal && pool.priced.Underpriced(tx) {
+			res.Error = ErrUnderpriced
+			return res
+		}
+		if pool.changesSinceReorg > int(pool.config.GlobalSlots/4) {
+			res.Error = ErrTxPoolOverflow
+			return res
+		}
+		drop, success := pool.priced.discardable(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+numSlots(tx), isLocal)
+		if !isLocal && !success {
+			res.Error = ErrTxPoolOverflow
+			return res
+		}
+		res.Evicts = drop
+	}
+	if res.Replaces != nil && (tx.GasFeeCapIntCmp(res.ReplaceFeeCap) < 0 || tx.GasTipCapIntCmp(res.ReplaceTip) < 0) {
+		res.Error = ErrReplaceUnderpriced
+		return res
+	}
+	// The transaction would be accepted, find out where it would end up
+	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
+		res.Pending = true
+	} else {
+		res.Pending = true
+		for nonce := pool.pendingNonces.get(from); nonce < tx.Nonce(); nonce++ {
+			if list := pool.queue[from]; list == nil || list.txs.Get(nonce) == nil {
+				res.Pending = false
+				break
+			}
+		}
+	}
+	baseFee := pool.priced.urgent.baseFee
+	for _, list := range pool.pending {
+		for _, pending := range list.txs.items {
+			if pending != res.Replaces && pending.EffectiveGasTipCmp(tx, baseFee) > 0 {
+				res.Position++
+			}
+		}
+	}
+	return res
+}
+
+// pooled returns the pending or queued transaction of an account with the given
+// nonce, if any.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) pooled(addr common.Address, nonce uint64) *types.Transaction {
+	if list := pool.pending[addr]; list != nil {
+		if tx := list.txs.Get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if list := pool.queue[addr]; list != nil {
+		return list.txs.Get(nonce)
+	}
+	return nil
+}
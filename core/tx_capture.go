@@ -0,0 +1,182 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TxCaptureEvent is the kind of a transaction pool capture entry.
+type TxCaptureEvent uint8
+
+const (
+	TxCaptureAdded   TxCaptureEvent = iota // Transaction admitted into the pool
+	TxCaptureDropped                       // Transaction dropped from the pool
+)
+
+// Reasons of transactions being dropped from the pool.
+const (
+	TxDropReplaced    = "replaced"    // Replaced by a transaction with the same nonce
+	TxDropUnderpriced = "underpriced" // Evicted by better priced transactions or below the price limit
+	TxDropStale       = "stale"       // Nonce used on chain, usually by the transaction being included
+	TxDropUnpayable   = "unpayable"   // Insufficient balance or gas above the block gas limit
+	TxDropOverflow    = "overflow"    // Exceeded the account or global slot limits
+	TxDropExpired     = "expired"     // Queued for longer than the configured lifetime
+)
+
+// Sources of added transactions other than the ID of the peer they were
+// received from.
+const (
+	TxSourceLocal = "local" // Submitted locally, e.g. via RPC
+	TxSourceReorg = "reorg" // Reinjected from blocks dropped in a reorg
+)
+
+// TxCaptureEntry is an entry of the transaction pool capture journal.
+type TxCaptureEntry struct {
+	Time   uint64         // Unix time of the event in nanoseconds
+	Head   uint64         // Number of the head block the pool was at
+	Event  TxCaptureEvent // Kind of the event
+	Hash   common.Hash    // Hash of the transaction
+	Tx     []byte         // Binary encoding of added transactions
+	Source string         // Source of added transactions, the peer ID for remote ones
+	Reason string         // Reason of dropping the transaction
+}
+
+// Transaction decodes the transaction of an added entry.
+func (entry *TxCaptureEntry) Transaction() (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(entry.Tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// txCapture is an append-only journal of all transactions entering and leaving
+// the pool, allowing to study and replay the history of the pool.
+type txCapture struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	mu     sync.Mutex
+}
+
+// newTxCapture opens the capture journal at the given path, appending to it if
+// it already exists.
+func newTxCapture(path string) (*txCapture, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &txCapture{path: path, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// added journals a transaction admitted into the pool.
+func (capture *txCapture) added(tx *types.Transaction, source string, head uint64) {
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		log.Warn("Failed to encode captured transaction", "hash", tx.Hash(), "err", err)
+		return
+	}
+	capture.write(&TxCaptureEntry{
+		Time:   uint64(time.Now().UnixNano()),
+		Head:   head,
+		Event:  TxCaptureAdded,
+		Hash:   tx.Hash(),
+		Tx:     blob,
+		Source: source,
+	})
+}
+
+// dropped journals transactions dropped from the pool.
+func (capture *txCapture) dropped(txs []*types.Transaction, reason string, head uint64) {
+	now := uint64(time.Now().UnixNano())
+	for _, tx := range txs {
+		capture.write(&TxCaptureEntry{
+			Time:   now,
+			Head:   head,
+			Event:  TxCaptureDropped,
+			Hash:   tx.Hash(),
+			Reason: reason,
+		})
+	}
+}
+
+func (capture *txCapture) write(entry *TxCaptureEntry) {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+
+	if capture.writer == nil {
+		return
+	}
+	if err := rlp.Encode(capture.writer, entry); err != nil {
+		log.Warn("Failed to capture transaction", "hash", entry.Hash, "err", err)
+	}
+}
+
+// flush writes any buffered entries to disk.
+func (capture *txCapture) flush() error {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+
+	if capture.writer == nil {
+		return nil
+	}
+	return capture.writer.Flush()
+}
+
+// close flushes the capture journal and closes the file.
+func (capture *txCapture) close() error {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+
+	if capture.writer == nil {
+		return nil
+	}
+	err := capture.writer.Flush()
+	if cerr := capture.file.Close(); err == nil {
+		err = cerr
+	}
+	capture.writer = nil
+	return err
+}
+
+// ReadTxCapture parses a transaction pool capture journal, calling fn for all
+// entries in order.
+func ReadTxCapture(r io.Reader, fn func(*TxCaptureEntry) error) error {
+	stream := rlp.NewStream(bufio.NewReader(r), 0)
+	for {
+		entry := new(TxCaptureEntry)
+		if err := stream.Decode(entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
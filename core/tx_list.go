@@ -285,19 +285,10 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 		if old.GasFeeCapCmp(tx) >= 0 || old.GasTipCapCmp(tx) >= 0 {
 			return false, nil
 		}
-		// thresholdFeeCap = oldFC  * (100 + priceBump) / 100
-		a := big.NewInt(100 + int64(priceBump))
-		aFeeCap := new(big.Int).Mul(a, old.GasFeeCap())
-		aTip := a.Mul(a, old.GasTipCap())
-
-		// thresholdTip    = oldTip * (100 + priceBump) / 100
-		b := big.NewInt(100)
-		thresholdFeeCap := aFeeCap.Div(aFeeCap, b)
-		thresholdTip := aTip.Div(aTip, b)
-
 		// We have to ensure that both the new fee cap and tip are higher than the
 		// old ones as well as checking the percentage threshold to ensure that
 		// this is accurate for low (Wei-level) gas price replacements.
+		thresholdFeeCap, thresholdTip := replacementThreshold(old, priceBump)
 		if tx.GasFeeCapIntCmp(thresholdFeeCap) < 0 || tx.GasTipCapIntCmp(thresholdTip) < 0 {
 			return false, nil
 		}
@@ -313,6 +304,17 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	return true, old
 }
 
+// replacementThreshold returns the fee cap and tip a transaction needs to pay at
+// least to replace old, i.e. the old ones increased by priceBump percent.
+func replacementThreshold(old *types.Transaction, priceBump uint64) (*big.Int, *big.Int) {
+	a := big.NewInt(100 + int64(priceBump))
+	aFeeCap := new(big.Int).Mul(a, old.GasFeeCap())
+	aTip := a.Mul(a, old.GasTipCap())
+
+	b := big.NewInt(100)
+	return aFeeCap.Div(aFeeCap, b), aTip.Div(aTip, b)
+}
+
 // Forward removes all transactions from the list with a nonce lower than the
 // provided threshold. Every removed transaction is returned for any post-removal
 // maintenance.
@@ -600,6 +602,32 @@ func (l *txPricedList) Discard(slots int, force bool) (types.Transactions, bool)
 	return drop, true
 }
 
+// cheapest returns the lowest priced remote transactions of the urgent and the
+// floating heaps, nil for empty heaps. A transaction needs to be priced above
+// both to enter a full pool.
+func (l *txPricedList) cheapest() (urgent *types.Transaction, floating *types.Transaction) {
+	// Discard stale price points at the heap starts by checking any transaction
+	free := types.NewTx(&types.LegacyTx{GasPrice: new(big.Int)})
+	if l.underpricedFor(&l.urgent, free) {
+		urgent = l.urgent.list[0]
+	}
+	if l.underpricedFor(&l.floating, free) {
+		floating = l.floating.list[0]
+	}
+	return urgent, floating
+}
+
+// discardable returns the transactions Discard would evict to make room for the
+// given number of slots, without removing them from the priced list.
+func (l *txPricedList) discardable(slots int, force bool) (types.Transactions, bool) {
+	cpy := &txPricedList{
+		all:      l.all,
+		urgent:   priceHeap{baseFee: l.urgent.baseFee, list: append([]*types.Transaction(nil), l.urgent.list...)},
+		floating: priceHeap{baseFee: l.floating.baseFee, list: append([]*types.Transaction(nil), l.floating.list...)},
+	}
+	return cpy.Discard(slots, force)
+}
+
 // Reheap forcibly rebuilds the heap based on the current remote transaction set.
 func (l *txPricedList) Reheap() {
 	l.reheapMu.Lock()
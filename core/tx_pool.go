@@ -155,6 +155,7 @@ type TxPoolConfig struct {
 	NoLocals  bool             // Whether local transaction handling should be disabled
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
+	Capture   string           // Journal capturing all transactions added to and dropped from the pool
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -247,9 +248,11 @@ type TxPool struct {
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
+	currentHead   uint64         // Number of the head block the state is based on
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
+	capture *txCapture  // Journal of all transactions entering and leaving the pool
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -305,6 +308,15 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pool.locals.add(addr)
 	}
 	pool.priced = newTxPricedList(pool.all)
+	if config.Capture != "" {
+		capture, err := newTxCapture(config.Capture)
+		if err != nil {
+			log.Warn("Failed to open transaction capture journal", "err", err)
+		} else {
+			log.Info("Capturing pool transactions", "path", config.Capture)
+			pool.capture = capture
+		}
+	}
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
@@ -377,6 +389,11 @@ func (pool *TxPool) loop() {
 				log.Debug("Transaction pool status report", "executable", pending, "queued", queued, "stales", stales)
 				prevPending, prevQueued, prevStales = pending, queued, stales
 			}
+			if pool.capture != nil {
+				if err := pool.capture.flush(); err != nil {
+					log.Warn("Failed to flush transaction capture journal", "err", err)
+				}
+			}
 
 		// Handle inactive account transaction eviction
 		case <-evict.C:
@@ -389,6 +406,7 @@ func (pool *TxPool) loop() {
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					list := pool.queue[addr].Flatten()
+					pool.captureDropped(list, TxDropExpired)
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true)
 					}
@@ -422,9 +440,21 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.capture != nil {
+		if err := pool.capture.close(); err != nil {
+			log.Warn("Failed to close transaction capture journal", "err", err)
+		}
+	}
 	log.Info("Transaction pool stopped")
 }
 
+// ResetHead moves the pool from oldHead to newHead, as if a chain head event was
+// received, and waits until the pool finished reorganising. It is meant for tools
+// driving the pool along an existing chain without importing blocks.
+func (pool *TxPool) ResetHead(oldHead, newHead *types.Header) {
+	<-pool.requestReset(oldHead, newHead)
+}
+
 // SubscribeNewTxsEvent registers a subscription of NewTxsEvent and
 // starts sending event to the given channel.
 func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
@@ -451,6 +481,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	if price.Cmp(old) > 0 {
 		// pool.priced is sorted by GasFeeCap, so we have to iterate through pool.all instead
 		drop := pool.all.RemotesBelowTip(price)
+		pool.captureDropped(drop, TxDropUnderpriced)
 		for _, tx := range drop {
 			pool.removeTx(tx.Hash(), false)
 		}
@@ -701,6 +732,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		// Bump the counter of rejections-since-reorg
 		pool.changesSinceReorg += len(drop)
 		// Kick out the underpriced remote transactions.
+		pool.captureDropped(drop, TxDropUnderpriced)
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
@@ -721,6 +753,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+			pool.captureDropped([]*types.Transaction{old}, TxDropReplaced)
 		}
 		pool.all.Add(tx, isLocal)
 		pool.priced.Put(tx, isLocal)
@@ -772,6 +805,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
+		pool.captureDropped([]*types.Transaction{old}, TxDropReplaced)
 	} else {
 		// Nothing was replaced, bump the queued counter
 		queuedGauge.Inc(1)
@@ -804,6 +838,22 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	}
 }
 
+// captureAdded adds a transaction admitted into the pool to the capture journal,
+// if capturing is enabled.
+func (pool *TxPool) captureAdded(tx *types.Transaction, source string) {
+	if pool.capture != nil {
+		pool.capture.added(tx, source, pool.currentHead)
+	}
+}
+
+// captureDropped adds transactions dropped from the pool to the capture journal,
+// if capturing is enabled.
+func (pool *TxPool) captureDropped(txs []*types.Transaction, reason string) {
+	if pool.capture != nil && len(txs) > 0 {
+		pool.capture.dropped(txs, reason, pool.currentHead)
+	}
+}
+
 // promoteTx adds a transaction to the pending (processable) list of transactions
 // and returns whether it was inserted or an older was better.
 //
@@ -821,6 +871,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
+		pool.captureDropped([]*types.Transaction{tx}, TxDropUnderpriced)
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -828,6 +879,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
+		pool.captureDropped([]*types.Transaction{old}, TxDropReplaced)
 	} else {
 		// Nothing was replaced, bump the pending counter
 		pendingGauge.Inc(1)
@@ -846,7 +898,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 // This method is used to add transactions from the RPC API and performs synchronous pool
 // reorganization and event propagation.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals, true)
+	return pool.addTxs(txs, TxSourceLocal, !pool.config.NoLocals, true)
 }
 
 // AddLocal enqueues a single local transaction into the pool if it is valid. This is
@@ -862,12 +914,18 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 // This method is used to add transactions from the p2p network and does not wait for pool
 // reorganization and internal event propagation.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, false)
+	return pool.addTxs(txs, "", false, false)
+}
+
+// AddRemotesFrom is like AddRemotes, but also records the peer the transactions
+// were received from.
+func (pool *TxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return pool.addTxs(txs, peer, false, false)
 }
 
 // This is like AddRemotes, but waits for pool reorganization. Tests use this method.
 func (pool *TxPool) AddRemotesSync(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, true)
+	return pool.addTxs(txs, "", false, true)
 }
 
 // This is like AddRemotes with a single transaction, but waits for pool reorganization. Tests use this method.
@@ -885,8 +943,9 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 	return errs[0]
 }
 
-// addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
+// addTxs attempts to queue a batch of transactions if they are valid. The source
+// identifies where the transactions come from in the capture journal.
+func (pool *TxPool) addTxs(txs []*types.Transaction, source string, local, sync bool) []error {
 	// Filter out known ones without obtaining the pool lock or recovering signatures
 	var (
 		errs = make([]error, len(txs))
@@ -917,7 +976,7 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
-	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	newErrs, dirtyAddrs := pool.addTxsLocked(news, source, local)
 	pool.mu.Unlock()
 
 	var nilSlot = 0
@@ -938,14 +997,17 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
 // The transaction pool lock must be held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error, *accountSet) {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, source string, local bool) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
 	for i, tx := range txs {
 		replaced, err := pool.add(tx, local)
 		errs[i] = err
-		if err == nil && !replaced {
-			dirty.addTx(tx)
+		if err == nil {
+			pool.captureAdded(tx, source)
+			if !replaced {
+				dirty.addTx(tx)
+			}
 		}
 	}
 	validTxMeter.Mark(int64(len(dirty.accounts)))
@@ -1293,11 +1355,12 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	pool.currentHead = newHead.Number.Uint64()
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsLocked(reinject, TxSourceReorg, false)
 
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
@@ -1325,6 +1388,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.captureDropped(forwards, TxDropStale)
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1332,6 +1396,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.captureDropped(drops, TxDropUnpayable)
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
@@ -1355,6 +1420,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.captureDropped(caps, TxDropOverflow)
 			queuedRateLimitMeter.Mark(int64(len(caps)))
 		}
 		// Mark all the items dropped as removed
@@ -1411,6 +1477,7 @@ func (pool *TxPool) truncatePending() {
 					list := pool.pending[offenders[i]]
 
 					caps := list.Cap(list.Len() - 1)
+					pool.captureDropped(caps, TxDropOverflow)
 					for _, tx := range caps {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
@@ -1438,6 +1505,7 @@ func (pool *TxPool) truncatePending() {
 				list := pool.pending[addr]
 
 				caps := list.Cap(list.Len() - 1)
+				pool.captureDropped(caps, TxDropOverflow)
 				for _, tx := range caps {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
@@ -1487,7 +1555,9 @@ func (pool *TxPool) truncateQueue() {
 
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
-			for _, tx := range list.Flatten() {
+			txs := list.Flatten()
+			pool.captureDropped(txs, TxDropOverflow)
+			for _, tx := range txs {
 				pool.removeTx(tx.Hash(), true)
 			}
 			drop -= size
@@ -1497,6 +1567,7 @@ func (pool *TxPool) truncateQueue() {
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+			pool.captureDropped(txs[i:i+1], TxDropOverflow)
 			pool.removeTx(txs[i].Hash(), true)
 			drop--
 			queuedRateLimitMeter.Mark(1)
@@ -1523,6 +1594,8 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
+		pool.captureDropped(olds, TxDropStale)
+
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -1530,6 +1603,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
+		pool.captureDropped(drops, TxDropUnpayable)
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
 		for _, tx := range invalids {
@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -2348,6 +2349,183 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
+// Tests that transactions entering and leaving the pool are captured along with
+// their sources and drop reasons.
+func TestTransactionCapture(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Capture = filepath.Join(t.TempDir(), "capture.rlp")
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a local transaction, a remote one and its replacement from another peer
+	var (
+		tx0 = pricedTransaction(0, 100000, big.NewInt(1), local)
+		tx1 = pricedTransaction(0, 100000, big.NewInt(1), remote)
+		tx2 = pricedTransaction(0, 100000, big.NewInt(2), remote)
+	)
+	if err := pool.AddLocal(tx0); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if errs := pool.addTxs([]*types.Transaction{tx1}, "peer1", false, true); errs[0] != nil {
+		t.Fatalf("failed to add remote transaction: %v", errs[0])
+	}
+	if errs := pool.addTxs([]*types.Transaction{tx2}, "peer2", false, true); errs[0] != nil {
+		t.Fatalf("failed to add replacement transaction: %v", errs[0])
+	}
+	// Include the remote transaction and ensure it's dropped as stale
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+	<-pool.requestReset(nil, nil)
+	pool.Stop()
+
+	want := []TxCaptureEntry{
+		{Event: TxCaptureAdded, Hash: tx0.Hash(), Source: TxSourceLocal},
+		{Event: TxCaptureAdded, Hash: tx1.Hash(), Source: "peer1"},
+		{Event: TxCaptureDropped, Hash: tx1.Hash(), Reason: TxDropReplaced},
+		{Event: TxCaptureAdded, Hash: tx2.Hash(), Source: "peer2"},
+		{Event: TxCaptureDropped, Hash: tx2.Hash(), Reason: TxDropStale},
+	}
+	file, err := os.Open(config.Capture)
+	if err != nil {
+		t.Fatalf("failed to open capture: %v", err)
+	}
+	defer file.Close()
+
+	var entries []*TxCaptureEntry
+	if err := ReadTxCapture(file, func(entry *TxCaptureEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to read capture: %v", err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("capture entry count mismatch: have %d, want %d", len(entries), len(want))
+	}
+	var last uint64
+	for i, entry := range entries {
+		if entry.Event != want[i].Event || entry.Hash != want[i].Hash || entry.Source != want[i].Source || entry.Reason != want[i].Reason {
+			t.Errorf("entry %d: have %v/%x/%q/%q, want %v/%x/%q/%q", i, entry.Event, entry.Hash, entry.Source, entry.Reason,
+				want[i].Event, want[i].Hash, want[i].Source, want[i].Reason)
+		}
+		if entry.Time < last {
+			t.Errorf("entry %d: time %d before previous %d", i, entry.Time, last)
+		}
+		last = entry.Time
+
+		if entry.Event == TxCaptureAdded {
+			tx, err := entry.Transaction()
+			if err != nil {
+				t.Errorf("entry %d: failed to decode transaction: %v", i, err)
+			} else if tx.Hash() != entry.Hash {
+				t.Errorf("entry %d: transaction hash mismatch: have %x, want %x", i, tx.Hash(), entry.Hash)
+			}
+		}
+	}
+}
+
+// Tests that simulating the admission of transactions reports the outcome and the
+// relevant thresholds without modifying the pool.
+func TestTransactionSimulateAdd(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	txs := types.Transactions{
+		pricedTransaction(0, 100000, big.NewInt(1), keys[0]),
+		pricedTransaction(1, 100000, big.NewInt(3), keys[0]),
+		pricedTransaction(0, 100000, big.NewInt(2), keys[1]),
+	}
+	for i, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	check := func(name string, tx *types.Transaction, want *TxAdmission) {
+		t.Helper()
+
+		pending, queued := pool.Stats()
+		have := pool.SimulateAdd(tx, false)
+		if have.Error != want.Error {
+			t.Errorf("%s: error mismatch: have %v, want %v", name, have.Error, want.Error)
+		}
+		if have.Pending != want.Pending || have.Position != want.Position || have.Replaces != want.Replaces || have.PoolFull != want.PoolFull {
+			t.Errorf("%s: outcome mismatch: have pending %v position %d replaces %v full %v, want pending %v position %d replaces %v full %v", name,
+				have.Pending, have.Position, have.Replaces, have.PoolFull, want.Pending, want.Position, want.Replaces, want.PoolFull)
+		}
+		if len(have.Evicts) != len(want.Evicts) {
+			t.Errorf("%s: eviction mismatch: have %d, want %d", name, len(have.Evicts), len(want.Evicts))
+		} else {
+			for i := range have.Evicts {
+				if have.Evicts[i] != want.Evicts[i] {
+					t.Errorf("%s: evicted transaction %d mismatch: have %x, want %x", name, i, have.Evicts[i].Hash(), want.Evicts[i].Hash())
+				}
+			}
+		}
+		for _, threshold := range []struct {
+			name       string
+			have, want *big.Int
+		}{
+			{"min tip", have.MinTip, want.MinTip},
+			{"replacement fee cap", have.ReplaceFeeCap, want.ReplaceFeeCap},
+			{"replacement tip", have.ReplaceTip, want.ReplaceTip},
+		} {
+			if (threshold.have == nil) != (threshold.want == nil) || (threshold.have != nil && threshold.have.Cmp(threshold.want) != 0) {
+				t.Errorf("%s: %s mismatch: have %v, want %v", name, threshold.name, threshold.have, threshold.want)
+			}
+		}
+		if p, q := pool.Stats(); p != pending || q != queued {
+			t.Errorf("%s: pool modified: have %d/%d transactions, want %d/%d", name, p, q, pending, queued)
+		}
+		if err := validateTxPoolInternals(pool); err != nil {
+			t.Fatalf("%s: pool internal state corrupted: %v", name, err)
+		}
+	}
+	check("known", txs[0], &TxAdmission{Error: ErrAlreadyKnown, MinTip: common.Big1})
+	check("below price limit", pricedTransaction(0, 100000, big.NewInt(0), keys[2]), &TxAdmission{Error: ErrUnderpriced, MinTip: common.Big1})
+	check("replacement underpriced", pricedTransaction(0, 100001, big.NewInt(1), keys[0]), &TxAdmission{
+		Error: ErrReplaceUnderpriced, Replaces: txs[0], MinTip: common.Big1, ReplaceFeeCap: common.Big2, ReplaceTip: common.Big2,
+	})
+	check("replacement", pricedTransaction(0, 100000, big.NewInt(2), keys[0]), &TxAdmission{
+		Pending: true, Position: 1, Replaces: txs[0], MinTip: common.Big1, ReplaceFeeCap: common.Big2, ReplaceTip: common.Big2,
+	})
+	check("executable", pricedTransaction(1, 100000, big.NewInt(1), keys[1]), &TxAdmission{Pending: true, Position: 2, MinTip: common.Big1})
+	check("nonce gap", pricedTransaction(2, 100000, big.NewInt(5), keys[1]), &TxAdmission{MinTip: common.Big1})
+
+	// Fill up the pool and ensure transactions need to outbid the cheapest ones
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(4), keys[3])); err != nil {
+		t.Fatalf("failed to fill pool: %v", err)
+	}
+	check("outbidding", pricedTransaction(0, 100000, big.NewInt(5), keys[2]), &TxAdmission{
+		Pending: true, PoolFull: true, Evicts: types.Transactions{txs[0]}, MinTip: common.Big1,
+	})
+	check("outbid", pricedTransaction(0, 100000, big.NewInt(1), keys[2]), &TxAdmission{Error: ErrUnderpriced, PoolFull: true, MinTip: common.Big1})
+
+	if have := pool.SimulateAdd(pricedTransaction(0, 100000, big.NewInt(1), keys[2]), true); have.Error != nil || have.MinTip != nil || !have.Pending {
+		t.Errorf("local transaction not admitted: error %v, min tip %v, pending %v", have.Error, have.MinTip, have.Pending)
+	}
+}
+
 func TestTransactionStatusCheck(t *testing.T) {
 	t.Parallel()
 
@@ -611,3 +611,68 @@ func (api *PrivateDebugAPI) GetAccessibleState(from, to rpc.BlockNumber) (uint64
 	}
 	return 0, fmt.Errorf("No state found")
 }
+
+// TrieAccessStats returns the trie node access statistics collected while the
+// given block was imported. It requires the node to run with trie access stats
+// enabled and only covers the most recently imported blocks.
+func (api *PrivateDebugAPI) TrieAccessStats(blockNrOrHash rpc.BlockNumberOrHash) (*trie.AccessStats, error) {
+	if !api.eth.config.TrieStats {
+		return nil, errors.New("trie access statistics not enabled")
+	}
+	header, err := api.headerByNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	stats := api.eth.blockchain.TrieAccessStats(header.Hash())
+	if stats == nil {
+		return nil, fmt.Errorf("no trie access statistics for block #%d", header.Number)
+	}
+	return stats, nil
+}
+
+// headerByNumberOrHash resolves a block number or hash into a local header.
+func (api *PrivateDebugAPI) headerByNumberOrHash(blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	var header *types.Header
+	if number, ok := blockNrOrHash.Number(); ok {
+		switch number {
+		case rpc.PendingBlockNumber, rpc.LatestBlockNumber:
+			header = api.eth.blockchain.CurrentHeader()
+		case rpc.FinalizedBlockNumber:
+			if block := api.eth.blockchain.CurrentFinalizedBlock(); block != nil {
+				header = block.Header()
+			}
+		default:
+			header = api.eth.blockchain.GetHeaderByNumber(uint64(number))
+		}
+	} else if hash, ok := blockNrOrHash.Hash(); ok {
+		header = api.eth.blockchain.GetHeaderByHash(hash)
+	}
+	if header == nil {
+		return nil, errors.New("block not found")
+	}
+	return header, nil
+}
+
+// StateDiffFilter restricts the accounts compared by debug_stateDiff.
+type StateDiffFilter struct {
+	Addresses []common.Address `json:"addresses"`
+}
+
+// StateDiff returns the accounts and storage slots which differ between the
+// states of two arbitrary blocks, along with their old and new values. If the
+// filter lists any addresses, only those accounts are compared.
+func (api *PrivateDebugAPI) StateDiff(from, to rpc.BlockNumberOrHash, filter *StateDiffFilter) (*state.Diff, error) {
+	fromHeader, err := api.headerByNumberOrHash(from)
+	if err != nil {
+		return nil, err
+	}
+	toHeader, err := api.headerByNumberOrHash(to)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []common.Address
+	if filter != nil {
+		addresses = filter.Addresses
+	}
+	return state.DiffStates(api.eth.blockchain.StateCache(), api.eth.blockchain.Snapshots(), fromHeader.Root, toHeader.Root, addresses)
+}
@@ -72,6 +72,8 @@ type Ethereum struct {
 	txPool             *core.TxPool
 	blockchain         *core.BlockChain
 	handler            *handler
+	pruner             *pruner.OnlinePruner // Background state pruner (nil if disabled)
+	stateDiffs         *stateDiffExporter   // State diff file exporter (nil if disabled)
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
 	merger             *consensus.Merger
@@ -85,9 +87,11 @@ type Ethereum struct {
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
+	cliqueIndexer     *core.ChainIndexer             // Clique signer statistics indexer, nil if not a clique chain
 	closeBloomHandler chan struct{}
 
 	APIBackend *EthAPIBackend
+	events     *filters.EventSystem // Event system backing the filters and subscriptions
 
 	miner     *miner.Miner
 	gasPrice  *big.Int
@@ -137,6 +141,22 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	scheme, err := rawdb.ParseStateScheme(config.StateScheme, chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if config.StatePruning && (config.NoPruning || scheme != rawdb.HashScheme) {
+		return nil, errors.New("online state pruning requires the hash-based state scheme in non-archive mode")
+	}
+	if scheme == rawdb.PathScheme {
+		if config.NoPruning {
+			return nil, errors.New("path-based state scheme is incompatible with archive mode")
+		}
+		if config.SyncMode == downloader.SnapSync {
+			log.Warn("Snap sync is not supported by the path-based state scheme, switching to full sync")
+			config.SyncMode = downloader.FullSync
+		}
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideGrayGlacier, config.OverrideTerminalTotalDifficulty)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -199,8 +219,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
+			TrieAccessStats:     config.TrieStats,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			StateScheme:         scheme,
 		}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
@@ -215,9 +237,19 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	if engine := cliqueEngine(eth.engine); engine != nil {
+		table := rawdb.NewTable(chainDb, clique.SignerStatsPrefix)
+		eth.cliqueIndexer = core.NewChainIndexer(chainDb, table, clique.NewSignerStatsIndexer(eth.blockchain, engine), params.CliqueStatsBlocks, params.CliqueStatsConfirms, 100*time.Millisecond, "cliquestats")
+		eth.cliqueIndexer.Start(eth.blockchain)
+		engine.SetStatsIndexer(eth.cliqueIndexer)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Capture != "" {
+		config.TxPool.Capture = stack.ResolvePath(config.TxPool.Capture)
+	}
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, eth.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -240,6 +272,26 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}); err != nil {
 		return nil, err
 	}
+	if config.StateDiffExport != "" {
+		if config.SnapshotCache == 0 {
+			return nil, errors.New("state diff export requires snapshots to be enabled")
+		}
+		if eth.stateDiffs, err = newStateDiffExporter(config.StateDiffExport, eth.blockchain); err != nil {
+			return nil, err
+		}
+	}
+	if config.StatePruning {
+		pruneConfig := pruner.DefaultOnlineConfig
+		if config.StatePruningBloom > 0 {
+			pruneConfig.BloomSize = config.StatePruningBloom
+		}
+		eth.pruner, err = pruner.NewOnlinePruner(chainDb, eth.blockchain, pruneConfig, func() bool {
+			return atomic.LoadUint32(&eth.handler.snapSync) == 1
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	eth.miner = miner.New(eth, &config.Miner, chainConfig, eth.EventMux(), eth.engine, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
@@ -253,6 +305,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		gpoParams.Default = config.Miner.GasPrice
 	}
 	eth.APIBackend.gpo = gasprice.NewOracle(eth.APIBackend, gpoParams)
+	eth.events = filters.NewEventSystem(eth.APIBackend, false)
 
 	// Setup DNS discovery iterators.
 	dnsclient := dnsdisc.NewClient(dnsdisc.Config{})
@@ -329,7 +382,12 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.APIBackend, false, 5*time.Minute),
+			Service:   filters.NewPublicFilterAPIWithEvents(s.APIBackend, s.events, 5*time.Minute, s.config.RPCLogsLimit),
+			Public:    true,
+		}, {
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicStateDiffAPI(s),
 			Public:    true,
 		}, {
 			Namespace: "admin",
@@ -443,6 +501,20 @@ func (s *Ethereum) SetEtherbase(etherbase common.Address) {
 	s.miner.SetEtherbase(etherbase)
 }
 
+// cliqueEngine returns the clique engine of a consensus engine, possibly wrapped
+// into the beacon engine, or nil if the engine is not clique.
+func cliqueEngine(engine consensus.Engine) *clique.Clique {
+	if c, ok := engine.(*clique.Clique); ok {
+		return c
+	}
+	if cl, ok := engine.(*beacon.Beacon); ok {
+		if c, ok := cl.InnerEngine().(*clique.Clique); ok {
+			return c
+		}
+	}
+	return nil
+}
+
 // StartMining starts the miner with the given number of CPU threads. If mining
 // is already running, this method adjust the number of threads allowed to use
 // and updates the minimum price required by the transaction pool.
@@ -472,15 +544,7 @@ func (s *Ethereum) StartMining(threads int) error {
 			log.Error("Cannot start mining without etherbase", "err", err)
 			return fmt.Errorf("etherbase missing: %v", err)
 		}
-		var cli *clique.Clique
-		if c, ok := s.engine.(*clique.Clique); ok {
-			cli = c
-		} else if cl, ok := s.engine.(*beacon.Beacon); ok {
-			if c, ok := cl.InnerEngine().(*clique.Clique); ok {
-				cli = c
-			}
-		}
-		if cli != nil {
+		if cli := cliqueEngine(s.engine); cli != nil {
 			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
 			if wallet == nil || err != nil {
 				log.Error("Etherbase account unavailable locally", "err", err)
@@ -553,6 +617,11 @@ func (s *Ethereum) Start() error {
 	// Regularly update shutdown marker
 	s.shutdownTracker.Start()
 
+	// Start pruning stale state in the background if requested
+	if s.pruner != nil {
+		s.pruner.Start()
+	}
+
 	// Figure out a max peers count based on the server limits
 	maxPeers := s.p2pServer.MaxPeers
 	if s.config.LightServ > 0 {
@@ -576,10 +645,21 @@ func (s *Ethereum) Stop() error {
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	if s.cliqueIndexer != nil {
+		s.cliqueIndexer.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
+	if s.pruner != nil {
+		s.pruner.Stop()
+	}
 	s.blockchain.Stop()
+	if s.stateDiffs != nil {
+		if err := s.stateDiffs.close(); err != nil {
+			log.Error("Failed to close state diff export", "err", err)
+		}
+	}
 	s.engine.Close()
 
 	// Clean shutdown marker as the last thing before closing db
@@ -36,14 +36,25 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
-// Register adds catalyst APIs to the full node.
-func Register(stack *node.Node, backend *eth.Ethereum) error {
+// Register adds catalyst APIs to the full node. If a capture file is given, the
+// payload and forkchoice calls served are logged into it.
+func Register(stack *node.Node, backend *eth.Ethereum, captureFile string) error {
 	log.Warn("Catalyst mode enabled", "protocol", "eth")
+
+	var service interface{} = NewConsensusAPI(backend)
+	if captureFile != "" {
+		capture, err := newCapture(captureFile)
+		if err != nil {
+			return err
+		}
+		stack.RegisterLifecycle(capture)
+		service = &capturingAPI{ConsensusAPI: service.(*ConsensusAPI), capture: capture}
+	}
 	stack.RegisterAPIs([]rpc.API{
 		{
 			Namespace:     "engine",
 			Version:       "1.0",
-			Service:       NewConsensusAPI(backend),
+			Service:       service,
 			Public:        true,
 			Authenticated: true,
 		},
@@ -0,0 +1,311 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Names of the engine API methods recorded into a capture.
+const (
+	captureForkchoiceUpdated = "engine_forkchoiceUpdatedV1"
+	captureGetPayload        = "engine_getPayloadV1"
+	captureNewPayload        = "engine_newPayloadV1"
+)
+
+// CaptureEntry is a single engine API call served by the node, along with its
+// response.
+type CaptureEntry struct {
+	Time    time.Time       `json:"time"`             // Time the call was received
+	Elapsed time.Duration   `json:"elapsed"`          // Time it took to serve the call
+	Method  string          `json:"method"`           // Name of the engine API method called
+	Params  json.RawMessage `json:"params"`           // Parameters of the call as a JSON array
+	Result  json.RawMessage `json:"result,omitempty"` // Result of the call, if it succeeded
+	Error   string          `json:"error,omitempty"`  // Error of the call, if it failed
+}
+
+// capture writes the payload and forkchoice calls served by the engine API into
+// a file, one JSON encoded CaptureEntry per line.
+type capture struct {
+	lock sync.Mutex
+	file *os.File // Capture file, nil if closed
+}
+
+// newCapture opens the capture file for appending.
+func newCapture(path string) (*capture, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Capturing engine API calls", "path", path)
+	return &capture{file: file}, nil
+}
+
+// Start implements node.Lifecycle.
+func (c *capture) Start() error {
+	return nil
+}
+
+// Stop implements node.Lifecycle, closing the capture file. Calls served
+// afterwards are not recorded anymore.
+func (c *capture) Stop() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// record writes a served call and its response into the capture.
+func (c *capture) record(method string, params []interface{}, result interface{}, err error, start time.Time) {
+	entry := &CaptureEntry{
+		Time:    start,
+		Elapsed: time.Since(start),
+		Method:  method,
+	}
+	var encErr error
+	if entry.Params, encErr = json.Marshal(params); encErr != nil {
+		log.Warn("Failed to encode captured parameters", "method", method, "err", encErr)
+		return
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else if entry.Result, encErr = json.Marshal(result); encErr != nil {
+		log.Warn("Failed to encode captured result", "method", method, "err", encErr)
+		return
+	}
+	blob, encErr := json.Marshal(entry)
+	if encErr != nil {
+		log.Warn("Failed to encode captured call", "method", method, "err", encErr)
+		return
+	}
+	blob = append(blob, '\n')
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.file == nil {
+		return
+	}
+	if _, err := c.file.Write(blob); err != nil {
+		log.Warn("Failed to write engine API capture", "err", err)
+	}
+}
+
+// capturingAPI is the engine API with the payload and forkchoice calls recorded
+// into a capture. All other methods are served by the wrapped API as is.
+type capturingAPI struct {
+	*ConsensusAPI
+	capture *capture
+}
+
+// ForkchoiceUpdatedV1 serves and records a forkchoice update.
+func (api *capturingAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, payloadAttributes *beacon.PayloadAttributesV1) (beacon.ForkChoiceResponse, error) {
+	start := time.Now()
+	resp, err := api.ConsensusAPI.ForkchoiceUpdatedV1(update, payloadAttributes)
+	api.capture.record(captureForkchoiceUpdated, []interface{}{update, payloadAttributes}, resp, err, start)
+	return resp, err
+}
+
+// GetPayloadV1 serves and records a payload retrieval.
+func (api *capturingAPI) GetPayloadV1(payloadID beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	start := time.Now()
+	data, err := api.ConsensusAPI.GetPayloadV1(payloadID)
+	api.capture.record(captureGetPayload, []interface{}{payloadID}, data, err, start)
+	return data, err
+}
+
+// NewPayloadV1 serves and records a payload execution.
+func (api *capturingAPI) NewPayloadV1(params beacon.ExecutableDataV1) (beacon.PayloadStatusV1, error) {
+	start := time.Now()
+	status, err := api.ConsensusAPI.NewPayloadV1(params)
+	api.capture.record(captureNewPayload, []interface{}{params}, status, err, start)
+	return status, err
+}
+
+// ReadCapture decodes the entries of an engine API capture, invoking the
+// callback for each of them in order.
+func ReadCapture(r io.Reader, fn func(*CaptureEntry) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		entry := new(CaptureEntry)
+		if err := dec.Decode(entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// ReplayDivergence is a captured call to which the replaying node responded
+// with a different payload status than the captured one.
+type ReplayDivergence struct {
+	Call  int           // Index of the call in the capture
+	Entry *CaptureEntry // Captured call
+
+	Want    *beacon.PayloadStatusV1 // Captured payload status, nil if the call failed
+	WantErr string                  // Captured error, if the call failed
+	Have    *beacon.PayloadStatusV1 // Replayed payload status, nil if the call failed
+	HaveErr string                  // Replayed error, if the call failed
+}
+
+// String implements fmt.Stringer.
+func (d *ReplayDivergence) String() string {
+	format := func(status *beacon.PayloadStatusV1, err string) string {
+		if status == nil {
+			return "error " + err
+		}
+		res := status.Status
+		if status.LatestValidHash != nil {
+			res += fmt.Sprintf(" latestValidHash=%x", *status.LatestValidHash)
+		}
+		if status.ValidationError != nil {
+			res += fmt.Sprintf(" validationError=%q", *status.ValidationError)
+		}
+		return res
+	}
+	return fmt.Sprintf("call %d (%s at %v): captured %s, replayed %s", d.Call, d.Entry.Method, d.Entry.Time,
+		format(d.Want, d.WantErr), format(d.Have, d.HaveErr))
+}
+
+// Replay feeds the calls of an engine API capture into the given API in order,
+// stopping at the first call to which the API responds with a different payload
+// status than the captured one. Payload retrievals are replayed too, but only
+// to keep the API in the same state, their results are not compared. The number
+// of replayed calls, including the diverging one, is returned along with the
+// divergence, if any.
+func Replay(api *ConsensusAPI, r io.Reader) (int, *ReplayDivergence, error) {
+	var (
+		calls      int
+		divergence *ReplayDivergence
+		errStop    = errors.New("divergence found")
+	)
+	err := ReadCapture(r, func(entry *CaptureEntry) error {
+		status, err := replayCall(api, entry)
+		if err != nil {
+			return fmt.Errorf("call %d: %v", calls, err)
+		}
+		calls++
+		if status != nil && !status.matches() {
+			divergence = &ReplayDivergence{Call: calls - 1, Entry: entry, Want: status.want, WantErr: status.wantErr, Have: status.have, HaveErr: status.haveErr}
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return calls, nil, err
+	}
+	return calls, divergence, nil
+}
+
+// replayedStatus is the captured and replayed payload status of a call.
+type replayedStatus struct {
+	want, have       *beacon.PayloadStatusV1
+	wantErr, haveErr string
+}
+
+// matches reports whether the replayed call responded like the captured one.
+func (s *replayedStatus) matches() bool {
+	if s.want == nil || s.have == nil {
+		return s.want == nil && s.have == nil && s.wantErr == s.haveErr
+	}
+	if s.want.Status != s.have.Status {
+		return false
+	}
+	if (s.want.LatestValidHash == nil) != (s.have.LatestValidHash == nil) {
+		return false
+	}
+	return s.want.LatestValidHash == nil || *s.want.LatestValidHash == *s.have.LatestValidHash
+}
+
+// replayCall replays a single captured call, returning the captured and replayed
+// payload statuses, or nil for calls not returning any.
+func replayCall(api *ConsensusAPI, entry *CaptureEntry) (*replayedStatus, error) {
+	var (
+		status = &replayedStatus{wantErr: entry.Error}
+		err    error
+	)
+	switch entry.Method {
+	case captureForkchoiceUpdated:
+		var (
+			update     beacon.ForkchoiceStateV1
+			attributes *beacon.PayloadAttributesV1
+		)
+		if err := json.Unmarshal(entry.Params, &[]interface{}{&update, &attributes}); err != nil {
+			return nil, fmt.Errorf("invalid %s parameters: %v", entry.Method, err)
+		}
+		if entry.Error == "" {
+			var want beacon.ForkChoiceResponse
+			if err := json.Unmarshal(entry.Result, &want); err != nil {
+				return nil, fmt.Errorf("invalid %s result: %v", entry.Method, err)
+			}
+			status.want = &want.PayloadStatus
+		}
+		var have beacon.ForkChoiceResponse
+		if have, err = api.ForkchoiceUpdatedV1(update, attributes); err == nil {
+			status.have = &have.PayloadStatus
+		}
+
+	case captureNewPayload:
+		var params beacon.ExecutableDataV1
+		if err := json.Unmarshal(entry.Params, &[]interface{}{&params}); err != nil {
+			return nil, fmt.Errorf("invalid %s parameters: %v", entry.Method, err)
+		}
+		if entry.Error == "" {
+			status.want = new(beacon.PayloadStatusV1)
+			if err := json.Unmarshal(entry.Result, status.want); err != nil {
+				return nil, fmt.Errorf("invalid %s result: %v", entry.Method, err)
+			}
+		}
+		var have beacon.PayloadStatusV1
+		if have, err = api.NewPayloadV1(params); err == nil {
+			status.have = &have
+		}
+
+	case captureGetPayload:
+		var id beacon.PayloadID
+		if err := json.Unmarshal(entry.Params, &[]interface{}{&id}); err != nil {
+			return nil, fmt.Errorf("invalid %s parameters: %v", entry.Method, err)
+		}
+		api.GetPayloadV1(id)
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown method %q", entry.Method)
+	}
+	if err != nil {
+		status.haveErr = err.Error()
+	}
+	return status, nil
+}
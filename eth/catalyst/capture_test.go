@@ -0,0 +1,123 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+)
+
+// Tests that the payload and forkchoice calls served are captured, and that
+// replaying the capture on a node with the same chain finds no divergence,
+// while it reports a tampered response.
+func TestCaptureReplay(t *testing.T) {
+	genesis, blocks := generatePreMergeChain(10)
+
+	// Build a few blocks through the capturing API
+	n, ethservice := startEthService(t, genesis, blocks)
+	defer n.Close()
+
+	path := filepath.Join(t.TempDir(), "engine.jsonl")
+	capture, err := newCapture(path)
+	if err != nil {
+		t.Fatalf("failed to create capture: %v", err)
+	}
+	api := &capturingAPI{ConsensusAPI: NewConsensusAPI(ethservice), capture: capture}
+
+	parent := ethservice.BlockChain().CurrentBlock()
+	for i := 0; i < 3; i++ {
+		fcState := beacon.ForkchoiceStateV1{HeadBlockHash: parent.Hash()}
+		attributes := &beacon.PayloadAttributesV1{Timestamp: parent.Time() + 5}
+		resp, err := api.ForkchoiceUpdatedV1(fcState, attributes)
+		if err != nil {
+			t.Fatalf("failed to start payload: %v", err)
+		}
+		payload, err := api.GetPayloadV1(*resp.PayloadID)
+		if err != nil {
+			t.Fatalf("failed to get payload: %v", err)
+		}
+		if status, err := api.NewPayloadV1(*payload); err != nil || status.Status != beacon.VALID {
+			t.Fatalf("failed to import payload: %v %v", status, err)
+		}
+		fcState.HeadBlockHash = payload.BlockHash
+		if _, err := api.ForkchoiceUpdatedV1(fcState, nil); err != nil {
+			t.Fatalf("failed to set head: %v", err)
+		}
+		parent = ethservice.BlockChain().CurrentBlock()
+	}
+	// Unknown payloads are captured too, but not compared
+	api.GetPayloadV1(beacon.PayloadID{0x01})
+
+	if err := capture.Stop(); err != nil {
+		t.Fatalf("failed to close capture: %v", err)
+	}
+	captured, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read capture: %v", err)
+	}
+	var entries []*CaptureEntry
+	ReadCapture(bytes.NewReader(captured), func(entry *CaptureEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if len(entries) != 13 {
+		t.Fatalf("captured call count mismatch: have %d, want %d", len(entries), 13)
+	}
+	if entries[12].Method != captureGetPayload || entries[12].Error == "" {
+		t.Fatalf("failed retrieval not captured: %+v", entries[12])
+	}
+	// Replay the capture on a fresh node, which should end up on the same head
+	replayNode, replayService := startEthService(t, genesis, blocks)
+	defer replayNode.Close()
+
+	calls, divergence, err := Replay(NewConsensusAPI(replayService), bytes.NewReader(captured))
+	if err != nil {
+		t.Fatalf("failed to replay capture: %v", err)
+	}
+	if divergence != nil {
+		t.Fatalf("unexpected divergence: %v", divergence)
+	}
+	if calls != len(entries) {
+		t.Fatalf("replayed call count mismatch: have %d, want %d", calls, len(entries))
+	}
+	if head := replayService.BlockChain().CurrentBlock(); head.Hash() != parent.Hash() {
+		t.Fatalf("replayed head mismatch: have %x, want %x", head.Hash(), parent.Hash())
+	}
+	// Tamper with a captured payload status and check that it's reported
+	tampered := bytes.Replace(captured, []byte(`"status":"VALID"`), []byte(`"status":"INVALID"`), 1)
+
+	tamperedNode, tamperedService := startEthService(t, genesis, blocks)
+	defer tamperedNode.Close()
+
+	calls, divergence, err = Replay(NewConsensusAPI(tamperedService), bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("failed to replay tampered capture: %v", err)
+	}
+	if divergence == nil {
+		t.Fatalf("divergence not reported")
+	}
+	if divergence.Call != 0 || calls != 1 {
+		t.Fatalf("divergence position mismatch: have call %d after %d calls, want 0 after 1", divergence.Call, calls)
+	}
+	if divergence.Want.Status != beacon.INVALID || divergence.Have.Status != beacon.VALID {
+		t.Fatalf("divergence status mismatch: %v", divergence)
+	}
+}
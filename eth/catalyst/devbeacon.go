@@ -0,0 +1,208 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// DevBeaconConfig contains the settings of the mock consensus client driving a
+// post-merge development chain.
+type DevBeaconConfig struct {
+	SlotTime     time.Duration  // Interval between the slots proposing blocks
+	FinalityLag  uint64         // Number of blocks the finalized block trails the head by (at least 1)
+	MissRate     float64        // Probability of a slot not proposing any block
+	ReorgRate    float64        // Probability of a slot replacing the head block instead of extending it
+	FeeRecipient common.Address // Address to credit the fees of the proposed blocks to
+}
+
+// DevBeacon is a mock consensus client, proposing a block in every slot through
+// the engine API of the local node. It's meant for development networks without
+// a beacon chain, simulating missed slots and head reorgs on request.
+type DevBeacon struct {
+	config DevBeaconConfig
+	api    *ConsensusAPI
+	chain  *core.BlockChain
+	rand   *mrand.Rand
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDevBeacon creates a mock consensus client driving the given node.
+func NewDevBeacon(backend *eth.Ethereum, config DevBeaconConfig) (*DevBeacon, error) {
+	if backend.BlockChain().Config().TerminalTotalDifficulty == nil {
+		return nil, errors.New("dev beacon started without terminal total difficulty")
+	}
+	if config.SlotTime <= 0 {
+		return nil, fmt.Errorf("invalid slot time %v", config.SlotTime)
+	}
+	return &DevBeacon{
+		config: config,
+		api:    NewConsensusAPI(backend),
+		chain:  backend.BlockChain(),
+		rand:   mrand.New(mrand.NewSource(time.Now().UnixNano())),
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// RegisterDevBeacon adds a mock consensus client driving the full node.
+func RegisterDevBeacon(stack *node.Node, backend *eth.Ethereum, config DevBeaconConfig) error {
+	devBeacon, err := NewDevBeacon(backend, config)
+	if err != nil {
+		return err
+	}
+	log.Warn("Dev beacon enabled", "slot", config.SlotTime, "finality", config.FinalityLag, "missrate", config.MissRate, "reorgrate", config.ReorgRate)
+	stack.RegisterLifecycle(devBeacon)
+	return nil
+}
+
+// Start implements node.Lifecycle, starting to propose blocks.
+func (b *DevBeacon) Start() error {
+	b.wg.Add(1)
+	go b.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating block proposal.
+func (b *DevBeacon) Stop() error {
+	close(b.quit)
+	b.wg.Wait()
+	return nil
+}
+
+// loop runs a slot whenever the slot time passes.
+func (b *DevBeacon) loop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.config.SlotTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.slot(); err != nil {
+				log.Error("Dev beacon failed to propose block", "err", err)
+			}
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// slot runs a single slot, proposing a block on top of the current head, or on
+// top of its parent if a reorg is simulated. If a missed slot is simulated, no
+// block is proposed at all.
+func (b *DevBeacon) slot() error {
+	if b.rand.Float64() < b.config.MissRate {
+		log.Info("Dev beacon missed slot", "head", b.chain.CurrentBlock().NumberU64())
+		return nil
+	}
+	parent := b.chain.CurrentBlock()
+	if b.rand.Float64() < b.config.ReorgRate {
+		// Only the unfinalized head can be reorged out
+		if final := b.chain.CurrentFinalizedBlock(); parent.NumberU64() > 0 && (final == nil || parent.NumberU64() > final.NumberU64()) {
+			log.Info("Dev beacon reorging head", "number", parent.NumberU64(), "hash", parent.Hash())
+			parent = b.chain.GetBlock(parent.ParentHash(), parent.NumberU64()-1)
+		}
+	}
+	_, err := b.propose(parent)
+	return err
+}
+
+// propose builds a block on top of the given parent through the engine API,
+// imports it and makes it the new head, advancing the safe and finalized
+// blocks too.
+func (b *DevBeacon) propose(parent *types.Block) (*types.Block, error) {
+	var random common.Hash
+	rand.Read(random[:])
+
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= parent.Time() {
+		timestamp = parent.Time() + 1
+	}
+	// Request a payload on top of the parent
+	attributes := &beacon.PayloadAttributesV1{
+		Timestamp:             timestamp,
+		Random:                random,
+		SuggestedFeeRecipient: b.config.FeeRecipient,
+	}
+	resp, err := b.api.ForkchoiceUpdatedV1(b.forkchoice(parent.Hash(), parent.NumberU64()), attributes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.PayloadID == nil {
+		return nil, fmt.Errorf("no payload built on %x: %s", parent.Hash(), resp.PayloadStatus.Status)
+	}
+	payload, err := b.api.GetPayloadV1(*resp.PayloadID)
+	if err != nil {
+		return nil, err
+	}
+	// Import the payload and make it the new head
+	status, err := b.api.NewPayloadV1(*payload)
+	if err != nil {
+		return nil, err
+	}
+	if status.Status != beacon.VALID {
+		return nil, fmt.Errorf("payload %x rejected: %s", payload.BlockHash, status.Status)
+	}
+	if _, err := b.api.ForkchoiceUpdatedV1(b.forkchoice(payload.BlockHash, payload.Number), nil); err != nil {
+		return nil, err
+	}
+	block := b.chain.GetBlock(payload.BlockHash, payload.Number)
+	log.Info("Dev beacon proposed block", "number", block.NumberU64(), "hash", block.Hash(), "txs", len(block.Transactions()))
+	return block, nil
+}
+
+// forkchoice assembles the forkchoice state of a head, with the safe block being
+// its parent and the finalized one trailing it by the finality lag. As the head
+// itself may still be reorged, the finalized block never goes beyond the safe one.
+func (b *DevBeacon) forkchoice(head common.Hash, number uint64) beacon.ForkchoiceStateV1 {
+	state := beacon.ForkchoiceStateV1{
+		HeadBlockHash:      head,
+		SafeBlockHash:      head,
+		FinalizedBlockHash: head,
+	}
+	// The ancestors of the head are canonical, apart from the head itself
+	if number > 0 {
+		state.SafeBlockHash = b.chain.GetHeaderByNumber(number - 1).Hash()
+
+		lag := b.config.FinalityLag
+		if lag == 0 {
+			lag = 1
+		}
+		if number > lag {
+			state.FinalizedBlockHash = b.chain.GetHeaderByNumber(number - lag).Hash()
+		} else {
+			state.FinalizedBlockHash = b.chain.Genesis().Hash()
+		}
+	}
+	return state
+}
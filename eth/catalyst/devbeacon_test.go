@@ -0,0 +1,82 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the dev beacon extends the chain in every slot, keeping the
+// finalized block behind by the finality lag, and that simulated reorgs and
+// missed slots replace or keep the head respectively.
+func TestDevBeacon(t *testing.T) {
+	genesis, blocks := generatePreMergeChain(10)
+	n, ethservice := startEthService(t, genesis, blocks)
+	defer n.Close()
+
+	feeRecipient := common.HexToAddress("0xdeadbeef")
+	devBeacon, err := NewDevBeacon(ethservice, DevBeaconConfig{SlotTime: time.Second, FinalityLag: 2, FeeRecipient: feeRecipient})
+	if err != nil {
+		t.Fatalf("failed to create dev beacon: %v", err)
+	}
+	chain := ethservice.BlockChain()
+
+	// Extend the chain and check the forkchoice
+	for i := 0; i < 5; i++ {
+		if err := devBeacon.slot(); err != nil {
+			t.Fatalf("slot %d failed: %v", i, err)
+		}
+	}
+	head := chain.CurrentBlock()
+	if head.NumberU64() != 15 {
+		t.Fatalf("head number mismatch: have %d, want %d", head.NumberU64(), 15)
+	}
+	if head.Coinbase() != feeRecipient {
+		t.Fatalf("fee recipient mismatch: have %x, want %x", head.Coinbase(), feeRecipient)
+	}
+	if final := chain.CurrentFinalizedBlock(); final.NumberU64() != 13 {
+		t.Fatalf("finalized number mismatch: have %d, want %d", final.NumberU64(), 13)
+	}
+	// Reorg out the head, which should replace it with a sibling
+	devBeacon.config.ReorgRate = 1
+	if err := devBeacon.slot(); err != nil {
+		t.Fatalf("reorg slot failed: %v", err)
+	}
+	reorged := chain.CurrentBlock()
+	if reorged.NumberU64() != head.NumberU64() || reorged.Hash() == head.Hash() {
+		t.Fatalf("head not reorged: have #%d %x, old #%d %x", reorged.NumberU64(), reorged.Hash(), head.NumberU64(), head.Hash())
+	}
+	if reorged.ParentHash() != head.ParentHash() {
+		t.Fatalf("reorged head parent mismatch: have %x, want %x", reorged.ParentHash(), head.ParentHash())
+	}
+	// Miss a slot, which should leave the head alone
+	devBeacon.config.MissRate = 1
+	if err := devBeacon.slot(); err != nil {
+		t.Fatalf("missed slot failed: %v", err)
+	}
+	if missed := chain.CurrentBlock(); missed.Hash() != reorged.Hash() {
+		t.Fatalf("head changed in missed slot: have %x, want %x", missed.Hash(), reorged.Hash())
+	}
+	// Without a finality lag, the finalized block should still stop at the safe one
+	devBeacon.config.FinalityLag = 0
+	if state := devBeacon.forkchoice(reorged.Hash(), reorged.NumberU64()); state.FinalizedBlockHash != state.SafeBlockHash {
+		t.Fatalf("finalized block ahead of safe one: have %x, want %x", state.FinalizedBlockHash, state.SafeBlockHash)
+	}
+}
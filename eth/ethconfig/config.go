@@ -168,8 +168,13 @@ type Config struct {
 	TrieCleanCacheRejournal time.Duration `toml:",omitempty"` // Time interval to regenerate the journal for clean cache
 	TrieDirtyCache          int
 	TrieTimeout             time.Duration
+	TrieStats               bool `toml:",omitempty"` // Whether to collect per-block trie node access statistics
 	SnapshotCache           int
 	Preimages               bool
+	StateScheme             string `toml:",omitempty"` // Scheme used to store trie nodes on disk (hash or path)
+	StatePruning            bool   `toml:",omitempty"` // Whether to prune stale state in the background
+	StatePruningBloom       uint64 `toml:",omitempty"` // Megabytes of memory allocated to the online pruning bloom filter
+	StateDiffExport         string `toml:",omitempty"` // File to append the state changes of every written block to
 
 	// Mining options
 	Miner miner.Config
@@ -199,6 +204,14 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCLogsLimit is the maximum number of logs returned by eth_getLogs and in
+	// a single page of eth_getLogsPage (0 = no limit, the default).
+	RPCLogsLimit int
+
+	// EngineCapture is the file to log the payload and forkchoice calls served
+	// by the engine API into (empty = no capture).
+	EngineCapture string `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
@@ -46,8 +46,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TrieCleanCacheRejournal         time.Duration `toml:",omitempty"`
 		TrieDirtyCache                  int
 		TrieTimeout                     time.Duration
+		TrieStats                       bool `toml:",omitempty"`
 		SnapshotCache                   int
 		Preimages                       bool
+		StateScheme                     string `toml:",omitempty"`
+		StatePruning                    bool   `toml:",omitempty"`
+		StatePruningBloom               uint64 `toml:",omitempty"`
+		StateDiffExport                 string `toml:",omitempty"`
 		Miner                           miner.Config
 		Ethash                          ethash.Config
 		TxPool                          core.TxPoolConfig
@@ -57,6 +62,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		RPCGasCap                       uint64
 		RPCEVMTimeout                   time.Duration
 		RPCTxFeeCap                     float64
+		RPCLogsLimit                    int
+		EngineCapture                   string                         `toml:",omitempty"`
 		Checkpoint                      *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle                *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideGrayGlacier             *big.Int                       `toml:",omitempty"`
@@ -91,8 +98,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieCleanCacheRejournal = c.TrieCleanCacheRejournal
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
+	enc.TrieStats = c.TrieStats
 	enc.SnapshotCache = c.SnapshotCache
 	enc.Preimages = c.Preimages
+	enc.StateScheme = c.StateScheme
+	enc.StatePruning = c.StatePruning
+	enc.StatePruningBloom = c.StatePruningBloom
+	enc.StateDiffExport = c.StateDiffExport
 	enc.Miner = c.Miner
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
@@ -102,6 +114,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCLogsLimit = c.RPCLogsLimit
+	enc.EngineCapture = c.EngineCapture
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	enc.OverrideGrayGlacier = c.OverrideGrayGlacier
@@ -140,8 +154,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TrieCleanCacheRejournal         *time.Duration `toml:",omitempty"`
 		TrieDirtyCache                  *int
 		TrieTimeout                     *time.Duration
+		TrieStats                       *bool `toml:",omitempty"`
 		SnapshotCache                   *int
 		Preimages                       *bool
+		StateScheme                     *string `toml:",omitempty"`
+		StatePruning                    *bool   `toml:",omitempty"`
+		StatePruningBloom               *uint64 `toml:",omitempty"`
+		StateDiffExport                 *string `toml:",omitempty"`
 		Miner                           *miner.Config
 		Ethash                          *ethash.Config
 		TxPool                          *core.TxPoolConfig
@@ -151,6 +170,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		RPCGasCap                       *uint64
 		RPCEVMTimeout                   *time.Duration
 		RPCTxFeeCap                     *float64
+		RPCLogsLimit                    *int
+		EngineCapture                   *string                        `toml:",omitempty"`
 		Checkpoint                      *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle                *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideGrayGlacier             *big.Int                       `toml:",omitempty"`
@@ -244,12 +265,27 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TrieTimeout != nil {
 		c.TrieTimeout = *dec.TrieTimeout
 	}
+	if dec.TrieStats != nil {
+		c.TrieStats = *dec.TrieStats
+	}
 	if dec.SnapshotCache != nil {
 		c.SnapshotCache = *dec.SnapshotCache
 	}
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StatePruning != nil {
+		c.StatePruning = *dec.StatePruning
+	}
+	if dec.StatePruningBloom != nil {
+		c.StatePruningBloom = *dec.StatePruningBloom
+	}
+	if dec.StateDiffExport != nil {
+		c.StateDiffExport = *dec.StateDiffExport
+	}
 	if dec.Miner != nil {
 		c.Miner = *dec.Miner
 	}
@@ -277,6 +313,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCLogsLimit != nil {
+		c.RPCLogsLimit = *dec.RPCLogsLimit
+	}
+	if dec.EngineCapture != nil {
+		c.EngineCapture = *dec.EngineCapture
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
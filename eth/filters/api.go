@@ -51,15 +51,25 @@ type PublicFilterAPI struct {
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
 	timeout   time.Duration
+	maxLogs   int // Maximum number of logs returned by a query, 0 if unlimited
 }
 
-// NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(backend Backend, lightMode bool, timeout time.Duration) *PublicFilterAPI {
+// NewPublicFilterAPI returns a new PublicFilterAPI instance. Log queries returning
+// more than maxLogs results are rejected, unless maxLogs is 0.
+func NewPublicFilterAPI(backend Backend, lightMode bool, timeout time.Duration, maxLogs int) *PublicFilterAPI {
+	return NewPublicFilterAPIWithEvents(backend, NewEventSystem(backend, lightMode), timeout, maxLogs)
+}
+
+// NewPublicFilterAPIWithEvents returns a new PublicFilterAPI instance installing
+// its filters and subscriptions in the given event system, which may be shared
+// with other subscription endpoints.
+func NewPublicFilterAPIWithEvents(backend Backend, events *EventSystem, timeout time.Duration, maxLogs int) *PublicFilterAPI {
 	api := &PublicFilterAPI{
 		backend: backend,
-		events:  NewEventSystem(backend, lightMode),
+		events:  events,
 		filters: make(map[rpc.ID]*filter),
 		timeout: timeout,
+		maxLogs: maxLogs,
 	}
 	go api.timeoutLoop(timeout)
 
@@ -231,6 +241,11 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 }
 
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
+//
+// If the criteria start at a specific block number, the matching logs already
+// stored within the state are delivered first, from that block on, before
+// switching over to the new ones. Logs are delivered exactly once across the
+// switch, and logs of blocks reorged out are redelivered with removed set.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
@@ -246,16 +261,54 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	if err != nil {
 		return nil, err
 	}
-
+	// The live subscription is installed before the historical logs are looked
+	// up, so there is no gap between the two
+	var (
+		catchUp   *logCatchUp
+		caughtUp  chan error
+		cancel    = func() {}
+		queued    [][]*types.Log
+		notifyAll = func(logs []*types.Log) {
+			for _, log := range logs {
+				log := log
+				notifier.Notify(rpcSub.ID, &log)
+			}
+		}
+	)
+	if crit.FromBlock != nil && crit.FromBlock.Int64() >= 0 {
+		var catchUpCtx context.Context
+		catchUpCtx, cancel = context.WithCancel(context.Background())
+
+		catchUp = &logCatchUp{delivered: make(map[uint64]common.Hash)}
+		caughtUp = make(chan error, 1)
+		go func() {
+			caughtUp <- catchUp.run(catchUpCtx, api.backend, crit, notifyAll)
+		}()
+	}
 	go func() {
+		defer cancel()
 
 		for {
 			select {
 			case logs := <-matchedLogs:
-				for _, log := range logs {
-					log := log
-					notifier.Notify(rpcSub.ID, &log)
+				if catchUp == nil {
+					notifyAll(logs)
+				} else if caughtUp != nil {
+					queued = append(queued, logs)
+				} else {
+					notifyAll(catchUp.filter(logs))
 				}
+			case err := <-caughtUp:
+				if err != nil {
+					// Let the client know the logs are incomplete
+					notifier.Close(rpcSub.ID, fmt.Errorf("failed to deliver historical logs: %v", err))
+					logsSub.Unsubscribe()
+					return
+				}
+				for _, logs := range queued {
+					notifyAll(catchUp.filter(logs))
+				}
+				caughtUp, queued = nil, nil
 			case <-rpcSub.Err(): // client send an unsubscribe request
 				logsSub.Unsubscribe()
 				return
@@ -269,6 +322,72 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// logCatchUp delivers the historical logs of a subscription, and tracks them to
+// reconcile the new logs arriving in the meantime with them.
+type logCatchUp struct {
+	head      uint64                 // Last block covered by the historical logs
+	delivered map[uint64]common.Hash // Blocks whose logs were delivered, by number
+}
+
+// run delivers the historical logs matching the criteria, up to the current
+// head of the chain.
+func (c *logCatchUp) run(ctx context.Context, backend Backend, crit FilterCriteria, notify func([]*types.Log)) error {
+	header, err := backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return errors.New("unknown head block")
+	}
+	c.head = header.Number.Uint64()
+
+	end := int64(c.head)
+	if crit.ToBlock != nil && crit.ToBlock.Int64() >= 0 && crit.ToBlock.Int64() < end {
+		end = crit.ToBlock.Int64()
+	}
+	filter := NewRangeFilter(backend, crit.FromBlock.Int64(), end, crit.Addresses, crit.Topics)
+	return filter.LogsFunc(ctx, func(logs []*types.Log) error {
+		c.delivered[logs[0].BlockNumber] = logs[0].BlockHash
+		notify(logs)
+		return nil
+	})
+}
+
+// filter drops the new logs which were already delivered as historical ones, and
+// the removed logs which were never delivered, as the chain may have changed
+// while the historical logs were retrieved.
+//
+// All logs of a block arrive in the same batch, so the delivered blocks are only
+// updated once the whole batch was checked against them.
+func (c *logCatchUp) filter(logs []*types.Log) []*types.Log {
+	var (
+		ret       []*types.Log
+		delivered = make(map[uint64]common.Hash)
+		removed   = make(map[uint64]bool)
+	)
+	for _, log := range logs {
+		if log.BlockNumber > c.head {
+			ret = append(ret, log)
+			continue
+		}
+		if log.Removed == (c.delivered[log.BlockNumber] == log.BlockHash) {
+			ret = append(ret, log)
+			if log.Removed {
+				removed[log.BlockNumber] = true
+			} else {
+				delivered[log.BlockNumber] = log.BlockHash
+			}
+		}
+	}
+	for number := range removed {
+		delete(c.delivered, number)
+	}
+	for number, hash := range delivered {
+		c.delivered[number] = hash
+	}
+	return ret
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as ethereum.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria ethereum.FilterQuery
@@ -317,32 +436,213 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 }
 
 // GetLogs returns logs matching the given argument that are stored within the state.
+//
+// If the query matches more logs than the configured limit, an error is returned
+// suggesting a narrower block range. Such queries can be retrieved page by page
+// through eth_getLogsPage instead.
 func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
-	var filter *Filter
-	if crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
-	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	logs, err := api.limitedLogs(ctx, newCriteriaFilter(api.backend, crit, nil))
 	if err != nil {
 		return nil, err
 	}
 	return returnLogs(logs), err
 }
 
+// limitedLogs runs the filter, aborting it once more logs than the configured
+// limit are found.
+func (api *PublicFilterAPI) limitedLogs(ctx context.Context, filter *Filter) ([]*types.Log, error) {
+	if api.maxLogs == 0 {
+		return filter.Logs(ctx)
+	}
+	var logs []*types.Log
+	err := filter.LogsFunc(ctx, func(found []*types.Log) error {
+		if len(logs)+len(found) > api.maxLogs {
+			return newLogLimitError(api.maxLogs, logs, found[0].BlockNumber)
+		}
+		logs = append(logs, found...)
+		return nil
+	})
+	return logs, err
+}
+
+// logLimitError is returned if a query matches more logs than allowed, telling
+// the client how to narrow it.
+type logLimitError struct {
+	message string
+	data    logLimitErrorData
+}
+
+type logLimitErrorData struct {
+	Limit   int             `json:"limit"`
+	ToBlock *hexutil.Uint64 `json:"toBlock,omitempty"` // End of the largest range fitting into the limit, if any
+}
+
+func (e *logLimitError) Error() string          { return e.message }
+func (e *logLimitError) ErrorCode() int         { return -32005 }
+func (e *logLimitError) ErrorData() interface{} { return e.data }
+
+// newLogLimitError creates the error rejecting a query, given the logs which fit
+// into the limit and the block whose logs exceeded it.
+func newLogLimitError(limit int, logs []*types.Log, overflow uint64) error {
+	if len(logs) == 0 {
+		return &logLimitError{
+			message: fmt.Sprintf("block %d alone matches more than %d logs, narrow the address and topic criteria or use eth_getLogsPage", overflow, limit),
+			data:    logLimitErrorData{Limit: limit},
+		}
+	}
+	to := hexutil.Uint64(overflow - 1)
+	return &logLimitError{
+		message: fmt.Sprintf("query returned more than %d results, retry with toBlock %d or use eth_getLogsPage", limit, to),
+		data:    logLimitErrorData{Limit: limit, ToBlock: &to},
+	}
+}
+
+// LogCursor is a position within the logs matched by a query: the logs before
+// it have already been delivered, the ones at or after it not yet.
+type LogCursor struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	LogIndex    hexutil.Uint   `json:"logIndex"`
+}
+
+// after reports whether the cursor is past the given log.
+func (c *LogCursor) after(log *types.Log) bool {
+	if log.BlockNumber != uint64(c.BlockNumber) {
+		return log.BlockNumber < uint64(c.BlockNumber)
+	}
+	return log.Index < uint(c.LogIndex)
+}
+
+// LogPage is a single page of the logs matched by a query.
+type LogPage struct {
+	Logs []*types.Log `json:"logs"`
+	Next *LogCursor   `json:"next"` // Position of the next page, nil if there are no more logs
+}
+
+// defaultLogPageSize is the number of logs returned in a page if neither the
+// client nor the node configuration limits it.
+const defaultLogPageSize = 1000
+
+// errPageFull is returned internally to stop a filter once a page is full.
+var errPageFull = errors.New("page full")
+
+// GetLogsPage returns a page of the logs matching the given argument, starting at
+// the given cursor, or at the start of the queried range if no cursor is given.
+// The returned page carries the cursor to request the next page with, unless
+// all matching logs were delivered. The size of the pages is capped to the
+// maximum number of logs returned by eth_getLogs.
+//
+// Pending logs cannot be paginated, as the pending block changes between pages.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, cursor *LogCursor, limit *hexutil.Uint) (*LogPage, error) {
+	pending := rpc.PendingBlockNumber.Int64()
+	if (crit.FromBlock != nil && crit.FromBlock.Int64() == pending) || (crit.ToBlock != nil && crit.ToBlock.Int64() == pending) {
+		return nil, errors.New("pending logs cannot be paginated")
+	}
+	size := api.maxLogs
+	if limit != nil && *limit > 0 && (size == 0 || int(*limit) < size) {
+		size = int(*limit)
+	}
+	if size == 0 {
+		size = defaultLogPageSize
+	}
+	page := &LogPage{Logs: []*types.Log{}}
+	err := newCriteriaFilter(api.backend, crit, cursor).LogsFunc(ctx, func(found []*types.Log) error {
+		for _, log := range found {
+			if cursor != nil && cursor.after(log) {
+				continue
+			}
+			if len(page.Logs) == size {
+				page.Next = &LogCursor{BlockNumber: hexutil.Uint64(log.BlockNumber), LogIndex: hexutil.Uint(log.Index)}
+				return errPageFull
+			}
+			page.Logs = append(page.Logs, log)
+		}
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		return nil, err
+	}
+	return page, nil
+}
+
+// LogStreamEvent is a notification of a log stream subscription. The matching
+// logs are delivered block by block along with the cursor to resume the query
+// from. The last notification has Done set, and an error if the query failed.
+type LogStreamEvent struct {
+	Logs   []*types.Log `json:"logs,omitempty"`
+	Cursor *LogCursor   `json:"cursor,omitempty"`
+	Done   bool         `json:"done,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// LogStream creates a subscription streaming the logs matching the given filter
+// criteria already stored within the state. Contrary to eth_getLogs, the logs
+// are pushed progressively as blocks are matched, and their number is not
+// limited.
+func (api *PublicFilterAPI) LogStream(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var (
+		rpcSub = notifier.CreateSubscription()
+		filter = newCriteriaFilter(api.backend, crit, nil)
+	)
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Abort the query if the client unsubscribes or goes away
+		go func() {
+			select {
+			case <-rpcSub.Err():
+			case <-notifier.Closed():
+			case <-ctx.Done():
+			}
+			cancel()
+		}()
+		err := filter.LogsFunc(ctx, func(logs []*types.Log) error {
+			last := logs[len(logs)-1]
+			return notifier.Notify(rpcSub.ID, &LogStreamEvent{
+				Logs:   logs,
+				Cursor: &LogCursor{BlockNumber: hexutil.Uint64(last.BlockNumber), LogIndex: hexutil.Uint(last.Index + 1)},
+			})
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		done := &LogStreamEvent{Done: true}
+		if err != nil {
+			done.Error = err.Error()
+		}
+		notifier.Notify(rpcSub.ID, done)
+	}()
+	return rpcSub, nil
+}
+
+// newCriteriaFilter constructs the filter executing the given query. If a cursor
+// is given, blocks preceding it are skipped.
+func newCriteriaFilter(backend Backend, crit FilterCriteria, cursor *LogCursor) *Filter {
+	if crit.BlockHash != nil {
+		// Block filter requested, construct a single-shot filter
+		return NewBlockFilter(backend, *crit.BlockHash, crit.Addresses, crit.Topics)
+	}
+	// Convert the RPC block numbers into internal representations
+	begin := rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	end := rpc.LatestBlockNumber.Int64()
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	if cursor != nil && begin < int64(cursor.BlockNumber) {
+		begin = int64(cursor.BlockNumber)
+	}
+	// Construct the range filter
+	return NewRangeFilter(backend, begin, end, crit.Addresses, crit.Topics)
+}
+
 // UninstallFilter removes the filter with the given filter id.
 func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
 	api.filtersMu.Lock()
@@ -368,26 +668,8 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 	if !found || f.typ != LogsSubscription {
 		return nil, fmt.Errorf("filter not found")
 	}
-
-	var filter *Filter
-	if f.crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
-		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
-		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
-	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	logs, err := api.limitedLogs(ctx, newCriteriaFilter(api.backend, f.crit, nil))
 	if err != nil {
 		return nil, err
 	}
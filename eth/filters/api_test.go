@@ -17,11 +17,22 @@
 package filters
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -183,3 +194,344 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
 }
+
+// newLogTestBackend creates a backend with a chain of 20 blocks, where blocks 2,
+// 5, 9 and 14 hold three logs each, emitted by the returned address.
+func newLogTestBackend(t *testing.T) (*testBackend, common.Address) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		addr    = common.HexToAddress("0x1234")
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 20, func(i int, gen *core.BlockGen) {
+		switch i + 1 {
+		case 2, 5, 9, 14:
+			receipt := types.NewReceipt(nil, false, 0)
+			for j := 0; j < 3; j++ {
+				receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, Topics: []common.Hash{{byte(j)}}})
+			}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, gen.BaseFee(), nil))
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	return backend, addr
+}
+
+// logPositions returns the block number and index of the given logs.
+func logPositions(logs []*types.Log) [][2]uint64 {
+	pos := make([][2]uint64, len(logs))
+	for i, log := range logs {
+		pos[i] = [2]uint64{log.BlockNumber, uint64(log.Index)}
+	}
+	return pos
+}
+
+// Tests that log queries exceeding the limit are rejected, suggesting a range
+// which fits into it.
+func TestGetLogsLimit(t *testing.T) {
+	backend, addr := newLogTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, deadline, 7)
+
+	from := big.NewInt(0)
+	logs, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: from, Addresses: []common.Address{addr}, Topics: [][]common.Hash{{{0}}}})
+	if err != nil {
+		t.Fatalf("failed to query logs within limit: %v", err)
+	}
+	if len(logs) != 4 {
+		t.Fatalf("wrong number of logs: have %d, want 4", len(logs))
+	}
+	_, err = api.GetLogs(context.Background(), FilterCriteria{FromBlock: from, Addresses: []common.Address{addr}})
+	if err == nil {
+		t.Fatal("query exceeding the limit succeeded")
+	}
+	limitErr, ok := err.(*logLimitError)
+	if !ok || limitErr.ErrorCode() != -32005 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limitErr.data.ToBlock == nil || *limitErr.data.ToBlock != 8 {
+		t.Fatalf("wrong suggested range end: %v", limitErr.data.ToBlock)
+	}
+	// The suggested range must fit
+	to := new(big.Int).SetUint64(uint64(*limitErr.data.ToBlock))
+	if _, err := api.GetLogs(context.Background(), FilterCriteria{FromBlock: from, ToBlock: to, Addresses: []common.Address{addr}}); err != nil {
+		t.Fatalf("failed to query suggested range: %v", err)
+	}
+}
+
+// Tests that logs can be retrieved page by page, resuming from the returned
+// cursors.
+func TestGetLogsPage(t *testing.T) {
+	backend, addr := newLogTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, deadline, 5)
+
+	var (
+		from   = big.NewInt(3)
+		crit   = FilterCriteria{FromBlock: from, Addresses: []common.Address{addr}}
+		limit  = hexutil.Uint(4)
+		cursor *LogCursor
+		pages  [][][2]uint64
+	)
+	for {
+		page, err := api.GetLogsPage(context.Background(), crit, cursor, &limit)
+		if err != nil {
+			t.Fatalf("failed to retrieve page %d: %v", len(pages), err)
+		}
+		pages = append(pages, logPositions(page.Logs))
+		if page.Next == nil {
+			break
+		}
+		cursor = page.Next
+	}
+	want := [][][2]uint64{
+		{{5, 0}, {5, 1}, {5, 2}, {9, 0}},
+		{{9, 1}, {9, 2}, {14, 0}, {14, 1}},
+		{{14, 2}},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("wrong pages: have %v, want %v", pages, want)
+	}
+	// Requested page sizes are capped by the configured limit
+	limit = 100
+	page, err := api.GetLogsPage(context.Background(), crit, nil, &limit)
+	if err != nil {
+		t.Fatalf("failed to retrieve page: %v", err)
+	}
+	if len(page.Logs) != 5 || page.Next == nil || *page.Next != (LogCursor{BlockNumber: 9, LogIndex: 2}) {
+		t.Fatalf("wrong capped page: logs %v, next %v", logPositions(page.Logs), page.Next)
+	}
+	// Pending logs cannot be paginated
+	pending := big.NewInt(rpc.PendingBlockNumber.Int64())
+	if _, err := api.GetLogsPage(context.Background(), FilterCriteria{ToBlock: pending}, nil, nil); err == nil {
+		t.Fatal("pending logs were paginated")
+	}
+}
+
+// Tests that the log stream subscription delivers the matching logs block by
+// block, finishing with a done notification.
+func TestLogStream(t *testing.T) {
+	backend, addr := newLogTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, deadline, 1)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	events := make(chan *LogStreamEvent)
+	sub, err := client.EthSubscribe(context.Background(), events, "logStream", map[string]interface{}{
+		"fromBlock": "0x0",
+		"address":   addr,
+		"topics":    [][]common.Hash{{{2}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var blocks []uint64
+	for {
+		select {
+		case ev := <-events:
+			if ev.Done {
+				if ev.Error != "" {
+					t.Fatalf("stream failed: %v", ev.Error)
+				}
+				if want := []uint64{2, 5, 9, 14}; !reflect.DeepEqual(blocks, want) {
+					t.Fatalf("wrong blocks streamed: have %v, want %v", blocks, want)
+				}
+				return
+			}
+			if len(ev.Logs) != 1 || ev.Cursor == nil || uint64(ev.Cursor.BlockNumber) != ev.Logs[0].BlockNumber || ev.Cursor.LogIndex != 3 {
+				t.Fatalf("unexpected event: logs %v, cursor %v", logPositions(ev.Logs), ev.Cursor)
+			}
+			blocks = append(blocks, ev.Logs[0].BlockNumber)
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for logs")
+		}
+	}
+}
+
+// Tests that log subscriptions starting at a historical block deliver the stored
+// logs first, then switch to the new ones without duplicates.
+func TestLogsCatchUp(t *testing.T) {
+	backend, addr := newLogTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, deadline, 0)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	logs := make(chan *types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{
+		"fromBlock": "0x3",
+		"address":   addr,
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Feed new logs racing with the historical ones: a duplicate of an already
+	// stored log, a log of a new block, and the removal of a delivered and of an
+	// undelivered log
+	hash := func(n uint64) common.Hash { return rawdb.ReadCanonicalHash(backend.db, n) }
+	backend.logsFeed.Send([]*types.Log{
+		{Address: addr, Topics: []common.Hash{{0}}, BlockNumber: 14, BlockHash: hash(14), Index: 0},
+		{Address: addr, Topics: []common.Hash{{0}}, BlockNumber: 21, BlockHash: common.Hash{21}, Index: 0},
+	})
+	backend.rmLogsFeed.Send(core.RemovedLogsEvent{Logs: []*types.Log{
+		{Address: addr, Topics: []common.Hash{{0}}, BlockNumber: 9, BlockHash: hash(9), Index: 0, Removed: true},
+		{Address: addr, Topics: []common.Hash{{0}}, BlockNumber: 9, BlockHash: common.Hash{9}, Index: 1, Removed: true},
+	}})
+
+	var received []*types.Log
+	for len(received) < 11 {
+		select {
+		case log := <-logs:
+			received = append(received, log)
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for logs, received %v", logPositions(received))
+		}
+	}
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log delivered: block %d index %d removed %v", log.BlockNumber, log.Index, log.Removed)
+	case <-time.After(100 * time.Millisecond):
+	}
+	want := [][2]uint64{{5, 0}, {5, 1}, {5, 2}, {9, 0}, {9, 1}, {9, 2}, {14, 0}, {14, 1}, {14, 2}}
+	if have := logPositions(received[:9]); !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong historical logs: have %v, want %v", have, want)
+	}
+	var live []string
+	for _, log := range received[9:] {
+		live = append(live, fmt.Sprintf("%d/%v", log.BlockNumber, log.Removed))
+	}
+	sort.Strings(live)
+	if want := []string{"21/false", "9/true"}; !reflect.DeepEqual(live, want) {
+		t.Fatalf("wrong new logs: have %v, want %v", live, want)
+	}
+}
+
+// Tests that all the logs of a block reorged in while a log subscription catches
+// up are delivered, along with the removal of the logs it replaced.
+func TestLogsCatchUpReorg(t *testing.T) {
+	backend, addr := newLogTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, deadline, 0)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	logs := make(chan *types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{
+		"fromBlock": "0x3",
+		"address":   addr,
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Replace block 9 with a sibling having three matching logs as well
+	var (
+		oldHash = rawdb.ReadCanonicalHash(backend.db, 9)
+		newHash = common.Hash{9}
+		removed []*types.Log
+		added   []*types.Log
+	)
+	for i := uint(0); i < 3; i++ {
+		removed = append(removed, &types.Log{Address: addr, Topics: []common.Hash{{0}}, BlockNumber: 9, BlockHash: oldHash, Index: i, Removed: true})
+		added = append(added, &types.Log{Address: addr, Topics: []common.Hash{{0}}, BlockNumber: 9, BlockHash: newHash, Index: i})
+	}
+	backend.rmLogsFeed.Send(core.RemovedLogsEvent{Logs: removed})
+	backend.logsFeed.Send(added)
+
+	var received []*types.Log
+	for len(received) < 15 {
+		select {
+		case log := <-logs:
+			received = append(received, log)
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for logs, received %v", logPositions(received))
+		}
+	}
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log delivered: block %d index %d removed %v", log.BlockNumber, log.Index, log.Removed)
+	case <-time.After(100 * time.Millisecond):
+	}
+	var live []string
+	for _, log := range received[9:] {
+		live = append(live, fmt.Sprintf("%x/%d/%v", log.BlockHash[:1], log.Index, log.Removed))
+	}
+	want := []string{
+		fmt.Sprintf("%x/0/true", oldHash[:1]), fmt.Sprintf("%x/1/true", oldHash[:1]), fmt.Sprintf("%x/2/true", oldHash[:1]),
+		"09/0/false", "09/1/false", "09/2/false",
+	}
+	if !reflect.DeepEqual(live, want) {
+		t.Fatalf("wrong new logs: have %v, want %v", live, want)
+	}
+}
+
+// Tests that log subscriptions failing to deliver the historical logs end with
+// the error delivered to the client.
+func TestLogsCatchUpError(t *testing.T) {
+	backend, addr := newLogTestBackend(t)
+	api := NewPublicFilterAPI(backend, false, deadline, 0)
+
+	// Lose track of the head block, failing the historical lookup
+	rawdb.WriteHeadBlockHash(backend.db, common.Hash{})
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	logs := make(chan *types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{
+		"fromBlock": "0x3",
+		"address":   addr,
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log delivered: block %d index %d", log.BlockNumber, log.Index)
+	case err := <-sub.Err():
+		if want := "failed to deliver historical logs: unknown head block"; err == nil || err.Error() != want {
+			t.Fatalf("wrong subscription error: have %v, want %q", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the subscription error")
+	}
+}
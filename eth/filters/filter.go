@@ -118,28 +118,45 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
+	var logs []*types.Log
+	err := f.LogsFunc(ctx, func(found []*types.Log) error {
+		logs = append(logs, found...)
+		return nil
+	})
+	return logs, err
+}
+
+// LogsFunc searches the blockchain for matching log entries like Logs, but
+// instead of gathering them, it invokes the callback with the matches of every
+// block as soon as they are found. The search is aborted if the callback returns
+// an error, which is passed back to the caller.
+func (f *Filter) LogsFunc(ctx context.Context, fn func([]*types.Log) error) error {
 	// If we're doing singleton block filtering, execute and return
 	if f.block != (common.Hash{}) {
 		header, err := f.backend.HeaderByHash(ctx, f.block)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if header == nil {
-			return nil, errors.New("unknown block")
+			return errors.New("unknown block")
+		}
+		logs, err := f.blockLogs(ctx, header)
+		if err != nil || len(logs) == 0 {
+			return err
 		}
-		return f.blockLogs(ctx, header)
+		return fn(logs)
 	}
 	// Short-cut if all we care about is pending logs
 	if f.begin == rpc.PendingBlockNumber.Int64() {
 		if f.end != rpc.PendingBlockNumber.Int64() {
-			return nil, errors.New("invalid block range")
+			return errors.New("invalid block range")
 		}
-		return f.pendingLogs()
+		return f.pendingLogs(fn)
 	}
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if header == nil {
-		return nil, nil
+		return nil
 	}
 	var (
 		head    = header.Number.Uint64()
@@ -153,50 +170,42 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		end = head
 	}
 	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs           []*types.Log
-		err            error
-		size, sections = f.backend.BloomStatus()
-	)
+	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
+		var err error
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			err = f.indexedLogs(ctx, end, fn)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			err = f.indexedLogs(ctx, indexed-1, fn)
 		}
 		if err != nil {
-			return logs, err
+			return err
 		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
-	logs = append(logs, rest...)
+	if err := f.unindexedLogs(ctx, end, fn); err != nil {
+		return err
+	}
 	if pending {
-		pendingLogs, err := f.pendingLogs()
-		if err != nil {
-			return nil, err
-		}
-		logs = append(logs, pendingLogs...)
+		return f.pendingLogs(fn)
 	}
-	return logs, err
+	return nil
 }
 
-// indexedLogs returns the logs matching the filter criteria based on the bloom
-// bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+// indexedLogs feeds the logs matching the filter criteria to the callback based
+// on the bloom bits indexed available locally or via the network.
+func (f *Filter) indexedLogs(ctx context.Context, end uint64, fn func([]*types.Log) error) error {
 	// Create a matcher session and request servicing from the backend
 	matches := make(chan uint64, 64)
 
 	session, err := f.matcher.Start(ctx, uint64(f.begin), end, matches)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer session.Close()
 
 	f.backend.ServiceFilter(ctx, session)
 
 	// Iterate over the matches until exhausted or context closed
-	var logs []*types.Log
-
 	for {
 		select {
 		case number, ok := <-matches:
@@ -206,44 +215,50 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 				if err == nil {
 					f.begin = int64(end) + 1
 				}
-				return logs, err
+				return err
 			}
 			f.begin = int64(number) + 1
 
 			// Retrieve the suggested block and pull any truly matching logs
 			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
 			if header == nil || err != nil {
-				return logs, err
+				return err
 			}
 			found, err := f.checkMatches(ctx, header)
 			if err != nil {
-				return logs, err
+				return err
+			}
+			if len(found) > 0 {
+				if err := fn(found); err != nil {
+					return err
+				}
 			}
-			logs = append(logs, found...)
 
 		case <-ctx.Done():
-			return logs, ctx.Err()
+			return ctx.Err()
 		}
 	}
 }
 
-// unindexedLogs returns the logs matching the filter criteria based on raw block
-// iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
-
+// unindexedLogs feeds the logs matching the filter criteria to the callback based
+// on raw block iteration and bloom matching.
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, fn func([]*types.Log) error) error {
 	for ; f.begin <= int64(end); f.begin++ {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
-			return logs, err
+			return err
 		}
 		found, err := f.blockLogs(ctx, header)
 		if err != nil {
-			return logs, err
+			return err
+		}
+		if len(found) > 0 {
+			if err := fn(found); err != nil {
+				return err
+			}
 		}
-		logs = append(logs, found...)
 	}
-	return logs, nil
+	return nil
 }
 
 // blockLogs returns the logs matching the filter criteria within a single block.
@@ -289,17 +304,20 @@ func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs [
 	return nil, nil
 }
 
-// pendingLogs returns the logs matching the filter criteria within the pending block.
-func (f *Filter) pendingLogs() ([]*types.Log, error) {
+// pendingLogs feeds the logs matching the filter criteria within the pending
+// block to the callback.
+func (f *Filter) pendingLogs(fn func([]*types.Log) error) error {
 	block, receipts := f.backend.PendingBlockAndReceipts()
 	if bloomFilter(block.Bloom(), f.addresses, f.topics) {
 		var unfiltered []*types.Log
 		for _, r := range receipts {
 			unfiltered = append(unfiltered, r.Logs...)
 		}
-		return filterLogs(unfiltered, nil, nil, f.addresses, f.topics), nil
+		if logs := filterLogs(unfiltered, nil, nil, f.addresses, f.topics); len(logs) > 0 {
+			return fn(logs)
+		}
 	}
-	return nil, nil
+	return nil
 }
 
 func includes(addresses []common.Address, a common.Address) bool {
@@ -0,0 +1,220 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BacktestResult contains the accuracy statistics of an oracle configuration
+// evaluated over a range of historical blocks.
+type BacktestResult struct {
+	Config       Config
+	Suggestions  int        // Number of blocks the oracle suggested a tip at
+	Included     int        // Number of suggestions which would have been included in time
+	Delay        uint64     // Total number of blocks the included suggestions waited for inclusion
+	Overpayments []*big.Int // Tips paid above the cheapest included one by the included suggestions, ascending
+}
+
+// HitRate returns the ratio of suggestions which would have been included in time.
+func (r *BacktestResult) HitRate() float64 {
+	if r.Suggestions == 0 {
+		return 0
+	}
+	return float64(r.Included) / float64(r.Suggestions)
+}
+
+// MeanDelay returns the average number of blocks the included suggestions waited
+// for inclusion.
+func (r *BacktestResult) MeanDelay() float64 {
+	if r.Included == 0 {
+		return 0
+	}
+	return float64(r.Delay) / float64(r.Included)
+}
+
+// MeanOverpayment returns the average tip paid above the cheapest included one.
+func (r *BacktestResult) MeanOverpayment() *big.Int {
+	total := new(big.Int)
+	if len(r.Overpayments) == 0 {
+		return total
+	}
+	for _, overpaid := range r.Overpayments {
+		total.Add(total, overpaid)
+	}
+	return total.Div(total, big.NewInt(int64(len(r.Overpayments))))
+}
+
+// OverpaymentPercentile returns the given percentile of the tips paid above the
+// cheapest included one.
+func (r *BacktestResult) OverpaymentPercentile(percentile int) *big.Int {
+	if len(r.Overpayments) == 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(r.Overpayments[(len(r.Overpayments)-1)*percentile/100])
+}
+
+// pinnedBackend is a view of an oracle backend with the head pinned to a
+// historical block, hiding any data which was not available at that time.
+type pinnedBackend struct {
+	OracleBackend
+	head uint64 // Accessed atomically
+}
+
+func (b *pinnedBackend) resolve(number rpc.BlockNumber) (rpc.BlockNumber, bool) {
+	head := rpc.BlockNumber(atomic.LoadUint64(&b.head))
+	if number == rpc.LatestBlockNumber {
+		return head, true
+	}
+	return number, number >= 0 && number <= head
+}
+
+// HeaderByNumber retrieves a header, if it was available at the pinned head.
+func (b *pinnedBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number, ok := b.resolve(number); ok {
+		return b.OracleBackend.HeaderByNumber(ctx, number)
+	}
+	return nil, nil
+}
+
+// BlockByNumber retrieves a block, if it was available at the pinned head.
+func (b *pinnedBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number, ok := b.resolve(number); ok {
+		return b.OracleBackend.BlockByNumber(ctx, number)
+	}
+	return nil, nil
+}
+
+// PendingBlockAndReceipts returns nothing, as the pending block at the time of
+// the pinned head is unknown.
+func (b *pinnedBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
+	return nil, nil
+}
+
+// Backtest evaluates the tip suggestions of a set of oracle configurations over
+// a range of historical blocks. At every block, each oracle suggests a tip seeing
+// only the chain up to that block. The suggestion counts as included if a
+// transaction paying it, with a fee cap of twice the base fee plus the tip,
+// would have out-tipped the cheapest transaction in any of the next window
+// blocks. Blocks without transactions of others than the miner are never
+// counted as including the suggestion, as they tell nothing about the price.
+func Backtest(ctx context.Context, backend OracleBackend, configs []Config, first, last uint64, window uint64) ([]*BacktestResult, error) {
+	if first > last {
+		return nil, fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if window == 0 {
+		return nil, fmt.Errorf("invalid inclusion window %d", window)
+	}
+	var (
+		view    = &pinnedBackend{OracleBackend: backend}
+		oracles = make([]*Oracle, len(configs))
+		results = make([]*BacktestResult, len(configs))
+		blocks  = make(map[uint64]*types.Block)
+		minTips = make(map[uint64]*big.Int)
+	)
+	for i, config := range configs {
+		oracles[i] = NewOracle(view, config)
+		results[i] = &BacktestResult{Config: config}
+	}
+	for number := first; number <= last; number++ {
+		atomic.StoreUint64(&view.head, number)
+
+		head, err := backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if head == nil {
+			return nil, fmt.Errorf("header #%d not found: %v", number, err)
+		}
+		// Retrieve the blocks of the inclusion window along with their cheapest
+		// tips, reusing the ones of the previous blocks
+		delete(blocks, number)
+		delete(minTips, number)
+		for next := number + 1; next <= number+window; next++ {
+			if _, ok := blocks[next]; ok {
+				continue
+			}
+			block, err := backend.BlockByNumber(ctx, rpc.BlockNumber(next))
+			if block == nil {
+				return nil, fmt.Errorf("block #%d not found: %v", next, err)
+			}
+			blocks[next] = block
+			minTips[next] = minimumTip(block, types.MakeSigner(backend.ChainConfig(), block.Number()))
+		}
+		for i, oracle := range oracles {
+			tip, err := oracle.SuggestTipCap(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("tip suggestion at block #%d failed: %v", number, err)
+			}
+			var feeCap *big.Int
+			if head.BaseFee != nil {
+				feeCap = new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+				feeCap.Add(feeCap, tip)
+			}
+			results[i].Suggestions++
+
+			for next := number + 1; next <= number+window; next++ {
+				minTip := minTips[next]
+				if minTip == nil {
+					continue
+				}
+				paid := tip
+				if baseFee := blocks[next].BaseFee(); feeCap != nil && baseFee != nil {
+					if baseFee.Cmp(feeCap) > 0 {
+						continue
+					}
+					if avail := new(big.Int).Sub(feeCap, baseFee); avail.Cmp(paid) < 0 {
+						paid = avail
+					}
+				}
+				if paid.Cmp(minTip) >= 0 {
+					results[i].Included++
+					results[i].Delay += next - number
+					results[i].Overpayments = append(results[i].Overpayments, new(big.Int).Sub(paid, minTip))
+					break
+				}
+			}
+		}
+	}
+	for _, result := range results {
+		sort.Sort(bigIntArray(result.Overpayments))
+	}
+	return results, nil
+}
+
+// minimumTip returns the lowest effective tip paid in a block by transactions
+// not sent by the miner, or nil if there are none.
+func minimumTip(block *types.Block, signer types.Signer) *big.Int {
+	var minTip *big.Int
+	for _, tx := range block.Transactions() {
+		if sender, err := types.Sender(signer, tx); err != nil || sender == block.Coinbase() {
+			continue
+		}
+		tip, err := tx.EffectiveGasTip(block.BaseFee())
+		if err != nil {
+			continue
+		}
+		if minTip == nil || tip.Cmp(minTip) < 0 {
+			minTip = tip
+		}
+	}
+	return minTip
+}
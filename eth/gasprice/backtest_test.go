@@ -0,0 +1,121 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newCyclicBackend creates a test backend with a transaction in every block,
+// paying tips cycling between 1 and 4 gwei.
+func newCyclicBackend(t *testing.T) *testBackend {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(math.MaxInt64)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, gspec.MustCommit(db), engine, db, testHead, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{1})
+		b.AddTx(types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &common.Address{},
+			Gas:       params.TxGas,
+			GasFeeCap: big.NewInt(100 * params.GWei),
+			GasTipCap: big.NewInt(int64(i%4+1) * params.GWei),
+		}))
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+	chain, err := core.NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create local chain, %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("Failed to insert chain, %v", err)
+	}
+	return &testBackend{chain: chain}
+}
+
+func TestBacktest(t *testing.T) {
+	backend := newCyclicBackend(t)
+	defer backend.chain.Stop()
+
+	var (
+		highest = Config{Blocks: 4, Percentile: 100, Default: big.NewInt(params.GWei)}
+		lowest  = Config{Blocks: 4, Percentile: 0, Default: big.NewInt(params.GWei)}
+	)
+	var cases = []struct {
+		config   Config
+		window   uint64
+		included int
+		delay    float64
+		overpaid *big.Int // Mean overpayment
+		maxpaid  *big.Int // Maximum overpayment
+	}{
+		// Suggesting the highest recent tip is always included in the next block,
+		// but pays up to 3 gwei more than needed
+		{highest, 1, 16, 1, big.NewInt(1.5 * params.GWei), big.NewInt(3 * params.GWei)},
+		{highest, 4, 16, 1, big.NewInt(1.5 * params.GWei), big.NewInt(3 * params.GWei)},
+
+		// Suggesting the lowest recent tip is only included in the blocks paying
+		// the same, at no overpayment
+		{lowest, 1, 4, 1, new(big.Int), new(big.Int)},
+		{lowest, 4, 16, 2.5, new(big.Int), new(big.Int)},
+	}
+	for i, c := range cases {
+		results, err := Backtest(context.Background(), backend, []Config{c.config}, 10, 25, c.window)
+		if err != nil {
+			t.Fatalf("test %d: backtest failed: %v", i, err)
+		}
+		res := results[0]
+		if res.Suggestions != 16 || res.Included != c.included {
+			t.Errorf("test %d: inclusion mismatch: have %d/%d, want %d/16", i, res.Included, res.Suggestions, c.included)
+		}
+		if res.MeanDelay() != c.delay {
+			t.Errorf("test %d: delay mismatch: have %v, want %v", i, res.MeanDelay(), c.delay)
+		}
+		if res.MeanOverpayment().Cmp(c.overpaid) != 0 {
+			t.Errorf("test %d: mean overpayment mismatch: have %v, want %v", i, res.MeanOverpayment(), c.overpaid)
+		}
+		if res.OverpaymentPercentile(100).Cmp(c.maxpaid) != 0 {
+			t.Errorf("test %d: max overpayment mismatch: have %v, want %v", i, res.OverpaymentPercentile(100), c.maxpaid)
+		}
+	}
+	// The inclusion window of the last evaluated block must be available
+	if _, err := Backtest(context.Background(), backend, []Config{highest}, 25, 32, 1); err == nil {
+		t.Errorf("backtest evaluated beyond the chain head")
+	}
+}
@@ -47,6 +47,7 @@ type Config struct {
 	Default          *big.Int `toml:",omitempty"`
 	MaxPrice         *big.Int `toml:",omitempty"`
 	IgnorePrice      *big.Int `toml:",omitempty"`
+	Strategy         string   `toml:",omitempty"`
 }
 
 // OracleBackend includes all necessary background APIs for oracle.
@@ -67,6 +68,7 @@ type Oracle struct {
 	lastPrice   *big.Int
 	maxPrice    *big.Int
 	ignorePrice *big.Int
+	strategy    strategy
 	cacheLock   sync.RWMutex
 	fetchLock   sync.Mutex
 
@@ -113,6 +115,11 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		maxBlockHistory = 1
 		log.Warn("Sanitizing invalid gasprice oracle max block history", "provided", params.MaxBlockHistory, "updated", maxBlockHistory)
 	}
+	strategy, err := newStrategy(params.Strategy)
+	if err != nil {
+		strategy, _ = newStrategy(StrategyPercentile)
+		log.Warn("Sanitizing invalid gasprice oracle strategy", "provided", params.Strategy, "updated", StrategyPercentile)
+	}
 
 	cache, _ := lru.New(2048)
 	headEvent := make(chan core.ChainHeadEvent, 1)
@@ -132,6 +139,7 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		lastPrice:        params.Default,
 		maxPrice:         maxPrice,
 		ignorePrice:      ignorePrice,
+		strategy:         strategy,
 		checkBlocks:      blocks,
 		percentile:       percent,
 		maxHeaderHistory: maxHeaderHistory,
@@ -172,7 +180,8 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 		number    = head.Number.Uint64()
 		result    = make(chan results, oracle.checkBlocks)
 		quit      = make(chan struct{})
-		results   []*big.Int
+		samples   []results
+		values    int // Number of sampled values, bounding the extra queries
 	)
 	for sent < oracle.checkBlocks && number > 0 {
 		go oracle.getBlockValues(ctx, types.MakeSigner(oracle.backend.ChainConfig(), big.NewInt(int64(number))), number, sampleNumber, oracle.ignorePrice, result, quit)
@@ -197,18 +206,18 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 		// Besides, in order to collect enough data for sampling, if nothing
 		// meaningful returned, try to query more blocks. But the maximum
 		// is 2*checkBlocks.
-		if len(res.values) == 1 && len(results)+1+exp < oracle.checkBlocks*2 && number > 0 {
+		if len(res.values) == 1 && values+1+exp < oracle.checkBlocks*2 && number > 0 {
 			go oracle.getBlockValues(ctx, types.MakeSigner(oracle.backend.ChainConfig(), big.NewInt(int64(number))), number, sampleNumber, oracle.ignorePrice, result, quit)
 			sent++
 			exp++
 			number--
 		}
-		results = append(results, res.values...)
+		samples = append(samples, res)
+		values += len(res.values)
 	}
 	price := lastPrice
-	if len(results) > 0 {
-		sort.Sort(bigIntArray(results))
-		price = results[(len(results)-1)*oracle.percentile/100]
+	if len(samples) > 0 {
+		price = oracle.strategy.suggest(samples, oracle.percentile)
 	}
 	if price.Cmp(oracle.maxPrice) > 0 {
 		price = new(big.Int).Set(oracle.maxPrice)
@@ -222,6 +231,7 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 }
 
 type results struct {
+	number uint64
 	values []*big.Int
 	err    error
 }
@@ -258,7 +268,7 @@ func (oracle *Oracle) getBlockValues(ctx context.Context, signer types.Signer, b
 	block, err := oracle.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
 	if block == nil {
 		select {
-		case result <- results{nil, err}:
+		case result <- results{blockNum, nil, err}:
 		case <-quit:
 		}
 		return
@@ -284,7 +294,7 @@ func (oracle *Oracle) getBlockValues(ctx context.Context, signer types.Signer, b
 		}
 	}
 	select {
-	case result <- results{prices, nil}:
+	case result <- results{blockNum, prices, nil}:
 	case <-quit:
 	}
 }
@@ -191,3 +191,32 @@ func TestSuggestTipCap(t *testing.T) {
 		}
 	}
 }
+
+func TestSuggestTipCapStrategy(t *testing.T) {
+	var cases = []struct {
+		strategy string
+		expect   *big.Int // Expected gasprice suggestion
+	}{
+		{StrategyPercentile, big.NewInt(params.GWei * int64(30))},
+		{StrategyMinimum, big.NewInt(params.GWei * int64(30))},
+		{StrategyWeighted, big.NewInt(params.GWei * int64(31))},
+		{"unknown", big.NewInt(params.GWei * int64(30))}, // Falls back to percentile
+	}
+	for _, c := range cases {
+		backend := newTestBackend(t, big.NewInt(0), false)
+		oracle := NewOracle(backend, Config{
+			Blocks:     3,
+			Percentile: 60,
+			Default:    big.NewInt(params.GWei),
+			Strategy:   c.strategy,
+		})
+		// The gas price sampled is: 32G, 31G, 30G, 29G, 28G, 27G
+		got, err := oracle.SuggestTipCap(context.Background())
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve recommended gas price: %v", c.strategy, err)
+		}
+		if got.Cmp(c.expect) != 0 {
+			t.Fatalf("%s: gas price mismatch, want %d, got %d", c.strategy, c.expect, got)
+		}
+	}
+}
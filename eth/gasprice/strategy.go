@@ -0,0 +1,116 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Strategies of deriving the suggested tip from the tips sampled in recent blocks.
+const (
+	StrategyPercentile = "percentile" // Percentile of all the sampled tips
+	StrategyMinimum    = "minimum"    // Percentile of the lowest sampled tip of each block
+	StrategyWeighted   = "weighted"   // Percentile of all the sampled tips, weighting recent blocks higher
+)
+
+// strategy derives the suggested tip from the lowest tips of recent blocks,
+// sampled in ascending order.
+type strategy interface {
+	suggest(samples []results, percentile int) *big.Int
+}
+
+// newStrategy creates the tip suggestion strategy with the given name.
+func newStrategy(name string) (strategy, error) {
+	switch name {
+	case StrategyPercentile, "":
+		return percentileStrategy{}, nil
+	case StrategyMinimum:
+		return minimumStrategy{}, nil
+	case StrategyWeighted:
+		return weightedStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown gasprice oracle strategy %q", name)
+	}
+}
+
+// percentileStrategy suggests the given percentile of all the sampled tips.
+type percentileStrategy struct{}
+
+func (percentileStrategy) suggest(samples []results, percentile int) *big.Int {
+	var tips []*big.Int
+	for _, sample := range samples {
+		tips = append(tips, sample.values...)
+	}
+	sort.Sort(bigIntArray(tips))
+	return tips[(len(tips)-1)*percentile/100]
+}
+
+// minimumStrategy suggests the given percentile of the cheapest sampled tip of
+// each block, i.e. the tips which were just enough to get included.
+type minimumStrategy struct{}
+
+func (minimumStrategy) suggest(samples []results, percentile int) *big.Int {
+	tips := make([]*big.Int, 0, len(samples))
+	for _, sample := range samples {
+		tips = append(tips, sample.values[0])
+	}
+	sort.Sort(bigIntArray(tips))
+	return tips[(len(tips)-1)*percentile/100]
+}
+
+// weightedStrategy suggests the given percentile of all the sampled tips, with
+// the tips of each block counted as many times as the block is newer than the
+// one before the oldest sampled block. This lets the suggestion follow rising
+// and falling prices faster than the plain percentile.
+type weightedStrategy struct{}
+
+func (weightedStrategy) suggest(samples []results, percentile int) *big.Int {
+	type weightedTip struct {
+		tip    *big.Int
+		weight uint64
+	}
+	oldest := samples[0].number
+	for _, sample := range samples {
+		if sample.number < oldest {
+			oldest = sample.number
+		}
+	}
+	var (
+		tips  []weightedTip
+		total uint64
+	)
+	for _, sample := range samples {
+		weight := sample.number - oldest + 1
+		for _, tip := range sample.values {
+			tips = append(tips, weightedTip{tip, weight})
+			total += weight
+		}
+	}
+	sort.SliceStable(tips, func(i, j int) bool { return tips[i].tip.Cmp(tips[j].tip) < 0 })
+
+	// Pick the tip at the percentile position of the weighted tips
+	position := (total - 1) * uint64(percentile) / 100
+	for _, tip := range tips {
+		if position < tip.weight {
+			return tip.tip
+		}
+		position -= tip.weight
+	}
+	return tips[len(tips)-1].tip
+}
@@ -65,6 +65,10 @@ type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// AddRemotesFrom should add the given transactions received from a peer
+	// to the pool.
+	AddRemotesFrom(string, []*types.Transaction) []error
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending(enforceTips bool) map[common.Address]types.Transactions
@@ -290,7 +294,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return p.RequestTxs(hashes)
 	}
-	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
+	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotesFrom, fetchTx)
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
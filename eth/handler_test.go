@@ -91,6 +91,12 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// AddRemotesFrom appends a batch of transactions received from a peer to the
+// pool, ignoring the peer.
+func (p *testTxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return p.AddRemotes(txs)
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending(enforceTips bool) map[common.Address]types.Transactions {
 	p.lock.RLock()
@@ -22,6 +22,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -246,10 +247,14 @@ func handleGetNodeData66(backend Backend, msg Decoder, peer *Peer) error {
 // ServiceGetNodeDataQuery assembles the response to a node data query. It is
 // exposed to allow external packages to test protocol behavior.
 func ServiceGetNodeDataQuery(chain *core.BlockChain, query GetNodeDataPacket) [][]byte {
-	// Gather state data until the fetch or network limits is reached
+	// Gather state data until the fetch or network limits is reached. Trie nodes
+	// can't be looked up by hash with the path-based scheme, so only contract
+	// codes are served in that case.
 	var (
 		bytes int
 		nodes [][]byte
+
+		hashScheme = chain.StateCache().TrieDB().Scheme() == rawdb.HashScheme
 	)
 	for lookups, hash := range query {
 		if bytes >= softResponseLimit || len(nodes) >= maxNodeDataServe ||
@@ -257,7 +262,13 @@ func ServiceGetNodeDataQuery(chain *core.BlockChain, query GetNodeDataPacket) []
 			break
 		}
 		// Retrieve the requested state entry
-		entry, err := chain.TrieNode(hash)
+		var (
+			entry []byte
+			err   error
+		)
+		if hashScheme {
+			entry, err = chain.TrieNode(hash)
+		}
 		if len(entry) == 0 || err != nil {
 			// Read the contract code with prefix only to save unnecessary lookups.
 			entry, err = chain.ContractCodeWithPrefix(hash)
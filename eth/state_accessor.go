@@ -30,6 +30,13 @@ import (
 	"github.com/ethereum/go-ethereum/trie"
 )
 
+// ephemeralTrieConfig returns the configuration of the trie databases created
+// to regenerate historical states in isolation from the live one. They have to
+// use the same node storage scheme as the chain to be able to read its state.
+func (eth *Ethereum) ephemeralTrieConfig() *trie.Config {
+	return &trie.Config{Cache: 16, Scheme: eth.blockchain.StateCache().TrieDB().Scheme()}
+}
+
 // StateAtBlock retrieves the state database associated with a certain block.
 // If no state is locally available for the given block, a number of blocks
 // are attempted to be reexecuted to generate the desired state. The optional
@@ -63,7 +70,7 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 		if preferDisk {
 			// Create an ephemeral trie.Database for isolating the live one. Otherwise
 			// the internal junks created by tracing will be persisted into the disk.
-			database = state.NewDatabaseWithConfig(eth.chainDb, &trie.Config{Cache: 16})
+			database = state.NewDatabaseWithConfig(eth.chainDb, eth.ephemeralTrieConfig())
 			if statedb, err = state.New(block.Root(), database, nil); err == nil {
 				log.Info("Found disk backend for state trie", "root", block.Root(), "number", block.Number())
 				return statedb, nil
@@ -78,7 +85,7 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 
 		// Create an ephemeral trie.Database for isolating the live one. Otherwise
 		// the internal junks created by tracing will be persisted into the disk.
-		database = state.NewDatabaseWithConfig(eth.chainDb, &trie.Config{Cache: 16})
+		database = state.NewDatabaseWithConfig(eth.chainDb, eth.ephemeralTrieConfig())
 
 		// If we didn't check the dirty database, do check the clean one, otherwise
 		// we would rewind past a persisted block (specific corner case is chain
@@ -0,0 +1,141 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newPathSchemeBackend creates an Ethereum backend around a chain using the
+// path-based state scheme, with a value transfer in each of the given number
+// of blocks.
+func newPathSchemeBackend(t *testing.T, n int) (*Ethereum, []*types.Block) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}}}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, n, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0xaa}, big.NewInt(1), params.TxGas, block.BaseFee(), nil), signer, key)
+		block.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteStateScheme(db, rawdb.PathScheme)
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieCleanLimit: 16, TrieTimeLimit: 5 * time.Minute, StateScheme: rawdb.PathScheme}, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	return &Ethereum{
+		config:     &ethconfig.Config{},
+		chainDb:    db,
+		blockchain: chain,
+		engine:     chain.Engine(),
+	}, blocks
+}
+
+// Tests that historical states are regenerated on top of the state persisted by
+// the path-based scheme, without touching the live database.
+func TestStateAtBlockPathScheme(t *testing.T) {
+	eth, blocks := newPathSchemeBackend(t, 16)
+	defer eth.blockchain.Stop()
+
+	block := blocks[9]
+	statedb, err := eth.StateAtBlock(block, 16, nil, false, false)
+	if err != nil {
+		t.Fatalf("failed to regenerate state: %v", err)
+	}
+	if root := statedb.IntermediateRoot(true); root != block.Root() {
+		t.Fatalf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	if have, want := statedb.GetBalance(common.Address{0xaa}), big.NewInt(10); have.Cmp(want) != 0 {
+		t.Fatalf("balance mismatch: have %v, want %v", have, want)
+	}
+	if _, err := eth.StateAtBlock(block, 4, nil, false, false); err == nil {
+		t.Fatalf("state regenerated beyond the reexec limit")
+	}
+}
+
+// Tests that a chain segment can be traced with the path-based scheme, which
+// regenerates the starting state in an ephemeral database.
+func TestTraceChainPathScheme(t *testing.T) {
+	eth, blocks := newPathSchemeBackend(t, 16)
+	defer eth.blockchain.Stop()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", tracers.NewAPI(&EthAPIBackend{eth: eth})); err != nil {
+		t.Fatalf("failed to register tracing API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	type result struct {
+		Block  hexutil.Uint64
+		Hash   common.Hash
+		Traces []struct {
+			Result json.RawMessage
+			Error  string
+		}
+	}
+	results := make(chan *result)
+	sub, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(5), hexutil.Uint64(10))
+	if err != nil {
+		t.Fatalf("failed to subscribe to chain traces: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for number := uint64(6); number <= 10; number++ {
+		select {
+		case res := <-results:
+			if uint64(res.Block) != number || res.Hash != blocks[number-1].Hash() {
+				t.Fatalf("traced block mismatch: have #%d [%x], want #%d [%x]", res.Block, res.Hash, number, blocks[number-1].Hash())
+			}
+			if len(res.Traces) != 1 || res.Traces[0].Error != "" {
+				t.Fatalf("block #%d: trace mismatch: %+v", number, res.Traces)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("chain tracing failed: %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("block #%d: trace timeout", number)
+		}
+	}
+}
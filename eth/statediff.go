@@ -0,0 +1,179 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// stateDiffChanSize is the size of channel listening to StateDiffEvent.
+const stateDiffChanSize = 64
+
+// StateDiffResult is the JSON representation of the state changes of a block.
+// If the changes are missing, only the block is identified, with Missing set.
+type StateDiffResult struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Missing     bool           `json:"missing,omitempty"`
+	*snapshot.StateDiff
+}
+
+// newStateDiffResult converts a state diff event into its JSON representation.
+func newStateDiffResult(ev core.StateDiffEvent) *StateDiffResult {
+	return &StateDiffResult{
+		BlockHash:   ev.Hash,
+		BlockNumber: hexutil.Uint64(ev.Number),
+		Missing:     ev.Diff == nil,
+		StateDiff:   ev.Diff,
+	}
+}
+
+// PublicStateDiffAPI provides an API to follow the state changes of the blocks
+// imported by the node.
+type PublicStateDiffAPI struct {
+	eth *Ethereum
+}
+
+// NewPublicStateDiffAPI creates a new state diff API.
+func NewPublicStateDiffAPI(eth *Ethereum) *PublicStateDiffAPI {
+	return &PublicStateDiffAPI{eth: eth}
+}
+
+// StateDiffs creates a subscription that is triggered each time the state
+// changes of a block are written into the database. Side chain blocks are
+// reported too, so subscribers should track the block hashes to follow the
+// canonical chain. Diffs are only available if snapshots are enabled, blocks
+// whose changes are unavailable are reported as missing.
+func (api *PublicStateDiffAPI) StateDiffs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		diffs := make(chan core.StateDiffEvent, stateDiffChanSize)
+		diffsSub := api.eth.blockchain.SubscribeStateDiffEvent(diffs)
+		defer diffsSub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-diffs:
+				notifier.Notify(rpcSub.ID, newStateDiffResult(ev))
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// stateDiffExporter appends the state changes of every block written by the
+// chain to a file, either as RLP stream (if the file ends with .rlp) or as JSON
+// lines (otherwise).
+type stateDiffExporter struct {
+	file   *os.File
+	out    *bufio.Writer
+	useRLP bool
+
+	sub  event.Subscription
+	ch   chan core.StateDiffEvent
+	done chan struct{}
+}
+
+// newStateDiffExporter opens the export file for appending and starts writing
+// the state diffs of the given chain into it.
+func newStateDiffExporter(path string, chain *core.BlockChain) (*stateDiffExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e := &stateDiffExporter{
+		file:   file,
+		out:    bufio.NewWriter(file),
+		useRLP: strings.ToLower(filepath.Ext(path)) == ".rlp",
+		ch:     make(chan core.StateDiffEvent, stateDiffChanSize),
+		done:   make(chan struct{}),
+	}
+	e.sub = chain.SubscribeStateDiffEvent(e.ch)
+
+	log.Info("Exporting state diffs", "path", path, "rlp", e.useRLP)
+	go e.loop()
+	return e, nil
+}
+
+// loop writes out the state diffs until the subscription is terminated.
+func (e *stateDiffExporter) loop() {
+	defer close(e.done)
+
+	for {
+		select {
+		case ev := <-e.ch:
+			if err := e.write(ev); err != nil {
+				log.Error("Failed to export state diff", "number", ev.Number, "hash", ev.Hash, "err", err)
+			}
+		case <-e.sub.Err():
+			return
+		}
+	}
+}
+
+// write appends a single state diff to the export file.
+func (e *stateDiffExporter) write(ev core.StateDiffEvent) error {
+	if e.useRLP {
+		if err := rlp.Encode(e.out, &ev); err != nil {
+			return err
+		}
+	} else {
+		blob, err := json.Marshal(newStateDiffResult(ev))
+		if err != nil {
+			return err
+		}
+		if _, err := e.out.Write(append(blob, '\n')); err != nil {
+			return err
+		}
+	}
+	return e.out.Flush()
+}
+
+// close terminates the export and closes the file.
+func (e *stateDiffExporter) close() error {
+	e.sub.Unsubscribe()
+	<-e.done
+
+	if err := e.out.Flush(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
@@ -163,6 +163,27 @@ func (api *API) blockByNumberAndHash(ctx context.Context, number rpc.BlockNumber
 	return api.blockByHash(ctx, hash)
 }
 
+// blockByNumberOrHash retrieves the block specified either by number or hash.
+// The pending block is rejected, since the miner is not accessible from here.
+func (api *API) blockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return api.blockByHash(ctx, hash)
+	}
+	number, ok := blockNrOrHash.Number()
+	if !ok {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if number == rpc.PendingBlockNumber {
+		// We don't have access to the miner here. For tracing 'future' transactions,
+		// it can be done with block- and state-overrides instead, which offers
+		// more flexibility and stability than trying to trace on 'pending', since
+		// the contents of 'pending' is unstable and probably not a true representation
+		// of what the next actual block is likely to contain.
+		return nil, errors.New("tracing on top of pending is not supported")
+	}
+	return api.blockByNumber(ctx, number)
+}
+
 // TraceConfig holds extra parameters to trace functions.
 type TraceConfig struct {
 	*logger.Config
@@ -809,25 +830,7 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 // top of the provided block and returns them as a JSON object.
 func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
 	// Try to retrieve the specified block
-	var (
-		err   error
-		block *types.Block
-	)
-	if hash, ok := blockNrOrHash.Hash(); ok {
-		block, err = api.blockByHash(ctx, hash)
-	} else if number, ok := blockNrOrHash.Number(); ok {
-		if number == rpc.PendingBlockNumber {
-			// We don't have access to the miner here. For tracing 'future' transactions,
-			// it can be done with block- and state-overrides instead, which offers
-			// more flexibility and stability than trying to trace on 'pending', since
-			// the contents of 'pending' is unstable and probably not a true representation
-			// of what the next actual block is likely to contain.
-			return nil, errors.New("tracing on top of pending is not supported")
-		}
-		block, err = api.blockByNumber(ctx, number)
-	} else {
-		return nil, errors.New("invalid arguments; neither block nor hash specified")
-	}
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
@@ -922,5 +925,11 @@ func APIs(backend Backend) []rpc.API {
 			Service:   NewAPI(backend),
 			Public:    false,
 		},
+		{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewBundleAPI(backend),
+			Public:    true,
+		},
 	}
 }
@@ -26,6 +26,7 @@ import (
 	"math/big"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -575,6 +576,83 @@ func TestTracingWithOverrides(t *testing.T) {
 	}
 }
 
+func TestTraceCallMany(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(3)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	var txs []common.Hash
+	signer := types.HomesteadSigner{}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		// Two transfers from account[0] to account[1]
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+			txs = append(txs, tx.Hash())
+		}
+	}))
+	// Insert a transfer from account[0] between the two transactions, which
+	// invalidates the second one, and a transfer without funds
+	index := hexutil.Uint(1)
+	calls := []ethapi.TransactionArgs{
+		{From: &accounts[0].addr, To: &accounts[2].addr, Value: (*hexutil.Big)(big.NewInt(1000))},
+		{From: &accounts[2].addr, To: &accounts[1].addr, Value: (*hexutil.Big)(big.NewInt(params.Ether))},
+	}
+	results, err := api.TraceCallMany(context.Background(), calls, rpc.BlockNumberOrHashWithNumber(1), &TraceCallManyConfig{
+		Config:   &logger.Config{},
+		TxIndex:  &index,
+		Continue: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to trace call bundle: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), 3)
+	}
+	if res := results[0]; res.TxHash != nil || res.Error != "" || res.Receipt == nil || res.Trace == nil {
+		t.Fatalf("injected call result mismatch: %+v", res)
+	}
+	if have, want := results[0].Receipt.CumulativeGasUsed, 2*params.TxGas; have != want {
+		t.Errorf("cumulative gas mismatch: have %d, want %d", have, want)
+	}
+	if res := results[1]; res.Receipt != nil || !strings.Contains(res.Error, "insufficient funds") {
+		t.Errorf("underfunded call result mismatch: %+v", res)
+	}
+	if res := results[2]; res.TxHash == nil || *res.TxHash != txs[1] || res.Receipt != nil || !strings.Contains(res.Error, "nonce too low") {
+		t.Errorf("replayed transaction result mismatch: %+v", res)
+	}
+	// Ensure the calls are appended to the end of the block by default
+	results, err = api.TraceCallMany(context.Background(), calls[:1], rpc.BlockNumberOrHashWithNumber(1), nil)
+	if err != nil {
+		t.Fatalf("failed to trace call bundle: %v", err)
+	}
+	if res := results[0]; res.Receipt == nil || res.Receipt.TransactionIndex != 2 || res.Receipt.CumulativeGasUsed != 3*params.TxGas || res.Trace != nil {
+		t.Errorf("appended call result mismatch: %+v", res)
+	}
+	// Ensure eth_callBundle executes the same bundle without tracing it
+	bundles := &BundleAPI{api: api}
+	results, err = bundles.CallBundle(context.Background(), calls, rpc.BlockNumberOrHashWithNumber(1), &CallBundleConfig{
+		TxIndex:  &index,
+		Continue: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to call bundle: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), 3)
+	}
+	if res := results[0]; res.Receipt == nil || res.Receipt.CumulativeGasUsed != 2*params.TxGas || res.Trace != nil {
+		t.Errorf("bundle call result mismatch: %+v", res)
+	}
+	if res := results[2]; res.TxHash == nil || *res.TxHash != txs[1] || !strings.Contains(res.Error, "nonce too low") {
+		t.Errorf("bundle replayed transaction result mismatch: %+v", res)
+	}
+}
+
 type Account struct {
 	key  *ecdsa.PrivateKey
 	addr common.Address
@@ -0,0 +1,298 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TraceCallManyConfig is the config for the traceCallMany API.
+type TraceCallManyConfig struct {
+	*logger.Config
+	Tracer         *string
+	Timeout        *string
+	Reexec         *uint64
+	StateOverrides *ethapi.StateOverride
+
+	// TxIndex is the position within the block at which the calls are inserted,
+	// defaulting to the end of the block.
+	TxIndex *hexutil.Uint
+
+	// Continue requests the remaining transactions of the block to be replayed
+	// on top of the calls.
+	Continue bool
+}
+
+// bundleTxResult is the outcome of a single transaction executed by traceCallMany.
+type bundleTxResult struct {
+	TxHash     *common.Hash   `json:"txHash,omitempty"`  // Hash of the block transaction, nil for the injected calls
+	Receipt    *types.Receipt `json:"receipt,omitempty"` // Receipt of the execution, nil if the transaction is invalid
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Error      string         `json:"error,omitempty"` // Execution failure, or the reason the transaction is invalid
+	Trace      interface{}    `json:"trace,omitempty"` // Trace result, if a tracer was requested
+}
+
+// TraceCallMany executes a bundle of calls inserted at a given position of an
+// existing block. The transactions of the block preceding the position are
+// replayed first, then the calls are executed one after the other, each on top
+// of the state left behind by the previous one. Optionally, the remaining
+// transactions of the block are replayed afterwards, showing how the calls
+// affect them.
+//
+// A receipt is returned for every executed transaction. If a tracer or a logger
+// config is specified, each of them is traced too.
+func (api *API) TraceCallMany(ctx context.Context, calls []ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallManyConfig) ([]*bundleTxResult, error) {
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if config == nil {
+		config = &TraceCallManyConfig{}
+	}
+	reexec := defaultTraceReexec
+	if config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	txs := block.Transactions()
+	index := len(txs)
+	if config.TxIndex != nil {
+		index = int(*config.TxIndex)
+	}
+	if index > len(txs) {
+		return nil, fmt.Errorf("transaction index %d out of range for block %#x", index, block.Hash())
+	}
+	// Retrieve the state right before the insertion point
+	var statedb *state.StateDB
+	if index < len(txs) || len(txs) == 0 {
+		_, _, statedb, err = api.backend.StateAtTransaction(ctx, block, index, reexec)
+	} else {
+		statedb, err = api.backend.StateAtBlock(ctx, block, reexec, nil, true, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := config.StateOverrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	var traceConfig *TraceConfig
+	if config.Tracer != nil || config.Config != nil {
+		traceConfig = &TraceConfig{
+			Config:  config.Config,
+			Tracer:  config.Tracer,
+			Timeout: config.Timeout,
+		}
+	}
+	// Resume the gas accounting of the block at the insertion point, if known
+	var cumulative uint64
+	if index > 0 {
+		receipts := rawdb.ReadReceipts(api.backend.ChainDb(), block.Hash(), block.NumberU64(), api.backend.ChainConfig())
+		if len(receipts) == len(txs) {
+			cumulative = receipts[index-1].CumulativeGasUsed
+		}
+	}
+	var (
+		vmctx   = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+		signer  = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+		results = make([]*bundleTxResult, 0, len(calls))
+		logs    int // Number of logs emitted by the previous calls, they share the empty hash
+	)
+	for i, args := range calls {
+		msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		txctx := &Context{BlockHash: block.Hash(), TxIndex: index + i}
+		result, err := api.executeBundleTx(ctx, msg, txctx, vmctx, statedb, traceConfig, &cumulative)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		if result.Receipt != nil {
+			all := statedb.GetLogs(common.Hash{}, block.Hash())
+			result.Receipt.Logs = all[logs:]
+			result.Receipt.Bloom = types.CreateBloom(types.Receipts{result.Receipt})
+			logs = len(all)
+		}
+		results = append(results, result)
+	}
+	if !config.Continue {
+		return results, nil
+	}
+	// Replay the rest of the block, their outcome might have been changed
+	for i, tx := range txs[index:] {
+		hash := tx.Hash()
+		msg, err := tx.AsMessage(signer, block.BaseFee())
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x: %w", hash, err)
+		}
+		txctx := &Context{BlockHash: block.Hash(), TxIndex: index + len(calls) + i, TxHash: hash}
+		result, err := api.executeBundleTx(ctx, msg, txctx, vmctx, statedb, traceConfig, &cumulative)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %#x: %w", hash, err)
+		}
+		result.TxHash = &hash
+		if result.Receipt != nil {
+			result.Receipt.Type = tx.Type()
+			result.Receipt.TxHash = hash
+			result.Receipt.Logs = statedb.GetLogs(hash, block.Hash())
+			result.Receipt.Bloom = types.CreateBloom(types.Receipts{result.Receipt})
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// CallBundleConfig is the config for the callBundle API.
+type CallBundleConfig struct {
+	Reexec         *uint64
+	StateOverrides *ethapi.StateOverride
+
+	// TxIndex is the position within the block at which the calls are inserted,
+	// defaulting to the end of the block.
+	TxIndex *hexutil.Uint
+
+	// Continue requests the remaining transactions of the block to be replayed
+	// on top of the calls.
+	Continue bool
+}
+
+// BundleAPI is the collection of bundle execution methods exposed over the eth
+// namespace.
+type BundleAPI struct {
+	api *API
+}
+
+// NewBundleAPI creates a new API definition for executing call bundles.
+func NewBundleAPI(backend Backend) *BundleAPI {
+	return &BundleAPI{api: NewAPI(backend)}
+}
+
+// CallBundle executes a bundle of calls inserted at a given position of an
+// existing block, like debug_traceCallMany does, returning the receipts of the
+// executed transactions without tracing them.
+func (b *BundleAPI) CallBundle(ctx context.Context, calls []ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *CallBundleConfig) ([]*bundleTxResult, error) {
+	traceConfig := new(TraceCallManyConfig)
+	if config != nil {
+		traceConfig.Reexec = config.Reexec
+		traceConfig.StateOverrides = config.StateOverrides
+		traceConfig.TxIndex = config.TxIndex
+		traceConfig.Continue = config.Continue
+	}
+	return b.api.TraceCallMany(ctx, calls, blockNrOrHash, traceConfig)
+}
+
+// executeBundleTx executes a single message of a bundle on top of the given
+// state, tracing it if requested. Messages which cannot be applied are reported
+// in the result without touching the state, only unexpected failures (such as a
+// timeout) are returned as errors.
+func (api *API) executeBundleTx(ctx context.Context, msg core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig, cumulative *uint64) (*bundleTxResult, error) {
+	var (
+		tracer   Tracer
+		err      error
+		timeout  = defaultTraceTimeout
+		vmConfig = vm.Config{NoBaseFee: true}
+	)
+	if config != nil {
+		tracer = logger.NewStructLogger(config.Config)
+		if config.Tracer != nil {
+			if tracer, err = New(*config.Tracer, txctx); err != nil {
+				return nil, err
+			}
+		}
+		if config.Timeout != nil {
+			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		vmConfig.Debug, vmConfig.Tracer = true, tracer
+	}
+	vmenv := vm.NewEVM(vmctx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vmConfig)
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		<-deadlineCtx.Done()
+		if errors.Is(deadlineCtx.Err(), context.DeadlineExceeded) {
+			if tracer != nil {
+				tracer.Stop(errors.New("execution timeout"))
+			}
+			vmenv.Cancel()
+		}
+	}()
+	defer cancel()
+
+	// Execute the message, invalid ones are skipped like during block building
+	var (
+		nonce = statedb.GetNonce(msg.From())
+		snap  = statedb.Snapshot()
+	)
+	statedb.Prepare(txctx.TxHash, txctx.TxIndex)
+	result, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+	if vmenv.Cancelled() {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+	}
+	if err != nil {
+		statedb.RevertToSnapshot(snap)
+		return &bundleTxResult{Error: err.Error()}, nil
+	}
+	statedb.Finalise(vmenv.ChainConfig().IsEIP158(vmctx.BlockNumber))
+	*cumulative += result.UsedGas
+
+	receipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: *cumulative,
+		GasUsed:           result.UsedGas,
+		BlockHash:         txctx.BlockHash,
+		BlockNumber:       vmctx.BlockNumber,
+		TransactionIndex:  uint(txctx.TxIndex),
+	}
+	if result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	if msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From(), nonce)
+	}
+	res := &bundleTxResult{
+		Receipt:    receipt,
+		ReturnData: result.Return(),
+	}
+	if result.Err != nil {
+		res.Error = result.Err.Error()
+	}
+	if tracer != nil {
+		if res.Trace, err = tracer.GetResult(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
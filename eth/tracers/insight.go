@@ -0,0 +1,236 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Insight is the outcome of executing a single message with the native call
+// tracer attached, along with everything needed to break down its gas usage
+// and to report the state changes it made.
+type Insight struct {
+	Result       *core.ExecutionResult // Outcome of the execution
+	CallTrace    json.RawMessage       // Call frames collected by the native call tracer
+	IntrinsicGas uint64                // Gas charged before the execution started
+
+	pre   *state.StateDB                              // State before the execution
+	post  *state.StateDB                              // State after the execution
+	slots map[common.Address]map[common.Hash]struct{} // Storage slots written during the execution
+}
+
+// ExecutionGas returns the gas consumed by the execution itself, excluding the
+// intrinsic gas and before any refunds are applied.
+func (in *Insight) ExecutionGas() uint64 {
+	return in.Result.UsedGas + in.Result.RefundedGas - in.IntrinsicGas
+}
+
+// AccountState is the content of an account on one side of a state change.
+type AccountState struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    []byte
+}
+
+// SlotChange is a storage slot modified by the execution.
+type SlotChange struct {
+	Key  common.Hash
+	Pre  common.Hash
+	Post common.Hash
+}
+
+// AccountChange is an account modified by the execution.
+type AccountChange struct {
+	Address common.Address
+	Pre     AccountState
+	Post    AccountState
+	Storage []SlotChange // Modified storage slots, sorted by key
+}
+
+// StateDiff returns the changes made by the execution to the given accounts.
+// Accounts left untouched are omitted.
+func (in *Insight) StateDiff(addresses []common.Address) []*AccountChange {
+	var changes []*AccountChange
+	for _, addr := range addresses {
+		change := &AccountChange{
+			Address: addr,
+			Pre: AccountState{
+				Balance: in.pre.GetBalance(addr),
+				Nonce:   in.pre.GetNonce(addr),
+				Code:    in.pre.GetCode(addr),
+			},
+			Post: AccountState{
+				Balance: in.post.GetBalance(addr),
+				Nonce:   in.post.GetNonce(addr),
+				Code:    in.post.GetCode(addr),
+			},
+		}
+		for key := range in.slots[addr] {
+			prev, post := in.pre.GetState(addr, key), in.post.GetState(addr, key)
+			if prev != post {
+				change.Storage = append(change.Storage, SlotChange{Key: key, Pre: prev, Post: post})
+			}
+		}
+		sort.Slice(change.Storage, func(i, j int) bool {
+			return bytes.Compare(change.Storage[i].Key[:], change.Storage[j].Key[:]) < 0
+		})
+		if len(change.Storage) == 0 && change.Pre.Balance.Cmp(change.Post.Balance) == 0 &&
+			change.Pre.Nonce == change.Post.Nonce && bytes.Equal(change.Pre.Code, change.Post.Code) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// TraceTransactionInsight re-executes an already included transaction with the
+// native call tracer, using the same reexec and timeout limits as the tracing
+// API. The "callTracer" must be registered, i.e. the native tracer package has
+// to be linked in.
+func TraceTransactionInsight(ctx context.Context, backend Backend, hash common.Hash) (*Insight, error) {
+	api := NewAPI(backend)
+
+	_, blockHash, blockNumber, index, err := backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	block, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	msg, vmctx, statedb, err := backend.StateAtTransaction(ctx, block, int(index), defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	txctx := &Context{
+		BlockHash: blockHash,
+		TxIndex:   int(index),
+		TxHash:    hash,
+	}
+	return api.traceInsight(ctx, msg, txctx, vmctx, statedb)
+}
+
+// TraceCallInsight executes a call on top of the state of the given block with
+// the native call tracer, using the same reexec and timeout limits as the
+// tracing API. The state overrides, if any, are applied before the execution.
+func TraceCallInsight(ctx context.Context, backend Backend, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *ethapi.StateOverride) (*Insight, error) {
+	api := NewAPI(backend)
+
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := backend.StateAtBlock(ctx, block, defaultTraceReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	msg, err := args.ToMessage(backend.RPCGasCap(), block.BaseFee())
+	if err != nil {
+		return nil, err
+	}
+	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	return api.traceInsight(ctx, msg, new(Context), vmctx, statedb)
+}
+
+// traceInsight executes the given message with the call tracer attached,
+// keeping a copy of the original state around to diff against afterwards.
+func (api *API) traceInsight(ctx context.Context, msg core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB) (*Insight, error) {
+	inner, err := New("callTracer", txctx)
+	if err != nil {
+		return nil, err
+	}
+	tracer := &insightTracer{
+		Tracer: inner,
+		slots:  make(map[common.Address]map[common.Hash]struct{}),
+	}
+	deadlineCtx, cancel := context.WithTimeout(ctx, defaultTraceTimeout)
+	go func() {
+		<-deadlineCtx.Done()
+		if errors.Is(deadlineCtx.Err(), context.DeadlineExceeded) {
+			tracer.Stop(errors.New("execution timeout"))
+		}
+	}()
+	defer cancel()
+
+	var (
+		pre   = statedb.Copy()
+		rules = api.backend.ChainConfig().Rules(vmctx.BlockNumber, vmctx.Random != nil)
+		vmenv = vm.NewEVM(vmctx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer, NoBaseFee: true})
+	)
+	intrinsic, err := core.IntrinsicGas(msg.Data(), msg.AccessList(), msg.To() == nil, rules.IsHomestead, rules.IsIstanbul)
+	if err != nil {
+		return nil, err
+	}
+	statedb.Prepare(txctx.TxHash, txctx.TxIndex)
+	result, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %w", err)
+	}
+	statedb.Finalise(vmenv.ChainConfig().IsEIP158(vmctx.BlockNumber))
+
+	trace, err := tracer.GetResult()
+	if err != nil {
+		return nil, err
+	}
+	return &Insight{
+		Result:       result,
+		CallTrace:    trace,
+		IntrinsicGas: intrinsic,
+		pre:          pre,
+		post:         statedb,
+		slots:        tracer.slots,
+	}, nil
+}
+
+// insightTracer wraps the call tracer, additionally collecting the storage slots
+// written during the execution.
+type insightTracer struct {
+	Tracer
+	slots map[common.Address]map[common.Hash]struct{}
+}
+
+// CaptureState records the slots touched by SSTORE before forwarding the step
+// to the wrapped tracer.
+func (t *insightTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if stack := scope.Stack.Data(); op == vm.SSTORE && len(stack) >= 1 {
+		addr := scope.Contract.Address()
+		if t.slots[addr] == nil {
+			t.slots[addr] = make(map[common.Hash]struct{})
+		}
+		t.slots[addr][common.Hash(stack[len(stack)-1].Bytes32())] = struct{}{}
+	}
+	t.Tracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+}
@@ -22,7 +22,9 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -294,6 +296,9 @@ func TestEthClient(t *testing.T) {
 		"TransactionSender": {
 			func(t *testing.T) { testTransactionSender(t, client) },
 		},
+		"BlockReceipts": {
+			func(t *testing.T) { testBlockReceipts(t, chain, client) },
+		},
 	}
 
 	t.Parallel()
@@ -302,6 +307,53 @@ func TestEthClient(t *testing.T) {
 	}
 }
 
+// Tests that the client works over the HTTP and WebSocket transports, both with
+// JSON and with the compact binary encoding.
+func TestEthClientTransports(t *testing.T) {
+	backend, chain := newTestBackend(t)
+	defer backend.Close()
+
+	handler, err := backend.RPCHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpsrv := httptest.NewServer(handler)
+	defer httpsrv.Close()
+	wssrv := httptest.NewServer(handler.WebsocketHandler([]string{"*"}))
+	defer wssrv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(wssrv.URL, "http")
+	dialers := map[string]func() (*rpc.Client, error){
+		"http":      func() (*rpc.Client, error) { return rpc.Dial(httpsrv.URL) },
+		"ws":        func() (*rpc.Client, error) { return rpc.Dial(wsURL) },
+		"http-cbor": func() (*rpc.Client, error) { return dialCBOR(httpsrv.URL) },
+		"ws-cbor":   func() (*rpc.Client, error) { return dialCBOR(wsURL) },
+	}
+	for name, dial := range dialers {
+		client, err := dial()
+		if err != nil {
+			t.Fatalf("%s: can't dial: %v", name, err)
+		}
+		defer client.Close()
+
+		t.Run(name, func(t *testing.T) {
+			testHeader(t, chain, client)
+			testGetBlock(t, client)
+			testTransactionSender(t, client)
+			testCallContract(t, client)
+			testBlockReceipts(t, chain, client)
+		})
+	}
+}
+
+func dialCBOR(rawurl string) (*rpc.Client, error) {
+	ec, err := DialCBOR(context.Background(), rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return ec.c, nil
+}
+
 func testHeader(t *testing.T, chain []*types.Block, client *rpc.Client) {
 	tests := map[string]struct {
 		block   *big.Int
@@ -414,6 +466,48 @@ func testTransactionInBlockInterrupted(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testBlockReceipts(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+
+	// Receipts retrieved by block number and hash match the ones of the transactions.
+	block := chain[2]
+	byNumber, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(2)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byHash, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(block.Hash(), true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(byNumber) != len(block.Transactions()) {
+		t.Fatalf("wrong number of receipts: have %d, want %d", len(byNumber), len(block.Transactions()))
+	}
+	for i, tx := range block.Transactions() {
+		want, err := ec.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(byNumber[i], want) {
+			t.Errorf("receipt %d by number mismatch:\nhave %+v\nwant %+v", i, byNumber[i], want)
+		}
+		if !reflect.DeepEqual(byHash[i], want) {
+			t.Errorf("receipt %d by hash mismatch:\nhave %+v\nwant %+v", i, byHash[i], want)
+		}
+		if byNumber[i].TxHash != tx.Hash() || byNumber[i].BlockHash != block.Hash() {
+			t.Errorf("receipt %d has wrong derived fields: %+v", i, byNumber[i])
+		}
+	}
+	// Blocks without transactions have no receipts.
+	receipts, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(1)))
+	if err != nil || len(receipts) != 0 {
+		t.Fatalf("unexpected receipts of empty block: %v, error %v", receipts, err)
+	}
+	// Missing blocks are reported.
+	if _, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(1000))); err != ethereum.NotFound {
+		t.Fatalf("error should be ethereum.NotFound, have %v", err)
+	}
+}
+
 func testChainID(t *testing.T, client *rpc.Client) {
 	ec := NewClient(client)
 	id, err := ec.ChainID(context.Background())
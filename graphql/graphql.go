@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"sync"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -188,6 +190,9 @@ type Transaction struct {
 	tx      *types.Transaction
 	block   *Block
 	index   uint64
+
+	insight     *tracers.Insight // The traced execution of the transaction, resolved on demand
+	insightLock sync.Mutex
 }
 
 // resolve returns the internal transaction object, fetching it if needed.
@@ -1018,6 +1023,15 @@ type CallResult struct {
 	data    hexutil.Bytes // The return data from the call
 	gasUsed Long          // The amount of gas used
 	status  Long          // The return status of the call - 0 for failure or 1 for success.
+
+	// Fields needed to trace the call on demand, only set for calls at a block
+	backend       ethapi.Backend
+	args          ethapi.TransactionArgs
+	blockNrOrHash *rpc.BlockNumberOrHash
+	overrides     *ethapi.StateOverride
+
+	insight     *tracers.Insight // The traced execution of the call, resolved on demand
+	insightLock sync.Mutex
 }
 
 func (c *CallResult) Data() hexutil.Bytes {
@@ -1033,7 +1047,8 @@ func (c *CallResult) Status() Long {
 }
 
 func (b *Block) Call(ctx context.Context, args struct {
-	Data ethapi.TransactionArgs
+	Data      ethapi.TransactionArgs
+	Overrides *[]AccountOverride
 }) (*CallResult, error) {
 	if b.numberOrHash == nil {
 		_, err := b.resolve(ctx)
@@ -1041,7 +1056,14 @@ func (b *Block) Call(ctx context.Context, args struct {
 			return nil, err
 		}
 	}
-	result, err := ethapi.DoCall(ctx, b.backend, args.Data, *b.numberOrHash, nil, b.backend.RPCEVMTimeout(), b.backend.RPCGasCap())
+	var overrides *ethapi.StateOverride
+	if args.Overrides != nil {
+		var err error
+		if overrides, err = stateOverride(*args.Overrides); err != nil {
+			return nil, err
+		}
+	}
+	result, err := ethapi.DoCall(ctx, b.backend, args.Data, *b.numberOrHash, overrides, b.backend.RPCEVMTimeout(), b.backend.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -1051,9 +1073,13 @@ func (b *Block) Call(ctx context.Context, args struct {
 	}
 
 	return &CallResult{
-		data:    result.ReturnData,
-		gasUsed: Long(result.UsedGas),
-		status:  status,
+		data:          result.ReturnData,
+		gasUsed:       Long(result.UsedGas),
+		status:        status,
+		backend:       b.backend,
+		args:          args.Data,
+		blockNrOrHash: b.numberOrHash,
+		overrides:     overrides,
 	}, nil
 }
 
@@ -17,6 +17,8 @@
 package graphql
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/big"
@@ -35,6 +37,8 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -195,6 +199,57 @@ func TestGraphQLBlockSerializationEIP2718(t *testing.T) {
 	}
 }
 
+// Tests that the execution insight fields of transactions and calls are served
+// by re-executing them with the tracers.
+func TestGraphQLExecutionInsight(t *testing.T) {
+	stack := createNode(t, true, true)
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	for i, tt := range []struct {
+		body string
+		want string
+	}{
+		{
+			body: `{"query": "{block {transactionAt(index: 0) { gasUsed gasBreakdown { intrinsic execution refund } callTrace { type from to value gasUsed calls { type } } stateDiff(addresses: [\"0x0000000000000000000000000000000000000dad\", \"0x0000000000000000000000000000000000000bad\"]) { address balanceBefore balanceAfter nonceBefore nonceAfter storage { key } }}}}"}`,
+			want: `{"data":{"block":{"transactionAt":{"gasUsed":25204,"gasBreakdown":{"intrinsic":21000,"execution":4204,"refund":0},"callTrace":{"type":"CALL","from":"0x71562b71999873db5b286df957af199ec94617f7","to":"0x0000000000000000000000000000000000000dad","value":"0x64","gasUsed":4204,"calls":[]},"stateDiff":[{"address":"0x0000000000000000000000000000000000000dad","balanceBefore":"0x0","balanceAfter":"0x64","nonceBefore":0,"nonceAfter":0,"storage":[]}]}}}}`,
+		},
+		{
+			// The overridden code returns the first storage slot, which is overridden too
+			body: `{"query": "{block {call(data: {to: \"0x0000000000000000000000000000000000000bad\"}, overrides: [{address: \"0x0000000000000000000000000000000000000bad\", code: \"0x60005460005260206000f3\", stateDiff: [{key: \"0x0000000000000000000000000000000000000000000000000000000000000000\", value: \"0x000000000000000000000000000000000000000000000000000000000000002a\"}]}]) { data status callTrace { type to output } }}}"}`,
+			want: `{"data":{"block":{"call":{"data":"0x000000000000000000000000000000000000000000000000000000000000002a","status":1,"callTrace":{"type":"CALL","to":"0x0000000000000000000000000000000000000bad","output":"0x000000000000000000000000000000000000000000000000000000000000002a"}}}}}`,
+		},
+		{
+			// Plain calls are not traced, but see the overrides too
+			body: `{"query": "{block {call(data: {to: \"0x0000000000000000000000000000000000000bad\"}, overrides: [{address: \"0x0000000000000000000000000000000000000bad\", code: \"0x60005460005260206000f3\", state: [{key: \"0x0000000000000000000000000000000000000000000000000000000000000000\", value: \"0x0000000000000000000000000000000000000000000000000000000000000007\"}]}]) { data gasUsed status }}}"}`,
+			want: `{"data":{"block":{"call":{"data":"0x0000000000000000000000000000000000000000000000000000000000000007","gasUsed":23118,"status":1}}}}`,
+		},
+		{
+			// Pending calls are not made at a block, so they have no trace
+			body: `{"query": "{pending {call(data: {to: \"0x0000000000000000000000000000000000000bad\"}) { status callTrace { type } gasBreakdown { intrinsic } }}}"}`,
+			want: `{"data":{"pending":{"call":{"status":1,"callTrace":null,"gasBreakdown":null}}}}`,
+		},
+		{
+			// The overridden code stores 0x2a into the first storage slot
+			body: `{"query": "{block {call(data: {to: \"0x0000000000000000000000000000000000000bad\"}, overrides: [{address: \"0x0000000000000000000000000000000000000bad\", code: \"0x602a600055\"}]) { stateDiff(addresses: [\"0x0000000000000000000000000000000000000bad\"]) { storage { key before after } } }}}"}`,
+			want: `{"data":{"block":{"call":{"stateDiff":[{"storage":[{"key":"0x0000000000000000000000000000000000000000000000000000000000000000","before":"0x0000000000000000000000000000000000000000000000000000000000000000","after":"0x000000000000000000000000000000000000000000000000000000000000002a"}]}]}}}}`,
+		},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("could not read from response body: %v", err)
+		}
+		if have := string(bodyBytes); have != tt.want {
+			t.Errorf("testcase %d %s,\nhave:\n%v\nwant:\n%v", i, tt.body, have, tt.want)
+		}
+	}
+}
+
 // Tests that a graphQL request is not handled successfully when graphql is not enabled on the specified endpoint
 func TestGraphQLHTTPOnSamePort_GQLRequest_Unsuccessful(t *testing.T) {
 	stack := createNode(t, false, false)
@@ -353,3 +408,185 @@ func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
 }
+
+// Tests that new blocks and logs are streamed to the clients subscribed over
+// websocket, and that queries are answered over the same connection.
+func TestGraphQLSubscription(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+
+		// The emitters log an empty record with a single topic
+		emitter = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+		other   = common.HexToAddress("0x00000000000000000000000000000000000000e2")
+	)
+	stack := createNode(t, false, false)
+	defer stack.Close()
+
+	ethBackend, err := eth.New(stack, &ethconfig.Config{
+		Genesis: &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			Alloc: core.GenesisAlloc{
+				address: {Balance: big.NewInt(params.Ether)},
+				emitter: {Code: common.FromHex("0x602a60006000a100"), Balance: new(big.Int)},
+				other:   {Code: common.FromHex("0x602b60006000a100"), Balance: new(big.Int)},
+			},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		},
+		Ethash: ethash.Config{
+			PowMode: ethash.ModeFake,
+		},
+		NetworkId:      1337,
+		TrieCleanCache: 5,
+		TrieDirtyCache: 5,
+		TrieTimeout:    60 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("could not create eth backend: %v", err)
+	}
+	if err := New(stack, ethBackend.APIBackend, []string{}, []string{}); err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	var (
+		dialer = websocket.Dialer{Subprotocols: []string{wsProtocol}}
+		url    = "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql/ws"
+	)
+	// Connections to virtual hosts which are not allowed are rejected
+	if _, _, err := dialer.Dial(url, http.Header{"Host": {"evil.example.com"}}); err == nil {
+		t.Fatalf("connection to disallowed virtual host succeeded")
+	}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not dial websocket: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	// read retrieves the next message, skipping over the keepalives
+	read := func() wsMessage {
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("could not read message: %v", err)
+			}
+			if msg.Type != gqlConnectionKeepAlive {
+				return msg
+			}
+		}
+	}
+	// start sends an operation to the server
+	start := func(id, query string) {
+		payload, _ := json.Marshal(map[string]string{"query": query})
+		if err := conn.WriteJSON(wsMessage{ID: id, Type: gqlStart, Payload: payload}); err != nil {
+			t.Fatalf("could not start operation %s: %v", id, err)
+		}
+	}
+	if err := conn.WriteJSON(wsMessage{Type: gqlConnectionInit}); err != nil {
+		t.Fatalf("could not initialize connection: %v", err)
+	}
+	if msg := read(); msg.Type != gqlConnectionAck {
+		t.Fatalf("unexpected message: have %s, want %s", msg.Type, gqlConnectionAck)
+	}
+	start("blocks", `subscription { newBlock { number } }`)
+	start("logs", fmt.Sprintf(`subscription { logs(filter: {addresses: ["%v"]}) { topics transaction { hash } } }`, emitter))
+
+	// The subscriptions are installed before the following messages are handled,
+	// so the query response means they are active
+	start("query", `{ block { number } }`)
+	if msg := read(); msg.Type != gqlData || msg.ID != "query" || string(msg.Payload) != `{"data":{"block":{"number":0}}}` {
+		t.Fatalf("unexpected query response: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+	if msg := read(); msg.Type != gqlComplete || msg.ID != "query" {
+		t.Fatalf("unexpected message: have %s %s, want query %s", msg.ID, msg.Type, gqlComplete)
+	}
+	// Invalid subscriptions are answered with the validation errors
+	start("invalid", `subscription { newBlock { nope } }`)
+	if msg := read(); msg.Type != gqlData || msg.ID != "invalid" || !strings.Contains(string(msg.Payload), `Cannot query field \"nope\"`) {
+		t.Fatalf("unexpected invalid subscription response: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+	if msg := read(); msg.Type != gqlComplete || msg.ID != "invalid" {
+		t.Fatalf("unexpected message: have %s %s, want invalid %s", msg.ID, msg.Type, gqlComplete)
+	}
+	// Import a few blocks calling both emitters, only the logs of the first one
+	// are expected
+	signer := types.LatestSigner(params.AllEthashProtocolChanges)
+	blocks, _ := core.GenerateChain(params.AllEthashProtocolChanges, ethBackend.BlockChain().Genesis(),
+		ethash.NewFaker(), ethBackend.ChainDb(), 3, func(i int, gen *core.BlockGen) {
+			for _, to := range []common.Address{other, emitter} {
+				tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(address), to, nil, 50000, gen.BaseFee(), nil), signer, key)
+				gen.AddTx(tx)
+			}
+		})
+	if _, err := ethBackend.BlockChain().InsertChain(blocks); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	var (
+		topic     = common.BigToHash(big.NewInt(0x2a))
+		blockNum  int
+		logNum    int
+		wantBlock = len(blocks)
+		wantLogs  = len(blocks)
+	)
+	for blockNum < wantBlock || logNum < wantLogs {
+		msg := read()
+		if msg.Type != gqlData {
+			t.Fatalf("unexpected message: %s %s %s", msg.ID, msg.Type, msg.Payload)
+		}
+		var want string
+		switch msg.ID {
+		case "blocks":
+			want = fmt.Sprintf(`{"data":{"newBlock":{"number":%d}}}`, blockNum+1)
+			blockNum++
+		case "logs":
+			if logNum == wantLogs {
+				t.Fatalf("unexpected log: %s", msg.Payload)
+			}
+			want = fmt.Sprintf(`{"data":{"logs":{"topics":["%v"],"transaction":{"hash":"%v"}}}}`, topic, blocks[logNum].Transactions()[1].Hash())
+			logNum++
+		default:
+			t.Fatalf("unexpected operation: %s", msg.ID)
+		}
+		if string(msg.Payload) != want {
+			t.Fatalf("%s: payload mismatch: have %s, want %s", msg.ID, msg.Payload, want)
+		}
+	}
+}
+
+// Tests that the subscription schema tells the subscriptions apart from the other
+// operations, with graph-gophers parsing the documents and selecting the operation.
+func TestSubscriptionResponse(t *testing.T) {
+	subscriptions, err := graphql.ParseSchema(subscriptionSchema, newSubscriptionResolver(nil))
+	if err != nil {
+		t.Fatalf("could not parse subscription schema: %v", err)
+	}
+	tests := []struct {
+		document, name string
+		variables      map[string]interface{}
+		want           bool
+	}{
+		{`{ block { number } }`, "", nil, false},
+		{`{ __typename }`, "", nil, false},
+		{`subscription { newBlock { number } }`, "", nil, true},
+		{`mutation Send { sendRawTransaction(data: "0x00") }`, "", nil, false},
+		{`subscription Logs($a: Address!) { logs(filter: {addresses: [$a]}) { index } }`, "Logs", map[string]interface{}{"a": "0x00000000000000000000000000000000000000e1"}, true},
+		{"# subscription {\nquery { block { hash } }", "", nil, false},
+		{"subscription { # }\n newBlock { number } }", "", nil, true},
+		{`query A { block(hash: """}subscription B {""") { number } }`, "", nil, false},
+		{`subscription A { newBlock { number } } subscription B { newBlock { hash } }`, "B", nil, true},
+		{`subscription A { newBlock { number } } subscription B { newBlock { hash } }`, "", nil, false},
+		{`subscription A { newBlock { number } }`, "B", nil, false},
+		{`subscription { newBlock { ...F } } fragment F on Block { number }`, "", nil, true},
+		{`subscription { newBlock { nope } }`, "", nil, false},
+	}
+	for _, tt := range tests {
+		response := subscriptions.Exec(context.Background(), tt.document, tt.name, tt.variables)
+		if have := isSubscriptionResponse(response); have != tt.want {
+			t.Errorf("%q (%s): have %v, want %v (errors %v)", tt.document, tt.name, have, tt.want, response.Errors)
+		}
+	}
+}
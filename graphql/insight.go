@@ -0,0 +1,364 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+
+	// Force-load the native tracers to make the call tracer available
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+var errTracingUnsupported = errors.New("tracing not supported by backend")
+
+// tracingBackend returns the backend as a tracing backend, if it supports it.
+func tracingBackend(backend ethapi.Backend) (tracers.Backend, error) {
+	tb, ok := backend.(tracers.Backend)
+	if !ok {
+		return nil, errTracingUnsupported
+	}
+	return tb, nil
+}
+
+// callFrame is the JSON encoding of a call frame reported by the native call
+// tracer.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to"`
+	Value   *hexutil.Big    `json:"value"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  *hexutil.Bytes  `json:"output"`
+	Error   *string         `json:"error"`
+	Calls   []*callFrame    `json:"calls"`
+}
+
+// CallFrame is a single call made during an execution.
+type CallFrame struct {
+	frame *callFrame
+}
+
+// newCallFrame decodes the result of the native call tracer.
+func newCallFrame(trace json.RawMessage) (*CallFrame, error) {
+	frame := new(callFrame)
+	if err := json.Unmarshal(trace, frame); err != nil {
+		return nil, err
+	}
+	return &CallFrame{frame: frame}, nil
+}
+
+func (f *CallFrame) Type() string {
+	return f.frame.Type
+}
+
+func (f *CallFrame) From() common.Address {
+	return f.frame.From
+}
+
+func (f *CallFrame) To() *common.Address {
+	return f.frame.To
+}
+
+func (f *CallFrame) Value() *hexutil.Big {
+	return f.frame.Value
+}
+
+func (f *CallFrame) Gas() Long {
+	return Long(f.frame.Gas)
+}
+
+func (f *CallFrame) GasUsed() Long {
+	return Long(f.frame.GasUsed)
+}
+
+func (f *CallFrame) Input() hexutil.Bytes {
+	return f.frame.Input
+}
+
+func (f *CallFrame) Output() *hexutil.Bytes {
+	return f.frame.Output
+}
+
+func (f *CallFrame) Error() *string {
+	return f.frame.Error
+}
+
+func (f *CallFrame) Calls() []*CallFrame {
+	ret := make([]*CallFrame, 0, len(f.frame.Calls))
+	for _, call := range f.frame.Calls {
+		ret = append(ret, &CallFrame{frame: call})
+	}
+	return ret
+}
+
+// AccountDiff is the change of an account caused by an execution.
+type AccountDiff struct {
+	change *tracers.AccountChange
+}
+
+func (a *AccountDiff) Address() common.Address {
+	return a.change.Address
+}
+
+func (a *AccountDiff) BalanceBefore() hexutil.Big {
+	return hexutil.Big(*a.change.Pre.Balance)
+}
+
+func (a *AccountDiff) BalanceAfter() hexutil.Big {
+	return hexutil.Big(*a.change.Post.Balance)
+}
+
+func (a *AccountDiff) NonceBefore() Long {
+	return Long(a.change.Pre.Nonce)
+}
+
+func (a *AccountDiff) NonceAfter() Long {
+	return Long(a.change.Post.Nonce)
+}
+
+func (a *AccountDiff) CodeBefore() hexutil.Bytes {
+	return a.change.Pre.Code
+}
+
+func (a *AccountDiff) CodeAfter() hexutil.Bytes {
+	return a.change.Post.Code
+}
+
+func (a *AccountDiff) Storage() []*StorageDiff {
+	ret := make([]*StorageDiff, 0, len(a.change.Storage))
+	for i := range a.change.Storage {
+		ret = append(ret, &StorageDiff{slot: &a.change.Storage[i]})
+	}
+	return ret
+}
+
+// StorageDiff is the change of a storage slot caused by an execution.
+type StorageDiff struct {
+	slot *tracers.SlotChange
+}
+
+func (s *StorageDiff) Key() common.Hash {
+	return s.slot.Key
+}
+
+func (s *StorageDiff) Before() common.Hash {
+	return s.slot.Pre
+}
+
+func (s *StorageDiff) After() common.Hash {
+	return s.slot.Post
+}
+
+// GasBreakdown splits the gas used by an execution into its components.
+type GasBreakdown struct {
+	insight *tracers.Insight
+}
+
+func (g *GasBreakdown) Intrinsic() Long {
+	return Long(g.insight.IntrinsicGas)
+}
+
+func (g *GasBreakdown) Execution() Long {
+	return Long(g.insight.ExecutionGas())
+}
+
+func (g *GasBreakdown) Refund() Long {
+	return Long(g.insight.Result.RefundedGas)
+}
+
+// StateDiffArgs encapsulates arguments to the `stateDiff` accessors.
+type StateDiffArgs struct {
+	Addresses []common.Address
+}
+
+// insightCallTrace decodes the call trace of an execution, if available.
+func insightCallTrace(insight *tracers.Insight) (*CallFrame, error) {
+	if insight == nil {
+		return nil, nil
+	}
+	return newCallFrame(insight.CallTrace)
+}
+
+// insightStateDiff returns the changes of an execution to the given accounts,
+// if available.
+func insightStateDiff(insight *tracers.Insight, addresses []common.Address) *[]*AccountDiff {
+	if insight == nil {
+		return nil
+	}
+	changes := insight.StateDiff(addresses)
+	ret := make([]*AccountDiff, 0, len(changes))
+	for _, change := range changes {
+		ret = append(ret, &AccountDiff{change: change})
+	}
+	return &ret
+}
+
+// insightGasBreakdown returns the gas breakdown of an execution, if available.
+func insightGasBreakdown(insight *tracers.Insight) *GasBreakdown {
+	if insight == nil {
+		return nil
+	}
+	return &GasBreakdown{insight: insight}
+}
+
+// resolveInsight re-executes the transaction with the call tracer attached,
+// fetching it if needed. Nil is returned if the transaction is not yet mined.
+func (t *Transaction) resolveInsight(ctx context.Context) (*tracers.Insight, error) {
+	t.insightLock.Lock()
+	defer t.insightLock.Unlock()
+
+	if t.insight != nil {
+		return t.insight, nil
+	}
+	if _, err := t.resolve(ctx); err != nil || t.block == nil {
+		return nil, err
+	}
+	backend, err := tracingBackend(t.backend)
+	if err != nil {
+		return nil, err
+	}
+	t.insight, err = tracers.TraceTransactionInsight(ctx, backend, t.hash)
+	return t.insight, err
+}
+
+func (t *Transaction) CallTrace(ctx context.Context) (*CallFrame, error) {
+	insight, err := t.resolveInsight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return insightCallTrace(insight)
+}
+
+func (t *Transaction) StateDiff(ctx context.Context, args StateDiffArgs) (*[]*AccountDiff, error) {
+	insight, err := t.resolveInsight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return insightStateDiff(insight, args.Addresses), nil
+}
+
+func (t *Transaction) GasBreakdown(ctx context.Context) (*GasBreakdown, error) {
+	insight, err := t.resolveInsight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return insightGasBreakdown(insight), nil
+}
+
+// resolveInsight re-executes the call with the call tracer attached. Nil is
+// returned if the call was not made at a block.
+func (c *CallResult) resolveInsight(ctx context.Context) (*tracers.Insight, error) {
+	c.insightLock.Lock()
+	defer c.insightLock.Unlock()
+
+	if c.insight != nil || c.blockNrOrHash == nil {
+		return c.insight, nil
+	}
+	backend, err := tracingBackend(c.backend)
+	if err != nil {
+		return nil, err
+	}
+	c.insight, err = tracers.TraceCallInsight(ctx, backend, c.args, *c.blockNrOrHash, c.overrides)
+	return c.insight, err
+}
+
+func (c *CallResult) CallTrace(ctx context.Context) (*CallFrame, error) {
+	insight, err := c.resolveInsight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return insightCallTrace(insight)
+}
+
+func (c *CallResult) StateDiff(ctx context.Context, args StateDiffArgs) (*[]*AccountDiff, error) {
+	insight, err := c.resolveInsight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return insightStateDiff(insight, args.Addresses), nil
+}
+
+func (c *CallResult) GasBreakdown(ctx context.Context) (*GasBreakdown, error) {
+	insight, err := c.resolveInsight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return insightGasBreakdown(insight), nil
+}
+
+// AccountOverride encapsulates the fields of an account overridden during a
+// call. All fields except the address are optional.
+type AccountOverride struct {
+	Address   common.Address  // The Ethereum address of the overridden account.
+	Nonce     *hexutil.Uint64 // The nonce replacing the account's one.
+	Code      *hexutil.Bytes  // The code replacing the account's one.
+	Balance   *hexutil.Big    // The balance replacing the account's one.
+	State     *[]StorageSlot  // The slots replacing the account's whole storage.
+	StateDiff *[]StorageSlot  // The slots replacing individual storage entries.
+}
+
+// StorageSlot encapsulates a storage slot of an account override.
+type StorageSlot struct {
+	Key   common.Hash
+	Value common.Hash
+}
+
+// stateOverride converts the account overrides into the format used by the
+// RPC APIs.
+func stateOverride(overrides []AccountOverride) (*ethapi.StateOverride, error) {
+	ret := make(ethapi.StateOverride, len(overrides))
+	for _, override := range overrides {
+		if _, ok := ret[override.Address]; ok {
+			return nil, fmt.Errorf("account %s overridden multiple times", override.Address.Hex())
+		}
+		account := ethapi.OverrideAccount{
+			Nonce: override.Nonce,
+			Code:  override.Code,
+		}
+		if override.Balance != nil {
+			balance := override.Balance
+			account.Balance = &balance
+		}
+		if override.State != nil {
+			account.State = storageSlots(*override.State)
+		}
+		if override.StateDiff != nil {
+			account.StateDiff = storageSlots(*override.StateDiff)
+		}
+		ret[override.Address] = account
+	}
+	return &ret, nil
+}
+
+// storageSlots converts a list of storage slots into a storage override.
+func storageSlots(slots []StorageSlot) *map[common.Hash]common.Hash {
+	ret := make(map[common.Hash]common.Hash, len(slots))
+	for _, slot := range slots {
+		ret[slot.Key] = slot.Value
+	}
+	return &ret
+}
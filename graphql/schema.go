@@ -16,7 +16,42 @@
 
 package graphql
 
+// schema is the GraphQL schema served over HTTP.
 const schema string = `
+    schema {
+        query: Query
+        mutation: Mutation
+    }
+` + schemaTypes
+
+// subscriptionSchema is the schema of the subscriptions served over websocket.
+// It is kept apart from the main schema since a root resolver serves all the
+// root types, and the logs subscription would collide with the logs query. The
+// query root is mandatory, but queries sent over websocket are executed by the
+// main schema.
+const subscriptionSchema string = `
+    schema {
+        query: SubscriptionQuery
+        subscription: Subscription
+    }
+
+    # SubscriptionQuery is the placeholder query root of the subscriptions.
+    type SubscriptionQuery {}
+
+    type Subscription {
+        # NewBlock is triggered each time a new block is appended to the
+        # canonical chain, including all the blocks of a reorg.
+        newBlock: Block!
+        # Logs is triggered for each log matching the filter that is emitted
+        # by a block appended to the canonical chain.
+        logs(filter: BlockFilterCriteria!): Log!
+        # PendingTransactions is triggered each time a transaction enters the
+        # transaction pool.
+        pendingTransactions: Transaction!
+    }
+` + schemaTypes
+
+const schemaTypes string = `
     # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
     scalar Bytes32
     # Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
@@ -31,11 +66,6 @@ const schema string = `
     # Long is a 64 bit unsigned integer.
     scalar Long
 
-    schema {
-        query: Query
-        mutation: Mutation
-    }
-
     # Account is an Ethereum account at a particular block.
     type Account {
         # Address is the address owning the account.
@@ -147,6 +177,85 @@ const schema string = `
         # RawReceipt is the canonical encoding of the receipt. For post EIP-2718 typed transactions
         # this is equivalent to TxType || ReceiptEncoding.
         rawReceipt: Bytes!
+        # CallTrace is the tree of calls made by this transaction, collected by
+        # re-executing it with the native call tracer. If the transaction has
+        # not yet been mined, this field will be null.
+        callTrace: CallFrame
+        # StateDiff lists the changes this transaction made to the given
+        # accounts, omitting untouched ones. If the transaction has not yet
+        # been mined, this field will be null.
+        stateDiff(addresses: [Address!]!): [AccountDiff!]
+        # GasBreakdown splits the gas used by this transaction into its
+        # components. If the transaction has not yet been mined, this field
+        # will be null.
+        gasBreakdown: GasBreakdown
+    }
+
+    # CallFrame is a single call made during the execution of a transaction.
+    type CallFrame {
+        # Type is the kind of the call: CALL, STATICCALL, DELEGATECALL, CALLCODE,
+        # CREATE, CREATE2 or SELFDESTRUCT.
+        type: String!
+        # From is the address making the call.
+        from: Address!
+        # To is the address the call is sent to.
+        to: Address
+        # Value is the value, in wei, sent along with the call.
+        value: BigInt
+        # Gas is the amount of gas provided for the call.
+        gas: Long!
+        # GasUsed is the amount of gas used by the call.
+        gasUsed: Long!
+        # Input is the data sent to the callee.
+        input: Bytes!
+        # Output is the data returned by the callee.
+        output: Bytes
+        # Error is the reason the call failed, null if it succeeded.
+        error: String
+        # Calls is the list of calls made by the callee.
+        calls: [CallFrame!]!
+    }
+
+    # AccountDiff is the change of an account caused by an execution.
+    type AccountDiff {
+        # Address is the address of the account.
+        address: Address!
+        # BalanceBefore is the balance of the account before the execution.
+        balanceBefore: BigInt!
+        # BalanceAfter is the balance of the account after the execution.
+        balanceAfter: BigInt!
+        # NonceBefore is the nonce of the account before the execution.
+        nonceBefore: Long!
+        # NonceAfter is the nonce of the account after the execution.
+        nonceAfter: Long!
+        # CodeBefore is the code of the account before the execution.
+        codeBefore: Bytes!
+        # CodeAfter is the code of the account after the execution.
+        codeAfter: Bytes!
+        # Storage lists the storage slots modified by the execution.
+        storage: [StorageDiff!]!
+    }
+
+    # StorageDiff is the change of a storage slot caused by an execution.
+    type StorageDiff {
+        # Key is the key of the storage slot.
+        key: Bytes32!
+        # Before is the value of the slot before the execution.
+        before: Bytes32!
+        # After is the value of the slot after the execution.
+        after: Bytes32!
+    }
+
+    # GasBreakdown splits the gas used by an execution into its components.
+    # The gas used is intrinsic + execution - refund.
+    type GasBreakdown {
+        # Intrinsic is the gas charged before the execution started, covering
+        # the transaction itself, its data and its access list.
+        intrinsic: Long!
+        # Execution is the gas consumed by the executed code, before refunds.
+        execution: Long!
+        # Refund is the gas refunded after the execution.
+        refund: Long!
     }
 
     # BlockFilterCriteria encapsulates log filter criteria for a filter applied
@@ -237,8 +346,9 @@ const schema string = `
         logs(filter: BlockFilterCriteria!): [Log!]!
         # Account fetches an Ethereum account at the current block's state.
         account(address: Address!): Account!
-        # Call executes a local call operation at the current block's state.
-        call(data: CallData!): CallResult
+        # Call executes a local call operation at the current block's state,
+        # with the given accounts overridden.
+        call(data: CallData!, overrides: [AccountOverride!]): CallResult
         # EstimateGas estimates the amount of gas that will be required for
         # successful execution of a transaction at the current block's state.
         estimateGas(data: CallData!): Long!
@@ -269,6 +379,28 @@ const schema string = `
         data: Bytes
     }
 
+    # AccountOverride replaces parts of an account for the duration of a call.
+    input AccountOverride {
+        # Address is the address of the overridden account.
+        address: Address!
+        # Nonce replaces the nonce of the account.
+        nonce: Long
+        # Code replaces the code of the account.
+        code: Bytes
+        # Balance replaces the balance of the account.
+        balance: BigInt
+        # State replaces the whole storage of the account.
+        state: [StorageSlot!]
+        # StateDiff replaces individual storage slots of the account.
+        stateDiff: [StorageSlot!]
+    }
+
+    # StorageSlot is a storage slot of an account override.
+    input StorageSlot {
+        key: Bytes32!
+        value: Bytes32!
+    }
+
     # CallResult is the result of a local call operation.
     type CallResult {
         # Data is the return data of the called contract.
@@ -277,6 +409,16 @@ const schema string = `
         gasUsed: Long!
         # Status is the result of the call - 1 for success or 0 for failure.
         status: Long!
+        # CallTrace is the tree of calls made by the call. This field is only
+        # available for calls at a block, null otherwise.
+        callTrace: CallFrame
+        # StateDiff lists the changes the call made to the given accounts,
+        # omitting untouched ones. This field is only available for calls at a
+        # block, null otherwise.
+        stateDiff(addresses: [Address!]!): [AccountDiff!]
+        # GasBreakdown splits the gas used by the call into its components.
+        # This field is only available for calls at a block, null otherwise.
+        gasBreakdown: GasBreakdown
     }
 
     # FilterCriteria encapsulates log filter criteria for searching log entries.
@@ -65,7 +65,8 @@ func New(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
-// It additionally exports an interactive query browser on the / endpoint.
+// It additionally exports an interactive query browser on the / endpoint, and
+// serves subscriptions over websocket on the /ws endpoint.
 func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
 	q := Resolver{backend}
 
@@ -73,12 +74,18 @@ func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string)
 	if err != nil {
 		return err
 	}
+	subs, err := graphql.ParseSchema(subscriptionSchema, newSubscriptionResolver(backend))
+	if err != nil {
+		return err
+	}
 	h := handler{Schema: s}
 	handler := node.NewHTTPHandlerStack(h, cors, vhosts, nil)
+	wsHandler := node.NewVHostHandler(vhosts, newWSHandler(s, subs, cors))
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
 	stack.RegisterHandler("GraphQL", "/graphql", handler)
 	stack.RegisterHandler("GraphQL", "/graphql/", handler)
+	stack.RegisterHandler("GraphQL WebSocket", "/graphql/ws", node.NewWSHandlerStack(wsHandler, nil))
 
 	return nil
 }
@@ -0,0 +1,474 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// wsProtocol is the websocket subprotocol spoken by GraphQL clients.
+	wsProtocol = "graphql-ws"
+
+	// wsMaxSubscriptions is the maximum number of concurrently active operations
+	// on a single websocket connection.
+	wsMaxSubscriptions = 32
+
+	wsReadBuffer       = 1024
+	wsWriteBuffer      = 1024
+	wsMessageSizeLimit = 1024 * 1024
+	wsWriteTimeout     = 10 * time.Second
+	wsKeepAlive        = 30 * time.Second
+)
+
+// Messages of the graphql-ws protocol.
+const (
+	gqlConnectionInit      = "connection_init"      // Client -> Server
+	gqlConnectionTerminate = "connection_terminate" // Client -> Server
+	gqlStart               = "start"                // Client -> Server
+	gqlStop                = "stop"                 // Client -> Server
+	gqlConnectionAck       = "connection_ack"       // Server -> Client
+	gqlConnectionError     = "connection_error"     // Server -> Client
+	gqlConnectionKeepAlive = "ka"                   // Server -> Client
+	gqlData                = "data"                 // Server -> Client
+	gqlError               = "error"                // Server -> Client
+	gqlComplete            = "complete"             // Server -> Client
+)
+
+// errSubscriptionExec is the error graph-gophers responds with when asked to
+// execute a subscription operation, before resolving anything.
+const errSubscriptionExec = "graphql-ws protocol header is missing"
+
+var errNoSubscriptions = errors.New("subscriptions not supported")
+
+// subscriptionResolver is the root resolver of the subscriptions.
+type subscriptionResolver struct {
+	backend ethapi.Backend
+	events  *filters.EventSystem // Event system shared with the filter API, nil if unsupported
+}
+
+// eventBackend is a backend exposing the event system of its filter API.
+type eventBackend interface {
+	EventSystem() *filters.EventSystem
+}
+
+// newSubscriptionResolver creates the root resolver of the subscriptions, which
+// are supported if the backend exposes its event system.
+func newSubscriptionResolver(backend ethapi.Backend) *subscriptionResolver {
+	r := &subscriptionResolver{backend: backend}
+	if eb, ok := backend.(eventBackend); ok {
+		r.events = eb.EventSystem()
+	}
+	return r
+}
+
+// NewBlock creates a subscription that is triggered each time a block is
+// appended to the canonical chain.
+func (r *subscriptionResolver) NewBlock(ctx context.Context) (<-chan *Block, error) {
+	if r.events == nil {
+		return nil, errNoSubscriptions
+	}
+	var (
+		headers = make(chan *types.Header)
+		sub     = r.events.SubscribeNewHeads(headers)
+		out     = make(chan *Block)
+	)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case header := <-headers:
+				numberOrHash := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+				block := &Block{
+					backend:      r.backend,
+					numberOrHash: &numberOrHash,
+					hash:         header.Hash(),
+					header:       header,
+				}
+				select {
+				case out <- block:
+				case <-ctx.Done():
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Logs creates a subscription that is triggered for each log matching the
+// filter criteria, emitted by a block appended to the canonical chain. Logs
+// removed by reorgs are not reported.
+func (r *subscriptionResolver) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) (<-chan *Log, error) {
+	if r.events == nil {
+		return nil, errNoSubscriptions
+	}
+	var crit ethereum.FilterQuery
+	if args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	logs := make(chan []*types.Log)
+	sub, err := r.events.SubscribeLogs(crit, logs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *Log)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-logs:
+				for _, l := range batch {
+					if l.Removed {
+						continue
+					}
+					res := &Log{
+						backend:     r.backend,
+						transaction: &Transaction{backend: r.backend, hash: l.TxHash},
+						log:         l,
+					}
+					select {
+					case out <- res:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// PendingTransactions creates a subscription that is triggered each time a
+// transaction enters the transaction pool.
+func (r *subscriptionResolver) PendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	if r.events == nil {
+		return nil, errNoSubscriptions
+	}
+	var (
+		hashes = make(chan []common.Hash)
+		sub    = r.events.SubscribePendingTxs(hashes)
+		out    = make(chan *Transaction)
+	)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-hashes:
+				for _, hash := range batch {
+					select {
+					case out <- &Transaction{backend: r.backend, hash: hash}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// wsMessage is a single message of the graphql-ws protocol.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsHandler serves GraphQL operations, including subscriptions, over websocket
+// connections using the graphql-ws protocol.
+type wsHandler struct {
+	schema        *graphql.Schema // Schema executing the queries and mutations
+	subscriptions *graphql.Schema // Schema executing the subscriptions
+	upgrader      websocket.Upgrader
+}
+
+// newWSHandler creates a websocket handler accepting connections from the given
+// origins, checked the same way as the ones of the RPC websocket endpoint.
+func newWSHandler(schema, subscriptions *graphql.Schema, origins []string) *wsHandler {
+	return &wsHandler{
+		schema:        schema,
+		subscriptions: subscriptions,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  wsReadBuffer,
+			WriteBufferSize: wsWriteBuffer,
+			Subprotocols:    []string{wsProtocol},
+			CheckOrigin:     rpc.WebsocketOriginValidator(origins),
+		},
+	}
+}
+
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL WebSocket upgrade failed", "err", err)
+		return
+	}
+	newWSConn(conn, h.schema, h.subscriptions).serve()
+}
+
+// wsConn is a single GraphQL websocket connection, multiplexing the operations
+// started by the client.
+type wsConn struct {
+	conn          *websocket.Conn
+	schema        *graphql.Schema
+	subscriptions *graphql.Schema
+
+	ops   map[string]context.CancelFunc // Active operations by id
+	opsWg sync.WaitGroup
+	lock  sync.Mutex // Protects the active operations
+
+	writeLock sync.Mutex
+}
+
+func newWSConn(conn *websocket.Conn, schema, subscriptions *graphql.Schema) *wsConn {
+	return &wsConn{
+		conn:          conn,
+		schema:        schema,
+		subscriptions: subscriptions,
+		ops:           make(map[string]context.CancelFunc),
+	}
+}
+
+// serve reads and handles the client messages until the connection is closed
+// or terminated, tearing down all the active operations afterwards.
+func (c *wsConn) serve() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		c.opsWg.Wait()
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(wsMessageSizeLimit)
+
+	var initialized bool
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			log.Trace("GraphQL WebSocket read failed", "err", err)
+			return
+		}
+		switch msg.Type {
+		case gqlConnectionInit:
+			if !initialized {
+				initialized = true
+				c.write(&wsMessage{Type: gqlConnectionAck})
+				c.write(&wsMessage{Type: gqlConnectionKeepAlive})
+				go c.keepAlive(ctx)
+			}
+		case gqlStart:
+			if !initialized {
+				c.write(&wsMessage{Type: gqlConnectionError, Payload: errorPayload("connection not initialized")})
+				return
+			}
+			c.start(ctx, msg)
+		case gqlStop:
+			c.stop(msg.ID)
+		case gqlConnectionTerminate:
+			return
+		default:
+			c.write(&wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload("unknown message type " + msg.Type)})
+		}
+	}
+}
+
+// keepAlive periodically pings the client, as mandated by the protocol.
+func (c *wsConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(&wsMessage{Type: gqlConnectionKeepAlive}); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// start executes a new operation, streaming its results to the client.
+func (c *wsConn) start(ctx context.Context, msg wsMessage) {
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if err := json.Unmarshal(msg.Payload, &params); err != nil {
+		c.write(&wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload(err.Error())})
+		return
+	}
+	c.lock.Lock()
+	if _, ok := c.ops[msg.ID]; ok {
+		c.lock.Unlock()
+		c.write(&wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload("duplicate operation id")})
+		return
+	}
+	if len(c.ops) >= wsMaxSubscriptions {
+		c.lock.Unlock()
+		c.write(&wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload("too many active operations")})
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.ops[msg.ID] = cancel
+	c.opsWg.Add(1)
+	c.lock.Unlock()
+
+	// Let graph-gophers parse the document and select the operation. Executing
+	// it with the subscription schema rejects subscriptions before resolving
+	// anything, while queries and mutations fail the validation, as the schema
+	// has no fields for them apart from the introspection ones.
+	probe := c.subscriptions.Exec(ctx, params.Query, params.OperationName, params.Variables)
+
+	// Subscriptions are installed before handling the next message, whereas the
+	// queries and mutations are executed in the background
+	if !isSubscriptionResponse(probe) {
+		go func() {
+			defer c.finish(msg.ID)
+
+			// Subscriptions failing the validation are rejected the same way
+			// by the main schema, report the validation errors instead
+			response := c.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+			if isSubscriptionResponse(response) {
+				response = probe
+			}
+			if c.send(ctx, msg.ID, response) == nil {
+				c.write(&wsMessage{ID: msg.ID, Type: gqlComplete})
+			}
+		}()
+		return
+	}
+	responses, err := c.subscriptions.Subscribe(ctx, params.Query, params.OperationName, params.Variables)
+	if err != nil {
+		c.finish(msg.ID)
+		c.write(&wsMessage{ID: msg.ID, Type: gqlError, Payload: errorPayload(err.Error())})
+		return
+	}
+	go func() {
+		defer c.finish(msg.ID)
+
+		// Keep draining the responses after a failure, until the operation
+		// notices the cancellation and closes the channel
+		var failed bool
+		for response := range responses {
+			if failed || ctx.Err() != nil {
+				continue
+			}
+			if c.send(ctx, msg.ID, response) != nil {
+				failed = true
+				cancel()
+			}
+		}
+		if ctx.Err() == nil {
+			c.write(&wsMessage{ID: msg.ID, Type: gqlComplete})
+		}
+	}()
+}
+
+// send delivers a response of an operation to the client, unless the operation
+// was stopped.
+func (c *wsConn) send(ctx context.Context, id string, response interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return c.write(&wsMessage{ID: id, Type: gqlData, Payload: payload})
+}
+
+// stop cancels an active operation.
+func (c *wsConn) stop(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cancel, ok := c.ops[id]; ok {
+		cancel()
+	}
+}
+
+// finish removes a terminated operation from the active set.
+func (c *wsConn) finish(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cancel, ok := c.ops[id]; ok {
+		cancel()
+		delete(c.ops, id)
+		c.opsWg.Done()
+	}
+}
+
+// write sends a single message to the client.
+func (c *wsConn) write(msg *wsMessage) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(msg)
+}
+
+// isSubscriptionResponse reports whether the response of an executed operation
+// is the rejection of a subscription.
+func isSubscriptionResponse(response *graphql.Response) bool {
+	return len(response.Errors) == 1 && response.Errors[0].Message == errSubscriptionExec
+}
+
+// errorPayload creates the payload of an error message.
+func errorPayload(message string) json.RawMessage {
+	payload, _ := json.Marshal(map[string]string{"message": message})
+	return payload
+}
@@ -252,6 +252,67 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// TxAdmissionResult is the outcome of simulating the admission of a transaction
+// into the transaction pool.
+type TxAdmissionResult struct {
+	Hash          common.Hash   `json:"hash"`
+	Accepted      bool          `json:"accepted"`
+	Error         string        `json:"error,omitempty"`
+	Status        string        `json:"status,omitempty"`
+	Position      hexutil.Uint  `json:"position"`
+	Replaces      *common.Hash  `json:"replaces,omitempty"`
+	Evicts        []common.Hash `json:"evicts,omitempty"`
+	MinTip        *hexutil.Big  `json:"minTip,omitempty"`
+	ReplaceFeeCap *hexutil.Big  `json:"replaceFeeCap,omitempty"`
+	ReplaceTip    *hexutil.Big  `json:"replaceTip,omitempty"`
+	PoolFull      bool          `json:"poolFull"`
+	EvictTip      *hexutil.Big  `json:"evictTip,omitempty"`
+	EvictFeeCap   *hexutil.Big  `json:"evictFeeCap,omitempty"`
+}
+
+// SimulateAdd runs the checks of adding a signed transaction to the pool without
+// inserting it. It reports the reason of rejection along with the thresholds the
+// transaction needs to meet, or whether it would be pending or queued and which
+// transactions it would replace or evict. The transaction is treated as received
+// from the network unless local is set.
+func (s *PublicTxPoolAPI) SimulateAdd(input hexutil.Bytes, local *bool) (*TxAdmissionResult, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return nil, err
+	}
+	admission, err := s.b.SimulatePoolAdd(tx, local != nil && *local)
+	if err != nil {
+		return nil, err
+	}
+	result := &TxAdmissionResult{
+		Hash:          tx.Hash(),
+		Accepted:      admission.Error == nil,
+		Position:      hexutil.Uint(admission.Position),
+		MinTip:        (*hexutil.Big)(admission.MinTip),
+		ReplaceFeeCap: (*hexutil.Big)(admission.ReplaceFeeCap),
+		ReplaceTip:    (*hexutil.Big)(admission.ReplaceTip),
+		PoolFull:      admission.PoolFull,
+		EvictTip:      (*hexutil.Big)(admission.EvictTip),
+		EvictFeeCap:   (*hexutil.Big)(admission.EvictFeeCap),
+	}
+	switch {
+	case admission.Error != nil:
+		result.Error = admission.Error.Error()
+	case admission.Pending:
+		result.Status = "pending"
+	default:
+		result.Status = "queued"
+	}
+	if admission.Replaces != nil {
+		hash := admission.Replaces.Hash()
+		result.Replaces = &hash
+	}
+	for _, tx := range admission.Evicts {
+		result.Evicts = append(result.Evicts, tx.Hash())
+	}
+	return result, nil
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -766,6 +827,50 @@ func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Ha
 	return nil, err
 }
 
+// GetBlockReceipts returns the receipts of all transactions in the given block,
+// or nil if the block is not found.
+func (s *PublicBlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	var (
+		block    *types.Block
+		receipts types.Receipts
+		err      error
+	)
+	pending := false
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		block, receipts = s.b.PendingBlockAndReceipts()
+		pending = true
+	} else {
+		block, err = s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+		if block == nil || err != nil {
+			return nil, err
+		}
+		receipts, err = s.b.GetReceipts(ctx, block.Hash())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if block == nil {
+		return nil, nil
+	}
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	var (
+		header = block.Header()
+		hash   = block.Hash()
+		signer = types.MakeSigner(s.b.ChainConfig(), block.Number())
+		result = make([]map[string]interface{}, len(receipts))
+	)
+	if pending {
+		hash = common.Hash{}
+	}
+	for i, receipt := range receipts {
+		result[i] = marshalReceipt(s.b.ChainConfig(), signer, header, hash, txs[i], uint64(i), receipt)
+	}
+	return result, nil
+}
+
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
 	block, err := s.b.BlockByNumber(ctx, blockNr)
@@ -896,6 +1001,7 @@ type BlockOverrides struct {
 	GasLimit   *hexutil.Uint64
 	Coinbase   *common.Address
 	Random     *common.Hash
+	BaseFee    *hexutil.Big
 }
 
 // Apply overrides the given header fields into the given block context.
@@ -921,6 +1027,9 @@ func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
 	if diff.Random != nil {
 		blockCtx.Random = diff.Random
 	}
+	if diff.BaseFee != nil {
+		blockCtx.BaseFee = diff.BaseFee.ToInt()
+	}
 }
 
 func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
@@ -1592,15 +1701,25 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	}
 	receipt := receipts[index]
 
+	header, err := s.b.HeaderByHash(ctx, blockHash)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), new(big.Int).SetUint64(blockNumber))
+	return marshalReceipt(s.b.ChainConfig(), signer, header, blockHash, tx, index, receipt), nil
+}
+
+// marshalReceipt converts the receipt of the transaction at the given index of a
+// block into the RPC representation. An empty block hash denotes the pending
+// block and is returned as null.
+func marshalReceipt(config *params.ChainConfig, signer types.Signer, header *types.Header, blockHash common.Hash, tx *types.Transaction, index uint64, receipt *types.Receipt) map[string]interface{} {
 	// Derive the sender.
-	bigblock := new(big.Int).SetUint64(blockNumber)
-	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
-		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"blockNumber":       hexutil.Uint64(header.Number.Uint64()),
+		"transactionHash":   tx.Hash(),
 		"transactionIndex":  hexutil.Uint64(index),
 		"from":              from,
 		"to":                tx.To(),
@@ -1611,14 +1730,13 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"logsBloom":         receipt.Bloom,
 		"type":              hexutil.Uint(tx.Type()),
 	}
+	if blockHash == (common.Hash{}) {
+		fields["blockHash"] = nil
+	}
 	// Assign the effective gas price paid
-	if !s.b.ChainConfig().IsLondon(bigblock) {
+	if !config.IsLondon(header.Number) {
 		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
 	} else {
-		header, err := s.b.HeaderByHash(ctx, blockHash)
-		if err != nil {
-			return nil, err
-		}
 		gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
 		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	}
@@ -1635,7 +1753,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
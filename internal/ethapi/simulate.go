@@ -0,0 +1,397 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks that can be simulated
+	// in a single request.
+	maxSimulateBlocks = 256
+
+	// simulateBlockTime is the timestamp increment of the simulated blocks, if
+	// not overridden.
+	simulateBlockTime = 12
+)
+
+// SimulateBlock is a block to simulate: a set of header and state overrides,
+// and the calls to execute on top of them.
+type SimulateBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// SimulateOpts is the input of eth_simulateBlocks.
+type SimulateOpts struct {
+	Blocks     []SimulateBlock `json:"blocks"`
+	Validation bool            `json:"validation"` // Whether to enforce nonces, base fee and block gas limits
+}
+
+// simCallError is the failure of a simulated call.
+type simCallError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"` // Hex encoded revert data
+}
+
+// simCallResult is the outcome of a simulated call.
+type simCallResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Logs       []*types.Log   `json:"logs"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Status     hexutil.Uint64 `json:"status"`
+	Error      *simCallError  `json:"error,omitempty"`
+}
+
+// simBlockResult is the outcome of a simulated block.
+type simBlockResult struct {
+	Number       hexutil.Uint64   `json:"number"`
+	Hash         common.Hash      `json:"hash"`
+	Timestamp    hexutil.Uint64   `json:"timestamp"`
+	GasLimit     hexutil.Uint64   `json:"gasLimit"`
+	GasUsed      hexutil.Uint64   `json:"gasUsed"`
+	FeeRecipient common.Address   `json:"feeRecipient"`
+	BaseFee      *hexutil.Big     `json:"baseFeePerGas,omitempty"`
+	Calls        []*simCallResult `json:"calls"`
+}
+
+// SimulateBlocks executes a sequence of blocks on top of the given one, each
+// with its own header and state overrides, and an ordered list of calls. Every
+// call sees the state left behind by the previous ones, both within a block and
+// across blocks.
+//
+// By default the calls are executed like eth_call, skipping the nonce and base
+// fee checks. If validation is requested, the calls must be valid transactions
+// of the simulated blocks: their nonces (if set) must match, the fee caps must
+// cover the base fee and the calls must fit into the block gas limit.
+//
+// In both modes, the gas used by all the calls together is limited by the RPC
+// gas cap, and the whole simulation by the RPC EVM timeout.
+//
+// Note, block rewards are not credited and the simulated block hashes are not
+// the ones of real blocks, since the headers lack the transaction and state
+// roots.
+func (s *PublicBlockChainAPI) SimulateBlocks(ctx context.Context, opts SimulateOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]*simBlockResult, error) {
+	if len(opts.Blocks) == 0 {
+		return nil, errors.New("no blocks to simulate")
+	}
+	if len(opts.Blocks) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks to simulate: %d > %d", len(opts.Blocks), maxSimulateBlocks)
+	}
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	state, parent, err := s.b.StateAndHeaderByNumberOrHash(ctx, bNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// The timeout covers the entire simulation, not the individual calls
+	var (
+		timeout = s.b.RPCEVMTimeout()
+		cancel  context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	sim := &simulator{
+		b:        s.b,
+		state:    state,
+		base:     parent,
+		hashes:   make(map[uint64]common.Hash),
+		validate: opts.Validation,
+		timeout:  timeout,
+	}
+	// Abort the executing call once the context is done, be it because of the
+	// timeout or because the simulation returned
+	go func() {
+		<-ctx.Done()
+		sim.abort()
+	}()
+	defer func(start time.Time) {
+		log.Debug("Simulating blocks finished", "blocks", len(opts.Blocks), "runtime", time.Since(start))
+	}(time.Now())
+
+	results := make([]*simBlockResult, 0, len(opts.Blocks))
+	for i, block := range opts.Blocks {
+		header, err := sim.makeHeader(parent, block.BlockOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		if err := block.StateOverrides.Apply(state); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		result, err := sim.processBlock(ctx, header, block.Calls)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// simulator executes a sequence of simulated blocks on top of a base state.
+type simulator struct {
+	b     Backend
+	state *state.StateDB
+	base  *types.Header // Header of the block the simulation is based on
+
+	hashes   map[uint64]common.Hash // Hashes of the simulated blocks and their ancestors
+	logs     int                    // Number of logs emitted by all the previous calls
+	gasUsed  uint64                 // Gas used by all the previous calls
+	validate bool
+	timeout  time.Duration
+
+	evm     *vm.EVM // EVM executing the current call
+	aborted bool    // Whether the simulation was aborted
+	lock    sync.Mutex
+}
+
+// abort cancels the executing call and any further one.
+func (sim *simulator) abort() {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+
+	sim.aborted = true
+	if sim.evm != nil {
+		sim.evm.Cancel()
+	}
+}
+
+// makeHeader assembles the header of the next simulated block, applying the
+// overrides on top of the defaults derived from the parent.
+func (sim *simulator) makeHeader(parent *types.Header, overrides *BlockOverrides) (*types.Header, error) {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  types.EmptyUncleHash,
+		Coinbase:   parent.Coinbase,
+		Difficulty: parent.Difficulty,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + simulateBlockTime,
+		MixDigest:  parent.MixDigest,
+	}
+	if overrides != nil {
+		if overrides.Number != nil {
+			if overrides.Number.ToInt().Cmp(parent.Number) <= 0 {
+				return nil, fmt.Errorf("block number %v not above parent %v", overrides.Number.ToInt(), parent.Number)
+			}
+			header.Number = new(big.Int).Set(overrides.Number.ToInt())
+		}
+		if overrides.Difficulty != nil {
+			header.Difficulty = new(big.Int).Set(overrides.Difficulty.ToInt())
+		}
+		if overrides.Time != nil {
+			if !overrides.Time.ToInt().IsUint64() {
+				return nil, fmt.Errorf("invalid timestamp %v", overrides.Time.ToInt())
+			}
+			header.Time = overrides.Time.ToInt().Uint64()
+			if sim.validate && header.Time <= parent.Time {
+				return nil, fmt.Errorf("timestamp %d not above parent %d", header.Time, parent.Time)
+			}
+		}
+		if overrides.GasLimit != nil {
+			header.GasLimit = uint64(*overrides.GasLimit)
+		}
+		if overrides.Coinbase != nil {
+			header.Coinbase = *overrides.Coinbase
+		}
+		if overrides.Random != nil {
+			header.MixDigest = *overrides.Random
+		}
+	}
+	if sim.b.ChainConfig().IsLondon(header.Number) {
+		if overrides != nil && overrides.BaseFee != nil {
+			header.BaseFee = new(big.Int).Set(overrides.BaseFee.ToInt())
+		} else {
+			header.BaseFee = misc.CalcBaseFee(sim.b.ChainConfig(), parent)
+		}
+	}
+	return header, nil
+}
+
+// processBlock executes the calls of a simulated block on top of the current
+// state, and seals the header with the gas used.
+func (sim *simulator) processBlock(ctx context.Context, header *types.Header, calls []TransactionArgs) (*simBlockResult, error) {
+	var (
+		gp      = new(core.GasPool).AddGas(math.MaxUint64)
+		gasUsed uint64
+		logs    []*types.Log
+		results = make([]*simCallResult, 0, len(calls))
+	)
+	if sim.validate {
+		gp = new(core.GasPool).AddGas(header.GasLimit)
+	}
+	for i, args := range calls {
+		result, err := sim.processCall(ctx, header, args, i, gp)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		gasUsed += uint64(result.GasUsed)
+		logs = append(logs, result.Logs...)
+		results = append(results, result)
+	}
+	header.GasUsed = gasUsed
+
+	// The block hash depends on the gas used, patch it into the logs afterwards
+	hash := header.Hash()
+	for i, l := range logs {
+		l.BlockHash = hash
+		l.Index = uint(i)
+	}
+	sim.hashes[header.Number.Uint64()] = hash
+
+	result := &simBlockResult{
+		Number:       hexutil.Uint64(header.Number.Uint64()),
+		Hash:         hash,
+		Timestamp:    hexutil.Uint64(header.Time),
+		GasLimit:     hexutil.Uint64(header.GasLimit),
+		GasUsed:      hexutil.Uint64(header.GasUsed),
+		FeeRecipient: header.Coinbase,
+		Calls:        results,
+	}
+	if header.BaseFee != nil {
+		result.BaseFee = (*hexutil.Big)(header.BaseFee)
+	}
+	return result, nil
+}
+
+// processCall executes a single call of a simulated block. Failed executions are
+// part of the result, whereas invalid calls abort the simulation.
+func (sim *simulator) processCall(ctx context.Context, header *types.Header, args TransactionArgs, index int, gp *core.GasPool) (*simCallResult, error) {
+	if sim.validate && args.Gas == nil {
+		gas := hexutil.Uint64(gp.Gas())
+		args.Gas = &gas
+	}
+	// All the calls share the gas cap, limit this one to what's left of it
+	gasCap := sim.b.RPCGasCap()
+	if gasCap != 0 {
+		if sim.gasUsed >= gasCap {
+			return nil, fmt.Errorf("gas cap of %d exhausted by the previous calls", gasCap)
+		}
+		gasCap -= sim.gasUsed
+	}
+	msg, err := args.ToMessage(gasCap, header.BaseFee)
+	if err != nil {
+		return nil, err
+	}
+	if sim.validate {
+		// Turn the call into a real transaction, enforcing the nonce
+		nonce := sim.state.GetNonce(msg.From())
+		if args.Nonce != nil {
+			nonce = uint64(*args.Nonce)
+		}
+		msg = types.NewMessage(msg.From(), msg.To(), nonce, msg.Value(), msg.Gas(), msg.GasPrice(), msg.GasFeeCap(), msg.GasTipCap(), msg.Data(), msg.AccessList(), false)
+	}
+	sim.state.Prepare(common.Hash{}, index)
+
+	evm, vmError, err := sim.b.GetEVM(ctx, msg, sim.state, header, &vm.Config{NoBaseFee: !sim.validate})
+	if err != nil {
+		return nil, err
+	}
+	evm.Context.GetHash = sim.getHash
+
+	sim.lock.Lock()
+	sim.evm = evm
+	if sim.aborted {
+		evm.Cancel()
+	}
+	sim.lock.Unlock()
+
+	result, err := core.ApplyMessage(evm, msg, gp)
+	if err := vmError(); err != nil {
+		return nil, err
+	}
+	if evm.Cancelled() {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", sim.timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err: %w (supplied gas %d)", err, msg.Gas())
+	}
+	sim.state.Finalise(sim.b.ChainConfig().IsEIP158(header.Number))
+	sim.gasUsed += result.UsedGas
+
+	// Collect the logs emitted by the call, all of them share the empty hash
+	all := sim.state.Logs()
+	logs := all[sim.logs:]
+	sim.logs = len(all)
+	for _, l := range logs {
+		l.BlockNumber = header.Number.Uint64()
+	}
+	call := &simCallResult{
+		ReturnData: result.Return(),
+		Logs:       append([]*types.Log{}, logs...),
+		GasUsed:    hexutil.Uint64(result.UsedGas),
+		Status:     hexutil.Uint64(types.ReceiptStatusSuccessful),
+	}
+	if result.Failed() {
+		call.Status = hexutil.Uint64(types.ReceiptStatusFailed)
+		if len(result.Revert()) > 0 {
+			err := newRevertError(result)
+			call.Error = &simCallError{Code: err.ErrorCode(), Message: err.Error(), Data: err.reason}
+		} else {
+			call.Error = &simCallError{Code: -32015, Message: result.Err.Error()}
+		}
+	}
+	return call, nil
+}
+
+// getHash resolves the hash of a simulated block or of an ancestor of the block
+// the simulation is based on.
+func (sim *simulator) getHash(number uint64) common.Hash {
+	if hash, ok := sim.hashes[number]; ok {
+		return hash
+	}
+	if number > sim.base.Number.Uint64() {
+		return common.Hash{}
+	}
+	// Walk back from the base block, caching all the hashes on the way
+	header := sim.base
+	for header != nil && header.Number.Uint64() > number {
+		sim.hashes[header.Number.Uint64()] = header.Hash()
+		header, _ = sim.b.HeaderByHash(context.Background(), header.ParentHash)
+	}
+	if header == nil || header.Number.Uint64() != number {
+		return common.Hash{}
+	}
+	sim.hashes[number] = header.Hash()
+	return header.Hash()
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	simSender   = common.Address{0x01}
+	simReceiver = common.Address{0x02}
+	simContract = common.Address{0xcc}
+)
+
+// simBackend is a backend serving the simulations on top of a genesis block.
+// Only the methods needed by the simulation are implemented.
+type simBackend struct {
+	Backend
+
+	db     *state.StateDB
+	header *types.Header
+	gasCap uint64
+}
+
+func newSimBackend(t *testing.T, gasCap uint64) *simBackend {
+	genesis := &core.Genesis{
+		Config:   params.TestChainConfig,
+		GasLimit: 30_000_000,
+		BaseFee:  big.NewInt(params.InitialBaseFee),
+		Alloc:    core.GenesisAlloc{simSender: {Balance: big.NewInt(params.Ether)}},
+	}
+	db := rawdb.NewMemoryDatabase()
+	block := genesis.ToBlock(db)
+	statedb, err := state.New(block.Root(), state.NewDatabase(db), nil)
+	if err != nil {
+		t.Fatalf("failed to open genesis state: %v", err)
+	}
+	return &simBackend{db: statedb, header: block.Header(), gasCap: gasCap}
+}
+
+func (b *simBackend) RPCGasCap() uint64                { return b.gasCap }
+func (b *simBackend) RPCEVMTimeout() time.Duration     { return 5 * time.Second }
+func (b *simBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *simBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	return b.db.Copy(), b.header, nil
+}
+
+func (b *simBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	context := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+	return vm.NewEVM(context, core.NewEVMTxContext(msg), state, b.ChainConfig(), *vmConfig), func() error { return nil }, nil
+}
+
+func simBig(n int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(n)) }
+
+func simBytes(b []byte) *hexutil.Bytes { return (*hexutil.Bytes)(&b) }
+
+func simUint64(n uint64) *hexutil.Uint64 { return (*hexutil.Uint64)(&n) }
+
+// Tests that the state left behind by the calls of a simulated block is seen by
+// the calls of the following ones.
+func TestSimulateBlocksChainedState(t *testing.T) {
+	var (
+		api = NewPublicBlockChainAPI(newSimBackend(t, 0))
+
+		// Stores 42 in the first slot if called with data, returns it otherwise
+		code = hexutil.Bytes(common.FromHex("0x3615600b57602a600055005b60005460005260206000f3"))
+	)
+	results, err := api.SimulateBlocks(context.Background(), SimulateOpts{
+		Blocks: []SimulateBlock{
+			{
+				StateOverrides: &StateOverride{simContract: {Code: &code}},
+				Calls: []TransactionArgs{
+					{From: &simSender, To: &simReceiver, Value: simBig(1000)},
+					{From: &simSender, To: &simContract, Data: simBytes([]byte{0x01})},
+				},
+			},
+			{
+				Calls: []TransactionArgs{
+					{From: &simReceiver, To: &simSender, Value: simBig(1000)},
+					{From: &simSender, To: &simContract},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to simulate blocks: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("block count mismatch: have %d, want 2", len(results))
+	}
+	for i, block := range results {
+		if want := uint64(i + 1); uint64(block.Number) != want {
+			t.Errorf("block %d: number mismatch: have %d, want %d", i, block.Number, want)
+		}
+		for j, call := range block.Calls {
+			if call.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+				t.Errorf("block %d call %d: failed: %+v", i, j, call.Error)
+			}
+		}
+	}
+	if results[1].Timestamp <= results[0].Timestamp {
+		t.Errorf("timestamps not increasing: %d, %d", results[0].Timestamp, results[1].Timestamp)
+	}
+	if have := new(big.Int).SetBytes(results[1].Calls[1].ReturnData); have.Uint64() != 42 {
+		t.Errorf("stored value mismatch: have %v, want 42", have)
+	}
+}
+
+// Tests that the validation mode enforces the nonces and the base fee, which
+// are ignored otherwise.
+func TestSimulateBlocksValidation(t *testing.T) {
+	api := NewPublicBlockChainAPI(newSimBackend(t, 0))
+
+	tests := []struct {
+		call TransactionArgs
+		err  string
+	}{
+		{
+			call: TransactionArgs{From: &simSender, To: &simReceiver, Nonce: simUint64(1), MaxFeePerGas: simBig(params.GWei)},
+			err:  "nonce too high",
+		},
+		{
+			call: TransactionArgs{From: &simSender, To: &simReceiver, MaxFeePerGas: simBig(1)},
+			err:  "max fee per gas less than block base fee",
+		},
+		{
+			call: TransactionArgs{From: &simSender, To: &simReceiver, Nonce: simUint64(0), MaxFeePerGas: simBig(params.GWei)},
+		},
+	}
+	for i, tt := range tests {
+		// Without validation and fees, all the calls succeed
+		call := tt.call
+		call.MaxFeePerGas = nil
+		if _, err := api.SimulateBlocks(context.Background(), SimulateOpts{Blocks: []SimulateBlock{{Calls: []TransactionArgs{call}}}}, nil); err != nil {
+			t.Errorf("test %d: failed to simulate without validation: %v", i, err)
+		}
+		_, err := api.SimulateBlocks(context.Background(), SimulateOpts{Blocks: []SimulateBlock{{Calls: []TransactionArgs{tt.call}}}, Validation: true}, nil)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("test %d: failed to simulate with validation: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that the base fee of a simulated block can be overridden, and that it
+// is then seen by the calls and enforced by the validation.
+func TestSimulateBlocksBaseFeeOverride(t *testing.T) {
+	var (
+		api = NewPublicBlockChainAPI(newSimBackend(t, 0))
+
+		// Returns the base fee of the block
+		code = hexutil.Bytes(common.FromHex("0x4860005260206000f3"))
+	)
+	opts := SimulateOpts{
+		Blocks: []SimulateBlock{{
+			BlockOverrides: &BlockOverrides{BaseFee: simBig(7 * params.GWei)},
+			StateOverrides: &StateOverride{simContract: {Code: &code}},
+			Calls: []TransactionArgs{
+				{From: &simSender, To: &simContract, MaxFeePerGas: simBig(8 * params.GWei)},
+			},
+		}},
+		Validation: true,
+	}
+	results, err := api.SimulateBlocks(context.Background(), opts, nil)
+	if err != nil {
+		t.Fatalf("failed to simulate blocks: %v", err)
+	}
+	if have := results[0].BaseFee.ToInt(); have.Cmp(big.NewInt(7*params.GWei)) != 0 {
+		t.Errorf("block base fee mismatch: have %v, want %v", have, 7*params.GWei)
+	}
+	if have := new(big.Int).SetBytes(results[0].Calls[0].ReturnData); have.Cmp(big.NewInt(7*params.GWei)) != 0 {
+		t.Errorf("executed base fee mismatch: have %v, want %v", have, 7*params.GWei)
+	}
+	// A fee cap covering the original base fee but not the overridden one fails
+	opts.Blocks[0].Calls[0].MaxFeePerGas = simBig(6 * params.GWei)
+	if _, err := api.SimulateBlocks(context.Background(), opts, nil); err == nil || !strings.Contains(err.Error(), "max fee per gas less than block base fee") {
+		t.Errorf("error mismatch: have %v, want base fee failure", err)
+	}
+}
+
+// Tests that the simulations are bounded in the number of blocks and in the
+// total gas used.
+func TestSimulateBlocksLimits(t *testing.T) {
+	api := NewPublicBlockChainAPI(newSimBackend(t, 100_000))
+
+	blocks := make([]SimulateBlock, maxSimulateBlocks+1)
+	if _, err := api.SimulateBlocks(context.Background(), SimulateOpts{Blocks: blocks}, nil); err == nil || !strings.Contains(err.Error(), "too many blocks") {
+		t.Errorf("error mismatch: have %v, want too many blocks", err)
+	}
+	if _, err := api.SimulateBlocks(context.Background(), SimulateOpts{Blocks: blocks[:maxSimulateBlocks]}, nil); err != nil {
+		t.Errorf("failed to simulate the maximum number of blocks: %v", err)
+	}
+	// Burn all the gas available in a loop, leaving none for the following calls
+	code := hexutil.Bytes(common.FromHex("0x5b600056"))
+	block := SimulateBlock{
+		StateOverrides: &StateOverride{simContract: {Code: &code}},
+		Calls:          []TransactionArgs{{From: &simSender, To: &simContract}},
+	}
+	results, err := api.SimulateBlocks(context.Background(), SimulateOpts{Blocks: []SimulateBlock{block}}, nil)
+	if err != nil {
+		t.Fatalf("failed to simulate blocks: %v", err)
+	}
+	if call := results[0].Calls[0]; call.GasUsed != 100_000 || call.Status != hexutil.Uint64(types.ReceiptStatusFailed) {
+		t.Fatalf("looping call mismatch: gas used %d, status %d", call.GasUsed, call.Status)
+	}
+	block.Calls = append(block.Calls, TransactionArgs{From: &simSender, To: &simReceiver})
+	if _, err := api.SimulateBlocks(context.Background(), SimulateOpts{Blocks: []SimulateBlock{block, block}}, nil); err == nil || !strings.Contains(err.Error(), "gas cap of 100000 exhausted") {
+		t.Errorf("error mismatch: have %v, want exhausted gas cap", err)
+	}
+}
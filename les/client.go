@@ -71,6 +71,7 @@ type LightEthereum struct {
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
 	ApiBackend     *LesApiBackend
+	events         *filters.EventSystem // Event system backing the filters and subscriptions
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
 	accountManager *accounts.Manager
@@ -182,6 +183,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*LightEthereum, error) {
 		gpoParams.Default = config.Miner.GasPrice
 	}
 	leth.ApiBackend.gpo = gasprice.NewOracle(leth.ApiBackend, gpoParams)
+	leth.events = filters.NewEventSystem(leth.ApiBackend, true)
 
 	leth.handler = newClientHandler(config.UltraLightServers, config.UltraLightFraction, checkpoint, leth)
 	if leth.handler.ulc != nil {
@@ -305,7 +307,7 @@ func (s *LightEthereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true, 5*time.Minute),
+			Service:   filters.NewPublicFilterAPIWithEvents(s.ApiBackend, s.events, 5*time.Minute, s.config.RPCLogsLimit),
 			Public:    true,
 		}, {
 			Namespace: "net",
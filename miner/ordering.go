@@ -0,0 +1,228 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Transaction ordering policies selectable via Config.Ordering.
+const (
+	OrderingPriceAndNonce = "price"  // Effective miner tip, then first seen time (default)
+	OrderingFirstSeen     = "fifo"   // First seen time
+	OrderingProfit        = "profit" // Coinbase balance change when executed, then first seen time
+)
+
+// transactionSet is a set of pending transactions committed into a block in
+// order, honouring the nonce order of each account.
+type transactionSet interface {
+	// Peek returns the next transaction to commit, or nil if all are done.
+	Peek() *types.Transaction
+
+	// Shift replaces the next transaction with the following one from the same
+	// account, after it has been committed or skipped.
+	Shift()
+
+	// Pop discards the next transaction together with all the following ones
+	// from the same account.
+	Pop()
+}
+
+// orderingPolicy decides the order the worker commits pending transactions in.
+type orderingPolicy interface {
+	// order returns the nonce-sorted transactions of each account as a set to be
+	// committed into the block being assembled in env. The map is reowned.
+	order(env *environment, txs map[common.Address]types.Transactions) transactionSet
+}
+
+// newOrderingPolicy creates the ordering policy with the given name, where the
+// empty name selects the default price and nonce ordering.
+func newOrderingPolicy(name string, config *params.ChainConfig, chain core.ChainContext) (orderingPolicy, error) {
+	switch name {
+	case "", OrderingPriceAndNonce:
+		return priceAndNonceOrdering{}, nil
+	case OrderingFirstSeen:
+		return firstSeenOrdering{}, nil
+	case OrderingProfit:
+		return &profitOrdering{config: config, chain: chain}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering %q", name)
+	}
+}
+
+// priceAndNonceOrdering orders transactions by the effective miner tip.
+type priceAndNonceOrdering struct{}
+
+func (priceAndNonceOrdering) order(env *environment, txs map[common.Address]types.Transactions) transactionSet {
+	return types.NewTransactionsByPriceAndNonce(env.signer, txs, env.header.BaseFee)
+}
+
+// firstSeenOrdering orders transactions by the time they were first seen.
+type firstSeenOrdering struct{}
+
+func (firstSeenOrdering) order(env *environment, txs map[common.Address]types.Transactions) transactionSet {
+	return newScoredTransactions(env.signer, txs, env.header.BaseFee, func(*types.Transaction) *big.Int {
+		return common.Big0
+	})
+}
+
+// profitOrdering orders transactions by the change of the coinbase balance when
+// executed on top of the block assembled so far, accounting for direct payments
+// to the coinbase too. Transactions are simulated as they become the next one
+// of their account, so the ranking of the others isn't updated as the block is
+// filled.
+type profitOrdering struct {
+	config *params.ChainConfig
+	chain  core.ChainContext
+}
+
+func (p *profitOrdering) order(env *environment, txs map[common.Address]types.Transactions) transactionSet {
+	return newScoredTransactions(env.signer, txs, env.header.BaseFee, func(tx *types.Transaction) *big.Int {
+		return p.simulate(env, tx)
+	})
+}
+
+// simulate executes the transaction on top of the environment state, returning
+// the coinbase balance change. The state is reverted afterwards.
+func (p *profitOrdering) simulate(env *environment, tx *types.Transaction) *big.Int {
+	msg, err := tx.AsMessage(env.signer, env.header.BaseFee)
+	if err != nil {
+		return common.Big0
+	}
+	var (
+		snap    = env.state.Snapshot()
+		before  = env.state.GetBalance(env.coinbase)
+		context = core.NewEVMBlockContext(env.header, p.chain, &env.coinbase)
+		evm     = vm.NewEVM(context, core.NewEVMTxContext(msg), env.state, p.config, vm.Config{})
+	)
+	defer env.state.RevertToSnapshot(snap)
+
+	// Simulate against the gas left in the block, without consuming it
+	gasPool := new(core.GasPool).AddGas(env.header.GasLimit)
+	if env.gasPool != nil {
+		*gasPool = *env.gasPool
+	}
+	if _, err := core.ApplyMessage(evm, msg, gasPool); err != nil {
+		return common.Big0
+	}
+	return new(big.Int).Sub(env.state.GetBalance(env.coinbase), before)
+}
+
+// scoredTransaction is the next transaction of an account along with its score.
+type scoredTransaction struct {
+	tx    *types.Transaction
+	from  common.Address
+	score *big.Int
+}
+
+// scoredHeads is a heap of scored transactions, sorted by descending score and
+// then by the time the transactions were first seen.
+type scoredHeads []*scoredTransaction
+
+func (s scoredHeads) Len() int { return len(s) }
+func (s scoredHeads) Less(i, j int) bool {
+	if cmp := s[i].score.Cmp(s[j].score); cmp != 0 {
+		return cmp > 0
+	}
+	return s[i].tx.Time().Before(s[j].tx.Time())
+}
+func (s scoredHeads) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *scoredHeads) Push(x interface{}) {
+	*s = append(*s, x.(*scoredTransaction))
+}
+
+func (s *scoredHeads) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// scoredTransactions is a transaction set ordering the next transactions of the
+// accounts by a score, which is evaluated when a transaction becomes the next
+// one of its account.
+type scoredTransactions struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads   scoredHeads                           // Next transaction for each unique account (score heap)
+	score   func(*types.Transaction) *big.Int     // Scoring function of the transactions
+	baseFee *big.Int                              // Current base fee, transactions paying less are dropped
+}
+
+// newScoredTransactions creates a transaction set ordered by the given score.
+// Like the price and nonce ordering, accounts whose next transaction can't pay
+// the base fee are dropped.
+func newScoredTransactions(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int, score func(*types.Transaction) *big.Int) *scoredTransactions {
+	heads := make(scoredHeads, 0, len(txs))
+	for from, accTxs := range txs {
+		// Remove the account if the sender doesn't match or the fee cap is too low
+		if acc, _ := types.Sender(signer, accTxs[0]); acc != from || !paysBaseFee(accTxs[0], baseFee) {
+			delete(txs, from)
+			continue
+		}
+		heads = append(heads, &scoredTransaction{tx: accTxs[0], from: from, score: score(accTxs[0])})
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	return &scoredTransactions{
+		txs:     txs,
+		heads:   heads,
+		score:   score,
+		baseFee: baseFee,
+	}
+}
+
+// paysBaseFee reports whether the fee cap of the transaction covers the base
+// fee, which is always the case before London.
+func paysBaseFee(tx *types.Transaction, baseFee *big.Int) bool {
+	return baseFee == nil || tx.GasFeeCapIntCmp(baseFee) >= 0
+}
+
+// Peek returns the next transaction by score.
+func (t *scoredTransactions) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+// Shift replaces the current best head with the next one from the same account.
+func (t *scoredTransactions) Shift() {
+	head := t.heads[0]
+	if txs := t.txs[head.from]; len(txs) > 0 && paysBaseFee(txs[0], t.baseFee) {
+		head.tx, head.score, t.txs[head.from] = txs[0], t.score(txs[0]), txs[1:]
+		heap.Fix(&t.heads, 0)
+		return
+	}
+	heap.Pop(&t.heads)
+}
+
+// Pop removes the best transaction, *not* replacing it with the next one from
+// the same account.
+func (t *scoredTransactions) Pop() {
+	heap.Pop(&t.heads)
+}
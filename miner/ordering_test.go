@@ -0,0 +1,142 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the ordering policies sort transactions according to their own
+// criteria while honouring the nonce order of each account.
+func TestTransactionOrdering(t *testing.T) {
+	var (
+		coinbase   = common.Address{0xc0}
+		signer     = types.LatestSigner(params.TestChainConfig)
+		keys       = make([]*ecdsa.PrivateKey, 4)
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		statedb.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(params.Ether))
+	}
+	env := &environment{
+		signer:   signer,
+		state:    statedb,
+		coinbase: coinbase,
+		header: &types.Header{
+			Number:     big.NewInt(1),
+			Difficulty: big.NewInt(1),
+			GasLimit:   10_000_000,
+			BaseFee:    big.NewInt(params.GWei),
+			Coinbase:   coinbase,
+		},
+	}
+	// Create transactions first seen in a known order:
+	//   - account 0 pays a high tip to a third party
+	//   - account 1 pays a low tip but transfers value to the coinbase
+	//   - account 2 pays a medium tip with two transactions
+	//   - account 3 and the second transaction of account 1 can't pay the
+	//     base fee, so they must be dropped by every policy
+	transfer := func(key int, nonce uint64, to common.Address, value int64, tip int64) *types.Transaction {
+		tx, _ := types.SignNewTx(keys[key], signer, &types.DynamicFeeTx{
+			ChainID:   params.TestChainConfig.ChainID,
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(tip * params.GWei),
+			GasFeeCap: big.NewInt((tip + 1) * params.GWei),
+			Gas:       params.TxGas,
+			To:        &to,
+			Value:     big.NewInt(value),
+		})
+		time.Sleep(time.Millisecond) // ensure distinct first seen times
+		return tx
+	}
+	underpriced := func(key int, nonce uint64) *types.Transaction {
+		tx, _ := types.SignNewTx(keys[key], signer, &types.DynamicFeeTx{
+			ChainID:   params.TestChainConfig.ChainID,
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(params.GWei / 2),
+			GasFeeCap: big.NewInt(params.GWei / 2),
+			Gas:       params.TxGas,
+			To:        &coinbase,
+			Value:     big.NewInt(1e16),
+		})
+		time.Sleep(time.Millisecond)
+		return tx
+	}
+	var (
+		tx0 = transfer(1, 0, coinbase, 1e15, 1)
+		tx1 = transfer(2, 0, common.Address{0xff}, 1, 5)
+		tx2 = transfer(0, 0, common.Address{0xff}, 1, 10)
+		tx3 = transfer(2, 1, common.Address{0xff}, 1, 5)
+		tx4 = underpriced(3, 0)
+		tx5 = underpriced(1, 1)
+	)
+	pending := func() map[common.Address]types.Transactions {
+		txs := make(map[common.Address]types.Transactions)
+		for _, tx := range []*types.Transaction{tx0, tx1, tx2, tx3, tx4, tx5} {
+			from, _ := types.Sender(signer, tx)
+			txs[from] = append(txs[from], tx)
+		}
+		return txs
+	}
+	tests := []struct {
+		ordering string
+		want     []*types.Transaction
+	}{
+		{OrderingPriceAndNonce, []*types.Transaction{tx2, tx1, tx3, tx0}},
+		{OrderingFirstSeen, []*types.Transaction{tx0, tx1, tx2, tx3}},
+		{OrderingProfit, []*types.Transaction{tx0, tx2, tx1, tx3}},
+	}
+	for _, test := range tests {
+		policy, err := newOrderingPolicy(test.ordering, params.TestChainConfig, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create policy: %v", test.ordering, err)
+		}
+		root := statedb.IntermediateRoot(false)
+		set := policy.order(env, pending())
+
+		var have []*types.Transaction
+		for tx := set.Peek(); tx != nil; tx = set.Peek() {
+			have = append(have, tx)
+			set.Shift()
+		}
+		if len(have) != len(test.want) {
+			t.Fatalf("%s: transaction count mismatch: have %d, want %d", test.ordering, len(have), len(test.want))
+		}
+		for i := range have {
+			if have[i] != test.want[i] {
+				t.Errorf("%s: transaction %d mismatch: have %x, want %x", test.ordering, i, have[i].Hash(), test.want[i].Hash())
+			}
+		}
+		if statedb.IntermediateRoot(false) != root {
+			t.Errorf("%s: state modified by ordering", test.ordering)
+		}
+	}
+	if _, err := newOrderingPolicy("random", params.TestChainConfig, nil); err == nil {
+		t.Errorf("unknown ordering accepted")
+	}
+}
@@ -203,6 +203,14 @@ type Config struct {
 
 	// JWTSecret is the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
+
+	// RPCRateLimit is the policy limiting the requests of the clients of the HTTP
+	// and WebSocket RPC servers. Requests are not limited if it is nil.
+	RPCRateLimit *rpc.RateLimitPolicy `toml:",omitempty"`
+
+	// RPCRecord configures the recording of the requests served by the HTTP and
+	// WebSocket RPC servers. Requests are not recorded if it is nil.
+	RPCRecord *rpc.RecordConfig `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -73,6 +74,6 @@ func (handler *jwtHandler) ServeHTTP(out http.ResponseWriter, r *http.Request) {
 	case time.Until(claims.IssuedAt.Time) > 5*time.Second:
 		http.Error(out, "future token", http.StatusForbidden)
 	default:
-		handler.next.ServeHTTP(out, r)
+		handler.next.ServeHTTP(out, r.WithContext(rpc.WithJWTSubject(r.Context(), claims.Subject)))
 	}
 }
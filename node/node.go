@@ -64,6 +64,7 @@ type Node struct {
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
+	recorder  *rpc.Recorder                 // Request log shared by the HTTP and WebSocket servers, if enabled
 	databases map[*closeTrackingDB]struct{} // All open databases
 }
 
@@ -395,6 +396,22 @@ func (n *Node) startRPC() error {
 		servers   []*httpServer
 		open, all = n.GetAPIs()
 	)
+	// Create the rate limiter shared by the HTTP and WebSocket servers.
+	var limiter *rpc.RateLimiter
+	if n.config.RPCRateLimit != nil {
+		var err error
+		if limiter, err = rpc.NewRateLimiter(*n.config.RPCRateLimit); err != nil {
+			return err
+		}
+	}
+	// Create the request recorder shared by the HTTP and WebSocket servers.
+	if n.config.RPCRecord != nil {
+		recorder, err := rpc.NewRecorder(*n.config.RPCRecord)
+		if err != nil {
+			return err
+		}
+		n.recorder = recorder
+	}
 
 	initHttp := func(server *httpServer, apis []rpc.API, port int) error {
 		if err := server.setListenAddr(n.config.HTTPHost, port); err != nil {
@@ -405,6 +422,8 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			rateLimiter:        limiter,
+			recorder:           n.recorder,
 		}); err != nil {
 			return err
 		}
@@ -418,9 +437,11 @@ func (n *Node) startRPC() error {
 			return err
 		}
 		if err := server.enableWS(n.rpcAPIs, wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:     n.config.WSModules,
+			Origins:     n.config.WSOrigins,
+			prefix:      n.config.WSPathPrefix,
+			rateLimiter: limiter,
+			recorder:    n.recorder,
 		}); err != nil {
 			return err
 		}
@@ -512,6 +533,10 @@ func (n *Node) stopRPC() {
 	n.wsAuth.stop()
 	n.ipc.stop()
 	n.stopInProc()
+	if n.recorder != nil {
+		n.recorder.Close()
+		n.recorder = nil
+	}
 }
 
 // startInProc registers all RPC APIs on the inproc server.
@@ -38,16 +38,20 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
-	jwtSecret          []byte // optional JWT secret
+	prefix             string           // path prefix on which to mount http handler
+	jwtSecret          []byte           // optional JWT secret
+	rateLimiter        *rpc.RateLimiter // optional request rate limiter
+	recorder           *rpc.Recorder    // optional request recorder
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins   []string
-	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	Origins     []string
+	Modules     []string
+	prefix      string           // path prefix on which to mount ws handler
+	jwtSecret   []byte           // optional JWT secret
+	rateLimiter *rpc.RateLimiter // optional request rate limiter
+	recorder    *rpc.Recorder    // optional request recorder
 }
 
 type rpcHandler struct {
@@ -182,12 +186,11 @@ func (h *httpServer) start() error {
 }
 
 func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// check if ws request and serve if ws enabled
+	// check if ws request and serve if ws enabled. Websocket requests to other
+	// paths might still be served by the handlers registered in the mux.
 	ws := h.wsHandler.Load().(*rpcHandler)
-	if ws != nil && isWebsocket(r) {
-		if checkPath(r, h.wsConfig.prefix) {
-			ws.ServeHTTP(w, r)
-		}
+	if ws != nil && isWebsocket(r) && checkPath(r, h.wsConfig.prefix) {
+		ws.ServeHTTP(w, r)
 		return
 	}
 	// if http-rpc is enabled, try to serve request
@@ -281,6 +284,12 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	if config.rateLimiter != nil {
+		srv.SetRateLimiter(config.rateLimiter)
+	}
+	if config.recorder != nil {
+		srv.SetRecorder(config.recorder)
+	}
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
@@ -312,6 +321,12 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	if config.rateLimiter != nil {
+		srv.SetRateLimiter(config.rateLimiter)
+	}
+	if config.recorder != nil {
+		srv.SetRecorder(config.recorder)
+	}
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
@@ -372,6 +387,13 @@ func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSe
 	return newGzipHandler(handler)
 }
 
+// NewVHostHandler returns a handler which only serves the requests whose Host
+// header is in the given list of virtual hosts. Unlike NewHTTPHandlerStack, it
+// can wrap websocket handlers.
+func NewVHostHandler(vhosts []string, next http.Handler) http.Handler {
+	return newVHostHandler(vhosts, next)
+}
+
 // NewWSHandlerStack returns a wrapped ws-related handler.
 func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
 	if len(jwtSecret) != 0 {
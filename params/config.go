@@ -260,16 +260,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int), false)
 )
 
@@ -365,6 +365,10 @@ type ChainConfig struct {
 	// the network that triggers the consensus upgrade.
 	TerminalTotalDifficulty *big.Int `json:"terminalTotalDifficulty,omitempty"`
 
+	// FeeMarket overrides the base fee parameters and update rule of EIP-1559,
+	// e.g. for private networks and fee market experiments.
+	FeeMarket *FeeMarketConfig `json:"feeMarket,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -389,6 +393,54 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// Base fee update rules selectable in the fee market config.
+const (
+	BaseFeeRuleEIP1559     = "eip1559"     // Linear adjustment by the deviation of the gas used from the target
+	BaseFeeRuleExponential = "exponential" // Exponential adjustment by the deviation, similar to the blob gas price of EIP-4844
+)
+
+// FeeMarketConfig holds the parameters of the base fee calculation. Zero values
+// select the defaults of EIP-1559.
+type FeeMarketConfig struct {
+	Rule                     string `json:"rule,omitempty"`                     // Base fee update rule (default = eip1559)
+	BaseFeeChangeDenominator uint64 `json:"baseFeeChangeDenominator,omitempty"` // Bounds the amount the base fee can change between blocks
+	ElasticityMultiplier     uint64 `json:"elasticityMultiplier,omitempty"`     // Ratio of the gas limit to the gas target
+	InitialBaseFee           uint64 `json:"initialBaseFee,omitempty"`           // Base fee of the first London block
+}
+
+// String implements the stringer interface, returning the fee market details.
+func (c *FeeMarketConfig) String() string {
+	return fmt.Sprintf("%s (change denominator: %d, elasticity: %d)", c.rule(), c.changeDenominator(), c.elasticityMultiplier())
+}
+
+func (c *FeeMarketConfig) rule() string {
+	if c == nil || c.Rule == "" {
+		return BaseFeeRuleEIP1559
+	}
+	return c.Rule
+}
+
+func (c *FeeMarketConfig) changeDenominator() uint64 {
+	if c == nil || c.BaseFeeChangeDenominator == 0 {
+		return BaseFeeChangeDenominator
+	}
+	return c.BaseFeeChangeDenominator
+}
+
+func (c *FeeMarketConfig) elasticityMultiplier() uint64 {
+	if c == nil || c.ElasticityMultiplier == 0 {
+		return ElasticityMultiplier
+	}
+	return c.ElasticityMultiplier
+}
+
+func (c *FeeMarketConfig) initialBaseFee() uint64 {
+	if c == nil || c.InitialBaseFee == 0 {
+		return InitialBaseFee
+	}
+	return c.InitialBaseFee
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var banner string
@@ -445,6 +497,9 @@ func (c *ChainConfig) String() string {
 	}
 	banner += "\n"
 
+	if c.FeeMarket != nil {
+		banner += fmt.Sprintf("Fee market: %v\n\n", c.FeeMarket)
+	}
 	// Add a special section for the merge as it's non-obvious
 	if c.TerminalTotalDifficulty == nil {
 		banner += "Merge not configured!\n"
@@ -458,6 +513,27 @@ func (c *ChainConfig) String() string {
 	return banner
 }
 
+// BaseFeeRule returns the update rule of the base fee.
+func (c *ChainConfig) BaseFeeRule() string {
+	return c.FeeMarket.rule()
+}
+
+// BaseFeeChangeDenominator bounds the amount the base fee can change between blocks.
+func (c *ChainConfig) BaseFeeChangeDenominator() uint64 {
+	return c.FeeMarket.changeDenominator()
+}
+
+// ElasticityMultiplier returns the ratio of the gas limit to the gas target of
+// blocks, bounding the maximum gas limit an EIP-1559 block may have.
+func (c *ChainConfig) ElasticityMultiplier() uint64 {
+	return c.FeeMarket.elasticityMultiplier()
+}
+
+// InitialBaseFee returns the base fee of the first London block.
+func (c *ChainConfig) InitialBaseFee() uint64 {
+	return c.FeeMarket.initialBaseFee()
+}
+
 // IsHomestead returns whether num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	return isForked(c.HomesteadBlock, num)
@@ -600,6 +676,10 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 			lastFork = cur
 		}
 	}
+	// Ensure the base fee is computed by a known rule
+	if rule := c.BaseFeeRule(); rule != BaseFeeRuleEIP1559 && rule != BaseFeeRuleExponential {
+		return fmt.Errorf("unsupported base fee rule %q", rule)
+	}
 	return nil
 }
 
@@ -659,6 +739,10 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock, head) {
 		return newCompatError("Merge netsplit fork block", c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock)
 	}
+	if c.IsLondon(head) && (c.BaseFeeRule() != newcfg.BaseFeeRule() || c.BaseFeeChangeDenominator() != newcfg.BaseFeeChangeDenominator() ||
+		c.ElasticityMultiplier() != newcfg.ElasticityMultiplier() || c.InitialBaseFee() != newcfg.InitialBaseFee()) {
+		return newCompatError("fee market parameters", c.LondonBlock, newcfg.LondonBlock)
+	}
 	return nil
 }
 
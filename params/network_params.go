@@ -32,6 +32,14 @@ const (
 	// considered probably final and its rotated bits are calculated.
 	BloomConfirms = 256
 
+	// CliqueStatsBlocks is the number of blocks a single section of the clique
+	// signer statistics index contains.
+	CliqueStatsBlocks uint64 = 64
+
+	// CliqueStatsConfirms is the number of confirmation blocks before a section of
+	// the clique signer statistics is indexed.
+	CliqueStatsConfirms = 16
+
 	// CHTFrequency is the block frequency for creating CHTs
 	CHTFrequency = 32768
 
@@ -0,0 +1,1087 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// This file implements the compact binary encoding of JSON-RPC messages in CBOR
+// (RFC 8949). Results are encoded straight from their Go values, following the
+// rules of encoding/json, except for the types known to carry binary data
+// (hexutil.Bytes, hashes and addresses), which are encoded as raw byte strings
+// instead of hex strings. This halves the size of blocks, receipts and traces on
+// the wire. Byte strings are turned back into the same hex strings when decoded,
+// so the rest of the RPC stack keeps operating on JSON.
+//
+// Values which only exist in JSON form, like the parameters of requests and the
+// output of json.Marshaler implementations, are transcoded as they are, keeping
+// all strings text strings.
+
+const (
+	cborContentType   = "application/cbor"
+	cborWSSubprotocol = "cbor"
+
+	// cborMaxDepth is the maximum nesting depth of decoded values, matching the
+	// one of encoding/json.
+	cborMaxDepth = 10000
+)
+
+// CBOR major types.
+const (
+	cborUint   = 0 << 5
+	cborNegint = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+)
+
+// CBOR simple values and special encodings.
+const (
+	cborFalse      = cborSimple | 20
+	cborTrue       = cborSimple | 21
+	cborNull       = cborSimple | 22
+	cborUndefined  = cborSimple | 23
+	cborFloat16    = cborSimple | 25
+	cborFloat32    = cborSimple | 26
+	cborFloat64    = cborSimple | 27
+	cborBreak      = cborSimple | 31
+	cborIndefinite = 31
+
+	cborTagPosBignum = 2
+	cborTagNegBignum = 3
+)
+
+var errCBORKey = errors.New("cbor: map key is not a text string")
+
+// encodeCBOR encodes a value into CBOR.
+func encodeCBOR(v interface{}) ([]byte, error) {
+	e := new(cborValueEncoder)
+	if err := e.value(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.out, nil
+}
+
+// decodeCBOR reads a CBOR data item from the stream and decodes it as JSON.
+func decodeCBOR(r *bufio.Reader, v interface{}) error {
+	data, err := cborToJSON(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// cborValueEncoder encodes Go values into CBOR.
+type cborValueEncoder struct {
+	out []byte
+}
+
+// value encodes a Go value the way encoding/json would, except for the types
+// carrying binary data, which are encoded as byte strings.
+func (e *cborValueEncoder) value(v reflect.Value, depth int) error {
+	if depth > cborMaxDepth {
+		return errors.New("cbor: exceeded max depth")
+	}
+	if !v.IsValid() {
+		e.out = append(e.out, cborNull)
+		return nil
+	}
+	t := v.Type()
+	if (t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface) && v.IsNil() {
+		e.out = append(e.out, cborNull)
+		return nil
+	}
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case *jsonrpcMessage:
+			return e.message(x, depth)
+		case hexutil.Bytes:
+			e.bytes(x)
+			return nil
+		case common.Hash:
+			e.bytes(x[:])
+			return nil
+		case common.Address:
+			e.bytes(x[:])
+			return nil
+		}
+	}
+	if m, ok := marshaler(v, jsonMarshalerType); ok {
+		data, err := m.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return &json.MarshalerError{Type: t, Err: err}
+		}
+		return e.json(data, depth)
+	}
+	if m, ok := marshaler(v, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return &json.MarshalerError{Type: t, Err: err}
+		}
+		e.text(string(text))
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.out = append(e.out, cborTrue)
+		} else {
+			e.out = append(e.out, cborFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n < 0 {
+			e.out = appendCBORHead(e.out, cborNegint, uint64(-(n + 1)))
+		} else {
+			e.out = appendCBORHead(e.out, cborUint, uint64(n))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.out = appendCBORHead(e.out, cborUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, t.Bits())}
+		}
+		if t.Kind() == reflect.Float32 {
+			bits := math.Float32bits(float32(f))
+			e.out = append(e.out, cborFloat32, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+		} else {
+			e.out = appendUint64(append(e.out, cborFloat64), math.Float64bits(f))
+		}
+	case reflect.String:
+		e.text(v.String())
+	case reflect.Interface, reflect.Ptr:
+		return e.value(v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.out = append(e.out, cborNull)
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(t.Elem()).Implements(jsonMarshalerType) && !reflect.PtrTo(t.Elem()).Implements(textMarshalerType) {
+			// Plain byte slices are base64 strings in JSON
+			e.text(base64.StdEncoding.EncodeToString(v.Bytes()))
+			return nil
+		}
+		return e.array(v, depth)
+	case reflect.Array:
+		return e.array(v, depth)
+	case reflect.Map:
+		return e.dict(v, depth)
+	case reflect.Struct:
+		return e.object(v, depth)
+	default:
+		return &json.UnsupportedTypeError{Type: t}
+	}
+	return nil
+}
+
+// marshaler returns the value as an interface if it implements the given
+// marshaler type, either directly or through its address.
+func marshaler(v reflect.Value, typ reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(typ) && v.CanInterface() {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(typ) && v.Addr().CanInterface() {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+// message encodes a JSON-RPC message, using the Go values of the params and
+// result if it carries them.
+func (e *cborValueEncoder) message(msg *jsonrpcMessage, depth int) error {
+	e.out = append(e.out, cborMap|cborIndefinite)
+	if msg.Version != "" {
+		e.text("jsonrpc")
+		e.text(msg.Version)
+	}
+	if len(msg.ID) > 0 {
+		e.text("id")
+		if err := e.json(msg.ID, depth+1); err != nil {
+			return err
+		}
+	}
+	if msg.Method != "" {
+		e.text("method")
+		e.text(msg.Method)
+	}
+	if msg.params != nil || len(msg.Params) > 0 {
+		e.text("params")
+		if err := e.valueOrJSON(msg.params, msg.Params, depth+1); err != nil {
+			return err
+		}
+	}
+	if msg.Error != nil {
+		e.text("error")
+		if err := e.value(reflect.ValueOf(msg.Error), depth+1); err != nil {
+			return err
+		}
+	}
+	if msg.result != nil || len(msg.Result) > 0 {
+		mark := len(e.out)
+		e.text("result")
+		if err := e.valueOrJSON(msg.result, msg.Result, depth+1); err != nil {
+			// Like failing to encode it as JSON, turn the result into an error
+			e.out = e.out[:mark]
+			e.text("error")
+			if err := e.value(reflect.ValueOf(errorMessage(err).Error), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	e.out = append(e.out, cborBreak)
+	return nil
+}
+
+// valueOrJSON encodes the Go value if set, and the JSON value otherwise.
+func (e *cborValueEncoder) valueOrJSON(v interface{}, data json.RawMessage, depth int) error {
+	if v != nil {
+		return e.value(reflect.ValueOf(v), depth)
+	}
+	return e.json(data, depth)
+}
+
+// json transcodes a JSON value.
+func (e *cborValueEncoder) json(data []byte, depth int) error {
+	out, err := appendJSONAsCBOR(e.out, data, depth)
+	if err != nil {
+		return err
+	}
+	e.out = out
+	return nil
+}
+
+func (e *cborValueEncoder) bytes(b []byte) {
+	e.out = appendCBORHead(e.out, cborBytes, uint64(len(b)))
+	e.out = append(e.out, b...)
+}
+
+func (e *cborValueEncoder) text(s string) {
+	e.out = appendCBORHead(e.out, cborText, uint64(len(s)))
+	e.out = append(e.out, s...)
+}
+
+func (e *cborValueEncoder) array(v reflect.Value, depth int) error {
+	e.out = appendCBORHead(e.out, cborArray, uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := e.value(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dict encodes a map, resolving its keys into strings like encoding/json.
+func (e *cborValueEncoder) dict(v reflect.Value, depth int) error {
+	if v.IsNil() {
+		e.out = append(e.out, cborNull)
+		return nil
+	}
+	type entry struct {
+		key string
+		val reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		k := it.Key()
+		var key string
+		switch {
+		case k.Kind() == reflect.String:
+			key = k.String()
+		case k.Type().Implements(textMarshalerType):
+			if k.Kind() == reflect.Ptr && k.IsNil() {
+				continue
+			}
+			text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return &json.MarshalerError{Type: k.Type(), Err: err}
+			}
+			key = string(text)
+		case k.Kind() >= reflect.Int && k.Kind() <= reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case k.Kind() >= reflect.Uint && k.Kind() <= reflect.Uintptr:
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return &json.UnsupportedTypeError{Type: v.Type()}
+		}
+		entries = append(entries, entry{key, it.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	e.out = appendCBORHead(e.out, cborMap, uint64(len(entries)))
+	for _, entry := range entries {
+		e.text(entry.key)
+		if err := e.value(entry.val, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// object encodes a struct as a map of its JSON fields.
+func (e *cborValueEncoder) object(v reflect.Value, depth int) error {
+	e.out = append(e.out, cborMap|cborIndefinite)
+	for _, f := range cborStructFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		e.text(f.name)
+		if f.quoted {
+			if err := e.quoted(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.value(fv, depth+1); err != nil {
+			return err
+		}
+	}
+	e.out = append(e.out, cborBreak)
+	return nil
+}
+
+// quoted encodes a field with the ",string" option, holding its JSON encoding
+// in a string.
+func (e *cborValueEncoder) quoted(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			e.out = append(e.out, cborNull)
+			return nil
+		}
+		v = v.Elem()
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	e.text(string(data))
+	return nil
+}
+
+// fieldByIndex returns the nested field, or false if it's behind a nil pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// cborField is a struct field encoded as a map entry.
+type cborField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+var cborFieldCache sync.Map // map[reflect.Type][]cborField
+
+// cborStructFields returns the fields encoding/json encodes for a struct type,
+// in order, including the ones promoted from embedded structs.
+func cborStructFields(t reflect.Type) []cborField {
+	if fields, ok := cborFieldCache.Load(t); ok {
+		return fields.([]cborField)
+	}
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	var (
+		fields  []cborField
+		next    = []embedded{{typ: t}}
+		visited = make(map[reflect.Type]bool)
+	)
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for _, embed := range current {
+			if visited[embed.typ] {
+				continue
+			}
+			visited[embed.typ] = true
+
+			for i := 0; i < embed.typ.NumField(); i++ {
+				sf := embed.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts := tag, ""
+				if idx := strings.Index(tag, ","); idx >= 0 {
+					name, opts = tag[:idx], tag[idx+1:]
+				}
+				index := append(append([]int{}, embed.index...), i)
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					// Untagged embedded structs have their fields promoted
+					next = append(next, embedded{ft, index})
+					continue
+				}
+				f := cborField{
+					name:      name,
+					index:     index,
+					tagged:    name != "",
+					omitEmpty: hasTagOption(opts, "omitempty"),
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				if hasTagOption(opts, "string") {
+					switch ft.Kind() {
+					case reflect.Bool, reflect.String,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64:
+						f.quoted = true
+					}
+				}
+				fields = append(fields, f)
+			}
+		}
+	}
+	fields = dominantFields(fields)
+	cborFieldCache.Store(t, fields)
+	return fields
+}
+
+// dominantFields resolves the fields sharing a name: the least nested one wins,
+// preferring tagged ones, and ambiguous names are dropped altogether.
+func dominantFields(fields []cborField) []cborField {
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if j == i+1 || len(fields[i+1].index) > len(fields[i].index) || fields[i].tagged && !fields[i+1].tagged {
+			out = append(out, fields[i])
+		}
+		i = j
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].index, out[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return out
+}
+
+func hasTagOption(opts, option string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonToCBOR transcodes a JSON value into CBOR.
+func jsonToCBOR(data []byte) ([]byte, error) {
+	return appendJSONAsCBOR(make([]byte, 0, len(data)), data, 0)
+}
+
+// appendJSONAsCBOR transcodes a JSON value nested at the given depth, appending
+// it to out.
+func appendJSONAsCBOR(out []byte, data []byte, depth int) ([]byte, error) {
+	e := &cborEncoder{in: data, out: out}
+	if err := e.value(depth); err != nil {
+		return nil, err
+	}
+	if e.skipSpace(); e.pos != len(e.in) {
+		return nil, e.syntaxError()
+	}
+	return e.out, nil
+}
+
+// cborEncoder transcodes a JSON value into CBOR.
+type cborEncoder struct {
+	in  []byte
+	pos int
+	out []byte
+}
+
+func (e *cborEncoder) syntaxError() error {
+	if e.pos >= len(e.in) {
+		return io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("cbor: invalid character %q in JSON at offset %d", e.in[e.pos], e.pos)
+}
+
+func (e *cborEncoder) skipSpace() {
+	for e.pos < len(e.in) {
+		switch e.in[e.pos] {
+		case ' ', '\t', '\n', '\r':
+			e.pos++
+		default:
+			return
+		}
+	}
+}
+
+// next skips whitespace and returns the next character without consuming it.
+func (e *cborEncoder) next() byte {
+	if e.skipSpace(); e.pos < len(e.in) {
+		return e.in[e.pos]
+	}
+	return 0
+}
+
+// value transcodes the next JSON value.
+func (e *cborEncoder) value(depth int) error {
+	if depth > cborMaxDepth {
+		return errors.New("cbor: exceeded max depth")
+	}
+	switch c := e.next(); {
+	case c == '{':
+		e.pos++
+		e.out = append(e.out, cborMap|cborIndefinite)
+		for i := 0; ; i++ {
+			if e.next() == '}' && i == 0 {
+				break
+			}
+			if e.next() != '"' {
+				return e.syntaxError()
+			}
+			key, err := e.str()
+			if err != nil {
+				return err
+			}
+			e.out = appendCBORHead(e.out, cborText, uint64(len(key)))
+			e.out = append(e.out, key...)
+			if e.next() != ':' {
+				return e.syntaxError()
+			}
+			e.pos++
+			if err := e.value(depth + 1); err != nil {
+				return err
+			}
+			if e.next() != ',' {
+				break
+			}
+			e.pos++
+		}
+		if e.next() != '}' {
+			return e.syntaxError()
+		}
+		e.pos++
+		e.out = append(e.out, cborBreak)
+
+	case c == '[':
+		e.pos++
+		e.out = append(e.out, cborArray|cborIndefinite)
+		for i := 0; ; i++ {
+			if e.next() == ']' && i == 0 {
+				break
+			}
+			if err := e.value(depth + 1); err != nil {
+				return err
+			}
+			if e.next() != ',' {
+				break
+			}
+			e.pos++
+		}
+		if e.next() != ']' {
+			return e.syntaxError()
+		}
+		e.pos++
+		e.out = append(e.out, cborBreak)
+
+	case c == '"':
+		s, err := e.str()
+		if err != nil {
+			return err
+		}
+		e.out = appendCBORHead(e.out, cborText, uint64(len(s)))
+		e.out = append(e.out, s...)
+
+	case c == '-' || '0' <= c && c <= '9':
+		start := e.pos
+		for e.pos < len(e.in) && strings.IndexByte("0123456789+-.eE", e.in[e.pos]) >= 0 {
+			e.pos++
+		}
+		num := string(e.in[start:e.pos])
+		if !json.Valid(e.in[start:e.pos]) {
+			e.pos = start
+			return e.syntaxError()
+		}
+		e.out = appendCBORNumber(e.out, num)
+
+	case e.literal("true"):
+		e.out = append(e.out, cborTrue)
+	case e.literal("false"):
+		e.out = append(e.out, cborFalse)
+	case e.literal("null"):
+		e.out = append(e.out, cborNull)
+
+	default:
+		return e.syntaxError()
+	}
+	return nil
+}
+
+// literal consumes the given literal if it's next in the input.
+func (e *cborEncoder) literal(lit string) bool {
+	if bytes.HasPrefix(e.in[e.pos:], []byte(lit)) {
+		e.pos += len(lit)
+		return true
+	}
+	return false
+}
+
+// str consumes a JSON string literal and returns its unquoted content.
+func (e *cborEncoder) str() ([]byte, error) {
+	start := e.pos
+	escaped := false
+	for e.pos++; e.pos < len(e.in); e.pos++ {
+		switch c := e.in[e.pos]; {
+		case c == '\\':
+			escaped = true
+			e.pos++
+		case c == '"':
+			e.pos++
+			lit := e.in[start:e.pos]
+			if !escaped {
+				return lit[1 : len(lit)-1], nil
+			}
+			var s string
+			if err := json.Unmarshal(lit, &s); err != nil {
+				return nil, err
+			}
+			return []byte(s), nil
+		case c < 0x20:
+			return nil, e.syntaxError()
+		}
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// appendCBORHead appends the head of a data item with the given major type and
+// argument.
+func appendCBORHead(out []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(out, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(out, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(out, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(out, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return appendUint64(append(out, major|27), n)
+	}
+}
+
+// appendUint64 appends an integer in big endian encoding.
+func appendUint64(out []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(out, buf[:]...)
+}
+
+// appendCBORNumber appends a JSON number, as an integer if it's integral, or as
+// a double precision float otherwise.
+func appendCBORNumber(out []byte, num string) []byte {
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		return appendCBORHead(out, cborUint, n)
+	}
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		return appendCBORHead(out, cborNegint, uint64(-(n + 1)))
+	}
+	if n, ok := new(big.Int).SetString(num, 10); ok {
+		tag := uint64(cborTagPosBignum)
+		if n.Sign() < 0 {
+			tag = cborTagNegBignum
+			n.Neg(n).Sub(n, big.NewInt(1))
+		}
+		b := n.Bytes()
+		out = appendCBORHead(out, cborTag, tag)
+		out = appendCBORHead(out, cborBytes, uint64(len(b)))
+		return append(out, b...)
+	}
+	f, _ := strconv.ParseFloat(num, 64)
+	return appendUint64(append(out, cborFloat64), math.Float64bits(f))
+}
+
+// cborToJSON reads a single CBOR data item from the stream and transcodes it
+// into JSON. It returns io.EOF if the stream ends before the item starts.
+func cborToJSON(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Peek(1); err != nil {
+		return nil, err
+	}
+	d := &cborDecoder{r: r}
+	if err := d.value(0); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// cborDecoder transcodes a CBOR data item into JSON.
+type cborDecoder struct {
+	r   *bufio.Reader
+	out []byte
+}
+
+// head reads the head of a data item, returning its initial byte and argument.
+// The argument of indefinite length items is meaningless.
+func (d *cborDecoder) head() (byte, uint64, error) {
+	ib, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	var size int
+	switch info := ib & 0x1f; {
+	case info < 24:
+		return ib, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == cborIndefinite:
+		return ib, 0, nil
+	default:
+		return 0, 0, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, 0, err
+	}
+	return ib, binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// value transcodes the next data item.
+func (d *cborDecoder) value(depth int) error {
+	if depth > cborMaxDepth {
+		return errors.New("cbor: exceeded max depth")
+	}
+	ib, n, err := d.head()
+	if err != nil {
+		return err
+	}
+	return d.item(ib, n, depth)
+}
+
+// item transcodes a data item whose head has already been read.
+func (d *cborDecoder) item(ib byte, n uint64, depth int) error {
+	indefinite := ib&0x1f == cborIndefinite
+	switch major := ib & 0xe0; major {
+	case cborUint:
+		d.out = strconv.AppendUint(d.out, n, 10)
+
+	case cborNegint:
+		if n <= math.MaxInt64 {
+			d.out = strconv.AppendInt(d.out, -int64(n)-1, 10)
+		} else {
+			v := new(big.Int).SetUint64(n)
+			d.out = v.Neg(v).Sub(v, big.NewInt(1)).Append(d.out, 10)
+		}
+
+	case cborBytes:
+		b, err := d.str(major, n, indefinite)
+		if err != nil {
+			return err
+		}
+		d.out = append(d.out, `"0x`...)
+		d.out = append(d.out, hex.EncodeToString(b)...)
+		d.out = append(d.out, '"')
+
+	case cborText:
+		s, err := d.str(major, n, indefinite)
+		if err != nil {
+			return err
+		}
+		d.out = appendJSONString(d.out, s)
+
+	case cborArray:
+		d.out = append(d.out, '[')
+		for i := uint64(0); indefinite || i < n; i++ {
+			ib, n, err := d.head()
+			if err != nil {
+				return err
+			}
+			if indefinite && ib == cborBreak {
+				break
+			}
+			if i > 0 {
+				d.out = append(d.out, ',')
+			}
+			if err := d.item(ib, n, depth+1); err != nil {
+				return err
+			}
+		}
+		d.out = append(d.out, ']')
+
+	case cborMap:
+		d.out = append(d.out, '{')
+		for i := uint64(0); indefinite || i < n; i++ {
+			ib, n, err := d.head()
+			if err != nil {
+				return err
+			}
+			if indefinite && ib == cborBreak {
+				break
+			}
+			if ib&0xe0 != cborText {
+				return errCBORKey
+			}
+			if i > 0 {
+				d.out = append(d.out, ',')
+			}
+			key, err := d.str(cborText, n, ib&0x1f == cborIndefinite)
+			if err != nil {
+				return err
+			}
+			d.out = append(appendJSONString(d.out, key), ':')
+			if err := d.value(depth + 1); err != nil {
+				return err
+			}
+		}
+		d.out = append(d.out, '}')
+
+	case cborTag:
+		if n != cborTagPosBignum && n != cborTagNegBignum {
+			// Unknown tags carry no meaning for JSON, transcode the tagged item
+			return d.value(depth + 1)
+		}
+		ib, size, err := d.head()
+		if err != nil {
+			return err
+		}
+		if ib&0xe0 != cborBytes {
+			return errors.New("cbor: invalid bignum")
+		}
+		b, err := d.str(cborBytes, size, ib&0x1f == cborIndefinite)
+		if err != nil {
+			return err
+		}
+		v := new(big.Int).SetBytes(b)
+		if n == cborTagNegBignum {
+			v.Neg(v).Sub(v, big.NewInt(1))
+		}
+		d.out = v.Append(d.out, 10)
+
+	default: // cborSimple
+		switch ib {
+		case cborFalse:
+			d.out = append(d.out, "false"...)
+		case cborTrue:
+			d.out = append(d.out, "true"...)
+		case cborNull, cborUndefined:
+			d.out = append(d.out, "null"...)
+		case cborFloat16, cborFloat32, cborFloat64:
+			var f float64
+			switch ib {
+			case cborFloat16:
+				f = float16ToFloat64(uint16(n))
+			case cborFloat32:
+				f = float64(math.Float32frombits(uint32(n)))
+			default:
+				f = math.Float64frombits(n)
+			}
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return errors.New("cbor: unsupported float value")
+			}
+			d.out = strconv.AppendFloat(d.out, f, 'g', -1, 64)
+		default:
+			return fmt.Errorf("cbor: unsupported simple value %#x", ib)
+		}
+	}
+	return nil
+}
+
+// str reads the content of a byte or text string whose head has been read.
+func (d *cborDecoder) str(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: string too long")
+		}
+		// Copy rather than preallocate, the length is not trusted
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	// Indefinite length strings are a sequence of definite length chunks
+	var out []byte
+	for {
+		ib, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if ib == cborBreak {
+			return out, nil
+		}
+		if ib&0xe0 != major || ib&0x1f == cborIndefinite {
+			return nil, errors.New("cbor: invalid string chunk")
+		}
+		chunk, err := d.str(major, n, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+	}
+}
+
+// float16ToFloat64 converts a half precision float.
+func float16ToFloat64(h uint16) float64 {
+	var (
+		exp  = int(h>>10) & 0x1f
+		mant = float64(h & 0x3ff)
+		val  float64
+	)
+	switch exp {
+	case 0:
+		val = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			val = math.Inf(1)
+		} else {
+			val = math.NaN()
+		}
+	default:
+		val = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		val = -val
+	}
+	return val
+}
+
+// appendJSONString appends a string as a JSON string literal.
+func appendJSONString(out []byte, s []byte) []byte {
+	const hexDigits = "0123456789abcdef"
+
+	out = append(out, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				out = append(out, '\\', c)
+			case c == '\n':
+				out = append(out, '\\', 'n')
+			case c == '\r':
+				out = append(out, '\\', 'r')
+			case c == '\t':
+				out = append(out, '\\', 't')
+			case c < 0x20:
+				out = append(out, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				out = append(out, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			out = append(out, `�`...)
+		} else {
+			out = append(out, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(out, '"')
+}
@@ -0,0 +1,479 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+)
+
+func TestCBORRoundTrip(t *testing.T) {
+	tests := []string{
+		`null`,
+		`true`,
+		`false`,
+		`0`,
+		`23`,
+		`24`,
+		`65536`,
+		`18446744073709551615`,
+		`18446744073709551616`,
+		`-1`,
+		`-9223372036854775808`,
+		`-18446744073709551617`,
+		`1.5`,
+		`-0.25`,
+		`""`,
+		`"0x"`,
+		`"0x00"`,
+		`"0xdeadbeef"`,
+		`"0x1"`,
+		`"0xDEADBEEF"`,
+		`"0xzz"`,
+		`"hello"`,
+		`"quote\"backslash\\newline\ncontrol\u0001"`,
+		`"ünicode ✓"`,
+		`[]`,
+		`{}`,
+		`[1,"0x02",[3,{}],null]`,
+		`{"0xab":"0xab","nested":{"list":[{"a":1},{"b":"0x"}]},"":false}`,
+		`{"jsonrpc":"2.0","id":1,"result":{"hash":"0x61f5c2e6a9d7f1e0b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071829","number":"0x10"}}`,
+	}
+	for _, test := range tests {
+		enc, err := jsonToCBOR([]byte(test))
+		if err != nil {
+			t.Errorf("%s: encoding failed: %v", test, err)
+			continue
+		}
+		dec, err := cborToJSON(bufio.NewReader(bytes.NewReader(enc)))
+		if err != nil {
+			t.Errorf("%s: decoding failed: %v", test, err)
+			continue
+		}
+		if string(dec) != test {
+			t.Errorf("round trip mismatch: have %s, want %s", dec, test)
+		}
+	}
+}
+
+func TestCBOREncoding(t *testing.T) {
+	tests := []struct {
+		json string
+		cbor string
+	}{
+		{`0`, "00"},
+		{`500`, "1901f4"},
+		{`-500`, "3901f3"},
+		{`1.5`, "fb3ff8000000000000"},
+		{`"0xdead"`, "66307864656164"},
+		{`"0xDEAD"`, "66307844454144"},
+		{`"abc"`, "63616263"},
+		{`[1,2]`, "9f0102ff"},
+		{`{"0x01":"0x01"}`, "bf64307830316430783031ff"},
+		{` [ 1 , { "a" : null } ] `, "9f01bf6161f6ffff"},
+	}
+	for _, test := range tests {
+		enc, err := jsonToCBOR([]byte(test.json))
+		if err != nil {
+			t.Errorf("%s: encoding failed: %v", test.json, err)
+			continue
+		}
+		if have := hex.EncodeToString(enc); have != test.cbor {
+			t.Errorf("%s: wrong encoding: have %s, want %s", test.json, have, test.cbor)
+		}
+	}
+}
+
+type cborTestEmbedded struct {
+	Inner  string
+	Shadow int
+}
+
+type cborTestStruct struct {
+	cborTestEmbedded
+	Shadow    string               `json:"shadow"`
+	Renamed   uint64               `json:"renamed"`
+	Omitted   []int                `json:"omitted,omitempty"`
+	Quoted    int64                `json:"quoted,string"`
+	Skipped   bool                 `json:"-"`
+	Data      hexutil.Bytes        `json:"data"`
+	Quantity  hexutil.Uint64       `json:"quantity"`
+	Big       *big.Int             `json:"big"`
+	Raw       json.RawMessage      `json:"raw"`
+	Blob      []byte               `json:"blob"`
+	Nil       []string             `json:"nil"`
+	Float     float64              `json:"float"`
+	Keys      map[int]string       `json:"keys"`
+	TextKeys  map[common.Hash]bool `json:"textKeys"`
+	Interface interface{}          `json:"interface"`
+	private   int
+}
+
+// Tests that Go values are encoded into the same JSON values as with encoding/json,
+// once the CBOR is transcoded back to JSON.
+func TestCBORValueEncoding(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		true,
+		-500,
+		uint8(200),
+		1.5,
+		"0xdeadbeef",
+		[]interface{}{1, "a", nil},
+		[2]uint16{1, 2},
+		map[string]interface{}{"b": 1, "a": []string{}},
+		hexutil.Bytes{0xde, 0xad},
+		hexutil.Bytes(nil),
+		common.HexToHash("0x61f5c2e6a9d7f1e0b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071829"),
+		&common.Address{0xff},
+		big.NewInt(-1),
+		cborTestStruct{
+			cborTestEmbedded: cborTestEmbedded{"inner", 1},
+			Shadow:           "outer",
+			Renamed:          math.MaxUint64,
+			Quoted:           -7,
+			Skipped:          true,
+			Data:             hexutil.Bytes{1, 2, 3},
+			Quantity:         0x10,
+			Big:              new(big.Int).Lsh(big.NewInt(1), 100),
+			Raw:              json.RawMessage(`{"x":["0x01"]}`),
+			Blob:             []byte{1, 2, 3},
+			Float:            -0.25,
+			Keys:             map[int]string{10: "ten", -1: "minus one"},
+			TextKeys:         map[common.Hash]bool{{1}: true},
+			Interface:        &cborTestEmbedded{"pointer", 2},
+			private:          1,
+		},
+		[]*cborTestStruct{nil, {}},
+	}
+	for _, test := range tests {
+		want, err := json.Marshal(test)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := encodeCBOR(test)
+		if err != nil {
+			t.Errorf("%s: encoding failed: %v", want, err)
+			continue
+		}
+		have, err := cborToJSON(bufio.NewReader(bytes.NewReader(enc)))
+		if err != nil {
+			t.Errorf("%s: decoding failed: %v", want, err)
+			continue
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("wrong value: have %s, want %s", have, want)
+		}
+	}
+}
+
+// Tests that only the types known to carry binary data are encoded as byte strings.
+func TestCBORValueByteStrings(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		cbor  string
+	}{
+		{hexutil.Bytes{0xde, 0xad}, "42dead"},
+		{"0xdead", "66307864656164"},
+		{hexutil.Uint64(0xdead), "66307864656164"},
+		{[]byte{0xde, 0xad}, "643371303d"},
+		{common.Hash{0xff}, "5820ff" + strings.Repeat("00", 31)},
+		{common.Address{0xff}, "54ff" + strings.Repeat("00", 19)},
+		{json.RawMessage(`"0xdead"`), "66307864656164"},
+	}
+	for _, test := range tests {
+		enc, err := encodeCBOR(test.value)
+		if err != nil {
+			t.Errorf("%v: encoding failed: %v", test.value, err)
+			continue
+		}
+		if have := hex.EncodeToString(enc); have != test.cbor {
+			t.Errorf("%v: wrong encoding: have %s, want %s", test.value, have, test.cbor)
+		}
+	}
+}
+
+// Tests that results which can't be encoded are turned into error responses, like
+// with JSON.
+func TestCBORResultError(t *testing.T) {
+	req := &jsonrpcMessage{Version: vsn, ID: json.RawMessage("1"), Method: "test_echo"}
+	enc, err := encodeCBOR(req.nativeResponse(make(chan int)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := cborToJSON(bufio.NewReader(bytes.NewReader(enc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"json: unsupported type: chan int"}}`
+	if string(have) != want {
+		t.Errorf("wrong response: have %s, want %s", have, want)
+	}
+}
+
+// Tests decoding of CBOR items which are never produced by the encoder, using
+// the examples of RFC 8949 appendix A.
+func TestCBORDecoding(t *testing.T) {
+	tests := []struct {
+		cbor string
+		json string
+	}{
+		{"1bffffffffffffffff", `18446744073709551615`},
+		{"c249010000000000000000", `18446744073709551616`},
+		{"3bffffffffffffffff", `-18446744073709551616`},
+		{"f93c00", `1`},
+		{"f9c400", `-4`},
+		{"fa47c35000", `100000`},
+		{"f7", `null`},
+		{"c074323031332d30332d32315432303a30343a30305a", `"2013-03-21T20:04:00Z"`},
+		{"4401020304", `"0x01020304"`},
+		{"5f42010243030405ff", `"0x0102030405"`},
+		{"7f657374726561646d696e67ff", `"streaming"`},
+		{"83010203", `[1,2,3]`},
+		{"8301820203820405", `[1,[2,3],[4,5]]`},
+		{"a26161016162820203", `{"a":1,"b":[2,3]}`},
+		{"bf61610161629f0203ffff", `{"a":1,"b":[2,3]}`},
+		{"9fff", `[]`},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.cbor)
+		dec, err := cborToJSON(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Errorf("%s: decoding failed: %v", test.cbor, err)
+			continue
+		}
+		if string(dec) != test.json {
+			t.Errorf("%s: wrong decoding: have %s, want %s", test.cbor, dec, test.json)
+		}
+	}
+}
+
+func TestCBORDecodingErrors(t *testing.T) {
+	tests := []string{
+		"18",           // truncated argument
+		"4301",         // truncated string
+		"9f01",         // unterminated array
+		"a10102",       // integer map key
+		"f97e00",       // NaN
+		"fc",           // reserved additional information
+		"5f41016100ff", // text chunk in byte string
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test)
+		_, err := cborToJSON(bufio.NewReader(bytes.NewReader(data)))
+		if err == nil {
+			t.Errorf("%s: expected error", test)
+		}
+	}
+}
+
+// cborTransport records the content type of the responses.
+type cborTransport struct {
+	contentTypes []string
+}
+
+func (t *cborTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		t.contentTypes = append(t.contentTypes, resp.Header.Get("content-type"))
+	}
+	return resp, err
+}
+
+// Tests that HTTP clients use JSON by default and the binary encoding when asking
+// for it.
+func TestHTTPCBOR(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	transport := new(cborTransport)
+	client, err := DialHTTPWithClient(ts.URL, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var (
+		want = echoResult{"0xdeadbeef", 1, &echoArgs{"0x"}}
+		res  echoResult
+	)
+	if err := client.Call(&res, "test_echo", want.String, want.Int, want.Args); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	// Clients asking for the binary encoding get it
+	client.SetHeader("accept", cborContentType)
+	res = echoResult{}
+	if err := client.Call(&res, "test_echo", want.String, want.Int, want.Args); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("wrong result: have %+v, want %+v", res, want)
+	}
+	batch := []BatchElem{
+		{Method: "test_echo", Args: []interface{}{"0x01", 2, &echoArgs{"x"}}, Result: new(echoResult)},
+		{Method: "no_such_method", Result: new(echoResult)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("batch call failed: %v", err)
+	}
+	if res := batch[0].Result.(*echoResult); batch[0].Error != nil || res.String != "0x01" {
+		t.Errorf("wrong batch result: %+v, error %v", res, batch[0].Error)
+	}
+	if batch[1].Error == nil {
+		t.Errorf("missing batch error")
+	}
+	wantTypes := []string{contentType, cborContentType, cborContentType}
+	if !reflect.DeepEqual(transport.contentTypes, wantTypes) {
+		t.Errorf("wrong response content types: have %v, want %v", transport.contentTypes, wantTypes)
+	}
+}
+
+// Tests that the server accepts requests in the binary encoding.
+func TestHTTPCBORRequest(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	body, err := jsonToCBOR([]byte(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["0xab",1,{"S":"0x"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(ts.URL, cborContentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("content-type"); ct != cborContentType {
+		t.Fatalf("wrong response content type %q", ct)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Strings in the result stay text strings, even if they look like hex
+	if !bytes.Contains(raw, []byte("\x640xab")) {
+		t.Errorf("result string not encoded as text: %x", raw)
+	}
+	have, err := cborToJSON(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"jsonrpc":"2.0","id":1,"result":{"String":"0xab","Int":1,"Args":{"S":"0x"}}}`
+	if string(have) != want {
+		t.Errorf("wrong response: have %s, want %s", have, want)
+	}
+}
+
+func TestWantsCBOR(t *testing.T) {
+	tests := []struct {
+		accept, contentType string
+		want                bool
+	}{
+		{"", contentType, false},
+		{"", cborContentType, true},
+		{"*/*", cborContentType, true},
+		{contentType, contentType, false},
+		{"application/cbor, application/json;q=0.9", contentType, true},
+		{contentType, cborContentType, false},
+		{cborContentType, contentType, true},
+		{"application/json, application/cbor;q=0.5", contentType, false},
+		{"application/cbor;q=0", cborContentType, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("accept", test.accept)
+		r.Header.Set("content-type", test.contentType)
+		if have := wantsCBOR(r); have != test.want {
+			t.Errorf("accept %q, content type %q: have %v, want %v", test.accept, test.contentType, have, test.want)
+		}
+	}
+}
+
+// Tests that websocket clients negotiate the binary encoding with the server
+// when asking for it, and keep using JSON otherwise.
+func TestWebsocketCBOR(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	ts := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer ts.Close()
+	wsURL := "ws:" + strings.TrimPrefix(ts.URL, "http:")
+
+	// Clients dialed with the defaults don't ask for the binary encoding
+	client, err := DialWebsocket(context.Background(), wsURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := client.writeConn.(*websocketCodec).conn.Subprotocol(); have != "" {
+		t.Errorf("wrong default subprotocol: have %q", have)
+	}
+	client.Close()
+
+	dialers := map[string]websocket.Dialer{
+		cborWSSubprotocol: {Subprotocols: []string{cborWSSubprotocol}},
+		"":                {},
+	}
+	for protocol, dialer := range dialers {
+		client, err := DialWebsocketWithDialer(context.Background(), wsURL, "", dialer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := client.writeConn.(*websocketCodec).conn.Subprotocol(); have != protocol {
+			t.Errorf("wrong subprotocol: have %q, want %q", have, protocol)
+		}
+		var (
+			want = echoResult{"0xdeadbeef", 1, &echoArgs{"0x"}}
+			res  echoResult
+		)
+		if err := client.Call(&res, "test_echo", want.String, want.Int, want.Args); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		if !reflect.DeepEqual(res, want) {
+			t.Errorf("wrong result: have %+v, want %+v", res, want)
+		}
+		// Check that notifications are delivered too
+		ch := make(chan int)
+		sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", 3, 0)
+		if err != nil {
+			t.Fatalf("subscribe failed: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if have := <-ch; have != i {
+				t.Errorf("wrong notification: have %d, want %d", have, i)
+			}
+		}
+		sub.Unsubscribe()
+		client.Close()
+	}
+}
@@ -76,6 +76,7 @@ type Client struct {
 	idgen    func() ID // for subscriptions
 	isHTTP   bool      // connection type: http, ws or ipc
 	services *serviceRegistry
+	config   *handlerConfig // settings of the handlers serving the remote end, nil on the client side
 
 	idCounter uint32
 
@@ -113,6 +114,9 @@ func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx = context.WithValue(ctx, clientContextKey{}, c)
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, conn.peerInfo())
 	handler := newHandler(ctx, conn, c.idgen, c.services)
+	if c.config != nil {
+		c.config.apply(handler, false)
+	}
 	return &clientConn{conn, handler}
 }
 
@@ -186,6 +190,28 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	}
 }
 
+// DialCBOR creates a new RPC client like DialContext, asking the server to use the
+// binary encoding over HTTP and WebSocket. Other transports use JSON.
+func DialCBOR(ctx context.Context, rawurl string) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		client, err := DialHTTP(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		client.SetHeader("accept", cborContentType)
+		return client, nil
+	case "ws", "wss":
+		return dialWebsocket(ctx, rawurl, "", cborWSSubprotocol)
+	default:
+		return DialContext(ctx, rawurl)
+	}
+}
+
 // ClientFromContext retrieves the client from the context, if any. This can be used to perform
 // 'reverse calls' in a handler method.
 func ClientFromContext(ctx context.Context) (*Client, bool) {
@@ -198,17 +224,18 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), nil)
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, config *handlerConfig) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
 		isHTTP:      isHTTP,
 		idgen:       idgen,
 		services:    services,
+		config:      config,
 		writeConn:   conn,
 		close:       make(chan struct{}),
 		closing:     make(chan struct{}),
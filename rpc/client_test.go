@@ -311,6 +311,40 @@ func TestClientSubscribe(t *testing.T) {
 	}
 }
 
+// Tests that subscriptions ended by the server deliver its error to the client.
+func TestClientSubscribeServerClose(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	count := 3
+	sub, err := client.Subscribe(context.Background(), "nftest", nc, "failingSubscription", count)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	for i := 0; i < count; i++ {
+		if val := <-nc; val != i {
+			t.Fatalf("value mismatch: got %d, want %d", val, i)
+		}
+	}
+	select {
+	case v := <-nc:
+		t.Fatal("received value after server close:", v)
+	case err := <-sub.Err():
+		if err == nil || err.Error() != "subscription failed" {
+			t.Fatalf("wrong subscription error: %v", err)
+		}
+		if re, ok := err.(Error); !ok || re.ErrorCode() != defaultErrorCode {
+			t.Fatalf("wrong error type %T", err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("subscription not closed within 1s after server close")
+	}
+	sub.Unsubscribe()
+}
+
 // In this test, the connection drops while Subscribe is waiting for a response.
 func TestClientSubscribeClose(t *testing.T) {
 	server := newTestServer()
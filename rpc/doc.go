@@ -106,5 +106,23 @@ Reverse Calls
 In any method handler, an instance of rpc.Client can be accessed through the
 ClientFromContext method. Using this client instance, server-to-client method calls can be
 performed on the RPC connection.
+
+Binary Encoding
+
+Over HTTP and WebSocket, messages can also be exchanged in CBOR instead of JSON, with
+binary data sent as raw bytes rather than hex strings. HTTP clients ask for it using the
+"application/cbor" media type in the Accept header, and can send CBOR request bodies with
+the same content type. WebSocket clients request the "cbor" subprotocol and then use binary
+messages.
+
+The server encodes results straight from their Go values. Only the values of types known
+to hold binary data (hexutil.Bytes, common.Hash and common.Address) are sent as byte
+strings, all other strings stay text. Clients transcode the responses back into JSON,
+which costs them some processing in exchange for smaller messages, so it pays off over
+slow links, when transferring large amounts of binary data. rpc.Client uses JSON unless
+asked otherwise: dial with DialCBOR, or set the Accept header to "application/cbor" with
+SetHeader over HTTP, and list the "cbor" subprotocol in the dialer of
+DialWebsocketWithDialer over WebSocket. Servers which don't support the binary encoding
+keep answering in JSON.
 */
 package rpc
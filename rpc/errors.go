@@ -54,6 +54,7 @@ var (
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(rateLimitError)
 )
 
 const defaultErrorCode = -32000
@@ -101,3 +102,23 @@ type invalidParamsError struct{ message string }
 func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
+
+// request rejected by the rate limiter
+type rateLimitError struct {
+	message string
+	data    rateLimitErrorData
+}
+
+// rateLimitErrorData is the structured data attached to rate limit errors.
+type rateLimitErrorData struct {
+	Reason     string `json:"reason"`               // Limit exceeded, "rate" or "concurrency"
+	Method     string `json:"method"`               // Method of the rejected request
+	Cost       int    `json:"cost"`                 // Cost of the rejected request
+	RetryAfter uint64 `json:"retryAfter,omitempty"` // Milliseconds until the request would be admitted
+}
+
+func (e *rateLimitError) ErrorCode() int { return -32005 }
+
+func (e *rateLimitError) Error() string { return e.message }
+
+func (e *rateLimitError) ErrorData() interface{} { return e.data }
@@ -61,11 +61,35 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	limiter        *RateLimiter // limits the served requests, nil if unlimited
+	recorder       *Recorder    // logs the served requests, nil if not recording
+	connID         uint64       // id of the connection in the request log
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 }
 
+// handlerConfig holds the server settings applied to the handler of every
+// served connection.
+type handlerConfig struct {
+	limiter  *RateLimiter
+	recorder *Recorder
+}
+
+// apply configures a newly created handler. Handlers serving a single request
+// are recorded under the shared HTTP connection id, the others get their own.
+func (cfg *handlerConfig) apply(h *handler, single bool) {
+	h.limiter = cfg.limiter
+	if cfg.recorder != nil {
+		h.recorder = cfg.recorder
+		if single {
+			h.connID = httpRecordConn
+		} else {
+			h.connID = cfg.recorder.nextConn()
+		}
+	}
+}
+
 type callProc struct {
 	ctx       context.Context
 	notifiers []*Notifier
@@ -254,9 +278,17 @@ func (h *handler) handleSubscriptionResult(msg *jsonrpcMessage) {
 		h.log.Debug("Dropping invalid subscription message")
 		return
 	}
-	if h.clientSubs[result.ID] != nil {
-		h.clientSubs[result.ID].deliver(result.Result)
+	sub := h.clientSubs[result.ID]
+	if sub == nil {
+		return
+	}
+	if result.Error != nil {
+		// The server ended the subscription, there's nothing to unsubscribe
+		delete(h.clientSubs, result.ID)
+		sub.close(result.Error)
+		return
 	}
+	sub.deliver(result.Result)
 }
 
 // handleResponse processes method call responses.
@@ -296,6 +328,9 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 		return nil
 	case msg.isCall():
 		resp := h.handleCall(ctx, msg)
+		if h.recorder != nil {
+			h.recorder.record(h.connID, PeerInfoFromContext(ctx.ctx), msg, resp, start)
+		}
 		var ctx []interface{}
 		ctx = append(ctx, "reqid", idForLog{msg.ID}, "duration", time.Since(start))
 		if resp.Error != nil {
@@ -329,6 +364,13 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
+	if h.limiter != nil && callb != h.unsubscribeCb {
+		release, err := h.limiter.acquire(cp.ctx, msg)
+		if err != nil {
+			return msg.errorResponse(err)
+		}
+		defer release()
+	}
 	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
@@ -367,6 +409,13 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	if callb == nil {
 		return msg.errorResponse(&subscriptionNotFoundError{namespace, name})
 	}
+	if h.limiter != nil {
+		release, err := h.limiter.acquire(cp.ctx, msg)
+		if err != nil {
+			return msg.errorResponse(err)
+		}
+		defer release()
+	}
 
 	// Parse subscription name arg too, but remove it before calling the callback.
 	argTypes := append([]reflect.Type{stringType}, callb.argTypes...)
@@ -390,6 +439,9 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	if err != nil {
 		return msg.errorResponse(err)
 	}
+	if h.conn.nativeResults() {
+		return msg.nativeResponse(result)
+	}
 	return msg.response(result)
 }
 
@@ -407,6 +459,18 @@ func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	return true, nil
 }
 
+// removeSubscription removes a subscription ended by the server, closing its error
+// channel like unsubscribing does.
+func (h *handler) removeSubscription(id ID) {
+	h.subLock.Lock()
+	defer h.subLock.Unlock()
+
+	if s := h.serverSubs[id]; s != nil {
+		close(s.err)
+		delete(h.serverSubs, id)
+	}
+}
+
 type idForLog struct{ json.RawMessage }
 
 func (id idForLog) String() string {
@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -26,6 +27,8 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -36,7 +39,7 @@ const (
 )
 
 // https://www.jsonrpc.org/historical/json-rpc-over-http.html#id13
-var acceptedContentTypes = []string{contentType, "application/json-rpc", "application/jsonrequest"}
+var acceptedContentTypes = []string{contentType, "application/json-rpc", "application/jsonrequest", cborContentType}
 
 type httpConn struct {
 	client    *http.Client
@@ -55,6 +58,10 @@ func (hc *httpConn) writeJSON(context.Context, interface{}) error {
 	panic("writeJSON called on httpConn")
 }
 
+func (hc *httpConn) nativeResults() bool {
+	return false
+}
+
 func (hc *httpConn) peerInfo() PeerInfo {
 	panic("peerInfo called on httpConn")
 }
@@ -205,6 +212,15 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 			Body:       body,
 		}
 	}
+	// Transcode binary encoded responses so that callers only deal with JSON
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("content-type")); mt == cborContentType {
+		defer resp.Body.Close()
+		body, err := cborToJSON(bufio.NewReader(resp.Body))
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
 	return resp.Body, nil
 }
 
@@ -215,10 +231,34 @@ type httpServerConn struct {
 	r *http.Request
 }
 
-func newHTTPServerConn(r *http.Request, w http.ResponseWriter) ServerCodec {
+func newHTTPServerConn(r *http.Request, w http.ResponseWriter, cborResponse bool) ServerCodec {
 	body := io.LimitReader(r.Body, maxRequestContentLength)
 	conn := &httpServerConn{Reader: body, Writer: w, r: r}
-	return NewCodec(conn)
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+	if mt != cborContentType && !cborResponse {
+		return NewCodec(conn)
+	}
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	dec.UseNumber()
+	encode, decode := enc.Encode, dec.Decode
+	if mt == cborContentType {
+		br := bufio.NewReader(conn)
+		decode = func(v interface{}) error { return decodeCBOR(br, v) }
+	}
+	if cborResponse {
+		encode = func(v interface{}) error {
+			data, err := encodeCBOR(v)
+			if err != nil {
+				return err
+			}
+			_, err = conn.Write(data)
+			return err
+		}
+	}
+	codec := NewFuncCodec(conn, encode, decode).(*jsonCodec)
+	codec.native = cborResponse
+	return codec
 }
 
 // Close does nothing and always returns nil.
@@ -250,14 +290,21 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	connInfo.HTTP.Host = r.Host
 	connInfo.HTTP.Origin = r.Header.Get("Origin")
 	connInfo.HTTP.UserAgent = r.Header.Get("User-Agent")
+	connInfo.HTTP.JWTSubject = jwtSubjectFromContext(r.Context())
+	connInfo.identityHeader = s.identityHeader(r.Header)
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, connInfo)
 
 	// All checks passed, create a codec that reads directly from the request body
 	// until EOF, writes the response to w, and orders the server to process a
 	// single request.
-	w.Header().Set("content-type", contentType)
-	codec := newHTTPServerConn(r, w)
+	cborResponse := wantsCBOR(r)
+	if cborResponse {
+		w.Header().Set("content-type", cborContentType)
+	} else {
+		w.Header().Set("content-type", contentType)
+	}
+	codec := newHTTPServerConn(r, w, cborResponse)
 	defer codec.close()
 	s.serveSingleRequest(ctx, codec)
 }
@@ -288,3 +335,33 @@ func validateRequest(r *http.Request) (int, error) {
 	err := fmt.Errorf("invalid content type, only %s is supported", contentType)
 	return http.StatusUnsupportedMediaType, err
 }
+
+// wantsCBOR reports whether the response to a request should use the compact
+// binary encoding. The Accept header decides if it lists any of the encodings,
+// otherwise the response uses the encoding of the request.
+func wantsCBOR(r *http.Request) bool {
+	var cborQ, jsonQ float64 = -1, -1
+	for _, accept := range strings.Split(r.Header.Get("accept"), ",") {
+		mt, params, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mt {
+		case cborContentType:
+			cborQ = q
+		case contentType:
+			jsonQ = q
+		}
+	}
+	if cborQ < 0 && jsonQ < 0 {
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+		return mt == cborContentType
+	}
+	return cborQ > 0 && cborQ >= jsonQ
+}
@@ -44,6 +44,14 @@ var null = json.RawMessage("null")
 type subscriptionResult struct {
 	ID     string          `json:"subscription"`
 	Result json.RawMessage `json:"result,omitempty"`
+	Error  *jsonError      `json:"error,omitempty"` // set when the server ends the subscription
+}
+
+// nativeSubscriptionResult is the subscriptionResult carrying the Go value of
+// the notification data.
+type nativeSubscriptionResult struct {
+	ID     string      `json:"subscription"`
+	Result interface{} `json:"result"`
 }
 
 // A value of this type can a JSON-RPC request, notification, successful response or
@@ -55,6 +63,11 @@ type jsonrpcMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Error   *jsonError      `json:"error,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
+
+	// Go values of the params and result, set instead of their JSON encoding on
+	// connections encoding them natively.
+	params interface{}
+	result interface{}
 }
 
 func (msg *jsonrpcMessage) isNotification() bool {
@@ -106,6 +119,15 @@ func (msg *jsonrpcMessage) response(result interface{}) *jsonrpcMessage {
 	return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: enc}
 }
 
+// nativeResponse creates a response carrying the Go value of the result, which
+// is encoded when the response is written.
+func (msg *jsonrpcMessage) nativeResponse(result interface{}) *jsonrpcMessage {
+	if result == nil {
+		return &jsonrpcMessage{Version: vsn, ID: msg.ID, Result: null}
+	}
+	return &jsonrpcMessage{Version: vsn, ID: msg.ID, result: result}
+}
+
 func errorMessage(err error) *jsonrpcMessage {
 	msg := &jsonrpcMessage{Version: vsn, ID: null, Error: &jsonError{
 		Code:    defaultErrorCode,
@@ -170,6 +192,7 @@ type jsonCodec struct {
 	decode  func(v interface{}) error // decoder to allow multiple transports
 	encMu   sync.Mutex                // guards the encoder
 	encode  func(v interface{}) error // encoder to allow multiple transports
+	native  bool                      // whether encode handles results natively
 	conn    deadlineCloser
 }
 
@@ -225,6 +248,10 @@ func (c *jsonCodec) readBatch() (messages []*jsonrpcMessage, batch bool, err err
 	return messages, batch, nil
 }
 
+func (c *jsonCodec) nativeResults() bool {
+	return c.native
+}
+
 func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}) error {
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
@@ -0,0 +1,296 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"golang.org/x/time/rate"
+)
+
+// Client identities a rate limit policy can tell clients apart by.
+const (
+	IdentityRemoteAddr = "addr"    // Remote IP address of the client
+	IdentityJWT        = "jwt"     // Subject of the JWT token the client authenticated with
+	IdentityHeader     = "header:" // Prefix of the HTTP header identifying the client
+)
+
+// rateLimitPruneInterval is the time between two sweeps of the idle clients,
+// dropping the buckets which have been fully refilled.
+const rateLimitPruneInterval = time.Minute
+
+// RateLimitPolicy configures the limits enforced on the requests of the clients
+// of a server.
+//
+// Every request has a cost, 1 unless configured otherwise, which is taken from
+// the token bucket of the client. Requests are rejected if the bucket of their
+// client does not hold enough tokens, or if too many requests of the same
+// namespace are already executing.
+type RateLimitPolicy struct {
+	// Identity selects how clients are told apart: "addr" uses the remote IP
+	// address, "jwt" the subject of the JWT token the client authenticated with
+	// and "header:<name>" the value of the given HTTP header. Clients lacking
+	// the selected identity fall back to their remote IP address.
+	Identity string `toml:",omitempty"`
+
+	Rate  float64 `toml:",omitempty"` // Cost units refilled per second into the bucket of each client (0 = no limit)
+	Burst int     `toml:",omitempty"` // Capacity of the bucket of each client
+
+	Costs       map[string]int      `toml:",omitempty"` // Static cost of individual methods
+	CostFuncs   map[string]CostFunc `toml:"-"`          // Parameter dependent cost of individual methods, overriding the static ones
+	Concurrency map[string]int      `toml:",omitempty"` // Maximum number of concurrently executing requests per namespace
+}
+
+// CostFunc computes the cost of a request from its raw positional parameters.
+type CostFunc func(params json.RawMessage) int
+
+// BlockRangeCost returns a cost function charging perBlock for every block in
+// the range of the filter criteria passed as first parameter, like the one of
+// eth_getLogs. Open ended ranges, i.e. ranges between a block number and a tag
+// relative to the chain head, are charged as maxRange blocks, which also caps
+// the cost of larger ranges.
+func BlockRangeCost(perBlock int, maxRange uint64) CostFunc {
+	return func(params json.RawMessage) int {
+		var (
+			args []json.RawMessage
+			crit struct {
+				BlockHash *common.Hash
+				FromBlock *BlockNumber
+				ToBlock   *BlockNumber
+			}
+		)
+		if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+			return perBlock // Invalid request, will be rejected cheaply
+		}
+		if err := json.Unmarshal(args[0], &crit); err != nil || crit.BlockHash != nil {
+			return perBlock
+		}
+		from, to := LatestBlockNumber, LatestBlockNumber
+		if crit.FromBlock != nil {
+			from = *crit.FromBlock
+		}
+		if crit.ToBlock != nil {
+			to = *crit.ToBlock
+		}
+		var blocks uint64
+		switch {
+		case from < 0 && to < 0:
+			blocks = 1
+		case from < 0 || to < 0:
+			blocks = maxRange
+		case to < from:
+			blocks = 1
+		default:
+			blocks = uint64(to-from) + 1
+		}
+		if blocks > maxRange {
+			blocks = maxRange
+		}
+		return perBlock * int(blocks)
+	}
+}
+
+var (
+	rateLimitCostMeter               = metrics.NewRegisteredMeter("rpc/ratelimit/cost", nil)
+	rateLimitRejectedRateMeter       = metrics.NewRegisteredMeter("rpc/ratelimit/rejected/rate", nil)
+	rateLimitRejectedConcurrentMeter = metrics.NewRegisteredMeter("rpc/ratelimit/rejected/concurrency", nil)
+	rateLimitClientsGauge            = metrics.NewRegisteredGauge("rpc/ratelimit/clients", nil)
+
+	// rateLimitMethodCostName is the prefix of the per-method cost meters.
+	rateLimitMethodCostName = "rpc/ratelimit/cost"
+
+	// rateLimitInflightName is the prefix of the per-namespace gauges counting
+	// the executing requests.
+	rateLimitInflightName = "rpc/ratelimit/inflight"
+)
+
+// RateLimiter enforces a rate limit policy on the requests served by one or
+// more servers, sharing the buckets of the clients between them.
+type RateLimiter struct {
+	policy   RateLimitPolicy
+	identity func(PeerInfo) string
+	header   string        // HTTP header identifying the clients, if identified by header
+	refill   time.Duration // Time needed to refill an empty bucket
+
+	slots    map[string]chan struct{} // Execution slots of the capped namespaces
+	inflight map[string]metrics.Gauge // Number of executing requests of the capped namespaces
+
+	lock      sync.Mutex
+	clients   map[string]*clientBucket // Token buckets of the recently seen clients
+	lastPrune time.Time
+}
+
+// clientBucket is the token bucket of a single client.
+type clientBucket struct {
+	limiter *rate.Limiter
+	used    time.Time // Last time the client made a request
+}
+
+// NewRateLimiter creates a rate limiter enforcing the given policy.
+func NewRateLimiter(policy RateLimitPolicy) (*RateLimiter, error) {
+	l := &RateLimiter{
+		policy:    policy,
+		slots:     make(map[string]chan struct{}),
+		inflight:  make(map[string]metrics.Gauge),
+		clients:   make(map[string]*clientBucket),
+		lastPrune: time.Now(),
+	}
+	switch {
+	case policy.Identity == "" || policy.Identity == IdentityRemoteAddr:
+		l.identity = remoteIdentity
+	case policy.Identity == IdentityJWT:
+		l.identity = func(info PeerInfo) string {
+			if info.HTTP.JWTSubject != "" {
+				return "jwt:" + info.HTTP.JWTSubject
+			}
+			return remoteIdentity(info)
+		}
+	case strings.HasPrefix(policy.Identity, IdentityHeader) && len(policy.Identity) > len(IdentityHeader):
+		l.header = http.CanonicalHeaderKey(strings.TrimPrefix(policy.Identity, IdentityHeader))
+		l.identity = func(info PeerInfo) string {
+			if info.identityHeader != "" {
+				return "header:" + info.identityHeader
+			}
+			return remoteIdentity(info)
+		}
+	default:
+		return nil, fmt.Errorf("invalid client identity %q", policy.Identity)
+	}
+	if policy.Rate < 0 {
+		return nil, errors.New("negative rate limit")
+	}
+	if policy.Rate > 0 {
+		if policy.Burst <= 0 {
+			return nil, errors.New("rate limit burst must be positive")
+		}
+		l.refill = time.Duration(float64(policy.Burst) / policy.Rate * float64(time.Second))
+	}
+	for namespace, limit := range policy.Concurrency {
+		if limit <= 0 {
+			return nil, fmt.Errorf("invalid concurrency limit %d for namespace %s", limit, namespace)
+		}
+		l.slots[namespace] = make(chan struct{}, limit)
+		l.inflight[namespace] = metrics.GetOrRegisterGauge(fmt.Sprintf("%s/%s", rateLimitInflightName, namespace), nil)
+	}
+	return l, nil
+}
+
+// remoteIdentity identifies a client by the IP address of its connection.
+func remoteIdentity(info PeerInfo) string {
+	if host, _, err := net.SplitHostPort(info.RemoteAddr); err == nil {
+		return "addr:" + host
+	}
+	return "addr:" + info.RemoteAddr
+}
+
+// cost returns the cost of the given request.
+func (l *RateLimiter) cost(msg *jsonrpcMessage) int {
+	if fn := l.policy.CostFuncs[msg.Method]; fn != nil {
+		return fn(msg.Params)
+	}
+	if cost, ok := l.policy.Costs[msg.Method]; ok {
+		return cost
+	}
+	return 1
+}
+
+// acquire admits a request for execution, returning the function to call once
+// it's done. An error is returned if the request exceeds the limits.
+func (l *RateLimiter) acquire(ctx context.Context, msg *jsonrpcMessage) (func(), error) {
+	cost := l.cost(msg)
+
+	// Reserve an execution slot first, so the tokens are not wasted if none
+	// are available
+	release := func() {}
+	if slots := l.slots[msg.namespace()]; slots != nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			rateLimitRejectedConcurrentMeter.Mark(1)
+			return nil, &rateLimitError{
+				message: fmt.Sprintf("too many concurrent %s requests", msg.namespace()),
+				data:    rateLimitErrorData{Reason: "concurrency", Method: msg.Method, Cost: cost},
+			}
+		}
+		inflight := l.inflight[msg.namespace()]
+		inflight.Inc(1)
+		release = func() {
+			inflight.Dec(1)
+			<-slots
+		}
+	}
+	if l.policy.Rate > 0 {
+		if err := l.take(l.identity(PeerInfoFromContext(ctx)), msg.Method, cost); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	rateLimitCostMeter.Mark(int64(cost))
+	metrics.GetOrRegisterMeter(fmt.Sprintf("%s/%s", rateLimitMethodCostName, msg.Method), nil).Mark(int64(cost))
+	return release, nil
+}
+
+// take removes the cost of a request from the bucket of the client.
+func (l *RateLimiter) take(client string, method string, cost int) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > rateLimitPruneInterval {
+		for id, bucket := range l.clients {
+			if now.Sub(bucket.used) > l.refill {
+				delete(l.clients, id)
+			}
+		}
+		l.lastPrune = now
+	}
+	bucket := l.clients[client]
+	if bucket == nil {
+		bucket = &clientBucket{limiter: rate.NewLimiter(rate.Limit(l.policy.Rate), l.policy.Burst)}
+		l.clients[client] = bucket
+	}
+	bucket.used = now
+	rateLimitClientsGauge.Update(int64(len(l.clients)))
+
+	if cost > l.policy.Burst {
+		rateLimitRejectedRateMeter.Mark(1)
+		return &rateLimitError{
+			message: fmt.Sprintf("request cost %d exceeds the rate limit burst %d", cost, l.policy.Burst),
+			data:    rateLimitErrorData{Reason: "rate", Method: method, Cost: cost},
+		}
+	}
+	reservation := bucket.limiter.ReserveN(now, cost)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		rateLimitRejectedRateMeter.Mark(1)
+		return &rateLimitError{
+			message: "rate limit exceeded",
+			data:    rateLimitErrorData{Reason: "rate", Method: method, Cost: cost, RetryAfter: uint64(delay.Milliseconds()) + 1},
+		}
+	}
+	return nil
+}
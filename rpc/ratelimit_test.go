@@ -0,0 +1,148 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// checkRateLimitError ensures the error is a rate limit rejection for the given
+// reason.
+func checkRateLimitError(t *testing.T, err error, reason string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("request not rejected")
+	}
+	rpcErr, ok := err.(Error)
+	if !ok || rpcErr.ErrorCode() != -32005 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, ok := err.(DataError).ErrorData().(map[string]interface{})
+	if !ok || data["reason"] != reason {
+		t.Fatalf("unexpected error data: %v", err.(DataError).ErrorData())
+	}
+}
+
+// Tests that requests are charged to the bucket of their client, rejecting them
+// once it's drained.
+func TestRateLimit(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitPolicy{
+		Identity: "header:X-Api-Key",
+		Rate:     0.001,
+		Burst:    3,
+		Costs:    map[string]int{"test_echo": 2, "test_peerInfo": 4},
+	})
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+	server := newTestServer()
+	server.SetRateLimiter(limiter)
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	dial := func(key string) *Client {
+		c, err := DialHTTP(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetHeader("X-Api-Key", key)
+		return c
+	}
+	var (
+		alice = dial("alice")
+		bob   = dial("bob")
+		res   echoResult
+	)
+	if err := alice.Call(&res, "test_echo", "x", 1); err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	checkRateLimitError(t, alice.Call(&res, "test_echo", "x", 1), "rate")
+
+	// The cheaper method still fits into the bucket, other clients are unaffected
+	if err := alice.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	if err := bob.Call(&res, "test_echo", "x", 1); err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	// Requests costing more than the bucket capacity are never admitted
+	checkRateLimitError(t, bob.Call(nil, "test_peerInfo"), "rate")
+}
+
+// Tests that the requests executing at once in a namespace are capped.
+func TestRateLimitConcurrency(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitPolicy{
+		Concurrency: map[string]int{"test": 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+	server := newTestServer()
+	server.SetRateLimiter(limiter)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- client.Call(nil, "test_sleep", 500*time.Millisecond)
+	}()
+	for len(limiter.slots["test"]) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkRateLimitError(t, client.Call(nil, "test_noArgsRets"), "concurrency")
+
+	var res int
+	if err := client.Call(&res, "nftest_echo", 1); err != nil {
+		t.Fatalf("failed to call other namespace: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to sleep: %v", err)
+	}
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("failed to call after slot release: %v", err)
+	}
+}
+
+// Tests that the cost of filter queries follows the size of their block range.
+func TestBlockRangeCost(t *testing.T) {
+	cost := BlockRangeCost(2, 100)
+	tests := []struct {
+		params string
+		want   int
+	}{
+		{`[{"fromBlock": "0x10", "toBlock": "0x19"}]`, 20},
+		{`[{"fromBlock": "0x10", "toBlock": "0x1000"}]`, 200},
+		{`[{"fromBlock": "0x10"}]`, 200},
+		{`[{"fromBlock": "latest", "toBlock": "pending"}]`, 2},
+		{`[{}]`, 2},
+		{`[{"blockHash": "0x0000000000000000000000000000000000000000000000000000000000000001"}]`, 2},
+		{`[]`, 2},
+		{`invalid`, 2},
+	}
+	for i, tt := range tests {
+		if have := cost(json.RawMessage(tt.params)); have != tt.want {
+			t.Errorf("test %d: cost mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
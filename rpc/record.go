@@ -0,0 +1,236 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// recordFilePattern is the name pattern of the request log files.
+	recordFilePattern = "rpc-*.jsonl"
+
+	// defaultRecordMaxSize is the size after which a request log file is rotated,
+	// if not configured otherwise.
+	defaultRecordMaxSize = 256 * 1024 * 1024
+
+	// httpRecordConn is the connection id all HTTP requests are recorded under.
+	// They are stateless, so there's no point in telling them apart, and doing
+	// so would make replays open a connection per request. Ids of persistent
+	// connections start at 1.
+	httpRecordConn = 0
+)
+
+// recordExcludedNamespaces are the namespaces whose requests are not recorded,
+// as they carry passwords, raw signing payloads and node administration calls.
+var recordExcludedNamespaces = map[string]bool{
+	"personal": true,
+	"admin":    true,
+}
+
+// RecordConfig configures the recording of the requests served by a server.
+type RecordConfig struct {
+	Dir      string // Directory the request log files are written into
+	MaxSize  int64  `toml:",omitempty"` // Size in bytes after which a log file is rotated
+	MaxFiles int    `toml:",omitempty"` // Number of log files kept, 0 keeps all of them
+}
+
+// RecordEntry is a single request served by a server, along with its response.
+type RecordEntry struct {
+	Conn      uint64          `json:"conn"`      // Sequence number of the connection the request arrived on, 0 for HTTP
+	Transport string          `json:"transport"` // Transport of the connection, "http", "ws" or "ipc"
+	Time      time.Time       `json:"time"`      // Time the request was received
+	Elapsed   time.Duration   `json:"elapsed"`   // Time it took to serve the request
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response"`
+}
+
+// Recorder writes the requests served by one or more servers into a rotating
+// set of log files, one JSON encoded RecordEntry per line. The log files are
+// named after the time they were created, so ordering them by name orders the
+// recorded requests by time.
+type Recorder struct {
+	config RecordConfig
+	conns  uint64 // Number of connections seen, used to assign connection ids
+
+	lock sync.Mutex
+	file *os.File // Log file currently written, nil if closed
+	size int64    // Number of bytes written into the current log file
+}
+
+// NewRecorder creates a request recorder writing into the configured directory.
+func NewRecorder(config RecordConfig) (*Recorder, error) {
+	if config.Dir == "" {
+		return nil, errors.New("no request log directory configured")
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultRecordMaxSize
+	}
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, err
+	}
+	r := &Recorder{config: config}
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close flushes and closes the current log file. Requests served afterwards
+// are not recorded anymore.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// nextConn returns the id of a newly served connection.
+func (r *Recorder) nextConn() uint64 {
+	return atomic.AddUint64(&r.conns, 1)
+}
+
+// record writes a served request and its response into the log, unless it
+// belongs to an excluded namespace.
+func (r *Recorder) record(conn uint64, info PeerInfo, msg, resp *jsonrpcMessage, start time.Time) {
+	if recordExcludedNamespaces[msg.namespace()] {
+		return
+	}
+	elapsed := time.Since(start)
+
+	req, err := json.Marshal(msg)
+	if err != nil {
+		log.Warn("Failed to encode recorded request", "err", err)
+		return
+	}
+	if resp.result != nil {
+		// Results written in the binary encoding still get logged as JSON
+		resp = resp.response(resp.result)
+	}
+	res, err := json.Marshal(resp)
+	if err != nil {
+		log.Warn("Failed to encode recorded response", "err", err)
+		return
+	}
+	blob, err := json.Marshal(&RecordEntry{
+		Conn:      conn,
+		Transport: info.Transport,
+		Time:      start,
+		Elapsed:   elapsed,
+		Request:   req,
+		Response:  res,
+	})
+	if err != nil {
+		log.Warn("Failed to encode recorded entry", "err", err)
+		return
+	}
+	blob = append(blob, '\n')
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	if r.size > 0 && r.size+int64(len(blob)) > r.config.MaxSize {
+		if err := r.rotate(); err != nil {
+			log.Warn("Failed to rotate request log", "err", err)
+			return
+		}
+	}
+	n, err := r.file.Write(blob)
+	r.size += int64(n)
+	if err != nil {
+		log.Warn("Failed to write request log", "err", err)
+	}
+}
+
+// rotate closes the current log file, if any, and starts a new one, deleting
+// the oldest files beyond the configured limit. The caller must hold the lock.
+func (r *Recorder) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	name := fmt.Sprintf("rpc-%s.jsonl", time.Now().UTC().Format("20060102T150405.000000000"))
+	file, err := os.OpenFile(filepath.Join(r.config.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	r.file, r.size = file, 0
+
+	if r.config.MaxFiles > 0 {
+		files, err := RecordFiles(r.config.Dir)
+		if err != nil {
+			return err
+		}
+		for len(files) > r.config.MaxFiles {
+			if err := os.Remove(files[0]); err != nil {
+				return err
+			}
+			files = files[1:]
+		}
+	}
+	return nil
+}
+
+// RecordFiles returns the request log files in the given directory, ordered
+// from the oldest to the newest.
+func RecordFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, recordFilePattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadRecords decodes the entries of a request log, invoking the callback for
+// each of them in order.
+func ReadRecords(r io.Reader, fn func(*RecordEntry) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		entry := new(RecordEntry)
+		if err := dec.Decode(entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// readRecordDir decodes all the entries logged into the given directory.
+func readRecordDir(t *testing.T, dir string) []*RecordEntry {
+	t.Helper()
+
+	files, err := RecordFiles(dir)
+	if err != nil {
+		t.Fatalf("failed to list request logs: %v", err)
+	}
+	var entries []*RecordEntry
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("failed to open request log: %v", err)
+		}
+		err = ReadRecords(f, func(entry *RecordEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			t.Fatalf("failed to read request log: %v", err)
+		}
+	}
+	return entries
+}
+
+// Tests that served requests are logged along with their responses and the
+// connection they arrived on.
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(RecordConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	server := newTestServer()
+	server.SetRecorder(recorder)
+	defer server.Stop()
+
+	for i := 0; i < 2; i++ {
+		client := DialInProc(server)
+		var res echoResult
+		if err := client.Call(&res, "test_echo", "x", i); err != nil {
+			t.Fatalf("failed to call: %v", err)
+		}
+		client.Call(nil, "test_missing")
+		client.Close()
+	}
+	recorder.Close()
+
+	entries := readRecordDir(t, dir)
+	if len(entries) != 4 {
+		t.Fatalf("wrong number of recorded requests: have %d, want 4", len(entries))
+	}
+	for i, entry := range entries {
+		if want := uint64(i/2 + 1); entry.Conn != want {
+			t.Errorf("entry %d: wrong connection: have %d, want %d", i, entry.Conn, want)
+		}
+		if entry.Transport != "ipc" {
+			t.Errorf("entry %d: wrong transport %q", i, entry.Transport)
+		}
+		var req, resp jsonrpcMessage
+		if err := json.Unmarshal(entry.Request, &req); err != nil {
+			t.Fatalf("entry %d: invalid request: %v", i, err)
+		}
+		if err := json.Unmarshal(entry.Response, &resp); err != nil {
+			t.Fatalf("entry %d: invalid response: %v", i, err)
+		}
+		if i%2 == 0 {
+			if req.Method != "test_echo" || resp.Error != nil {
+				t.Errorf("entry %d: unexpected call %s, error %v", i, req.Method, resp.Error)
+			}
+		} else if req.Method != "test_missing" || resp.Error == nil {
+			t.Errorf("entry %d: unexpected call %s, error %v", i, req.Method, resp.Error)
+		}
+	}
+}
+
+// Tests that HTTP requests are all recorded under the same connection, and that
+// the requests of the sensitive namespaces are not recorded.
+func TestRecorderHTTP(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(RecordConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	server := newTestServer()
+	server.SetRecorder(recorder)
+	if err := server.RegisterName("personal", new(testService)); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for i := 0; i < 3; i++ {
+		var res echoResult
+		if err := client.Call(&res, "test_echo", "x", i); err != nil {
+			t.Fatalf("failed to call: %v", err)
+		}
+		if err := client.Call(&res, "personal_echo", "secret", i); err != nil {
+			t.Fatalf("failed to call: %v", err)
+		}
+	}
+	recorder.Close()
+
+	entries := readRecordDir(t, dir)
+	if len(entries) != 3 {
+		t.Fatalf("wrong number of recorded requests: have %d, want 3", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Conn != httpRecordConn {
+			t.Errorf("entry %d: wrong connection: have %d, want %d", i, entry.Conn, httpRecordConn)
+		}
+		if entry.Transport != "http" {
+			t.Errorf("entry %d: wrong transport %q", i, entry.Transport)
+		}
+		var req jsonrpcMessage
+		if err := json.Unmarshal(entry.Request, &req); err != nil {
+			t.Fatalf("entry %d: invalid request: %v", i, err)
+		}
+		if req.Method != "test_echo" {
+			t.Errorf("entry %d: unexpected call %s", i, req.Method)
+		}
+	}
+}
+
+// Tests that the request log is rotated once it reaches its size limit, keeping
+// only the configured number of files.
+func TestRecorderRotation(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(RecordConfig{Dir: dir, MaxSize: 1, MaxFiles: 3})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	server := newTestServer()
+	server.SetRecorder(recorder)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+	for i := 0; i < 5; i++ {
+		var res echoResult
+		if err := client.Call(&res, "test_echo", "x", i); err != nil {
+			t.Fatalf("failed to call: %v", err)
+		}
+	}
+	recorder.Close()
+
+	files, err := RecordFiles(dir)
+	if err != nil {
+		t.Fatalf("failed to list request logs: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("wrong number of request logs: have %d, want 3", len(files))
+	}
+	entries := readRecordDir(t, dir)
+	if len(entries) != 3 {
+		t.Fatalf("wrong number of retained requests: have %d, want 3", len(entries))
+	}
+	for i, entry := range entries {
+		var req struct{ Params []interface{} }
+		if err := json.Unmarshal(entry.Request, &req); err != nil {
+			t.Fatalf("entry %d: invalid request: %v", i, err)
+		}
+		if n := req.Params[1].(float64); int(n) != i+2 {
+			t.Errorf("entry %d: wrong request retained: have %v, want %d", i, n, i+2)
+		}
+	}
+}
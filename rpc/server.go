@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"io"
+	"net/http"
 	"sync/atomic"
 
 	mapset "github.com/deckarep/golang-set"
@@ -47,6 +48,7 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	config   handlerConfig
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -67,6 +69,27 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetRateLimiter sets the rate limiter enforced on the requests served. It must
+// be called before serving any connection.
+func (s *Server) SetRateLimiter(limiter *RateLimiter) {
+	s.config.limiter = limiter
+}
+
+// identityHeader returns the value of the HTTP header identifying the client to
+// the rate limiter, if the server has one identifying clients by header.
+func (s *Server) identityHeader(header http.Header) string {
+	if s.config.limiter == nil || s.config.limiter.header == "" {
+		return ""
+	}
+	return header.Get(s.config.limiter.header)
+}
+
+// SetRecorder sets the recorder logging the requests served. It must be called
+// before serving any connection.
+func (s *Server) SetRecorder(recorder *Recorder) {
+	s.config.recorder = recorder
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
@@ -84,7 +107,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, &s.config)
 	<-codec.closed()
 	c.Close()
 }
@@ -100,6 +123,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
 	h.allowSubscribe = false
+	s.config.apply(h, true)
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -168,11 +192,33 @@ type PeerInfo struct {
 		UserAgent string
 		Origin    string
 		Host      string
+
+		// Subject of the JWT token the client authenticated with, if any.
+		JWTSubject string
 	}
+
+	// Value of the HTTP header identifying the client to the rate limiter, if
+	// it identifies clients by header. No other header is retained.
+	identityHeader string
 }
 
 type peerInfoContextKey struct{}
 
+type jwtSubjectContextKey struct{}
+
+// WithJWTSubject returns a copy of the context carrying the subject of the JWT
+// token a request was authenticated with. Authenticating HTTP middleware uses it
+// to make the subject available in the PeerInfo of the request.
+func WithJWTSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, jwtSubjectContextKey{}, subject)
+}
+
+// jwtSubjectFromContext returns the JWT subject stored in the context, if any.
+func jwtSubjectFromContext(ctx context.Context) string {
+	subject, _ := ctx.Value(jwtSubjectContextKey{}).(string)
+	return subject
+}
+
 // PeerInfoFromContext returns information about the client's network connection.
 // Use this with the context passed to RPC method handler functions.
 //
@@ -96,9 +96,11 @@ type Notifier struct {
 
 	mu           sync.Mutex
 	sub          *Subscription
-	buffer       []json.RawMessage
+	buffer       []interface{}
 	callReturned bool
 	activated    bool
+	closed       bool
+	closeErr     error
 }
 
 // CreateSubscription returns a new subscription that is coupled to the
@@ -121,9 +123,13 @@ func (n *Notifier) CreateSubscription() *Subscription {
 // Notify sends a notification to the client with the given data as payload.
 // If an error occurs the RPC connection is closed and the error is returned.
 func (n *Notifier) Notify(id ID, data interface{}) error {
-	enc, err := json.Marshal(data)
-	if err != nil {
-		return err
+	// Connections encoding results natively encode the data when it is sent
+	if !n.h.conn.nativeResults() {
+		enc, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		data = json.RawMessage(enc)
 	}
 
 	n.mu.Lock()
@@ -134,13 +140,45 @@ func (n *Notifier) Notify(id ID, data interface{}) error {
 	} else if n.sub.ID != id {
 		panic("Notify with wrong ID")
 	}
+	if n.closed {
+		return nil
+	}
 	if n.activated {
-		return n.send(n.sub, enc)
+		return n.send(n.sub, data)
 	}
-	n.buffer = append(n.buffer, enc)
+	n.buffer = append(n.buffer, data)
 	return nil
 }
 
+// Close ends the subscription because of an error on the server side. The error
+// is sent to the client in a last notification, and the subscription is removed
+// as if the client had unsubscribed. Later notifications are dropped.
+func (n *Notifier) Close(id ID, err error) error {
+	n.mu.Lock()
+	if n.sub == nil {
+		n.mu.Unlock()
+		panic("can't Close before subscription is created")
+	} else if n.sub.ID != id {
+		n.mu.Unlock()
+		panic("Close with wrong ID")
+	}
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed, n.closeErr = true, err
+
+	var sendErr error
+	if n.activated {
+		sendErr = n.sendError(n.sub, err)
+	}
+	n.mu.Unlock()
+
+	// Subscriptions closed before the subscribe call returned are never added
+	n.h.removeSubscription(id)
+	return sendErr
+}
+
 // Closed returns a channel that is closed when the RPC connection is closed.
 // Deprecated: use subscription error channel
 func (n *Notifier) Closed() <-chan interface{} {
@@ -153,6 +191,9 @@ func (n *Notifier) takeSubscription() *Subscription {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	n.callReturned = true
+	if n.closed {
+		return nil
+	}
 	return n.sub
 }
 
@@ -169,13 +210,29 @@ func (n *Notifier) activate() error {
 		}
 	}
 	n.activated = true
+	if n.closed {
+		return n.sendError(n.sub, n.closeErr)
+	}
 	return nil
 }
 
-func (n *Notifier) send(sub *Subscription, data json.RawMessage) error {
-	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Result: data})
-	ctx := context.Background()
-	return n.h.conn.writeJSON(ctx, &jsonrpcMessage{
+func (n *Notifier) send(sub *Subscription, data interface{}) error {
+	msg := &jsonrpcMessage{
+		Version: vsn,
+		Method:  n.namespace + notificationMethodSuffix,
+	}
+	if enc, ok := data.(json.RawMessage); ok {
+		msg.Params, _ = json.Marshal(&subscriptionResult{ID: string(sub.ID), Result: enc})
+	} else {
+		msg.params = &nativeSubscriptionResult{ID: string(sub.ID), Result: data}
+	}
+	return n.h.conn.writeJSON(context.Background(), msg)
+}
+
+// sendError sends the notification ending a subscription with an error.
+func (n *Notifier) sendError(sub *Subscription, err error) error {
+	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Error: errorMessage(err).Error})
+	return n.h.conn.writeJSON(context.Background(), &jsonrpcMessage{
 		Version: vsn,
 		Method:  n.namespace + notificationMethodSuffix,
 		Params:  params,
@@ -190,7 +247,8 @@ type Subscription struct {
 	err       chan error // closed on unsubscribe
 }
 
-// Err returns a channel that is closed when the client send an unsubscribe request.
+// Err returns a channel that is closed when the client send an unsubscribe request,
+// or when the subscription is ended by Notifier.Close.
 func (s *Subscription) Err() <-chan error {
 	return s.err
 }
@@ -52,6 +52,9 @@ type ServerCodec interface {
 // Implementations must be safe for concurrent use.
 type jsonWriter interface {
 	writeJSON(context.Context, interface{}) error
+	// nativeResults reports whether results are written from their Go values
+	// rather than from their JSON encoding.
+	nativeResults() bool
 	// Closed returns a channel which is closed when the connection is closed.
 	closed() <-chan interface{}
 	// RemoteAddr returns the peer address of the connection.
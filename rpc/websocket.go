@@ -17,8 +17,10 @@
 package rpc
 
 import (
+	"bufio"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -53,6 +55,7 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 		WriteBufferSize: wsWriteBuffer,
 		WriteBufferPool: wsBufferPool,
 		CheckOrigin:     wsHandshakeValidator(allowedOrigins),
+		Subprotocols:    []string{cborWSSubprotocol},
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -61,6 +64,8 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			return
 		}
 		codec := newWebsocketCodec(conn, r.Host, r.Header)
+		codec.info.HTTP.JWTSubject = jwtSubjectFromContext(r.Context())
+		codec.info.identityHeader = s.identityHeader(r.Header)
 		s.ServeCodec(codec, 0)
 	})
 }
@@ -109,6 +114,12 @@ func wsHandshakeValidator(allowedOrigins []string) func(*http.Request) bool {
 	return f
 }
 
+// WebsocketOriginValidator returns a function checking the Origin header of
+// websocket handshakes against the allowed origins, like WebsocketHandler does.
+func WebsocketOriginValidator(allowedOrigins []string) func(*http.Request) bool {
+	return wsHandshakeValidator(allowedOrigins)
+}
+
 type wsHandshakeError struct {
 	err    error
 	status string
@@ -207,10 +218,16 @@ func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, diale
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin)
+}
+
+// dialWebsocket dials with the default dialer, requesting the given subprotocols.
+func dialWebsocket(ctx context.Context, endpoint, origin string, subprotocols ...string) (*Client, error) {
 	dialer := websocket.Dialer{
 		ReadBufferSize:  wsReadBuffer,
 		WriteBufferSize: wsWriteBuffer,
 		WriteBufferPool: wsBufferPool,
+		Subprotocols:    subprotocols,
 	}
 	return DialWebsocketWithDialer(ctx, endpoint, origin, dialer)
 }
@@ -241,14 +258,19 @@ type websocketCodec struct {
 	pingReset chan struct{}
 }
 
-func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) ServerCodec {
+func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) *websocketCodec {
 	conn.SetReadLimit(wsMessageSizeLimit)
 	conn.SetPongHandler(func(appData string) error {
 		conn.SetReadDeadline(time.Time{})
 		return nil
 	})
+	encode, decode := conn.WriteJSON, conn.ReadJSON
+	native := conn.Subprotocol() == cborWSSubprotocol
+	if native {
+		encode, decode = wsWriteCBOR(conn), wsReadCBOR(conn)
+	}
 	wc := &websocketCodec{
-		jsonCodec: NewFuncCodec(conn, conn.WriteJSON, conn.ReadJSON).(*jsonCodec),
+		jsonCodec: NewFuncCodec(conn, encode, decode).(*jsonCodec),
 		conn:      conn,
 		pingReset: make(chan struct{}, 1),
 		info: PeerInfo{
@@ -256,6 +278,7 @@ func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) Serve
 			RemoteAddr: conn.RemoteAddr().String(),
 		},
 	}
+	wc.jsonCodec.native = native
 	// Fill in connection details.
 	wc.info.HTTP.Host = host
 	wc.info.HTTP.Origin = req.Get("Origin")
@@ -266,6 +289,33 @@ func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) Serve
 	return wc
 }
 
+// wsWriteCBOR returns an encoder sending values as binary messages in the
+// compact binary encoding.
+func wsWriteCBOR(conn *websocket.Conn) func(v interface{}) error {
+	return func(v interface{}) error {
+		data, err := encodeCBOR(v)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+}
+
+// wsReadCBOR returns a decoder reading values from binary messages in the
+// compact binary encoding. Text messages are still accepted and decoded as JSON.
+func wsReadCBOR(conn *websocket.Conn) func(v interface{}) error {
+	return func(v interface{}) error {
+		typ, r, err := conn.NextReader()
+		if err != nil {
+			return err
+		}
+		if typ == websocket.TextMessage {
+			return json.NewDecoder(r).Decode(v)
+		}
+		return decodeCBOR(bufio.NewReader(r), v)
+	}
+}
+
 func (wc *websocketCodec) close() {
 	wc.jsonCodec.close()
 	wc.wg.Wait()
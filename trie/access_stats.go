@@ -0,0 +1,161 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	accessAccountDirtyMeter     = metrics.NewRegisteredMeter("trie/access/account/dirty", nil)
+	accessAccountCleanMeter     = metrics.NewRegisteredMeter("trie/access/account/clean", nil)
+	accessAccountDiskMeter      = metrics.NewRegisteredMeter("trie/access/account/disk", nil)
+	accessAccountDiskReadMeter  = metrics.NewRegisteredMeter("trie/access/account/disk/read", nil)
+	accessAccountDepthHistogram = metrics.NewRegisteredHistogram("trie/access/account/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
+
+	accessStorageDirtyMeter     = metrics.NewRegisteredMeter("trie/access/storage/dirty", nil)
+	accessStorageCleanMeter     = metrics.NewRegisteredMeter("trie/access/storage/clean", nil)
+	accessStorageDiskMeter      = metrics.NewRegisteredMeter("trie/access/storage/disk", nil)
+	accessStorageDiskReadMeter  = metrics.NewRegisteredMeter("trie/access/storage/disk/read", nil)
+	accessStorageDepthHistogram = metrics.NewRegisteredHistogram("trie/access/storage/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
+// accessSource identifies the layer a trie node was served from when resolved.
+type accessSource uint8
+
+const (
+	accessDirty   accessSource = iota // Node served from the dirty (unflushed) node cache
+	accessClean                       // Node served from the clean fastcache
+	accessDisk                        // Node loaded from the persistent database
+	accessMissing                     // Node not found anywhere
+)
+
+// TrieAccessStats contains the aggregated node resolution counters of either the
+// account trie or all the storage tries.
+type TrieAccessStats struct {
+	DirtyHits  uint64   `json:"dirtyHits"`  // Nodes served from the dirty node cache
+	DirtyBytes uint64   `json:"dirtyBytes"` // Bytes served from the dirty node cache
+	CleanHits  uint64   `json:"cleanHits"`  // Nodes served from the clean node cache
+	CleanBytes uint64   `json:"cleanBytes"` // Bytes served from the clean node cache
+	DiskReads  uint64   `json:"diskReads"`  // Nodes loaded from the persistent database
+	DiskBytes  uint64   `json:"diskBytes"`  // Bytes loaded from the persistent database
+	Missing    uint64   `json:"missing"`    // Nodes that could not be found at all
+	Depths     []uint64 `json:"depths"`     // Number of resolved nodes indexed by path depth (nibbles)
+}
+
+// Resolved returns the total number of trie nodes successfully resolved.
+func (s *TrieAccessStats) Resolved() uint64 {
+	return s.DirtyHits + s.CleanHits + s.DiskReads
+}
+
+// add accumulates a single node resolution into the stats.
+func (s *TrieAccessStats) add(depth int, source accessSource, size int) {
+	switch source {
+	case accessDirty:
+		s.DirtyHits++
+		s.DirtyBytes += uint64(size)
+	case accessClean:
+		s.CleanHits++
+		s.CleanBytes += uint64(size)
+	case accessDisk:
+		s.DiskReads++
+		s.DiskBytes += uint64(size)
+	case accessMissing:
+		s.Missing++
+		return
+	}
+	for len(s.Depths) <= depth {
+		s.Depths = append(s.Depths, 0)
+	}
+	s.Depths[depth]++
+}
+
+// AccessStats is the set of trie node resolution statistics collected by a trie
+// database since the last time they were taken.
+type AccessStats struct {
+	Account TrieAccessStats `json:"account"` // Nodes resolved from the account trie
+	Storage TrieAccessStats `json:"storage"` // Nodes resolved from any of the storage tries
+}
+
+// accessTracker aggregates the trie node resolutions of a trie database. It is
+// safe for concurrent use as nodes are resolved from prefetchers and RPC handlers
+// in parallel with block processing.
+type accessTracker struct {
+	stats AccessStats
+	lock  sync.Mutex
+}
+
+// record accumulates a single node resolution into the tracker and reports it
+// to the metrics system. The owner is the zero hash for the account trie.
+func (t *accessTracker) record(owner common.Hash, depth int, source accessSource, size int) {
+	t.lock.Lock()
+	if owner == (common.Hash{}) {
+		t.stats.Account.add(depth, source, size)
+	} else {
+		t.stats.Storage.add(depth, source, size)
+	}
+	t.lock.Unlock()
+
+	if !metrics.Enabled {
+		return
+	}
+	if owner == (common.Hash{}) {
+		markAccess(source, size, depth, accessAccountDirtyMeter, accessAccountCleanMeter, accessAccountDiskMeter, accessAccountDiskReadMeter, accessAccountDepthHistogram)
+	} else {
+		markAccess(source, size, depth, accessStorageDirtyMeter, accessStorageCleanMeter, accessStorageDiskMeter, accessStorageDiskReadMeter, accessStorageDepthHistogram)
+	}
+}
+
+// markAccess reports a single node resolution into the given set of metrics.
+func markAccess(source accessSource, size int, depth int, dirty, clean, disk, diskRead metrics.Meter, depths metrics.Histogram) {
+	switch source {
+	case accessDirty:
+		dirty.Mark(1)
+	case accessClean:
+		clean.Mark(1)
+	case accessDisk:
+		disk.Mark(1)
+		diskRead.Mark(int64(size))
+	default:
+		return
+	}
+	depths.Update(int64(depth))
+}
+
+// take returns the statistics aggregated so far and resets the tracker.
+func (t *accessTracker) take() AccessStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stats := t.stats
+	t.stats = AccessStats{}
+	return stats
+}
+
+// TakeAccessStats returns the trie node access statistics aggregated since the
+// last invocation and resets the counters. If access tracking was not enabled in
+// the database config, nil is returned.
+func (db *Database) TakeAccessStats() *AccessStats {
+	if db.tracker == nil {
+		return nil
+	}
+	stats := db.tracker.take()
+	return &stats
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// Tests that node resolutions are attributed to the correct cache layer and
+// trie type, and that taking the stats resets them.
+func TestAccessStats(t *testing.T) {
+	diskdb := rawdb.NewMemoryDatabase()
+	triedb := NewDatabaseWithConfig(diskdb, &Config{Cache: 16, Stats: true})
+
+	// Create a storage trie with a few entries and commit it into the dirty cache
+	owner := common.HexToHash("0x01")
+	trie := NewEmpty(triedb)
+	for _, key := range []string{"do", "dog", "doge", "horse", "house"} {
+		trie.Update([]byte(key), []byte(key))
+	}
+	root, _, err := trie.Commit(nil)
+	if err != nil {
+		t.Fatalf("Failed to commit trie: %v", err)
+	}
+	triedb.TakeAccessStats()
+
+	// Resolve the nodes from the dirty cache
+	dirtyTrie, _ := New(owner, root, triedb)
+	dirtyTrie.Get([]byte("doge"))
+
+	stats := triedb.TakeAccessStats()
+	if stats.Account.Resolved() != 0 {
+		t.Fatalf("Account trie accesses mismatch: have %d, want 0", stats.Account.Resolved())
+	}
+	if stats.Storage.DirtyHits == 0 || stats.Storage.CleanHits != 0 || stats.Storage.DiskReads != 0 {
+		t.Fatalf("Dirty cache accesses mismatch: %+v", stats.Storage)
+	}
+	if stats.Storage.Depths[0] != 1 {
+		t.Fatalf("Root access missing: %v", stats.Storage.Depths)
+	}
+	// Flush the nodes to disk and resolve them from disk, then the clean cache
+	if err := triedb.Commit(root, false, nil); err != nil {
+		t.Fatalf("Failed to flush trie: %v", err)
+	}
+	triedb = NewDatabaseWithConfig(diskdb, &Config{Cache: 16, Stats: true})
+
+	diskTrie, _ := New(common.Hash{}, root, triedb)
+	diskTrie.Get([]byte("doge"))
+	stats = triedb.TakeAccessStats()
+	if stats.Account.DiskReads == 0 || stats.Account.DiskBytes == 0 || stats.Account.CleanHits != 0 {
+		t.Fatalf("Disk accesses mismatch: %+v", stats.Account)
+	}
+	cleanTrie, _ := New(common.Hash{}, root, triedb)
+	cleanTrie.Get([]byte("doge"))
+	stats = triedb.TakeAccessStats()
+	if stats.Account.CleanHits == 0 || stats.Account.DiskReads != 0 {
+		t.Fatalf("Clean cache accesses mismatch: %+v", stats.Account)
+	}
+	// Ensure stats are nil if tracking is disabled
+	if stats := NewDatabase(diskdb).TakeAccessStats(); stats != nil {
+		t.Fatalf("Unexpected stats with tracking disabled: %+v", stats)
+	}
+}
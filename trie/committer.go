@@ -33,6 +33,7 @@ type leaf struct {
 	size int         // size of the rlp data (estimate)
 	hash common.Hash // hash of rlp data
 	node node        // the node to commit
+	path []byte      // the hex path of the node
 }
 
 // committer is a type used for the trie Commit operation. A committer has some
@@ -42,6 +43,7 @@ type leaf struct {
 // By 'some level' of parallelism, it's still the case that all leaves will be
 // processed sequentially - onleaf will never be called in parallel or out of order.
 type committer struct {
+	owner  common.Hash
 	onleaf LeafCallback
 	leafCh chan *leaf
 }
@@ -59,6 +61,7 @@ func newCommitter() *committer {
 }
 
 func returnCommitterToPool(h *committer) {
+	h.owner = common.Hash{}
 	h.onleaf = nil
 	h.leafCh = nil
 	committerPool.Put(h)
@@ -69,7 +72,7 @@ func (c *committer) Commit(n node, db *Database) (hashNode, int, error) {
 	if db == nil {
 		return nil, 0, errors.New("no db provided")
 	}
-	h, committed, err := c.commit(n, db)
+	h, committed, err := c.commit(nil, n, db)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -77,7 +80,7 @@ func (c *committer) Commit(n node, db *Database) (hashNode, int, error) {
 }
 
 // commit collapses a node down into a hash node and inserts it into the database
-func (c *committer) commit(n node, db *Database) (node, int, error) {
+func (c *committer) commit(path []byte, n node, db *Database) (node, int, error) {
 	// if this path is clean, use available cached data
 	hash, dirty := n.cache()
 	if hash != nil && !dirty {
@@ -93,7 +96,7 @@ func (c *committer) commit(n node, db *Database) (node, int, error) {
 		// otherwise it can only be hashNode or valueNode.
 		var childCommitted int
 		if _, ok := cn.Val.(*fullNode); ok {
-			childV, committed, err := c.commit(cn.Val, db)
+			childV, committed, err := c.commit(append(path, cn.Key...), cn.Val, db)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -101,20 +104,20 @@ func (c *committer) commit(n node, db *Database) (node, int, error) {
 		}
 		// The key needs to be copied, since we're delivering it to database
 		collapsed.Key = hexToCompact(cn.Key)
-		hashedNode := c.store(collapsed, db)
+		hashedNode := c.store(path, collapsed, db)
 		if hn, ok := hashedNode.(hashNode); ok {
 			return hn, childCommitted + 1, nil
 		}
 		return collapsed, childCommitted, nil
 	case *fullNode:
-		hashedKids, childCommitted, err := c.commitChildren(cn, db)
+		hashedKids, childCommitted, err := c.commitChildren(path, cn, db)
 		if err != nil {
 			return nil, 0, err
 		}
 		collapsed := cn.copy()
 		collapsed.Children = hashedKids
 
-		hashedNode := c.store(collapsed, db)
+		hashedNode := c.store(path, collapsed, db)
 		if hn, ok := hashedNode.(hashNode); ok {
 			return hn, childCommitted + 1, nil
 		}
@@ -128,7 +131,7 @@ func (c *committer) commit(n node, db *Database) (node, int, error) {
 }
 
 // commitChildren commits the children of the given fullnode
-func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, int, error) {
+func (c *committer) commitChildren(path []byte, n *fullNode, db *Database) ([17]node, int, error) {
 	var (
 		committed int
 		children  [17]node
@@ -148,7 +151,7 @@ func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, int, er
 		// Commit the child recursively and store the "hashed" value.
 		// Note the returned node can be some embedded nodes, so it's
 		// possible the type is not hashNode.
-		hashed, childCommitted, err := c.commit(child, db)
+		hashed, childCommitted, err := c.commit(append(path, byte(i)), child, db)
 		if err != nil {
 			return children, 0, err
 		}
@@ -165,7 +168,7 @@ func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, int, er
 // store hashes the node n and if we have a storage layer specified, it writes
 // the key/value pair to it and tracks any node->child references as well as any
 // node->external trie references.
-func (c *committer) store(n node, db *Database) node {
+func (c *committer) store(path []byte, n node, db *Database) node {
 	// Larger nodes are replaced by their hash and stored in the database.
 	var (
 		hash, _ = n.cache()
@@ -189,11 +192,12 @@ func (c *committer) store(n node, db *Database) node {
 			size: size,
 			hash: common.BytesToHash(hash),
 			node: n,
+			path: common.CopyBytes(path),
 		}
 	} else if db != nil {
 		// No leaf-callback used, but there's still a database. Do serial
 		// insertion
-		db.insert(common.BytesToHash(hash), size, n)
+		db.insertNode(c.owner, path, common.BytesToHash(hash), size, n)
 	}
 	return hash
 }
@@ -207,7 +211,7 @@ func (c *committer) commitLoop(db *Database) {
 			n    = item.node
 		)
 		// We are pooling the trie nodes into an intermediate memory cache
-		db.insert(hash, size, n)
+		db.insertNode(c.owner, item.path, hash, size, n)
 
 		if c.onleaf != nil {
 			switch n := n.(type) {
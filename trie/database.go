@@ -23,6 +23,7 @@ import (
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/fastcache"
@@ -58,6 +59,11 @@ var (
 	memcacheCommitSizeMeter  = metrics.NewRegisteredMeter("trie/memcache/commit/size", nil)
 )
 
+// ErrHashLookupUnsupported is returned when a trie node is requested by hash
+// alone from a database using the path-based scheme, which doesn't index nodes
+// by hash. Such nodes can only be resolved through a trie, by path.
+var ErrHashLookupUnsupported = errors.New("trie node lookup by hash not supported by the path scheme")
+
 // Database is an intermediate write layer between the trie data structures and
 // the disk database. The aim is to accumulate trie writes in-memory and only
 // periodically flush a couple tries to disk, garbage collecting the remainder.
@@ -88,6 +94,11 @@ type Database struct {
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
 
+	tracker *accessTracker // Node access statistics tracker (nil if disabled)
+	paths   *pathStore     // Path-based node storage backend (nil for the hash scheme)
+
+	flushHook atomic.Value // Callback notified of every node flushed to disk (func(common.Hash))
+
 	lock sync.RWMutex
 }
 
@@ -266,6 +277,8 @@ type Config struct {
 	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
 	Journal   string // Journal of clean cache to survive node restarts
 	Preimages bool   // Flag whether the preimage of trie key is recorded
+	Stats     bool   // Flag whether node access statistics are collected
+	Scheme    string // Node storage scheme to use, defaults to the hash scheme
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
@@ -297,9 +310,38 @@ func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database
 	if config == nil || config.Preimages { // TODO(karalabe): Flip to default off in the future
 		db.preimages = make(map[common.Hash][]byte)
 	}
+	if config != nil && config.Stats {
+		db.tracker = new(accessTracker)
+	}
+	if config != nil && config.Scheme == rawdb.PathScheme {
+		db.paths = newPathStore(diskdb)
+	}
 	return db
 }
 
+// Scheme returns the node storage scheme used by the database.
+func (db *Database) Scheme() string {
+	if db.paths != nil {
+		return rawdb.PathScheme
+	}
+	return rawdb.HashScheme
+}
+
+// SetFlushHook registers a callback which is invoked with the hash of every dirty
+// trie node right before it's written into the persistent database, either by a
+// Cap or a Commit. The hook runs on the flushing goroutine and must not call back
+// into the database. Passing nil removes any registered hook.
+func (db *Database) SetFlushHook(hook func(common.Hash)) {
+	db.flushHook.Store(hook)
+}
+
+// notifyFlush invokes the registered flush hook, if any.
+func (db *Database) notifyFlush(hash common.Hash) {
+	if hook, _ := db.flushHook.Load().(func(common.Hash)); hook != nil {
+		hook(hash)
+	}
+}
+
 // DiskDB retrieves the persistent storage backing the trie database.
 func (db *Database) DiskDB() ethdb.KeyValueStore {
 	return db.diskdb
@@ -359,17 +401,55 @@ func (db *Database) insertPreimage(hash common.Hash, preimage []byte) {
 	db.preimagesSize += common.StorageSize(common.HashLength + len(preimage))
 }
 
+// insertNode inserts a committed trie node into the memory database, keyed by
+// hash or by owner and path depending on the storage scheme.
+func (db *Database) insertNode(owner common.Hash, path []byte, hash common.Hash, size int, n node) {
+	if db.paths != nil {
+		db.paths.insert(owner, path, hash, nodeToBytes(n))
+		return
+	}
+	db.insert(hash, size, n)
+}
+
+// deleteNode marks the trie node at the given path as deleted. It's a noop for
+// the hash scheme as stale nodes are garbage collected by reference counting.
+func (db *Database) deleteNode(owner common.Hash, path []byte) {
+	if db.paths != nil {
+		db.paths.delete(owner, path)
+	}
+}
+
+// DeleteStorage marks the whole storage trie of an account as deleted by the
+// state being committed, e.g. because the account self-destructed. It must be
+// called before the storage trie of a resurrected account is committed. It's a
+// noop for the hash scheme as stale nodes are garbage collected by reference
+// counting.
+func (db *Database) DeleteStorage(owner common.Hash) {
+	if db.paths != nil {
+		db.paths.destruct(owner)
+	}
+}
+
 // node retrieves a cached trie node from memory, or returns nil if none can be
-// found in the memory cache.
-func (db *Database) node(hash common.Hash) node {
+// found in the memory cache. Beside the node itself, the layer it was served from
+// and its encoded size are also returned for access tracking. The state, owner
+// and path are only used by the path-based scheme.
+func (db *Database) node(state common.Hash, owner common.Hash, path []byte, hash common.Hash) (node, accessSource, int) {
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
 			memcacheCleanHitMeter.Mark(1)
 			memcacheCleanReadMeter.Mark(int64(len(enc)))
-			return mustDecodeNode(hash[:], enc)
+			return mustDecodeNode(hash[:], enc), accessClean, len(enc)
 		}
 	}
+	if db.paths != nil {
+		enc, source := db.pathNode(state, owner, path, hash)
+		if enc == nil {
+			return nil, accessMissing, 0
+		}
+		return mustDecodeNode(hash[:], enc), source, len(enc)
+	}
 	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
 	dirty := db.dirties[hash]
@@ -378,30 +458,77 @@ func (db *Database) node(hash common.Hash) node {
 	if dirty != nil {
 		memcacheDirtyHitMeter.Mark(1)
 		memcacheDirtyReadMeter.Mark(int64(dirty.size))
-		return dirty.obj(hash)
+		return dirty.obj(hash), accessDirty, int(dirty.size)
 	}
 	memcacheDirtyMissMeter.Mark(1)
 
 	// Content unavailable in memory, attempt to retrieve from disk
 	enc, err := db.diskdb.Get(hash[:])
 	if err != nil || enc == nil {
-		return nil
+		return nil, accessMissing, 0
 	}
 	if db.cleans != nil {
 		db.cleans.Set(hash[:], enc)
 		memcacheCleanMissMeter.Mark(1)
 		memcacheCleanWriteMeter.Mark(int64(len(enc)))
 	}
-	return mustDecodeNode(hash[:], enc)
+	return mustDecodeNode(hash[:], enc), accessDisk, len(enc)
+}
+
+// pathNode retrieves an encoded trie node from the path-based backend, caching
+// it in the clean cache if it was loaded from disk.
+func (db *Database) pathNode(state common.Hash, owner common.Hash, path []byte, hash common.Hash) ([]byte, accessSource) {
+	enc, memory := db.paths.node(state, owner, path, hash)
+	if enc == nil {
+		return nil, accessMissing
+	}
+	if memory {
+		memcacheDirtyHitMeter.Mark(1)
+		memcacheDirtyReadMeter.Mark(int64(len(enc)))
+		return enc, accessDirty
+	}
+	memcacheDirtyMissMeter.Mark(1)
+	if db.cleans != nil {
+		db.cleans.Set(hash[:], enc)
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(enc)))
+	}
+	return enc, accessDisk
+}
+
+// nodeBlob retrieves an encoded trie node of a state by owner, path and hash.
+// Contrary to Node, it works with both storage schemes.
+func (db *Database) nodeBlob(state common.Hash, owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	if db.paths == nil {
+		return db.Node(hash)
+	}
+	if db.cleans != nil {
+		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(enc)))
+			return enc, nil
+		}
+	}
+	if enc, _ := db.pathNode(state, owner, path, hash); enc != nil {
+		return enc, nil
+	}
+	return nil, errors.New("not found")
 }
 
 // Node retrieves an encoded cached trie node from memory. If it cannot be found
 // cached, the method queries the persistent database for the content.
+//
+// Note, the path-based scheme cannot look nodes up by hash alone, so the method
+// always fails with ErrHashLookupUnsupported in that case. Serving whatever the
+// clean cache happens to hold would only make the failures unpredictable.
 func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	// It doesn't make sense to retrieve the metaroot
 	if hash == (common.Hash{}) {
 		return nil, errors.New("not found")
 	}
+	if db.paths != nil {
+		return nil, ErrHashLookupUnsupported
+	}
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
@@ -453,10 +580,19 @@ func (db *Database) preimage(hash common.Hash) []byte {
 	return rawdb.ReadPreimage(db.diskdb, hash)
 }
 
+// Preimage retrieves the pre-image of a hashed secure trie key, or nil if it is
+// not known (e.g. preimage recording disabled).
+func (db *Database) Preimage(hash common.Hash) []byte {
+	return db.preimage(hash)
+}
+
 // Nodes retrieves the hashes of all the nodes cached within the memory database.
 // This method is extremely expensive and should only be used to validate internal
 // states in test code.
 func (db *Database) Nodes() []common.Hash {
+	if db.paths != nil {
+		return nil
+	}
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
@@ -474,6 +610,9 @@ func (db *Database) Nodes() []common.Hash {
 // and external node(e.g. storage trie root), all internal trie nodes
 // are referenced together by database itself.
 func (db *Database) Reference(child common.Hash, parent common.Hash) {
+	if db.paths != nil {
+		return // Path-based nodes are not reference counted
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -508,6 +647,9 @@ func (db *Database) Dereference(root common.Hash) {
 		log.Error("Attempted to dereference the trie cache meta root")
 		return
 	}
+	if db.paths != nil {
+		return // Path-based nodes are not reference counted
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -582,6 +724,9 @@ func (db *Database) dereference(child common.Hash, parent common.Hash) {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Cap(limit common.StorageSize) error {
+	if db.paths != nil {
+		return nil // Path-based layers are capped by count, see CapLayers
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -616,6 +761,7 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	for size > limit && oldest != (common.Hash{}) {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
+		db.notifyFlush(oldest)
 		rawdb.WriteTrieNode(batch, oldest, node.rlp())
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -702,6 +848,25 @@ func (db *Database) Commit(node common.Hash, report bool, callback func(common.H
 		}
 		batch.Reset()
 	}
+	// With the path-based scheme, flatten all layers up to the given root
+	if db.paths != nil {
+		if err := db.paths.cap(node, 0); err != nil {
+			log.Error("Failed to commit trie layers", "err", err)
+			return err
+		}
+		db.lock.Lock()
+		if db.preimages != nil {
+			db.preimages, db.preimagesSize = make(map[common.Hash][]byte), 0
+		}
+		db.lock.Unlock()
+
+		logger := log.Info
+		if !report {
+			logger = log.Debug
+		}
+		logger("Persisted trie layers", "root", node, "time", time.Since(start))
+		return nil
+	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.dirties), db.dirtiesSize
 
@@ -761,6 +926,7 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 		return err
 	}
 	// If we've reached an optimal batch size, commit and start over
+	db.notifyFlush(hash)
 	rawdb.WriteTrieNode(batch, hash, node.rlp())
 	if callback != nil {
 		callback(hash)
@@ -829,6 +995,13 @@ func (c *cleaner) Delete(key []byte) error {
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
+	if db.paths != nil {
+		size := db.paths.size()
+
+		db.lock.RLock()
+		defer db.lock.RUnlock()
+		return size, db.preimagesSize
+	}
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
@@ -840,6 +1013,50 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	return db.dirtiesSize + db.childrenSize + metadataSize - metarootRefs, db.preimagesSize
 }
 
+// Update seals all trie nodes committed since the last update into a new layer
+// representing the state transition from parent to root. It's a noop for the
+// hash scheme, where committed nodes are linked by reference counting instead.
+//
+// Note, this method is a non-synchronized mutator. It is unsafe to call this
+// concurrently with other mutators.
+func (db *Database) Update(root common.Hash, parent common.Hash) error {
+	if db.paths == nil {
+		return nil
+	}
+	return db.paths.update(root, parent)
+}
+
+// LockCommit acquires the exclusive right to commit tries into the database until
+// the next Update. With the path-based scheme committed nodes are accumulated in
+// a shared set until sealed into a layer, so whole state commits must not be
+// interleaved. It's a noop for the hash scheme.
+func (db *Database) LockCommit() {
+	if db.paths != nil {
+		db.paths.commitLock.Lock()
+	}
+}
+
+// UnlockCommit releases the commit lock acquired by LockCommit.
+func (db *Database) UnlockCommit() {
+	if db.paths != nil {
+		db.paths.commitLock.Unlock()
+	}
+}
+
+// CapLayers flattens the in-memory layers of the path-based scheme into the
+// database, retaining at most the given number of layers below root. Any layer
+// not built on top of the flattened state is discarded. It's a noop for the
+// hash scheme.
+//
+// Note, this method is a non-synchronized mutator. It is unsafe to call this
+// concurrently with other mutators.
+func (db *Database) CapLayers(root common.Hash, layers int) error {
+	if db.paths == nil {
+		return nil
+	}
+	return db.paths.cap(root, layers)
+}
+
 // saveCache saves clean state cache to given directory path
 // using specified CPU cores.
 func (db *Database) saveCache(dir string, threads int) error {
@@ -0,0 +1,330 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	pathLayersGauge         = metrics.NewRegisteredGauge("trie/path/layers", nil)
+	pathLayersSizeGauge     = metrics.NewRegisteredGauge("trie/path/layers/size", nil)
+	pathFlattenTimeTimer    = metrics.NewRegisteredResettingTimer("trie/path/flatten/time", nil)
+	pathFlattenNodesMeter   = metrics.NewRegisteredMeter("trie/path/flatten/nodes", nil)
+	pathFlattenDeletesMeter = metrics.NewRegisteredMeter("trie/path/flatten/deletes", nil)
+)
+
+// pathNode is a trie node tracked by the path-based scheme. A nil blob marks
+// the node at the path as deleted.
+type pathNode struct {
+	hash common.Hash // Hash of the node, zero if deleted
+	blob []byte      // RLP encoded node, nil if deleted
+}
+
+// pathNodeSet is a set of trie nodes keyed by trie owner and hex node path.
+type pathNodeSet map[common.Hash]map[string]*pathNode
+
+// add inserts a node into the set, overwriting any previous version.
+func (set pathNodeSet) add(owner common.Hash, path []byte, n *pathNode) {
+	subset, ok := set[owner]
+	if !ok {
+		subset = make(map[string]*pathNode)
+		set[owner] = subset
+	}
+	subset[string(path)] = n
+}
+
+// size returns the approximate memory used by the node set.
+func (set pathNodeSet) size() common.StorageSize {
+	var size common.StorageSize
+	for _, subset := range set {
+		size += common.HashLength
+		for path, n := range subset {
+			size += common.StorageSize(len(path) + len(n.blob) + common.HashLength)
+		}
+	}
+	return size
+}
+
+// pathLayer is an immutable set of trie node changes created by a single state
+// transition on top of its parent state.
+type pathLayer struct {
+	root      common.Hash              // Root hash of the state after the transition
+	parent    common.Hash              // Root hash of the state before the transition
+	nodes     pathNodeSet              // Trie nodes changed by the transition
+	destructs map[common.Hash]struct{} // Storage tries wiped by the transition before applying the nodes
+	size      common.StorageSize       // Approximate memory used by the layer
+}
+
+// pathStore is the backend of the path-based trie node storage scheme. Nodes
+// are persisted keyed by owner and path, so a newer version of a node overwrites
+// the older one and the disk only ever holds a single state. On top of that, a
+// bounded tree of in-memory diff layers is maintained to serve recent states and
+// to allow chain reorganisations within the retained range.
+type pathStore struct {
+	diskdb   ethdb.KeyValueStore        // Persistent storage holding the flattened state
+	diskRoot common.Hash                // Root hash of the state persisted in the database
+	layers   map[common.Hash]*pathLayer // In-memory diff layers keyed by state root
+	pending  pathNodeSet                // Nodes committed since the last layer was sealed
+	wiped    map[common.Hash]struct{}   // Storage tries deleted since the last layer was sealed
+	lock     sync.RWMutex
+
+	commitLock sync.Mutex // Lock serializing state commits sharing the pending set
+}
+
+// newPathStore creates a path-based node store on top of the given database,
+// detecting the persisted state from the stored account trie root node.
+func newPathStore(diskdb ethdb.KeyValueStore) *pathStore {
+	root := emptyRoot
+	if blob := rawdb.ReadAccountTrieNode(diskdb, nil); len(blob) > 0 {
+		root = crypto.Keccak256Hash(blob)
+	}
+	return &pathStore{
+		diskdb:   diskdb,
+		diskRoot: root,
+		layers:   make(map[common.Hash]*pathLayer),
+		pending:  make(pathNodeSet),
+		wiped:    make(map[common.Hash]struct{}),
+	}
+}
+
+// insert adds a freshly committed trie node to the pending set.
+func (s *pathStore) insert(owner common.Hash, path []byte, hash common.Hash, blob []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pending.add(owner, path, &pathNode{hash: hash, blob: blob})
+}
+
+// delete marks the trie node at the given path as removed in the pending set.
+func (s *pathStore) delete(owner common.Hash, path []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pending.add(owner, path, &pathNode{})
+}
+
+// destruct marks the whole storage trie of the given owner as deleted in the
+// pending set, dropping any of its nodes committed before.
+func (s *pathStore) destruct(owner common.Hash) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.pending, owner)
+	s.wiped[owner] = struct{}{}
+}
+
+// node retrieves the trie node with the given owner, path and hash within the
+// state with the given root. The layers are walked from the requested state down
+// to the disk, the first one changing the path being authoritative. The nodes
+// are still checked against the requested hash, so unknown states are served
+// whatever matches on disk. The boolean flag reports whether the node was served
+// from memory.
+func (s *pathStore) node(state common.Hash, owner common.Hash, path []byte, hash common.Hash) ([]byte, bool) {
+	s.lock.RLock()
+	// Nodes of a state being committed are not sealed in a layer yet
+	if n := s.pending[owner][string(path)]; n != nil && n.hash == hash {
+		s.lock.RUnlock()
+		return n.blob, true
+	}
+	for layer := s.layers[state]; layer != nil; layer = s.layers[layer.parent] {
+		if n := layer.nodes[owner][string(path)]; n != nil {
+			s.lock.RUnlock()
+			if n.hash != hash {
+				return nil, false
+			}
+			return n.blob, true
+		}
+		if _, ok := layer.destructs[owner]; ok {
+			s.lock.RUnlock()
+			return nil, false
+		}
+	}
+	s.lock.RUnlock()
+
+	var blob []byte
+	if owner == (common.Hash{}) {
+		blob = rawdb.ReadAccountTrieNode(s.diskdb, path)
+	} else {
+		blob = rawdb.ReadStorageTrieNode(s.diskdb, owner, path)
+	}
+	if len(blob) == 0 || crypto.Keccak256Hash(blob) != hash {
+		return nil, false
+	}
+	return blob, false
+}
+
+// update seals all pending nodes into a new diff layer representing the state
+// transition from parent to root.
+func (s *pathStore) update(root, parent common.Hash) error {
+	if parent == (common.Hash{}) {
+		parent = emptyRoot
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nodes, wiped := s.pending, s.wiped
+	s.pending, s.wiped = make(pathNodeSet), make(map[common.Hash]struct{})
+
+	// Empty transitions (e.g. clique blocks without transactions) and already
+	// known states don't need a new layer
+	if root == parent || root == s.diskRoot {
+		return nil
+	}
+	if _, ok := s.layers[root]; ok {
+		return nil
+	}
+	if _, ok := s.layers[parent]; !ok && parent != s.diskRoot {
+		return fmt.Errorf("parent state [%#x] missing", parent)
+	}
+	s.layers[root] = &pathLayer{
+		root:      root,
+		parent:    parent,
+		nodes:     nodes,
+		destructs: wiped,
+		size:      nodes.size() + common.StorageSize(len(wiped)*common.HashLength),
+	}
+	s.reportMetrics()
+	return nil
+}
+
+// cap flattens all the diff layers below the given root which exceed the number
+// of layers to retain into the database, discarding any layer which doesn't
+// descend from the new disk state anymore.
+func (s *pathStore) cap(root common.Hash, layers int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Collect the chain of layers from the requested root down to the disk
+	var chain []*pathLayer
+	for hash := root; hash != s.diskRoot; {
+		layer, ok := s.layers[hash]
+		if !ok {
+			return fmt.Errorf("state [%#x] not available", root)
+		}
+		chain = append(chain, layer)
+		hash = layer.parent
+	}
+	if len(chain) <= layers {
+		return nil
+	}
+	// Flatten the oldest layers into the database, bottom up
+	var (
+		start   = time.Now()
+		batch   = s.diskdb.NewBatch()
+		nodes   int
+		deletes int
+	)
+	for i := len(chain) - 1; i >= layers; i-- {
+		// Wipe the destructed storage tries first. The deletions are based on
+		// the disk content, so flush the nodes of the previous layers before
+		if len(chain[i].destructs) > 0 {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+			for owner := range chain[i].destructs {
+				deletes += rawdb.DeleteStorageTrieNodes(s.diskdb, batch, owner)
+			}
+		}
+		for owner, subset := range chain[i].nodes {
+			for path, n := range subset {
+				if n.blob == nil {
+					if owner == (common.Hash{}) {
+						rawdb.DeleteAccountTrieNode(batch, []byte(path))
+					} else {
+						rawdb.DeleteStorageTrieNode(batch, owner, []byte(path))
+					}
+					deletes++
+				} else {
+					if owner == (common.Hash{}) {
+						rawdb.WriteAccountTrieNode(batch, []byte(path), n.blob)
+					} else {
+						rawdb.WriteStorageTrieNode(batch, owner, []byte(path), n.blob)
+					}
+					nodes++
+				}
+				if batch.ValueSize() > ethdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						return err
+					}
+					batch.Reset()
+				}
+			}
+		}
+		delete(s.layers, chain[i].root)
+		s.diskRoot = chain[i].root
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// Drop all the layers which are not built on top of the new disk state
+	retained := map[common.Hash]bool{s.diskRoot: true}
+	var descends func(hash common.Hash) bool
+	descends = func(hash common.Hash) bool {
+		if keep, ok := retained[hash]; ok {
+			return keep
+		}
+		layer, ok := s.layers[hash]
+		keep := ok && descends(layer.parent)
+		retained[hash] = keep
+		return keep
+	}
+	for hash := range s.layers {
+		if !descends(hash) {
+			delete(s.layers, hash)
+		}
+	}
+	pathFlattenTimeTimer.UpdateSince(start)
+	pathFlattenNodesMeter.Mark(int64(nodes))
+	pathFlattenDeletesMeter.Mark(int64(deletes))
+	s.reportMetrics()
+
+	log.Debug("Flattened trie node layers", "root", s.diskRoot, "nodes", nodes, "deletes", deletes, "layers", len(s.layers), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// size returns the memory used by the in-memory diff layers and pending nodes.
+func (s *pathStore) size() common.StorageSize {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	size := s.pending.size()
+	for _, layer := range s.layers {
+		size += layer.size
+	}
+	return size
+}
+
+// reportMetrics updates the layer gauges. The caller must hold the lock.
+func (s *pathStore) reportMetrics() {
+	var size common.StorageSize
+	for _, layer := range s.layers {
+		size += layer.size
+	}
+	pathLayersGauge.Update(int64(len(s.layers)))
+	pathLayersSizeGauge.Update(int64(size))
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// commitPathState applies the given updates on top of the state at parent and
+// seals the result into a new layer of the path-based database.
+func commitPathState(t *testing.T, db *Database, parent common.Hash, updates map[string]string) common.Hash {
+	tr, err := New(common.Hash{}, parent, db)
+	if err != nil {
+		t.Fatalf("Failed to open trie %x: %v", parent, err)
+	}
+	for key, val := range updates {
+		if val == "" {
+			tr.Delete([]byte(key))
+		} else {
+			tr.Update([]byte(key), []byte(val))
+		}
+	}
+	root, _, err := tr.Commit(nil)
+	if err != nil {
+		t.Fatalf("Failed to commit trie: %v", err)
+	}
+	if err := db.Update(root, parent); err != nil {
+		t.Fatalf("Failed to update layers: %v", err)
+	}
+	return root
+}
+
+// checkPathState verifies that the trie at root contains exactly the given data.
+func checkPathState(db *Database, root common.Hash, want map[string]string) error {
+	tr, err := New(common.Hash{}, root, db)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]string)
+	it := NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		have[string(it.Key)] = string(it.Value)
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	if len(have) != len(want) {
+		return fmt.Errorf("item count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for key, val := range want {
+		if have[key] != val {
+			return fmt.Errorf("value mismatch for %q: have %q, want %q", key, have[key], val)
+		}
+	}
+	return nil
+}
+
+// countPathNodes returns the number of account trie nodes persisted on disk.
+func countPathNodes(db ethdb.Iteratee) int {
+	it := db.NewIterator(rawdb.TrieNodeAccountPrefix, nil)
+	defer it.Release()
+
+	var count int
+	for it.Next() {
+		if bytes.HasPrefix(it.Key(), rawdb.TrieNodeAccountPrefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// Tests that the path-based scheme serves the states of all in-memory layers,
+// flattens the old ones into the disk and overwrites stale nodes in place.
+func TestPathSchemeLayers(t *testing.T) {
+	diskdb := rawdb.NewMemoryDatabase()
+	db := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+
+	var (
+		states = []map[string]string{{}}
+		roots  = []common.Hash{emptyRoot}
+	)
+	for i := 0; i < 10; i++ {
+		updates := make(map[string]string)
+		for j := 0; j < 20; j++ {
+			updates[fmt.Sprintf("key-%d", (i*7+j)%50)] = fmt.Sprintf("val-%d-%d", i, j)
+		}
+		if i%3 == 2 {
+			for j := 0; j < 5; j++ {
+				updates[fmt.Sprintf("key-%d", (i*11+j)%50)] = "" // delete
+			}
+		}
+		state := make(map[string]string)
+		for key, val := range states[len(states)-1] {
+			state[key] = val
+		}
+		for key, val := range updates {
+			if val == "" {
+				delete(state, key)
+			} else {
+				state[key] = val
+			}
+		}
+		roots = append(roots, commitPathState(t, db, roots[len(roots)-1], updates))
+		states = append(states, state)
+	}
+	// All states should be available from the in-memory layers
+	for i, root := range roots[1:] {
+		if err := checkPathState(db, root, states[i+1]); err != nil {
+			t.Fatalf("state %d: %v", i+1, err)
+		}
+	}
+	if countPathNodes(diskdb) != 0 {
+		t.Fatalf("unexpected nodes persisted before flattening")
+	}
+	// Flatten all but the last 3 layers into disk
+	if err := db.CapLayers(roots[len(roots)-1], 3); err != nil {
+		t.Fatalf("Failed to cap layers: %v", err)
+	}
+	for i, root := range roots {
+		err := checkPathState(db, root, states[i])
+		if i < len(roots)-4 && i > 0 {
+			if err == nil {
+				t.Fatalf("state %d: pruned state still available", i)
+			}
+		} else if err != nil {
+			t.Fatalf("state %d: %v", i, err)
+		}
+	}
+	// Commit the head and ensure a fresh database picks up the persisted state
+	if err := db.Commit(roots[len(roots)-1], false, nil); err != nil {
+		t.Fatalf("Failed to commit layers: %v", err)
+	}
+	reopen := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+	if err := checkPathState(reopen, roots[len(roots)-1], states[len(states)-1]); err != nil {
+		t.Fatalf("persisted state: %v", err)
+	}
+	// Ensure the disk only contains the nodes of a single state
+	tr, _ := New(common.Hash{}, roots[len(roots)-1], reopen)
+	var live int
+	for it := tr.NodeIterator(nil); it.Next(true); {
+		if it.Hash() != (common.Hash{}) {
+			live++
+		}
+	}
+	if have := countPathNodes(diskdb); have != live {
+		t.Fatalf("stale nodes left on disk: have %d, want %d", have, live)
+	}
+}
+
+// Tests that layers not descending from the flattened state are discarded.
+func TestPathSchemeSideChain(t *testing.T) {
+	db := NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &Config{Scheme: rawdb.PathScheme})
+
+	base := commitPathState(t, db, emptyRoot, map[string]string{"a": "1", "b": "2"})
+	side := commitPathState(t, db, base, map[string]string{"a": "side"})
+	head := commitPathState(t, db, base, map[string]string{"a": "head"})
+	head = commitPathState(t, db, head, map[string]string{"c": "3"})
+
+	if err := checkPathState(db, side, map[string]string{"a": "side", "b": "2"}); err != nil {
+		t.Fatalf("side state: %v", err)
+	}
+	// Nodes are only resolved through the layers the requested state is built on
+	if blob, _ := db.paths.node(side, common.Hash{}, nil, side); blob == nil {
+		t.Fatalf("side root node not resolved within the side state")
+	}
+	if blob, _ := db.paths.node(head, common.Hash{}, nil, side); blob != nil {
+		t.Fatalf("side root node resolved within the head state")
+	}
+	if err := db.CapLayers(head, 1); err != nil {
+		t.Fatalf("Failed to cap layers: %v", err)
+	}
+	if err := checkPathState(db, side, map[string]string{"a": "side", "b": "2"}); err == nil {
+		t.Fatalf("side state still available after flattening")
+	}
+	if err := checkPathState(db, head, map[string]string{"a": "head", "b": "2", "c": "3"}); err != nil {
+		t.Fatalf("head state: %v", err)
+	}
+	if err := db.Update(common.HexToHash("0x01"), side); err == nil {
+		t.Fatalf("layer creation on top of discarded state succeeded")
+	}
+}
+
+// Tests that the storage trie of a destructed account is not resolved anymore
+// within the later states, and that it is wiped from disk once flattened.
+func TestPathSchemeStorageDestruct(t *testing.T) {
+	diskdb := rawdb.NewMemoryDatabase()
+	db := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+
+	// Commit a storage trie along with the account trie referencing it
+	owner := common.HexToHash("0xaa")
+	storage, _ := NewStorage(emptyRoot, owner, emptyRoot, db)
+	for i := 0; i < 20; i++ {
+		storage.Update([]byte(fmt.Sprintf("slot-%d", i)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	storageRoot, _, err := storage.Commit(nil)
+	if err != nil {
+		t.Fatalf("Failed to commit storage trie: %v", err)
+	}
+	base := commitPathState(t, db, emptyRoot, map[string]string{"account": string(storageRoot[:]), "other": "1"})
+
+	// Destruct the account in the next state
+	db.DeleteStorage(owner)
+	head := commitPathState(t, db, base, map[string]string{"account": ""})
+
+	if _, err := NewStorage(base, owner, storageRoot, db); err != nil {
+		t.Fatalf("Failed to open storage trie within the original state: %v", err)
+	}
+	if _, err := NewStorage(head, owner, storageRoot, db); err == nil {
+		t.Fatalf("destructed storage trie opened within the later state")
+	}
+	// Flatten both states in one go and ensure no storage node is left behind
+	if err := db.CapLayers(head, 0); err != nil {
+		t.Fatalf("Failed to cap layers: %v", err)
+	}
+	it := diskdb.NewIterator(append(rawdb.TrieNodeStoragePrefix, owner[:]...), nil)
+	defer it.Release()
+	for it.Next() {
+		t.Fatalf("stale storage trie node left on disk: %x", it.Key())
+	}
+}
+
+// Tests that the path-based scheme refuses node lookups by hash, even for nodes
+// held in the clean cache, while serving them by path through a trie.
+func TestPathSchemeNodeRetrieval(t *testing.T) {
+	db := NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &Config{Cache: 16, Scheme: rawdb.PathScheme})
+
+	base := commitPathState(t, db, emptyRoot, map[string]string{"a": "1", "b": "2"})
+	head := commitPathState(t, db, base, map[string]string{"c": "3"})
+	if err := db.CapLayers(head, 1); err != nil {
+		t.Fatalf("Failed to cap layers: %v", err)
+	}
+	for _, root := range []common.Hash{base, head} {
+		// Retrieving the root by path pulls flattened nodes into the clean cache
+		tr, err := New(common.Hash{}, root, db)
+		if err != nil {
+			t.Fatalf("Failed to open trie %x: %v", root, err)
+		}
+		blob, _, err := tr.TryGetNode(hexToCompact(nil))
+		if err != nil {
+			t.Fatalf("Failed to retrieve root node of %x by path: %v", root, err)
+		}
+		if hash := crypto.Keccak256Hash(blob); hash != root {
+			t.Fatalf("root node hash mismatch: have %x, want %x", hash, root)
+		}
+		if _, err := db.Node(root); err != ErrHashLookupUnsupported {
+			t.Fatalf("root node of %x lookup by hash error mismatch: have %v, want %v", root, err, ErrHashLookupUnsupported)
+		}
+	}
+}
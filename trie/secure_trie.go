@@ -64,6 +64,19 @@ func NewSecure(owner common.Hash, root common.Hash, db *Database) (*SecureTrie,
 	return &SecureTrie{trie: *trie}, nil
 }
 
+// NewSecureStorage creates a secure storage trie with an existing root node from
+// a backing database, which belongs to the state with the given root.
+func NewSecureStorage(stateRoot common.Hash, owner common.Hash, root common.Hash, db *Database) (*SecureTrie, error) {
+	if db == nil {
+		panic("trie.NewSecureStorage called without a database")
+	}
+	trie, err := NewStorage(stateRoot, owner, root, db)
+	if err != nil {
+		return nil, err
+	}
+	return &SecureTrie{trie: *trie}, nil
+}
+
 // Get returns the value for key stored in the trie.
 // The value bytes must not be modified by the caller.
 func (t *SecureTrie) Get(key []byte) []byte {
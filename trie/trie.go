@@ -64,6 +64,7 @@ type Trie struct {
 	db    *Database
 	root  node
 	owner common.Hash
+	state common.Hash // Root of the state the trie belongs to, used to resolve path-based nodes
 
 	// Keep track of the number leaves which have been inserted since the last
 	// hashing operation. This number will not directly map to the number of
@@ -86,6 +87,7 @@ func (t *Trie) Copy() *Trie {
 		db:       t.db,
 		root:     t.root,
 		owner:    t.owner,
+		state:    t.state,
 		unhashed: t.unhashed,
 		tracer:   t.tracer.copy(),
 	}
@@ -98,13 +100,26 @@ func (t *Trie) Copy() *Trie {
 // trie is initially empty and does not require a database. Otherwise,
 // New will panic if db is nil and returns a MissingNodeError if root does
 // not exist in the database. Accessing the trie loads nodes from db on demand.
+//
+// Storage tries on top of a path-based database should be opened with NewStorage
+// instead, since their nodes can only be resolved against the state they belong
+// to. Without one, only the nodes persisted on disk are available.
 func New(owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
-	return newTrie(owner, root, db)
+	if owner == (common.Hash{}) {
+		return newTrie(root, owner, root, db)
+	}
+	return newTrie(common.Hash{}, owner, root, db)
+}
+
+// NewStorage creates a storage trie with an existing root node from db, which
+// belongs to the state with the given root.
+func NewStorage(stateRoot common.Hash, owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
+	return newTrie(stateRoot, owner, root, db)
 }
 
 // NewEmpty is a shortcut to create empty tree. It's mostly used in tests.
 func NewEmpty(db *Database) *Trie {
-	tr, _ := newTrie(common.Hash{}, common.Hash{}, db)
+	tr, _ := newTrie(common.Hash{}, common.Hash{}, common.Hash{}, db)
 	return tr
 }
 
@@ -119,15 +134,20 @@ func newWithRootNode(root node) *Trie {
 }
 
 // newTrie is the internal function used to construct the trie with given parameters.
-func newTrie(owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
+func newTrie(state common.Hash, owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
 	if db == nil {
 		panic("trie.New called without a database")
 	}
 	trie := &Trie{
 		db:    db,
 		owner: owner,
+		state: state,
 		//tracer: newTracer(),
 	}
+	// The path-based scheme needs the deleted nodes to remove them from disk
+	if db.paths != nil {
+		trie.tracer = newTracer()
+	}
 	if root != (common.Hash{}) && root != emptyRoot {
 		rootnode, err := trie.resolveHash(root[:], nil)
 		if err != nil {
@@ -236,7 +256,7 @@ func (t *Trie) tryGetNode(origNode node, path []byte, pos int) (item []byte, new
 		if hash == nil {
 			return nil, origNode, 0, errors.New("non-consensus node")
 		}
-		blob, err := t.db.Node(common.BytesToHash(hash))
+		blob, err := t.db.nodeBlob(t.state, t.owner, path[:pos], common.BytesToHash(hash))
 		return blob, origNode, 1, err
 	}
 	// Path still needs to be traversed, descend into children
@@ -574,7 +594,11 @@ func (t *Trie) resolve(n node, prefix []byte) (node, error) {
 
 func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
-	if node := t.db.node(hash); node != nil {
+	node, source, size := t.db.node(t.state, t.owner, prefix, hash)
+	if t.db.tracker != nil {
+		t.db.tracker.record(t.owner, len(prefix), source, size)
+	}
+	if node != nil {
 		return node, nil
 	}
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
@@ -582,7 +606,7 @@ func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 
 func (t *Trie) resolveBlob(n hashNode, prefix []byte) ([]byte, error) {
 	hash := common.BytesToHash(n)
-	blob, _ := t.db.Node(hash)
+	blob, _ := t.db.nodeBlob(t.state, t.owner, prefix, hash)
 	if len(blob) != 0 {
 		return blob, nil
 	}
@@ -605,12 +629,19 @@ func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 	}
 	defer t.tracer.reset()
 
+	// Mark all the deleted nodes first, the committed ones might resurrect
+	// some of the paths afterwards.
+	for _, path := range t.tracer.deleteList() {
+		t.db.deleteNode(t.owner, path)
+	}
 	if t.root == nil {
+		t.advanceState(emptyRoot)
 		return emptyRoot, 0, nil
 	}
 	// Derive the hash for all dirty nodes first. We hold the assumption
 	// in the following procedure that all nodes are hashed.
 	rootHash := t.Hash()
+	t.advanceState(rootHash)
 	h := newCommitter()
 	defer returnCommitterToPool(h)
 
@@ -624,6 +655,7 @@ func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 		return rootHash, 0, nil
 	}
 	var wg sync.WaitGroup
+	h.owner = t.owner
 	if onleaf != nil {
 		h.onleaf = onleaf
 		h.leafCh = make(chan *leaf, leafChanSize)
@@ -649,6 +681,15 @@ func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 	return rootHash, committed, nil
 }
 
+// advanceState moves an account trie over to the state it is committed as. The
+// storage tries don't know the state root they end up in, so they need to be
+// reopened after a commit to resolve nodes of the new state.
+func (t *Trie) advanceState(root common.Hash) {
+	if t.owner == (common.Hash{}) {
+		t.state = root
+	}
+}
+
 // hashRoot calculates the root hash of the given trie
 func (t *Trie) hashRoot() (node, node, error) {
 	if t.root == nil {
@@ -666,6 +707,7 @@ func (t *Trie) hashRoot() (node, node, error) {
 func (t *Trie) Reset() {
 	t.root = nil
 	t.owner = common.Hash{}
+	t.state = common.Hash{}
 	t.unhashed = 0
 	t.tracer.reset()
 }